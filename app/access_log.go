@@ -0,0 +1,133 @@
+package app
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// These are the keys of the fiber locals which are set by the access log middleware and the error handler.
+const (
+	RequestIDKey  = "request_id"
+	errorKey      = "error"
+	errorGroupKey = "error_group"
+)
+
+// AccessLog returns a pointer to the accessLogUtil instance (accessLog).
+// If accessLog is not initialized, it creates a new accessLogUtil instance, configures it, and assigns it to accessLog.
+// It ensures that only one instance of accessLogUtil is created and reused.
+func AccessLog() *accessLogUtil {
+	if accessLog == nil {
+		accessLog = &accessLogUtil{}
+		accessLog.configure()
+	}
+	return accessLog
+}
+
+// accessLog is a pointer to an accessLogUtil instance.
+// It is used to store and access the singleton instance of accessLogUtil.
+var accessLog *accessLogUtil
+
+// accessLogUtil represents the access log, one log line of every request.
+type accessLogUtil struct {
+	IsEnabled     bool
+	SlowThreshold time.Duration
+}
+
+// configure configures the access log instance based on the ACCESS_LOG_XXX environment variables.
+func (a *accessLogUtil) configure() {
+	a.IsEnabled = ACCESS_LOG_ENABLED
+	a.SlowThreshold = ACCESS_LOG_SLOW_THRESHOLD
+}
+
+// New is the middleware which logs the request and records its metrics after the response is written.
+// It must be the outermost middleware so the panics recovered by Error().Recover and the errors of the other middlewares are logged too,
+// the error returned by the chain is written by the error handler here so its status code is logged.
+// The request id is taken from the X-Request-ID header or generated, it is sent back on the response and available as Ctx.RequestID.
+// The queries of the request are counted on the user context, see WithQueryStats.
+func (a *accessLogUtil) New(c *fiber.Ctx) error {
+	requestID := c.Get(fiber.HeaderXRequestID)
+	if requestID == "" || len(requestID) > 128 {
+		requestID = NewNullUUID().String
+	}
+	c.Locals(RequestIDKey, requestID)
+	c.Set(fiber.HeaderXRequestID, requestID)
+	c.SetUserContext(WithQueryStats(c.UserContext(), requestID))
+
+	start := time.Now()
+	err := c.Next()
+	if err != nil {
+		err = c.App().ErrorHandler(c, err)
+		if err != nil {
+			c.Status(fiber.StatusInternalServerError)
+		}
+	}
+	latency := time.Since(start)
+	Metrics().ObserveRequest(a.route(c), c.Method(), c.Response().StatusCode(), latency)
+	Alert().ObserveStatus(c.Response().StatusCode())
+	if a.IsEnabled {
+		a.log(c, requestID, latency)
+	}
+	return nil
+}
+
+// route returns the route pattern of the request, it is empty if no route is matched.
+func (*accessLogUtil) route(c *fiber.Ctx) string {
+	route := c.Route().Path
+	if route == "/" && c.Response().StatusCode() == fiber.StatusNotFound {
+		return "" // the request ends on the catch-all middleware, see Server().NotFoundHandler
+	}
+	return route
+}
+
+// size returns the size of the response body, the stream body (for example c.SendStream) is not read so its size is the Content-Length.
+// The size of the stream body without the Content-Length is -1.
+func (*accessLogUtil) size(c *fiber.Ctx) int {
+	if c.Response().IsBodyStream() {
+		return c.Response().Header.ContentLength()
+	}
+	return len(c.Response().Body())
+}
+
+// log writes the access log line of the request, the server errors are logged as error and the slow requests as warning.
+// The route pattern is logged instead of the raw path, so the ids on the path are not logged and the lines can be grouped by the route.
+func (a *accessLogUtil) log(c *fiber.Ctx, requestID string, latency time.Duration) {
+	status := c.Response().StatusCode()
+	isSlow := a.SlowThreshold > 0 && latency >= a.SlowThreshold
+
+	var ev *zerolog.Event
+	switch {
+	case status >= fiber.StatusInternalServerError:
+		ev = Logger().Module("http").Error()
+	case isSlow:
+		ev = Logger().Module("http").Warn()
+	default:
+		ev = Logger().Module("http").Info()
+	}
+
+	ev = ev.
+		Str("request_id", requestID).
+		Str("method", c.Method()).
+		Str("route", a.route(c)).
+		Int("status", status).
+		Dur("latency", latency).
+		Int("size", a.size(c)).
+		Str("ip", c.IP()).
+		Bool("is_slow", isSlow).
+		Int("queries", QueryStatsFromContext(c.UserContext()).Total())
+	if sc := trace.SpanContextFromContext(c.UserContext()); sc.IsValid() {
+		ev = ev.Str("trace_id", sc.TraceID().String())
+	}
+	if ctx, ok := c.Locals(CtxKey).(*Ctx); ok {
+		ev = ev.Str("user_id", ctx.UserID).Str("tenant_id", ctx.TenantID).Str("lang", ctx.Lang)
+	}
+	if err, ok := c.Locals(errorKey).(error); ok {
+		ev = ev.Err(err)
+		if trace := Error().TraceSimple(err); trace != nil && status >= fiber.StatusInternalServerError {
+			ev = ev.Interface("trace", trace)
+		}
+	}
+	ev.Msg("Request is handled.")
+}
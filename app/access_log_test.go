@@ -0,0 +1,91 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+)
+
+func TestAccessLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	prev := logger
+	logger = &loggerUtil{Logger: zerolog.New(buf)}
+	defer func() { logger = prev }()
+
+	a := &accessLogUtil{IsEnabled: true, SlowThreshold: 20 * time.Millisecond}
+	f := fiber.New(fiber.Config{ErrorHandler: Error().Handler})
+	f.Use(a.New)
+	f.Use(Error().Recover)
+	f.Get("/items/:id", func(c *fiber.Ctx) error {
+		c.Locals(CtxKey, &Ctx{Lang: "en", UserID: "u1"})
+		if c.Params("id") == "slow" {
+			time.Sleep(30 * time.Millisecond)
+		}
+		if c.Params("id") == "panic" {
+			panic("boom")
+		}
+		if c.Params("id") == "stream" {
+			return c.SendStream(strings.NewReader("streamed"), 8)
+		}
+		return c.SendString("ok")
+	})
+
+	tests := []struct {
+		path     string
+		status   int
+		level    string
+		isSlow   bool
+		hasError bool
+		size     int
+	}{
+		{"/items/1", 200, "info", false, false, 2},
+		{"/items/slow", 200, "warn", true, false, 2},
+		{"/items/stream", 200, "info", false, false, 8},
+		{"/items/panic", 500, "error", false, true, -1},
+	}
+	for _, test := range tests {
+		buf.Reset()
+		req := httptest.NewRequest("GET", test.path, nil)
+		req.Header.Set(fiber.HeaderXRequestID, "req-1")
+		res, err := f.Test(req)
+		if err != nil {
+			t.Fatalf("Error occurred [%v]", err)
+		}
+		if res.StatusCode != test.status || res.Header.Get(fiber.HeaderXRequestID) != "req-1" {
+			t.Errorf("Expected status [%v] with the request id, got [%v] [%v]", test.status, res.StatusCode, res.Header.Get(fiber.HeaderXRequestID))
+		}
+
+		// the panic line is followed by the access log line
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		line := map[string]any{}
+		json.Unmarshal([]byte(lines[len(lines)-1]), &line)
+		if line["route"] != "/items/:id" || line["status"] != float64(test.status) || line["level"] != test.level ||
+			line["is_slow"] != test.isSlow || line["user_id"] != "u1" || line["request_id"] != "req-1" || (line["error"] != nil) != test.hasError {
+			t.Errorf("Unexpected access log of [%v], got [%v]", test.path, lines[len(lines)-1])
+		}
+		if test.size >= 0 && line["size"] != float64(test.size) {
+			t.Errorf("Expected the size [%v] of [%v], got [%v]", test.size, test.path, line["size"])
+		}
+		if test.hasError && !strings.Contains(lines[0], `"panic":"boom"`) {
+			t.Errorf("Expected the panic to be logged, got [%v]", lines[0])
+		}
+		if body, _ := io.ReadAll(res.Body); test.hasError && strings.Contains(string(body), "boom") {
+			t.Errorf("Expected the panic to be not sent to the client, got [%v]", string(body))
+		}
+	}
+
+	buf.Reset()
+	res, _ := f.Test(httptest.NewRequest("GET", "/unknown", nil))
+	line := map[string]any{}
+	json.Unmarshal(buf.Bytes(), &line)
+	if res.Header.Get(fiber.HeaderXRequestID) == "" || line["route"] != "" || line["status"] != float64(404) {
+		t.Errorf("Expected the generated request id and no route, got [%v]", buf.String())
+	}
+}
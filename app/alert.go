@@ -0,0 +1,507 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Alert returns a pointer to the alertUtil instance (alert).
+// If alert is not initialized, it creates a new alertUtil instance, configures it, and assigns it to alert.
+// It ensures that only one instance of alertUtil is created and reused.
+func Alert() *alertUtil {
+	if alert == nil {
+		alert = &alertUtil{}
+		alert.configure()
+	}
+	return alert
+}
+
+// alert is a pointer to an alertUtil instance.
+// It is used to store and access the singleton instance of alertUtil.
+var alert *alertUtil
+
+// AlertSeverity is the severity of the alert, it is used by the routing rules, see ALERT_ROUTES.
+type AlertSeverity int
+
+// These are the severities of the alert, from the lowest.
+const (
+	AlertInfo AlertSeverity = iota
+	AlertWarning
+	AlertCritical
+)
+
+// String returns the name of the severity.
+func (s AlertSeverity) String() string {
+	switch s {
+	case AlertCritical:
+		return "critical"
+	case AlertWarning:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// MarshalJSON encodes the severity as its name.
+func (s AlertSeverity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// ParseAlertSeverity returns the severity of the name.
+func ParseAlertSeverity(name string) (AlertSeverity, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "info":
+		return AlertInfo, true
+	case "warning", "warn":
+		return AlertWarning, true
+	case "critical":
+		return AlertCritical, true
+	}
+	return AlertInfo, false
+}
+
+// AlertEvent is the alert which is sent to the channels.
+// The alerts of the same key are deduplicated, and the key is used to send the recovery notification, see Resolve.
+type AlertEvent struct {
+	Key        string            `json:"key"`
+	Source     string            `json:"source"` // the module which raises the alert, for example http, db or outbox
+	Severity   AlertSeverity     `json:"severity"`
+	Title      string            `json:"title"`
+	Message    string            `json:"message"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	IsResolved bool              `json:"is_resolved"`
+	Suppressed int               `json:"suppressed"` // the number of the same alerts which are suppressed since the last sent one
+	Env        string            `json:"env"`
+	Time       time.Time         `json:"time"`
+}
+
+// Text returns the plain text of the alert, used by the chat and the email channels.
+func (e AlertEvent) Text() string {
+	status := strings.ToUpper(e.Severity.String())
+	if e.IsResolved {
+		status = "RESOLVED"
+	}
+	b := strings.Builder{}
+	b.WriteString("[" + status + "] " + e.Title + " (" + e.Env + ")\n")
+	if e.Message != "" {
+		b.WriteString(e.Message + "\n")
+	}
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString(k + ": " + e.Fields[k] + "\n")
+	}
+	if e.Suppressed > 0 {
+		b.WriteString("repeated " + strconv.Itoa(e.Suppressed) + " times since the last alert\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// AlertChannel is the interface of the channel which delivers the alert, see alert_channel.go.
+type AlertChannel interface {
+	Name() string
+	Send(e AlertEvent) error
+}
+
+// AlertRoute is the routing rule, the alert of the severity or higher (and of the source, if it is set) is sent to the channels.
+type AlertRoute struct {
+	Severity AlertSeverity
+	Source   string
+	Channels []string
+}
+
+// alertUtil dispatches the alerts to the channels based on the routing rules.
+// The alerts of the same key are sent once per DedupWindow, and every channel sends up to RateLimit alerts per RateWindow,
+// so a failure storm doesn't flood the channels. The alerts are sent in the background, use Flush to wait for them.
+// The raised alerts which are never resolved, like the panic and the fatal ones, are forgotten after ActiveTTL.
+type alertUtil struct {
+	Channels    map[string]AlertChannel
+	Routes      []AlertRoute
+	DedupWindow time.Duration
+	ActiveTTL   time.Duration // the raised alert which is not sent again within it is forgotten, 0 to keep it until it is resolved
+	RateLimit   int
+	RateWindow  time.Duration
+
+	ErrorBurstThreshold int           // the number of the server errors in the ErrorBurstWindow which raises the alert, 0 to disable it
+	ErrorBurstWindow    time.Duration //
+
+	active     map[string]*alertState // the raised and not resolved alerts by key
+	instanceID string                 // used to ignore the own broadcasted resolves, see SubscribeResolved
+	rates      map[string]*alertRate  // the sent alerts of the current rate window by channel
+	burst      alertBurst
+	mu         sync.Mutex
+	wg         sync.WaitGroup
+}
+
+// alertState is the state of the raised alert.
+type alertState struct {
+	event      AlertEvent
+	channels   []string
+	lastSentAt time.Time
+	suppressed int
+}
+
+// alertRate is the number of the sent alerts of the channel in the current rate window.
+type alertRate struct {
+	start     time.Time
+	sent      int
+	isDropped bool
+}
+
+// alertBurst counts the server errors of the current burst window.
+type alertBurst struct {
+	start    time.Time
+	errors   int
+	isRaised bool
+}
+
+// alertBurstKey is the key of the alert of the server errors burst.
+const alertBurstKey = "http_5xx_burst"
+
+// configure configures the channels and the routing rules based on the ALERT_XXX environment variables,
+// only the channels which are configured are registered.
+func (a *alertUtil) configure() {
+	a.Channels = map[string]AlertChannel{}
+	a.instanceID = NewNullUUID().String
+	a.DedupWindow = ALERT_DEDUP_WINDOW
+	a.ActiveTTL = ALERT_ACTIVE_TTL
+	a.RateLimit = ALERT_RATE_LIMIT
+	a.RateWindow = ALERT_RATE_WINDOW
+	a.ErrorBurstThreshold = ALERT_5XX_THRESHOLD
+	a.ErrorBurstWindow = ALERT_5XX_WINDOW
+	if TELEGRAM_ALERT_TOKEN != "" && TELEGRAM_ALERT_USER_ID != "" {
+		a.AddChannel(telegramAlertChannel{})
+	}
+	if ALERT_SLACK_WEBHOOK_URL != "" {
+		a.AddChannel(slackAlertChannel{url: ALERT_SLACK_WEBHOOK_URL})
+	}
+	if ALERT_EMAIL_SMTP_HOST != "" && ALERT_EMAIL_TO != "" {
+		a.AddChannel(newEmailAlertChannel())
+	}
+	if ALERT_WEBHOOK_URL != "" {
+		a.AddChannel(webhookAlertChannel{url: ALERT_WEBHOOK_URL, secret: ALERT_WEBHOOK_SECRET})
+	}
+
+	routes, err := ParseAlertRoutes(ALERT_ROUTES)
+	if err != nil {
+		Logger().Module("alert").Warn().Err(err).Str("ALERT_ROUTES", ALERT_ROUTES).Msg("Invalid alert routes, the default route will be used.")
+	}
+	a.Routes = routes
+}
+
+// AddChannel registers the channel, it replaces the channel of the same name.
+func (a *alertUtil) AddChannel(ch AlertChannel) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Channels[ch.Name()] = ch
+}
+
+// ParseAlertRoutes parses the semicolon separated routing rules, each rule is {severity}[@{source}]={comma separated channels},
+// for example "critical=telegram,email;warning@db=slack". The empty rules is the default route, every alert of warning or higher is sent to every channel.
+func ParseAlertRoutes(rules string) ([]AlertRoute, error) {
+	res := []AlertRoute{}
+	for _, rule := range strings.Split(rules, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		match, channels, ok := strings.Cut(rule, "=")
+		severity, source, _ := strings.Cut(match, "@")
+		r := AlertRoute{Source: strings.TrimSpace(source), Channels: splitAndTrim(channels)}
+		var isValid bool
+		r.Severity, isValid = ParseAlertSeverity(severity)
+		if !ok || !isValid || len(r.Channels) == 0 {
+			return []AlertRoute{}, errors.New("invalid alert route " + strconv.Quote(rule))
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// route returns the names of the channels of the alert.
+func (a *alertUtil) route(e AlertEvent) []string {
+	if len(a.Routes) == 0 {
+		if e.Severity < AlertWarning {
+			return nil
+		}
+		names := []string{}
+		for name := range a.Channels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	names := []string{}
+	for _, r := range a.Routes {
+		if e.Severity < r.Severity || (r.Source != "" && r.Source != "*" && r.Source != e.Source) {
+			continue
+		}
+		for _, name := range r.Channels {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Raise sends the alert to the channels of its routes in the background.
+// The alert of the same key is suppressed until the dedup window passes since the last sent one, then it is sent with the number of the suppressed alerts.
+func (a *alertUtil) Raise(e AlertEvent) {
+	channels, e, ok := a.prepare(e)
+	if !ok {
+		return
+	}
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.send(channels, e)
+	}()
+}
+
+// RaiseSync is like Raise but it waits until the alert is sent, for example before the process exits on the fatal error.
+func (a *alertUtil) RaiseSync(e AlertEvent) {
+	channels, e, ok := a.prepare(e)
+	if ok {
+		a.send(channels, e)
+	}
+}
+
+// Resolve sends the recovery notification of the raised alert of the key to the channels which received the alert.
+// It does nothing if the alert of the key is not raised or it is already resolved.
+func (a *alertUtil) Resolve(key, message string) {
+	a.mu.Lock()
+	state, ok := a.active[key]
+	if ok {
+		delete(a.active, key)
+	}
+	a.mu.Unlock()
+	if !ok || len(state.channels) == 0 {
+		return
+	}
+	e := state.event
+	e.IsResolved = true
+	e.Message = message
+	e.Suppressed = state.suppressed
+	e.Time = time.Now()
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.send(state.channels, e)
+	}()
+}
+
+// BroadcastResolve resolves the alert of the key on all instances, for the alert which can be raised by any instance,
+// for example the error group one, it is broadcasted on ALERT_RESOLVE_CHANNEL.
+// The instance which raised the alert sends the recovery notification, the others only forget it.
+func (a *alertUtil) BroadcastResolve(key, message string) {
+	a.Resolve(key, message)
+	_, err := Cache().Publish(ALERT_RESOLVE_CHANNEL, alertResolve{Origin: a.instanceID, Key: key, Message: message})
+	if err != nil {
+		Logger().Module("alert").Warn().Err(err).Str("key", key).Msg("Failed to broadcast the resolved alert, only the current instance resolves it.")
+	}
+}
+
+// SubscribeResolved resolves the alerts which are resolved by the other instances, see BroadcastResolve.
+func (a *alertUtil) SubscribeResolved() {
+	Cache().Subscribe(ALERT_RESOLVE_CHANNEL, func(payload []byte) {
+		r := alertResolve{}
+		err := json.Unmarshal(payload, &r)
+		if err != nil {
+			Logger().Module("alert").Warn().Err(err).Str("payload", string(payload)).Msg("Invalid resolved alert, it is ignored.")
+			return
+		}
+		if r.Origin != a.instanceID {
+			a.Resolve(r.Key, r.Message)
+		}
+	})
+}
+
+// alertResolve is the resolved alert broadcasted on ALERT_RESOLVE_CHANNEL.
+type alertResolve struct {
+	Origin  string `json:"origin"`
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// Flush waits until the alerts which are being sent are done, or the ctx is done.
+func (a *alertUtil) Flush(ctx context.Context) error {
+	return waitContext(ctx, &a.wg)
+}
+
+// prepare deduplicates the alert and returns the channels to send it, it returns false if the alert is suppressed or has no channel.
+func (a *alertUtil) prepare(e AlertEvent) ([]string, AlertEvent, bool) {
+	if e.Key == "" {
+		e.Key = e.Source + ":" + e.Title
+	}
+	e.Env = APP_ENV
+	e.Time = time.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.active == nil {
+		a.active = map[string]*alertState{}
+	}
+	a.evict(e.Time)
+	state, ok := a.active[e.Key]
+	if ok && e.Time.Sub(state.lastSentAt) < a.DedupWindow {
+		state.suppressed++
+		return nil, e, false
+	}
+	if !ok {
+		state = &alertState{}
+		a.active[e.Key] = state
+	}
+	e.Suppressed = state.suppressed
+	state.event = e
+	state.lastSentAt = e.Time
+	state.suppressed = 0
+	state.channels = a.route(e)
+	return state.channels, e, len(state.channels) > 0
+}
+
+// evict forgets the raised alerts which are not sent again within the ActiveTTL, so the keys which are never resolved don't pile up.
+// The caller must hold the lock.
+func (a *alertUtil) evict(now time.Time) {
+	if a.ActiveTTL <= 0 {
+		return
+	}
+	for key, state := range a.active {
+		if now.Sub(state.lastSentAt) >= a.ActiveTTL {
+			delete(a.active, key)
+		}
+	}
+}
+
+// send sends the alert to the channels, the channel which exceeds the rate limit drops the alert.
+func (a *alertUtil) send(channels []string, e AlertEvent) {
+	for _, name := range channels {
+		a.mu.Lock()
+		ch, ok := a.Channels[name]
+		a.mu.Unlock()
+		if !ok {
+			Logger().Module("alert").Warn().Str("channel", name).Str("key", e.Key).Msg("The alert channel is not configured, the alert is not sent.")
+			continue
+		}
+		if !a.allow(name) {
+			continue
+		}
+		err := ch.Send(e)
+		if err != nil {
+			Logger().Module("alert").Error().Err(err).Str("channel", name).Str("key", e.Key).Msg("Failed to send the alert.")
+		}
+	}
+}
+
+// allow reports whether the channel can send the alert in the current rate window.
+func (a *alertUtil) allow(channel string) bool {
+	if a.RateLimit <= 0 {
+		return true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.rates == nil {
+		a.rates = map[string]*alertRate{}
+	}
+	now := time.Now()
+	r, ok := a.rates[channel]
+	if !ok || now.Sub(r.start) >= a.RateWindow {
+		r = &alertRate{start: now}
+		a.rates[channel] = r
+	}
+	if r.sent >= a.RateLimit {
+		if !r.isDropped {
+			r.isDropped = true
+			Logger().Module("alert").Warn().Str("channel", channel).Int("ALERT_RATE_LIMIT", a.RateLimit).Msg("The alert rate limit is exceeded, the alerts are dropped until the next window.")
+		}
+		return false
+	}
+	r.sent++
+	return true
+}
+
+// ObserveStatus counts the server errors of the responses, it is called by the access log middleware.
+// The critical alert is raised when the errors reach the threshold in the window, and it is resolved after a window below the threshold.
+func (a *alertUtil) ObserveStatus(status int) {
+	if a.ErrorBurstThreshold <= 0 {
+		return
+	}
+	now := time.Now()
+	a.mu.Lock()
+	b := &a.burst
+	isResolved := false
+	if now.Sub(b.start) >= a.ErrorBurstWindow {
+		isResolved = b.isRaised && b.errors < a.ErrorBurstThreshold
+		if isResolved {
+			b.isRaised = false
+		}
+		b.start, b.errors = now, 0
+	}
+	isRaised := false
+	if status >= 500 {
+		b.errors++
+		isRaised = b.errors == a.ErrorBurstThreshold
+		if isRaised {
+			b.isRaised = true
+		}
+	}
+	a.mu.Unlock()
+
+	if isResolved {
+		a.Resolve(alertBurstKey, "The server errors are back below "+strconv.Itoa(a.ErrorBurstThreshold)+" per "+a.ErrorBurstWindow.String()+".")
+	}
+	if isRaised {
+		a.Raise(AlertEvent{
+			Key:      alertBurstKey,
+			Source:   "http",
+			Severity: AlertCritical,
+			Title:    "Server errors burst",
+			Message:  strconv.Itoa(a.ErrorBurstThreshold) + " or more responses with 5xx status in " + a.ErrorBurstWindow.String() + ".",
+		})
+	}
+}
+
+// fatalAlertWriter raises the critical alert of the fatal log, for example the startup failure, before the process exits.
+// It is registered as one of the log writers, so the message and the error of the log line are known.
+type fatalAlertWriter struct{}
+
+// Write does nothing, only the fatal and the panic levels are handled by WriteLevel.
+func (fatalAlertWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// WriteLevel raises the alert of the fatal and the panic log lines synchronously.
+func (fatalAlertWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level != zerolog.FatalLevel && level != zerolog.PanicLevel {
+		return len(p), nil
+	}
+	line := map[string]any{}
+	json.Unmarshal(p, &line)
+	msg, _ := line[zerolog.MessageFieldName].(string)
+	errMsg, _ := line[zerolog.ErrorFieldName].(string)
+	if msg == "" {
+		msg = "Fatal error"
+	}
+	module, _ := line["module"].(string)
+	Alert().RaiseSync(AlertEvent{
+		Key:      "fatal:" + msg,
+		Source:   module,
+		Severity: AlertCritical,
+		Title:    msg,
+		Message:  errMsg,
+		Fields:   map[string]string{"level": level.String()},
+	})
+	return len(p), nil
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// telegramAlertChannel sends the alert to the chat of TELEGRAM_ALERT_USER_ID.
+type telegramAlertChannel struct{}
+
+// Name returns the name of the channel.
+func (telegramAlertChannel) Name() string {
+	return "telegram"
+}
+
+// Send sends the alert as the telegram message, a new client is used for every alert since the message is accumulated on the client.
+func (telegramAlertChannel) Send(e AlertEvent) error {
+	t := &telegramUtil{}
+	t.configure()
+	t.AddMessage(e.Text())
+	return t.Send()
+}
+
+// slackAlertChannel sends the alert to the slack compatible incoming webhook, for example slack, mattermost or rocket.chat.
+type slackAlertChannel struct {
+	url string
+}
+
+// Name returns the name of the channel.
+func (slackAlertChannel) Name() string {
+	return "slack"
+}
+
+// Send posts the alert as the text of the incoming webhook.
+func (c slackAlertChannel) Send(e AlertEvent) error {
+	hc := HttpClient("POST", c.url)
+	hc.AddHeader("Content-Type", "application/json")
+	err := hc.AddJsonBody(map[string]any{"text": e.Text()})
+	if err != nil {
+		return err
+	}
+	return sendAlertRequest(hc)
+}
+
+// webhookAlertChannel posts the alert as json to the generic webhook.
+// The body is signed using HMAC-SHA256 with ALERT_WEBHOOK_SECRET on X-Signature header if the secret is set.
+type webhookAlertChannel struct {
+	url    string
+	secret string
+}
+
+// Name returns the name of the channel.
+func (webhookAlertChannel) Name() string {
+	return "webhook"
+}
+
+// Send posts the alert as json.
+func (c webhookAlertChannel) Send(e AlertEvent) error {
+	hc := HttpClient("POST", c.url)
+	hc.AddHeader("Content-Type", "application/json")
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if c.secret != "" {
+		mac := hmac.New(sha256.New, []byte(c.secret))
+		mac.Write(body)
+		hc.AddHeader("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+	// the raw message is sent as is, so the signature matches the exact bytes which are sent
+	err = hc.AddJsonBody(json.RawMessage(body))
+	if err != nil {
+		return err
+	}
+	return sendAlertRequest(hc)
+}
+
+// sendAlertRequest sends the request of the alert, the non 2xx response is an error.
+func sendAlertRequest(hc *httpClientUtil) error {
+	res, err := hc.Send()
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return errors.New("alert webhook responded with status " + strconv.Itoa(res.StatusCode))
+	}
+	return nil
+}
+
+// emailAlertChannel sends the alert by email through the smtp server of ALERT_EMAIL_SMTP_XXX.
+type emailAlertChannel struct {
+	addr     string
+	auth     smtp.Auth
+	from     string
+	to       []string
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// newEmailAlertChannel creates the email channel, the smtp server is authenticated with the plain auth if the username is set.
+func newEmailAlertChannel() *emailAlertChannel {
+	c := &emailAlertChannel{
+		addr:     net.JoinHostPort(ALERT_EMAIL_SMTP_HOST, strconv.Itoa(ALERT_EMAIL_SMTP_PORT)),
+		from:     ALERT_EMAIL_FROM,
+		to:       splitAndTrim(ALERT_EMAIL_TO),
+		sendMail: smtp.SendMail,
+	}
+	if ALERT_EMAIL_USERNAME != "" {
+		c.auth = smtp.PlainAuth("", ALERT_EMAIL_USERNAME, ALERT_EMAIL_PASSWORD, ALERT_EMAIL_SMTP_HOST)
+	}
+	return c
+}
+
+// Name returns the name of the channel.
+func (*emailAlertChannel) Name() string {
+	return "email"
+}
+
+// Send sends the alert as the plain text email, the first line of the text is the subject.
+func (c *emailAlertChannel) Send(e AlertEvent) error {
+	text := e.Text()
+	subject, _, _ := strings.Cut(text, "\n")
+	msg := "From: " + c.from + "\r\n" +
+		"To: " + strings.Join(c.to, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		strings.ReplaceAll(text, "\n", "\r\n") + "\r\n"
+	return c.sendMail(c.addr, c.auth, c.from, c.to, []byte(msg))
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeAlertChannel struct {
+	name   string
+	events []AlertEvent
+	mu     sync.Mutex
+}
+
+func (c *fakeAlertChannel) Name() string {
+	return c.name
+}
+
+func (c *fakeAlertChannel) Send(e AlertEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, e)
+	return nil
+}
+
+func (c *fakeAlertChannel) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.events)
+}
+
+func newTestAlert(t *testing.T, routes string) (*alertUtil, *fakeAlertChannel, *fakeAlertChannel) {
+	r, err := ParseAlertRoutes(routes)
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	a := &alertUtil{Channels: map[string]AlertChannel{}, Routes: r, DedupWindow: time.Hour, RateWindow: time.Hour}
+	chat, email := &fakeAlertChannel{name: "telegram"}, &fakeAlertChannel{name: "email"}
+	a.AddChannel(chat)
+	a.AddChannel(email)
+	return a, chat, email
+}
+
+func TestAlertRouting(t *testing.T) {
+	a, chat, email := newTestAlert(t, "critical=telegram,email;warning@db=telegram")
+	a.RaiseSync(AlertEvent{Source: "db", Severity: AlertWarning, Title: "Replica lag"})
+	a.RaiseSync(AlertEvent{Source: "http", Severity: AlertWarning, Title: "Slow"})
+	a.RaiseSync(AlertEvent{Source: "http", Severity: AlertCritical, Title: "Down"})
+	if chat.count() != 2 || email.count() != 1 {
+		t.Errorf("Expected [2] chat and [1] email alerts, got [%v] [%v]", chat.count(), email.count())
+	}
+
+	_, err := ParseAlertRoutes("fatal=telegram")
+	if err == nil {
+		t.Errorf("Expected the invalid severity to be rejected")
+	}
+}
+
+func TestAlertDedupAndResolve(t *testing.T) {
+	a, chat, _ := newTestAlert(t, "")
+	for i := 0; i < 5; i++ {
+		a.RaiseSync(AlertEvent{Key: "queue", Source: "outbox", Severity: AlertCritical, Title: "Queue is stuck"})
+	}
+	if chat.count() != 1 {
+		t.Fatalf("Expected the duplicated alerts to be suppressed, got [%v]", chat.count())
+	}
+	a.Resolve("queue", "Queue is flowing")
+	a.Resolve("queue", "Queue is flowing")
+	a.Flush(context.Background())
+	if chat.count() != 2 {
+		t.Fatalf("Expected one recovery notification, got [%v]", chat.count())
+	}
+	resolved := chat.events[1]
+	if !resolved.IsResolved || resolved.Suppressed != 4 || resolved.Message != "Queue is flowing" {
+		t.Errorf("Expected the recovery notification with [4] suppressed alerts, got [%+v]", resolved)
+	}
+
+	a.DedupWindow = 0
+	a.RaiseSync(AlertEvent{Key: "queue", Source: "outbox", Severity: AlertCritical, Title: "Queue is stuck"})
+	if chat.count() != 3 {
+		t.Errorf("Expected the alert to be sent again after it is resolved, got [%v]", chat.count())
+	}
+}
+
+func TestAlertActiveTTL(t *testing.T) {
+	a, chat, _ := newTestAlert(t, "")
+	a.ActiveTTL = 50 * time.Millisecond
+	a.RaiseSync(AlertEvent{Key: "panic:GET /api/products", Source: "http", Severity: AlertCritical, Title: "Panic is recovered"})
+	time.Sleep(100 * time.Millisecond)
+	a.RaiseSync(AlertEvent{Key: "fatal:Failed to connect", Severity: AlertCritical, Title: "Failed to connect"})
+	a.mu.Lock()
+	_, isPanicActive := a.active["panic:GET /api/products"]
+	a.mu.Unlock()
+	if isPanicActive || chat.count() != 2 {
+		t.Errorf("Expected the alert which is never resolved to be forgotten after the ttl, got [%v] sent", chat.count())
+	}
+}
+
+func TestAlertRateLimit(t *testing.T) {
+	a, chat, _ := newTestAlert(t, "")
+	a.RateLimit = 3
+	for _, title := range []string{"a", "b", "c", "d", "e"} {
+		a.RaiseSync(AlertEvent{Source: "http", Severity: AlertCritical, Title: title})
+	}
+	if chat.count() != 3 {
+		t.Errorf("Expected [3] alerts within the rate limit, got [%v]", chat.count())
+	}
+}
+
+func TestAlertErrorBurst(t *testing.T) {
+	a, chat, _ := newTestAlert(t, "")
+	a.ErrorBurstThreshold = 3
+	a.ErrorBurstWindow = 50 * time.Millisecond
+	for _, status := range []int{500, 200, 502, 503, 500, 500} {
+		a.ObserveStatus(status)
+	}
+	a.Flush(context.Background())
+	if chat.count() != 1 || chat.events[0].Key != alertBurstKey {
+		t.Fatalf("Expected one burst alert, got [%v]", chat.count())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	a.ObserveStatus(200) // the burst window is closed, the errors of the next window are counted
+	time.Sleep(60 * time.Millisecond)
+	a.ObserveStatus(200) // the window below the threshold resolves the alert
+	a.Flush(context.Background())
+	if chat.count() != 2 || !chat.events[1].IsResolved {
+		t.Errorf("Expected the burst alert to be resolved, got [%v]", chat.count())
+	}
+}
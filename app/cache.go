@@ -2,6 +2,11 @@ package app
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -25,18 +30,77 @@ var cache *cacheUtil
 
 // cacheUtil represents a cache utility.
 // It embeds grest.Cache, indicating that cacheUtil inherits from grest.Cache.
+// The entries can be tagged, so a write can invalidate every entry which contains the changed data.
+// The entries are kept in the bounded in-process lru tier in front of redis, see cache_local.go.
 type cacheUtil struct {
 	grest.Cache
+	StaleTTL     time.Duration // the stale entry is served while it is refreshed in the background, see Remember
+	LockTimeout  time.Duration // the max duration of the redis lock which coalesces the loads across instances
+	TombstoneTTL time.Duration // the invalidated tags reject the values which are loaded before the invalidation, see load
+	ttls         map[string]time.Duration
+	calls        map[string]*cacheCall
+	callsMu      sync.Mutex
+	refreshes    sync.WaitGroup // the background refreshes of the stale entries, see Flush
+
+	LocalTTL   time.Duration // the max ttl of the in-process entries
+	local      *localCache
+	stats      cacheStats
+	instanceID string        // used to ignore the own broadcasted invalidations
+	pubSub     *redis.PubSub // the subscription of the invalidations broadcasted by the other instances
+
+	subscriptions   []*redis.PubSub // the subscriptions of the other broadcasts, see Subscribe
+	subscriptionsMu sync.Mutex
+
+	dependents   map[string][]string // the end points which cached payloads depend on the key end point
+	dependentsMu sync.RWMutex
 }
 
+// CacheDependencyInterface is implemented by the model which declares the end points its cached payloads depend on,
+// otherwise the dependencies are derived from the tables of GetRelations.
+type CacheDependencyInterface interface {
+	CacheDependsOn() []string
+}
+
+// cacheTagPrefix is the prefix of the redis set which contains the keys of the tag.
+const cacheTagPrefix = "tag:"
+
+// cacheTombstonePrefix is the prefix of the redis key which keeps the generation of the last invalidation of the tag, see cacheGenerationKey.
+const cacheTombstonePrefix = "tomb:"
+
+// cacheGenerationKey is the redis key of the generation which is increased on every invalidation.
+const cacheGenerationKey = "cache:generation"
+
+// invalidateTagsScript deletes the keys of the tags and the tags itself atomically, it returns the deleted keys.
+// The tags are tombstoned with the increased generation for the tombstone ttl (ARGV[3] in milliseconds),
+// so the values which are loaded before the invalidation are not cached, see cacheUtil.load.
+var invalidateTagsScript = redis.NewScript(`
+local deleted = {}
+local generation = redis.call('INCR', ARGV[1])
+for _, tag in ipairs(KEYS) do
+	local keys = redis.call('SMEMBERS', tag)
+	for i = 1, #keys, 500 do
+		redis.call('DEL', unpack(keys, i, math.min(i + 499, #keys)))
+	end
+	for _, key in ipairs(keys) do
+		table.insert(deleted, key)
+	end
+	redis.call('DEL', tag)
+	if tonumber(ARGV[3]) > 0 then
+		redis.call('SET', ARGV[2] .. string.sub(tag, #ARGV[4] + 1), generation, 'PX', ARGV[3])
+	end
+end
+return deleted
+`)
+
 // configure configures the cache utility instance.
-// It sets the expiration time (c.Exp) to 24 hours and initializes the Redis client (c.RedisClient) with the provided Redis options.
+// It sets the default and per end point expiration time (c.Exp) based on CACHE_XXX environment variables and initializes the Redis client (c.RedisClient) with the provided Redis options.
 // It sets the context (c.Ctx) to the background context.
 // It pings the Redis server to check the connection status and stores the result in the err variable.
 // If there is an error connecting to Redis, it logs the error and the Redis connection details.
-// Otherwise, it sets c.IsUseRedis to true and logs a successful cache configuration with Redis.
+// Otherwise, it sets c.IsUseRedis to true, subscribes to the invalidations of the other instances and logs a successful cache configuration with Redis.
 func (c *cacheUtil) configure() {
-	c.Exp = 24 * time.Hour
+	c.configureTTL()
+	c.configureLocal()
 	c.RedisClient = redis.NewClient(&redis.Options{
 		Addr:     REDIS_HOST + ":" + REDIS_PORT,
 		Username: REDIS_USERNAME,
@@ -44,9 +108,10 @@ func (c *cacheUtil) configure() {
 		DB:       REDIS_CACHE_DB,
 	})
 	c.Ctx = context.Background()
+	c.RedisClient.AddHook(Trace().RedisHook())
 	err := c.RedisClient.Ping(c.Ctx).Err()
 	if err != nil {
-		Logger().Error().
+		Logger().Module("cache").Error().
 			Err(err).
 			Str("REDIS_HOST", REDIS_HOST).
 			Str("REDIS_PORT", REDIS_PORT).
@@ -56,6 +121,176 @@ func (c *cacheUtil) configure() {
 			Msg("Failed to connect to redis. The cache will be use in-memory local storage.")
 	} else {
 		c.IsUseRedis = true
-		Logger().Info().Msg("Cache configured with redis.")
+		c.subscribe()
+		Logger().Module("cache").Info().Msg("Cache configured with redis.")
+	}
+}
+
+// EndPointTag returns the tag of all entries of the end point, for example "products".
+func (*cacheUtil) EndPointTag(endPoint string) string {
+	return endPoint
+}
+
+// ListTag returns the tag of the list entries of the end point, for example "products?".
+func (*cacheUtil) ListTag(endPoint string) string {
+	return endPoint + "?"
+}
+
+// IDTag returns the tag of the entries which contain the data of the id, for example "products.{id}".
+func (*cacheUtil) IDTag(endPoint, id string) string {
+	return endPoint + "." + id
+}
+
+// DetailTags returns the tags of the detail entry of the id.
+func (c *cacheUtil) DetailTags(endPoint, id string) []string {
+	return []string{c.EndPointTag(endPoint), c.IDTag(endPoint, id)}
+}
+
+// ListTags returns the tags of the list entry, it is tagged by the ids it contains.
+func (c *cacheUtil) ListTags(endPoint string, data []map[string]any) []string {
+	tags := []string{c.EndPointTag(endPoint), c.ListTag(endPoint)}
+	for _, d := range data {
+		if id, ok := d["id"]; ok && id != nil {
+			tags = append(tags, c.IDTag(endPoint, fmt.Sprint(id)))
+		}
+	}
+	return tags
+}
+
+// SetWithTags sets the cache entry with the expiration time and adds its key to the tags,
+// so it is deleted when one of the tags is invalidated.
+func (c *cacheUtil) SetWithTags(key string, val any, exp time.Duration, tags ...string) error {
+	return c.setWithTags(c.Ctx, key, val, exp, tags...)
+}
+
+// setWithTags is SetWithTags with the context of the redis commands.
+// The entry and its tags are written in one MULTI, so the entry is never left in redis without the tags which invalidate it.
+func (c *cacheUtil) setWithTags(ctx context.Context, key string, val any, exp time.Duration, tags ...string) error {
+	if len(tags) == 0 {
+		return c.set(ctx, key, val, exp)
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	c.local.Set(key, b, min(c.LocalTTL, exp))
+	c.local.Tag(key, tags...)
+	if !c.IsUseRedis {
+		return nil
+	}
+	_, err = c.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, b, exp)
+		for _, tag := range tags {
+			pipe.SAdd(ctx, cacheTagPrefix+tag, key)
+			pipe.Expire(ctx, cacheTagPrefix+tag, exp)
+		}
+		return nil
+	})
+	return err
+}
+
+// InvalidateTags deletes every cache entry of the tags, then tombstones the tags for the TombstoneTTL.
+// It is atomic in redis (lua script) and in the in-memory fallback (mutex).
+// The deleted keys are broadcasted, so the other instances drop their in-process copies.
+func (c *cacheUtil) InvalidateTags(tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	c.local.InvalidateTags(tags...)
+	c.local.Tombstone(c.TombstoneTTL, tags...)
+	if !c.IsUseRedis {
+		return nil
+	}
+
+	tagKeys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tagKeys = append(tagKeys, cacheTagPrefix+tag)
+	}
+	keys, err := invalidateTagsScript.Run(c.Ctx, c.RedisClient, tagKeys,
+		cacheGenerationKey, cacheTombstonePrefix, c.TombstoneTTL.Milliseconds(), cacheTagPrefix).StringSlice()
+	if err != nil {
+		return err
+	}
+	c.local.Delete(keys...)
+	return c.publish(cacheInvalidation{Keys: keys})
+}
+
+// Invalidate invalidates the list entries of the end point and the entries which contain the data of the ids,
+// it is called on every write, for example app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String).
+// It cascades to all entries of the dependent end points, see RegisterDependency.
+func (c *cacheUtil) Invalidate(endPoint string, ids ...string) {
+	tags := []string{c.ListTag(endPoint)}
+	for _, id := range ids {
+		tags = append(tags, c.IDTag(endPoint, id))
+	}
+	tags = append(tags, c.cascade(endPoint)...)
+	err := c.InvalidateTags(tags...)
+	if err != nil {
+		Logger().Module("cache").Error().Err(err).Strs("tags", tags).Msg("Failed to invalidate the cache.")
+	}
+}
+
+// RegisterDependency registers the model as the dependent of the end points its cached payloads depend on,
+// so a write to one of those end points also invalidates all entries of the model end point.
+// The dependencies are declared by CacheDependsOn, otherwise they are the tables of GetRelations (the table name is the end point).
+func (c *cacheUtil) RegisterDependency(model ModelInterface) {
+	e, ok := model.(interface{ EndPoint() string })
+	if !ok {
+		return
+	}
+	endPoint := e.EndPoint()
+
+	dependencies := []string{}
+	if d, ok := model.(CacheDependencyInterface); ok {
+		dependencies = d.CacheDependsOn()
+	} else {
+		for _, r := range model.GetRelations() {
+			if table, ok := r["tableName"].(string); ok {
+				dependencies = append(dependencies, strings.Fields(table)[0])
+			} else if table, ok := r["table"].(string); ok {
+				dependencies = append(dependencies, strings.Fields(table)[0])
+			}
+		}
+	}
+
+	c.dependentsMu.Lock()
+	defer c.dependentsMu.Unlock()
+	if c.dependents == nil {
+		c.dependents = map[string][]string{}
+	}
+	for _, d := range dependencies {
+		if !slices.Contains(c.dependents[d], endPoint) {
+			c.dependents[d] = append(c.dependents[d], endPoint)
+		}
+	}
+}
+
+// cascade returns the end point tags of the transitive dependents of the (tenant-prefixed) end point.
+func (c *cacheUtil) cascade(endPoint string) []string {
+	prefix, base := "", endPoint
+	if i := strings.LastIndex(endPoint, ":"); i >= 0 {
+		prefix, base = endPoint[:i+1], endPoint[i+1:]
+	}
+
+	c.dependentsMu.RLock()
+	defer c.dependentsMu.RUnlock()
+	tags := []string{}
+	visited := map[string]bool{}
+	queue := []string{base}
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		for _, to := range c.dependents[from] {
+			if visited[to] {
+				continue
+			}
+			visited[to] = true
+			tags = append(tags, c.EndPointTag(prefix+to))
+			Logger().Module("cache").Debug().Str("from", prefix+from).Str("to", prefix+to).Msg("Cache invalidation is cascaded to the dependent end point.")
+			if to != base {
+				queue = append(queue, to) // the self dependency is not followed again, for example the parent of the categories
+			}
+		}
 	}
+	return tags
 }
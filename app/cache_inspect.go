@@ -0,0 +1,96 @@
+package app
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// CacheKeyInfo is the detail of the cache entry, used to inspect the cache without the hit and miss counters.
+type CacheKeyInfo struct {
+	Key        string          `json:"key"`
+	Tiers      []string        `json:"tiers"`
+	TTL        time.Duration   `json:"ttl"`
+	FreshUntil *time.Time      `json:"fresh_until,omitempty"`
+	Value      json.RawMessage `json:"value"`
+}
+
+// Keys returns up to limit keys with the prefix, from redis or from the in-process tier if redis is not available.
+// The internal keys of the tags, the tombstones and the locks are excluded.
+func (c *cacheUtil) Keys(prefix string, limit int) ([]string, error) {
+	keys := []string{}
+	isInternal := func(key string) bool {
+		return strings.HasPrefix(key, cacheTagPrefix) || strings.HasPrefix(key, cacheTombstonePrefix) ||
+			strings.HasPrefix(key, cacheLockPrefix) || key == cacheGenerationKey
+	}
+	if !c.IsUseRedis {
+		for _, key := range c.local.Keys(prefix) {
+			if len(keys) >= limit {
+				break
+			}
+			if !isInternal(key) {
+				keys = append(keys, key)
+			}
+		}
+		return keys, nil
+	}
+
+	iter := c.RedisClient.Scan(c.Ctx, 0, escapeGlob(prefix)+"*", 1000).Iterator()
+	for len(keys) < limit && iter.Next(c.Ctx) {
+		if !isInternal(iter.Val()) {
+			keys = append(keys, iter.Val())
+		}
+	}
+	return keys, iter.Err()
+}
+
+// Inspect returns the detail of the cache entry, it returns an error if the key is not exists in any tier.
+// The value of the entry which is cached by Remember is unwrapped with its freshness.
+func (c *cacheUtil) Inspect(key string) (CacheKeyInfo, error) {
+	info := CacheKeyInfo{Key: key, Tiers: []string{}}
+	if b, ok := c.local.Get(key); ok {
+		info.Tiers = append(info.Tiers, "local")
+		info.Value = b
+		info.TTL, _ = c.local.TTL(key)
+	}
+	if c.IsUseRedis {
+		var get *redis.StringCmd
+		var pttl *redis.DurationCmd
+		c.RedisClient.Pipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+			get = pipe.Get(c.Ctx, key)
+			pttl = pipe.PTTL(c.Ctx, key)
+			return nil
+		})
+		if b, err := get.Bytes(); err == nil {
+			info.Tiers = append(info.Tiers, "redis")
+			info.Value = b
+			info.TTL = pttl.Val()
+		}
+	}
+	if len(info.Tiers) == 0 {
+		return info, errCacheMiss
+	}
+
+	e := cacheEntry{}
+	if json.Unmarshal(info.Value, &e) == nil && len(e.Value) > 0 && !e.FreshUntil.IsZero() {
+		info.Value = e.Value
+		info.FreshUntil = &e.FreshUntil
+	}
+	return info, nil
+}
+
+// FlushEndPoint deletes all entries of the (tenant-prefixed) end point and its dependents,
+// including the entries which are not tagged, for example the entries which are set directly with Set.
+func (c *cacheUtil) FlushEndPoint(endPoint string) error {
+	err := c.InvalidateTags(append([]string{c.EndPointTag(endPoint)}, c.cascade(endPoint)...)...)
+	if err != nil {
+		return err
+	}
+	err = c.DeleteWithPrefix(endPoint + ".")
+	if err != nil {
+		return err
+	}
+	return c.DeleteWithPrefix(endPoint + "?")
+}
@@ -0,0 +1,481 @@
+package app
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// errCacheMiss is returned by Get when the key is not exists in any tier.
+var errCacheMiss = errors.New("cache: key is not exists")
+
+// cacheInvalidation is the invalidation broadcasted to the other instances over redis pub/sub.
+type cacheInvalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys,omitempty"`
+	Prefix *string  `json:"prefix,omitempty"` // the empty prefix deletes all keys
+}
+
+// cacheStats is the hit and miss counters per tier.
+type cacheStats struct {
+	localHits   atomic.Int64
+	localMisses atomic.Int64
+	redisHits   atomic.Int64
+	redisMisses atomic.Int64
+}
+
+// CacheStats is the snapshot of the cache hit and miss counters per tier since the instance is started.
+type CacheStats struct {
+	IsUseRedis   bool  `json:"is_use_redis"`
+	LocalEntries int   `json:"local_entries"`
+	LocalHits    int64 `json:"local_hits"`
+	LocalMisses  int64 `json:"local_misses"`
+	RedisHits    int64 `json:"redis_hits"`
+	RedisMisses  int64 `json:"redis_misses"`
+}
+
+// configureLocal configures the in-process tier based on the CACHE_LOCAL_XXX environment variables.
+func (c *cacheUtil) configureLocal() {
+	c.LocalTTL = CACHE_LOCAL_TTL
+	c.local = newLocalCache(CACHE_LOCAL_MAX_ENTRIES)
+	c.instanceID = NewNullUUID().String
+}
+
+// Stats returns the hit and miss counters per tier.
+func (c *cacheUtil) Stats() CacheStats {
+	return CacheStats{
+		IsUseRedis:   c.IsUseRedis,
+		LocalEntries: c.local.Len(),
+		LocalHits:    c.stats.localHits.Load(),
+		LocalMisses:  c.stats.localMisses.Load(),
+		RedisHits:    c.stats.redisHits.Load(),
+		RedisMisses:  c.stats.redisMisses.Load(),
+	}
+}
+
+// Get gets the value of the key into val from the in-process tier, or from redis then keeps it in the in-process tier.
+func (c *cacheUtil) Get(key string, val any) error {
+	return c.get(c.Ctx, key, val)
+}
+
+// get is Get with the context of the redis commands.
+func (c *cacheUtil) get(ctx context.Context, key string, val any) error {
+	if b, ok := c.local.Get(key); ok {
+		c.stats.localHits.Add(1)
+		return json.Unmarshal(b, val)
+	}
+	c.stats.localMisses.Add(1)
+	if !c.IsUseRedis {
+		return errCacheMiss
+	}
+
+	var get *redis.StringCmd
+	var pttl *redis.DurationCmd
+	_, err := c.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pttl = pipe.PTTL(ctx, key)
+		return nil
+	})
+	b, getErr := get.Bytes()
+	if getErr != nil {
+		c.stats.redisMisses.Add(1)
+		return getErr
+	}
+	if err != nil {
+		return err
+	}
+	c.stats.redisHits.Add(1)
+	c.local.Set(key, b, min(c.LocalTTL, pttl.Val()))
+	return json.Unmarshal(b, val)
+}
+
+// Set sets the json encoded value of the key to the in-process tier and redis, with the expiration time e or the default Exp.
+func (c *cacheUtil) Set(key string, val any, e ...time.Duration) error {
+	exp := c.Exp
+	if len(e) > 0 {
+		exp = e[0]
+	}
+	return c.set(c.Ctx, key, val, exp)
+}
+
+// set is Set with the context of the redis commands.
+func (c *cacheUtil) set(ctx context.Context, key string, val any, exp time.Duration) error {
+	b, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	c.local.Set(key, b, min(c.LocalTTL, exp))
+	if c.IsUseRedis {
+		return c.RedisClient.Set(ctx, key, b, exp).Err()
+	}
+	return nil
+}
+
+// Delete deletes the key from all tiers of all instances.
+func (c *cacheUtil) Delete(key string) error {
+	c.local.Delete(key)
+	if !c.IsUseRedis {
+		return nil
+	}
+	err := c.RedisClient.Del(c.Ctx, key).Err()
+	if err != nil {
+		return err
+	}
+	return c.publish(cacheInvalidation{Keys: []string{key}})
+}
+
+// DeleteWithPrefix deletes the keys with the prefix from all tiers of all instances.
+func (c *cacheUtil) DeleteWithPrefix(prefix string) error {
+	c.local.DeleteWithPrefix(prefix)
+	if !c.IsUseRedis {
+		return nil
+	}
+	keys := []string{}
+	iter := c.RedisClient.Scan(c.Ctx, 0, escapeGlob(prefix)+"*", 1000).Iterator()
+	for iter.Next(c.Ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 500 {
+			if err := c.RedisClient.Del(c.Ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		if err := c.RedisClient.Del(c.Ctx, keys...).Err(); err != nil {
+			return err
+		}
+	}
+	return c.publish(cacheInvalidation{Prefix: &prefix})
+}
+
+// Clear deletes all keys from all tiers of all instances.
+func (c *cacheUtil) Clear() error {
+	return c.DeleteWithPrefix("")
+}
+
+// Flush waits until the background refreshes of the stale entries are done, or the ctx is done.
+func (c *cacheUtil) Flush(ctx context.Context) error {
+	return waitContext(ctx, &c.refreshes)
+}
+
+// Close closes the subscription of the invalidations and the redis client, it is called on shutdown after Flush.
+func (c *cacheUtil) Close() error {
+	var err error
+	if c.pubSub != nil {
+		err = c.pubSub.Close()
+	}
+	c.subscriptionsMu.Lock()
+	for _, ps := range c.subscriptions {
+		err = errors.Join(err, ps.Close())
+	}
+	c.subscriptions = nil
+	c.subscriptionsMu.Unlock()
+	if c.RedisClient != nil {
+		err = errors.Join(err, c.RedisClient.Close())
+	}
+	return err
+}
+
+// publish broadcasts the invalidation to the other instances.
+func (c *cacheUtil) publish(inv cacheInvalidation) error {
+	if !c.IsUseRedis || CACHE_INVALIDATION_CHANNEL == "" {
+		return nil
+	}
+	inv.Origin = c.instanceID
+	b, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return c.RedisClient.Publish(c.Ctx, CACHE_INVALIDATION_CHANNEL, b).Err()
+}
+
+// subscribe drops the in-process copies of the keys invalidated by the other instances.
+// The subscription is reconnected by the redis client, the invalidations which are missed meanwhile are bounded by LocalTTL.
+func (c *cacheUtil) subscribe() {
+	if CACHE_INVALIDATION_CHANNEL == "" || c.local.maxEntries <= 0 {
+		return
+	}
+	c.pubSub = c.RedisClient.Subscribe(c.Ctx, CACHE_INVALIDATION_CHANNEL)
+	go func() {
+		for msg := range c.pubSub.Channel() {
+			inv := cacheInvalidation{}
+			err := json.Unmarshal([]byte(msg.Payload), &inv)
+			if err != nil {
+				Logger().Module("cache").Warn().Err(err).Str("payload", msg.Payload).Msg("Invalid cache invalidation, it is ignored.")
+				continue
+			}
+			c.applyInvalidation(inv)
+		}
+	}()
+}
+
+// Publish broadcasts the json encoded message on the redis channel to the subscribers of all instances, see Subscribe.
+// It returns false without redis, so the caller only applies the message on the current instance.
+func (c *cacheUtil) Publish(channel string, msg any) (bool, error) {
+	if !c.IsUseRedis || channel == "" {
+		return false, nil
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return false, err
+	}
+	return true, c.RedisClient.Publish(c.Ctx, channel, b).Err()
+}
+
+// Subscribe calls the handle with the payload of every message published on the redis channel by all instances, including the current one.
+// The subscription is reconnected by the redis client, the messages which are missed meanwhile are lost. It is closed by Close.
+func (c *cacheUtil) Subscribe(channel string, handle func(payload []byte)) {
+	if !c.IsUseRedis || channel == "" {
+		return
+	}
+	ps := c.RedisClient.Subscribe(c.Ctx, channel)
+	c.subscriptionsMu.Lock()
+	c.subscriptions = append(c.subscriptions, ps)
+	c.subscriptionsMu.Unlock()
+	go func() {
+		for msg := range ps.Channel() {
+			handle([]byte(msg.Payload))
+		}
+	}()
+}
+
+// applyInvalidation drops the in-process copies of the invalidation broadcasted by the other instance.
+func (c *cacheUtil) applyInvalidation(inv cacheInvalidation) {
+	if inv.Origin == c.instanceID {
+		return
+	}
+	c.local.Delete(inv.Keys...)
+	if inv.Prefix != nil {
+		c.local.DeleteWithPrefix(*inv.Prefix)
+	}
+}
+
+// escapeGlob escapes the special characters of the redis glob-style pattern, for example the "?" of the list keys.
+func escapeGlob(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(s)
+}
+
+// newLocalCache returns a bounded in-process LRU cache with max entries.
+func newLocalCache(maxEntries int) *localCache {
+	return &localCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      map[string]*list.Element{},
+		tags:       map[string]map[string]struct{}{},
+		tombs:      map[string]localTomb{},
+	}
+}
+
+// localCache is the bounded in-process LRU tier in front of redis, it stores the json encoded values.
+// When redis is not available, it is the only tier and it keeps its own tag index for the tag invalidation.
+type localCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	tags       map[string]map[string]struct{}
+	generation uint64
+	tombs      map[string]localTomb
+}
+
+// localTomb is the tombstone of the invalidated tag, see cacheUtil.isInvalidatedSince.
+type localTomb struct {
+	generation uint64
+	expiresAt  time.Time
+}
+
+// localEntry is the entry of the local cache.
+type localEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+	tags      []string
+}
+
+// Get returns the value of the key if it exists and is not expired, and marks it as recently used.
+func (l *localCache) Get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*localEntry)
+	if time.Now().After(e.expiresAt) {
+		l.remove(el)
+		return nil, false
+	}
+	l.ll.MoveToFront(el)
+	return e.value, true
+}
+
+// Set sets the value of the key with the ttl, then evicts the least recently used entries over the max entries.
+func (l *localCache) Set(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 || l.maxEntries <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		e := el.Value.(*localEntry)
+		e.value = value
+		e.expiresAt = time.Now().Add(ttl)
+		l.ll.MoveToFront(el)
+		return
+	}
+	l.items[key] = l.ll.PushFront(&localEntry{key: key, value: value, expiresAt: time.Now().Add(ttl)})
+	for l.ll.Len() > l.maxEntries {
+		l.remove(l.ll.Back())
+	}
+}
+
+// Tag adds the key to the tags, it is ignored if the key is not exists.
+func (l *localCache) Tag(key string, tags ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[key]
+	if !ok {
+		return
+	}
+	e := el.Value.(*localEntry)
+	for _, tag := range tags {
+		if l.tags[tag] == nil {
+			l.tags[tag] = map[string]struct{}{}
+		}
+		if _, ok := l.tags[tag][key]; !ok {
+			l.tags[tag][key] = struct{}{}
+			e.tags = append(e.tags, tag)
+		}
+	}
+}
+
+// Delete deletes the keys.
+func (l *localCache) Delete(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := l.items[key]; ok {
+			l.remove(el)
+		}
+	}
+}
+
+// DeleteWithPrefix deletes the keys with the prefix, all keys are deleted if the prefix is empty.
+func (l *localCache) DeleteWithPrefix(prefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, el := range l.items {
+		if strings.HasPrefix(key, prefix) {
+			l.remove(el)
+		}
+	}
+}
+
+// InvalidateTags deletes the keys of the tags and the tags itself.
+func (l *localCache) InvalidateTags(tags ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, tag := range tags {
+		for key := range l.tags[tag] {
+			if el, ok := l.items[key]; ok {
+				l.remove(el)
+			}
+		}
+		delete(l.tags, tag)
+	}
+}
+
+// Tombstone increases the generation, then tombstones the tags with it for the ttl, the expired tombstones are dropped.
+func (l *localCache) Tombstone(ttl time.Duration, tags ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.generation++
+	if ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	for tag, tomb := range l.tombs {
+		if now.After(tomb.expiresAt) {
+			delete(l.tombs, tag)
+		}
+	}
+	for _, tag := range tags {
+		l.tombs[tag] = localTomb{generation: l.generation, expiresAt: now.Add(ttl)}
+	}
+}
+
+// Generation returns the generation of the last invalidation.
+func (l *localCache) Generation() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.generation
+}
+
+// IsInvalidatedSince returns true if one of the tags is tombstoned after the generation.
+func (l *localCache) IsInvalidatedSince(generation uint64, tags ...string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for _, tag := range tags {
+		if tomb, ok := l.tombs[tag]; ok && tomb.generation > generation && now.Before(tomb.expiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// Keys returns the keys with the prefix which are not expired.
+func (l *localCache) Keys(prefix string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	keys := []string{}
+	for key, el := range l.items {
+		if strings.HasPrefix(key, prefix) && now.Before(el.Value.(*localEntry).expiresAt) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// TTL returns the remaining ttl of the key, it returns false if the key is not exists.
+func (l *localCache) TTL(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[key]
+	if !ok {
+		return 0, false
+	}
+	return time.Until(el.Value.(*localEntry).expiresAt), true
+}
+
+// Len returns the number of the entries, including the expired entries which are not evicted yet.
+func (l *localCache) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len()
+}
+
+// remove removes the entry and its key from the tags, the caller must hold the lock.
+func (l *localCache) remove(el *list.Element) {
+	e := el.Value.(*localEntry)
+	l.ll.Remove(el)
+	delete(l.items, e.key)
+	for _, tag := range e.tags {
+		delete(l.tags[tag], e.key)
+		if len(l.tags[tag]) == 0 {
+			delete(l.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// cacheEntry is the cached value with its freshness, the entry is kept for the stale window after it is not fresh anymore.
+type cacheEntry struct {
+	Value      json.RawMessage `json:"v"`
+	FreshUntil time.Time       `json:"f"`
+}
+
+// cacheCall is an in-flight or completed load of a cache key, used to coalesce the concurrent loads.
+type cacheCall struct {
+	wg    sync.WaitGroup
+	value json.RawMessage
+	err   error
+}
+
+// cacheLockPrefix is the prefix of the redis lock which coalesces the loads across instances.
+const cacheLockPrefix = "lock:"
+
+// releaseLockScript deletes the lock only if it is still owned by the token.
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// configureTTL configures the default and per end point ttl based on the CACHE_XXX environment variables.
+func (c *cacheUtil) configureTTL() {
+	c.Exp = CACHE_TTL
+	c.StaleTTL = CACHE_STALE_TTL
+	c.LockTimeout = CACHE_LOCK_TIMEOUT
+	c.TombstoneTTL = CACHE_TOMBSTONE_TTL
+	c.ttls = map[string]time.Duration{}
+	for _, v := range splitAndTrim(CACHE_ENDPOINT_TTLS) {
+		endPoint, ttl, ok := strings.Cut(v, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(ttl))
+		if !ok || err != nil {
+			Logger().Module("cache").Warn().Str("CACHE_ENDPOINT_TTLS", v).Msg("Invalid cache ttl, it is ignored.")
+			continue
+		}
+		c.ttls[strings.TrimSpace(endPoint)] = d
+	}
+}
+
+// TTL returns the ttl of the (tenant-prefixed) end point, or the default ttl if it is not configured.
+func (c *cacheUtil) TTL(endPoint string) time.Duration {
+	if i := strings.LastIndex(endPoint, ":"); i >= 0 {
+		endPoint = endPoint[i+1:]
+	}
+	if ttl, ok := c.ttls[endPoint]; ok {
+		return ttl
+	}
+	return c.Exp
+}
+
+// Remember gets the cached value of the key into val, or loads it using load, then caches it with the returned tags.
+// The concurrent loads of the same key are coalesced, in-process and across instances using a short redis lock.
+// When the entry is stale (within StaleTTL after its ttl), the stale value is returned and the entry is refreshed
+// in the background, except isAllowStale is false (for example inside a db transaction which can't be used in the background).
+// The endPoint is the tenant-prefixed end point of the entry, used for the ttl.
+func (c *cacheUtil) Remember(endPoint, key string, val any, isAllowStale bool, load func() (any, []string, error)) error {
+	return c.RememberContext(c.Ctx, endPoint, key, val, isAllowStale, load)
+}
+
+// RememberContext is like Remember, the redis commands are issued with the ctx so they are traced as the child spans of the request.
+func (c *cacheUtil) RememberContext(ctx context.Context, endPoint, key string, val any, isAllowStale bool, load func() (any, []string, error)) error {
+	e := cacheEntry{}
+	if c.get(ctx, key, &e) == nil && len(e.Value) > 0 {
+		if time.Now().Before(e.FreshUntil) {
+			return json.Unmarshal(e.Value, val)
+		}
+		if isAllowStale {
+			c.refreshes.Add(1)
+			go func() {
+				defer c.refreshes.Done()
+				c.load(c.Ctx, endPoint, key, load) // the refresh outlives the request, so it is not traced
+			}()
+			return json.Unmarshal(e.Value, val)
+		}
+	}
+
+	value, err := c.load(ctx, endPoint, key, load)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(value, val)
+}
+
+// load loads the value of the key once for the concurrent callers, then caches it.
+func (c *cacheUtil) load(ctx context.Context, endPoint, key string, load func() (any, []string, error)) (json.RawMessage, error) {
+	c.callsMu.Lock()
+	if c.calls == nil {
+		c.calls = map[string]*cacheCall{}
+	}
+	if call, ok := c.calls[key]; ok {
+		c.callsMu.Unlock()
+		call.wg.Wait()
+		return call.value, call.err
+	}
+	call := &cacheCall{}
+	call.wg.Add(1)
+	c.calls[key] = call
+	c.callsMu.Unlock()
+
+	defer func() {
+		call.wg.Done()
+		c.callsMu.Lock()
+		delete(c.calls, key)
+		c.callsMu.Unlock()
+	}()
+
+	// wait for the other instance which holds the lock to cache the value
+	token, isLocked := c.lock(ctx, key)
+	if !isLocked {
+		if value, ok := c.wait(ctx, key); ok {
+			call.value = value
+			return call.value, nil
+		}
+	}
+	if token != "" {
+		defer releaseLockScript.Run(ctx, c.RedisClient, []string{cacheLockPrefix + key}, token)
+	}
+
+	// the generation is read before the load, so the value is not cached if its tags are invalidated during the load
+	generation := c.generation(ctx)
+	v, tags, err := load()
+	if err != nil {
+		call.err = err
+		return nil, err
+	}
+	call.value, call.err = json.Marshal(v)
+	if call.err != nil {
+		return nil, call.err
+	}
+	if c.isInvalidatedSince(ctx, generation, tags) {
+		return call.value, nil
+	}
+	ttl := c.TTL(endPoint)
+	c.setWithTags(ctx, key, cacheEntry{Value: call.value, FreshUntil: time.Now().Add(ttl)}, ttl+c.StaleTTL, tags...)
+	return call.value, nil
+}
+
+// generation returns the generation of the last invalidation, see InvalidateTags.
+func (c *cacheUtil) generation(ctx context.Context) uint64 {
+	if !c.IsUseRedis {
+		return c.local.Generation()
+	}
+	generation, err := c.RedisClient.Get(ctx, cacheGenerationKey).Uint64()
+	if err != nil && err != redis.Nil {
+		Logger().Module("cache").Warn().Err(err).Msg("Failed to get the cache generation.")
+	}
+	return generation
+}
+
+// isInvalidatedSince returns true if one of the tags is invalidated after the generation,
+// it returns true if the tombstones can't be checked, so the value which may be stale is not cached.
+func (c *cacheUtil) isInvalidatedSince(ctx context.Context, generation uint64, tags []string) bool {
+	if c.TombstoneTTL <= 0 || len(tags) == 0 {
+		return false
+	}
+	if !c.IsUseRedis {
+		return c.local.IsInvalidatedSince(generation, tags...)
+	}
+	keys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys = append(keys, cacheTombstonePrefix+tag)
+	}
+	tombs, err := c.RedisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return true
+	}
+	for _, tomb := range tombs {
+		s, ok := tomb.(string)
+		if !ok {
+			continue
+		}
+		g, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || g > generation {
+			return true
+		}
+	}
+	return false
+}
+
+// lock acquires the redis lock of the key, it returns the token to release the lock if it is acquired.
+// It returns false only if the lock is held by another caller, so the loads are not blocked when redis is not available.
+func (c *cacheUtil) lock(ctx context.Context, key string) (string, bool) {
+	if !c.IsUseRedis || c.LockTimeout <= 0 {
+		return "", true
+	}
+	token := NewNullUUID().String
+	isLocked, err := c.RedisClient.SetNX(ctx, cacheLockPrefix+key, token, c.LockTimeout).Result()
+	if err != nil {
+		return "", true
+	}
+	if !isLocked {
+		return "", false
+	}
+	return token, true
+}
+
+// wait waits until the fresh value of the key is cached by the lock holder, up to the lock timeout.
+func (c *cacheUtil) wait(ctx context.Context, key string) (json.RawMessage, bool) {
+	deadline := time.Now().Add(c.LockTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		c.local.Delete(key) // the in-process copy is the stale one, the fresh value is set to redis by the lock holder
+		e := cacheEntry{}
+		if c.get(ctx, key, &e) == nil && len(e.Value) > 0 && time.Now().Before(e.FreshUntil) {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestCache() *cacheUtil {
+	return &cacheUtil{LocalTTL: time.Hour, local: newLocalCache(1000)}
+}
+
+func TestCacheTags(t *testing.T) {
+	c := newTestCache()
+	data := []map[string]any{{"id": "a"}, {"id": "b"}}
+	c.SetWithTags("products?$page=1", ListModel{Data: data}, time.Hour, c.ListTags("products", data)...)
+	c.SetWithTags("products.a", map[string]any{"id": "a"}, time.Hour, c.DetailTags("products", "a")...)
+	c.SetWithTags("products.c", map[string]any{"id": "c"}, time.Hour, c.DetailTags("products", "c")...)
+
+	if _, ok := c.local.tags[c.IDTag("products", "b")]["products?$page=1"]; !ok {
+		t.Errorf("Expected list entry to be tagged by the ids it contains")
+	}
+
+	c.Invalidate("products", "a")
+	for _, tag := range []string{c.ListTag("products"), c.IDTag("products", "a")} {
+		if _, ok := c.local.tags[tag]; ok {
+			t.Errorf("Expected tag [%v] to be invalidated", tag)
+		}
+	}
+	if _, ok := c.local.tags[c.IDTag("products", "c")]["products.c"]; !ok {
+		t.Errorf("Expected unrelated entry to be kept")
+	}
+
+	c.Invalidate("products")
+	if _, ok := c.local.tags[c.IDTag("products", "c")]["products.c"]; !ok {
+		t.Errorf("Expected create to only invalidate the list entries")
+	}
+}
+
+type testCacheProduct struct {
+	Model
+}
+
+func (testCacheProduct) EndPoint() string { return "products" }
+func (testCacheProduct) GetRelations() map[string]map[string]any {
+	return map[string]map[string]any{"c": {"tableName": "categories"}}
+}
+
+type testCacheCategory struct {
+	Model
+}
+
+func (testCacheCategory) EndPoint() string         { return "categories" }
+func (testCacheCategory) CacheDependsOn() []string { return []string{"categories", "products"} }
+
+func TestCacheDependency(t *testing.T) {
+	c := newTestCache()
+	c.RegisterDependency(&testCacheProduct{})
+	c.RegisterDependency(&testCacheCategory{})
+
+	c.SetWithTags("acme:products.a", map[string]any{"id": "a"}, time.Hour, c.DetailTags("acme:products", "a")...)
+	c.SetWithTags("acme:categories.b", map[string]any{"id": "b"}, time.Hour, c.DetailTags("acme:categories", "b")...)
+	c.SetWithTags("globex:products.a", map[string]any{"id": "a"}, time.Hour, c.DetailTags("globex:products", "a")...)
+
+	c.Invalidate("acme:categories", "x")
+	if _, ok := c.local.tags[c.EndPointTag("acme:products")]; ok {
+		t.Errorf("Expected category write to cascade to the products")
+	}
+	if _, ok := c.local.tags[c.EndPointTag("acme:categories")]; ok {
+		t.Errorf("Expected category write to cascade to the categories itself")
+	}
+	if _, ok := c.local.tags[c.EndPointTag("globex:products")]; !ok {
+		t.Errorf("Expected cascade to be scoped to the tenant")
+	}
+}
+
+func TestCacheRemember(t *testing.T) {
+	c := newTestCache()
+	c.Exp = time.Hour
+	c.ttls = map[string]time.Duration{"products": time.Minute}
+	if ttl := c.TTL("acme:products"); ttl != time.Minute {
+		t.Errorf("Expected ttl [%v], got [%v]", time.Minute, ttl)
+	}
+	if ttl := c.TTL("categories"); ttl != time.Hour {
+		t.Errorf("Expected ttl [%v], got [%v]", time.Hour, ttl)
+	}
+
+	var loads atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := map[string]any{}
+			err := c.Remember("products", "products.a", &res, true, func() (any, []string, error) {
+				loads.Add(1)
+				time.Sleep(100 * time.Millisecond)
+				return map[string]any{"id": "a"}, c.DetailTags("products", "a"), nil
+			})
+			if err != nil || res["id"] != "a" {
+				t.Errorf("Expected [a], got [%v] [%v]", res["id"], err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := loads.Load(); n != 1 {
+		t.Errorf("Expected concurrent loads to be coalesced, got [%v] loads", n)
+	}
+}
+
+func TestCacheRememberInvalidatedDuringLoad(t *testing.T) {
+	c := newTestCache()
+	c.Exp = time.Hour
+	c.TombstoneTTL = time.Minute
+
+	res := map[string]any{}
+	err := c.Remember("products", "products.a", &res, true, func() (any, []string, error) {
+		c.Invalidate("products", "a") // the write is committed after the old data is read
+		return map[string]any{"id": "a", "name": "old"}, c.DetailTags("products", "a"), nil
+	})
+	if err != nil || res["name"] != "old" {
+		t.Errorf("Expected the loaded value to be returned, got [%v] [%v]", res["name"], err)
+	}
+	if _, ok := c.local.Get("products.a"); ok {
+		t.Errorf("Expected the value loaded before the invalidation not to be cached")
+	}
+
+	err = c.Remember("products", "products.a", &res, true, func() (any, []string, error) {
+		return map[string]any{"id": "a", "name": "new"}, c.DetailTags("products", "a"), nil
+	})
+	if err != nil || res["name"] != "new" {
+		t.Errorf("Expected [new], got [%v] [%v]", res["name"], err)
+	}
+	if _, ok := c.local.Get("products.a"); !ok {
+		t.Errorf("Expected the value loaded after the invalidation to be cached")
+	}
+}
+
+func TestCacheLocal(t *testing.T) {
+	c := newTestCache()
+	c.local = newLocalCache(2)
+	c.Set("products.a", "a", time.Hour)
+	c.Set("products.b", "b", time.Hour)
+	c.Get("products.a", new(string)) // a is recently used, so b is evicted
+	c.Set("products.c", "c", time.Hour)
+	if err := c.Get("products.b", new(string)); err == nil {
+		t.Errorf("Expected least recently used entry to be evicted")
+	}
+	if s := c.Stats(); s.LocalHits != 1 || s.LocalMisses != 1 || s.LocalEntries != 2 {
+		t.Errorf("Expected 1 hit, 1 miss and 2 entries, got [%+v]", s)
+	}
+
+	c.Set("products.d", "d", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if err := c.Get("products.d", new(string)); err == nil {
+		t.Errorf("Expected expired entry to be missed")
+	}
+
+	c.instanceID = "self"
+	c.applyInvalidation(cacheInvalidation{Origin: "self", Keys: []string{"products.c"}})
+	if err := c.Get("products.c", new(string)); err != nil {
+		t.Errorf("Expected own invalidation to be ignored")
+	}
+	prefix := "products."
+	c.applyInvalidation(cacheInvalidation{Origin: "other", Prefix: &prefix})
+	if c.local.Len() != 0 {
+		t.Errorf("Expected other instance invalidation to drop the in-process copies")
+	}
+
+	if p := escapeGlob("products?$page=1"); p != `products\?$page=1` {
+		t.Errorf("Expected escaped pattern, got [%v]", p)
+	}
+}
@@ -28,6 +28,8 @@ var (
 	APP_PORT = "4001"
 	APP_URL  = "http://localhost:4001"
 
+	APP_SHUTDOWN_TIMEOUT = 30 * time.Second // on SIGINT or SIGTERM, the max duration to drain the in-flight requests and the running jobs, then to flush and close the resources
+
 	IS_MAIN_SERVER = true // set to true to run migration, seed and task scheduling
 
 	IS_GENERATE_OPEN_API_DOC = false
@@ -44,6 +46,25 @@ var (
 	LOG_FILE_MAX_SIZE       = 100            // MB
 	LOG_FILE_MAX_AGE        = 7              // days
 	LOG_FILE_MAX_BACKUPS    = 0              // files
+	LOG_FORMAT              = "console"      // format of the console log, console (human readable) or json (for the log shippers), the file log is always json
+
+	LOG_LEVEL              = "trace"          // trace, debug, info, warn, error, fatal, panic or disabled
+	LOG_MODULE_LEVELS      = ""               // comma separated level overrides of the modules (http, db, cache, fs, outbox), for example db=debug,cache=warn
+	LOG_LEVEL_REVERT_AFTER = 15 * time.Minute // the default duration of the levels which are changed at runtime, see Logger().SetLevels
+	LOG_LEVEL_CHANNEL      = "log:levels"     // redis pub/sub channel to broadcast the levels which are changed at runtime to all instances
+
+	ACCESS_LOG_ENABLED        = true            // log every request with its route, status, latency, etc
+	ACCESS_LOG_SLOW_THRESHOLD = 1 * time.Second // the request which takes longer than this is logged as warning, 0 to disable it
+
+	METRICS_ENABLED     = true       // expose the prometheus metrics on METRICS_PATH
+	METRICS_PATH        = "/metrics" //
+	METRICS_ALLOWED_IPS = ""         // comma separated ips or cidrs which can access the metrics with the token, empty to allow any ip, behind a proxy it is the ip of the proxy
+	METRICS_TOKEN       = ""         // the bearer token to access the metrics, it is required, the metrics are forbidden if it is empty
+
+	TRACE_EXPORTER          = "none"                  // none, otlp (OTLP over http) or stdout
+	TRACE_OTLP_ENDPOINT     = "http://localhost:4318" // the url of the OTLP collector, the spans are sent to its /v1/traces
+	TRACE_SAMPLE_RATE_LIMIT = 10                      // max new traces sampled per second, the incoming sampled traces are limited too, 0 to sample every trace
+	TRACE_SERVICE_NAME      = "grest-belajar"         //
 
 	JWT_KEY     = "45845ccb526944ef8288337fafcccabd"
 	CRYPTO_KEY  = "793a9474e8c3418fa7bbe39dd7d8f076"
@@ -62,6 +83,12 @@ var (
 	DB_CONN_MAX_LIFETIME = time.Hour // on .env = "1h". Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h".
 	DB_IS_DEBUG          = false
 
+	DB_SLOW_QUERY_THRESHOLD     = 200 * time.Millisecond // the query which takes longer than this is logged as warning, 0 to disable it
+	DB_REPEATED_QUERY_THRESHOLD = 10                     // the same query shape which runs more than this times in a request is logged as a possible N+1 query, 0 to disable it
+
+	DB_REPLICA_MAX_LAG        = 10 * time.Second // replica with greater replication lag is taken out of rotation
+	DB_REPLICA_CHECK_INTERVAL = 5 * time.Second  // interval of the replica health check
+
 	REDIS_HOST      = "127.0.0.1"
 	REDIS_PORT      = "6379"
 	REDIS_CACHE_DB  = 3
@@ -69,7 +96,17 @@ var (
 	REDIS_USERNAME  = ""
 	REDIS_PASSWORD  = ""
 
-	FS_DRIVER          = "local"
+	CACHE_TTL           = 24 * time.Hour   // default ttl of the cache entries
+	CACHE_ENDPOINT_TTLS = ""               // comma separated ttl per end point, for example "products=5m,categories=1h"
+	CACHE_STALE_TTL     = time.Duration(0) // stale-while-revalidate window after the ttl, 0 to disable
+	CACHE_LOCK_TIMEOUT  = 5 * time.Second  // max duration of the redis lock which coalesces the loads across instances
+	CACHE_TOMBSTONE_TTL = time.Minute      // the invalidated tags reject the values loaded before the invalidation, it should be longer than the slowest load
+
+	CACHE_LOCAL_MAX_ENTRIES    = 10000              // max entries of the in-process lru tier in front of redis, 0 to disable
+	CACHE_LOCAL_TTL            = time.Minute        // max ttl of the in-process entries, it bounds the staleness if an invalidation is missed
+	CACHE_INVALIDATION_CHANNEL = "cache:invalidate" // redis pub/sub channel to broadcast the invalidations to all instances
+
+	FS_DRIVER          = "local" // local, s3 (or any unregistered name for S3 compatible storage), memory or the registered driver, see RegisterFSDriver
 	FS_LOCAL_DIR_PATH  = "storages"
 	FS_PUBLIC_DIR_PATH = "storages"
 	FS_END_POINT       = "s3.amazonaws.com"
@@ -78,9 +115,67 @@ var (
 	FS_BUCKET_NAME     = "attachments"
 	FS_ACCESS_KEY      = ""
 	FS_SECRET_KEY      = ""
+	FS_IS_PUBLIC       = false            // upload the files with public-read acl and return the public urls, otherwise the urls are signed
+	FS_URL_EXPIRY      = 15 * time.Minute // default expiry of the presigned and signed urls
+	FS_SIGNING_KEY     = ""               // hmac key of the local signed urls, required outside local env, a random key is used on local env
+	FS_SIGNED_URL_PATH = "/api/storages"  // route of the local signed urls
+
+	FS_GC_SCHEDULE     = "CRON_TZ=Asia/Jakarta 30 1 * * *" // cron schedule of the orphan files garbage collection, empty to disable it
+	FS_GC_GRACE_PERIOD = 24 * time.Hour                    // the objects and rows younger than this are never collected, to protect the uploads in progress
+	FS_GC_IS_DRY_RUN   = true                              // only report the orphan files to the log without deleting them
+
+	FILE_MAX_SIZE           = 10 << 20                                                                        // max size of the uploaded file in bytes
+	FILE_ALLOWED_MIME_TYPES = "image/jpeg,image/png,image/gif,image/webp,application/pdf,text/plain,text/csv" // comma separated, the sniffed mime type must match one of them, "image/*" is allowed
+
+	TUS_MAX_SIZE  = 1 << 30        // max size of the resumable upload in bytes
+	TUS_EXPIRY    = 24 * time.Hour // the resumable upload expires after this, then its chunks are collected by the orphan files garbage collection
+	TUS_PART_SIZE = 8 << 20        // the chunk is streamed to the storage in parts of this size, the offset is saved after every part
+
+	IMAGE_RENDITIONS   = "thumbnail:150x150:jpeg,medium:800x800:jpeg,large:1600x1600:webp" // comma separated name:{width}x{height}:format, the format is jpeg, png or webp
+	IMAGE_JPEG_QUALITY = 85                                                                // 1-100, the quality of the re-encoded jpeg original and renditions, higher is better quality but bigger file
+	IMAGE_MAX_PIXELS   = 40000000                                                          // max width x height of the decoded image, to reject the decompression bomb
 
 	TELEGRAM_ALERT_TOKEN   = ""
 	TELEGRAM_ALERT_USER_ID = ""
+
+	ALERT_ROUTES            = ""                // semicolon separated {severity}[@{source}]={comma separated channels}, for example "critical=telegram,email;warning@db=slack", empty to send warning or higher to every channel
+	ALERT_DEDUP_WINDOW      = 10 * time.Minute  // the alert of the same key is sent once per window
+	ALERT_ACTIVE_TTL        = 24 * time.Hour    // the raised alert which is not resolved nor sent again within it is forgotten, it should be longer than the dedup window
+	ALERT_RESOLVE_CHANNEL   = "alerts:resolved" // redis pub/sub channel to broadcast the resolved alerts to all instances
+	ALERT_RATE_LIMIT        = 20                // max alerts per channel per window, 0 to disable it
+	ALERT_RATE_WINDOW       = time.Minute       //
+	ALERT_5XX_THRESHOLD     = 20                // the number of 5xx responses in the window which raises the critical alert, 0 to disable it
+	ALERT_5XX_WINDOW        = time.Minute       //
+	ALERT_SLACK_WEBHOOK_URL = ""                // slack compatible incoming webhook
+	ALERT_EMAIL_SMTP_HOST   = ""                //
+	ALERT_EMAIL_SMTP_PORT   = 587               //
+	ALERT_EMAIL_USERNAME    = ""                // plain auth is used if set
+	ALERT_EMAIL_PASSWORD    = ""                //
+	ALERT_EMAIL_FROM        = ""                //
+	ALERT_EMAIL_TO          = ""                // comma separated
+	ALERT_WEBHOOK_URL       = ""                // the alert is posted as json
+	ALERT_WEBHOOK_SECRET    = ""                // used to sign the webhook body
+
+	ERROR_GROUP_ENABLED        = true             // aggregate the server errors by their fingerprint on the error_groups table
+	ERROR_GROUP_FLUSH_INTERVAL = 10 * time.Second // the errors are counted in memory and saved periodically by every instance
+	ERROR_GROUP_MAX_PENDING    = 1000             // max distinct errors between the flushes, the new ones are dropped when it is reached
+
+	OUTBOX_RELAY_INTERVAL       = 2 * time.Second    // interval of the outbox relay to publish the pending events
+	OUTBOX_BATCH_SIZE           = 100                //
+	OUTBOX_MAX_ATTEMPTS         = 10                 // the event is marked as failed after max attempts
+	OUTBOX_RETENTION            = 7 * 24 * time.Hour // the dispatched events older than this are deleted
+	OUTBOX_LEASE                = 5 * time.Minute    // the events claimed by a relay are skipped by the other instances until this is expired
+	OUTBOX_WEBHOOK_URLS         = ""                 // comma separated, every event is posted to these urls
+	OUTBOX_WEBHOOK_SECRET       = ""                 // used to sign the webhook body
+	OUTBOX_REDIS_STREAM         = ""                 // every event is added to this redis stream if set
+	OUTBOX_REDIS_STREAM_MAX_LEN = 100000             //
+
+	TENANT_ENABLED        = false                                           // scope the data, cache keys and files per tenant
+	TENANT_RESOLVERS      = "token,header,subdomain"                        // comma separated, in order of precedence
+	TENANT_HEADER         = "X-Tenant-ID"                                   //
+	TENANT_BASE_DOMAIN    = ""                                              // used by subdomain resolver, for example "example.com" to resolve "acme.example.com" to "acme"
+	TENANT_TOKEN_CLAIM    = "tenant_id"                                     // used by token resolver
+	TENANT_EXCLUDED_PATHS = "/api/version,/api/docs,/api/storages,/metrics" // comma separated path prefixes which can be accessed without tenant
 )
 
 // config is a pointer to a configUtil instance.
@@ -108,6 +203,7 @@ func (*configUtil) configure() {
 	grest.LoadEnv("APP_ENV", &APP_ENV)
 	grest.LoadEnv("APP_PORT", &APP_PORT)
 	grest.LoadEnv("APP_URL", &APP_URL)
+	grest.LoadEnv("APP_SHUTDOWN_TIMEOUT", &APP_SHUTDOWN_TIMEOUT)
 
 	grest.LoadEnv("IS_MAIN_SERVER", &IS_MAIN_SERVER)
 
@@ -122,6 +218,24 @@ func (*configUtil) configure() {
 	grest.LoadEnv("LOG_FILE_MAX_SIZE", &LOG_FILE_MAX_SIZE)
 	grest.LoadEnv("LOG_FILE_MAX_AGE", &LOG_FILE_MAX_AGE)
 	grest.LoadEnv("LOG_FILE_MAX_BACKUPS", &LOG_FILE_MAX_BACKUPS)
+	grest.LoadEnv("LOG_FORMAT", &LOG_FORMAT)
+	grest.LoadEnv("LOG_LEVEL", &LOG_LEVEL)
+	grest.LoadEnv("LOG_MODULE_LEVELS", &LOG_MODULE_LEVELS)
+	grest.LoadEnv("LOG_LEVEL_REVERT_AFTER", &LOG_LEVEL_REVERT_AFTER)
+	grest.LoadEnv("LOG_LEVEL_CHANNEL", &LOG_LEVEL_CHANNEL)
+
+	grest.LoadEnv("ACCESS_LOG_ENABLED", &ACCESS_LOG_ENABLED)
+	grest.LoadEnv("ACCESS_LOG_SLOW_THRESHOLD", &ACCESS_LOG_SLOW_THRESHOLD)
+
+	grest.LoadEnv("METRICS_ENABLED", &METRICS_ENABLED)
+	grest.LoadEnv("METRICS_PATH", &METRICS_PATH)
+	grest.LoadEnv("METRICS_ALLOWED_IPS", &METRICS_ALLOWED_IPS)
+	grest.LoadEnv("METRICS_TOKEN", &METRICS_TOKEN)
+
+	grest.LoadEnv("TRACE_EXPORTER", &TRACE_EXPORTER)
+	grest.LoadEnv("TRACE_OTLP_ENDPOINT", &TRACE_OTLP_ENDPOINT)
+	grest.LoadEnv("TRACE_SAMPLE_RATE_LIMIT", &TRACE_SAMPLE_RATE_LIMIT)
+	grest.LoadEnv("TRACE_SERVICE_NAME", &TRACE_SERVICE_NAME)
 
 	grest.LoadEnv("JWT_KEY", &JWT_KEY)
 	grest.LoadEnv("CRYPTO_KEY", &CRYPTO_KEY)
@@ -139,6 +253,10 @@ func (*configUtil) configure() {
 	grest.LoadEnv("DB_MAX_IDLE_CONNS", &DB_MAX_IDLE_CONNS)
 	grest.LoadEnv("DB_CONN_MAX_LIFETIME", &DB_CONN_MAX_LIFETIME)
 	grest.LoadEnv("DB_IS_DEBUG", &DB_IS_DEBUG)
+	grest.LoadEnv("DB_SLOW_QUERY_THRESHOLD", &DB_SLOW_QUERY_THRESHOLD)
+	grest.LoadEnv("DB_REPEATED_QUERY_THRESHOLD", &DB_REPEATED_QUERY_THRESHOLD)
+	grest.LoadEnv("DB_REPLICA_MAX_LAG", &DB_REPLICA_MAX_LAG)
+	grest.LoadEnv("DB_REPLICA_CHECK_INTERVAL", &DB_REPLICA_CHECK_INTERVAL)
 
 	grest.LoadEnv("REDIS_HOST", &REDIS_HOST)
 	grest.LoadEnv("REDIS_PORT", &REDIS_PORT)
@@ -147,13 +265,81 @@ func (*configUtil) configure() {
 	grest.LoadEnv("REDIS_USERNAME", &REDIS_USERNAME)
 	grest.LoadEnv("REDIS_PASSWORD", &REDIS_PASSWORD)
 
+	grest.LoadEnv("CACHE_TTL", &CACHE_TTL)
+	grest.LoadEnv("CACHE_ENDPOINT_TTLS", &CACHE_ENDPOINT_TTLS)
+	grest.LoadEnv("CACHE_STALE_TTL", &CACHE_STALE_TTL)
+	grest.LoadEnv("CACHE_LOCK_TIMEOUT", &CACHE_LOCK_TIMEOUT)
+	grest.LoadEnv("CACHE_TOMBSTONE_TTL", &CACHE_TOMBSTONE_TTL)
+	grest.LoadEnv("CACHE_LOCAL_MAX_ENTRIES", &CACHE_LOCAL_MAX_ENTRIES)
+	grest.LoadEnv("CACHE_LOCAL_TTL", &CACHE_LOCAL_TTL)
+	grest.LoadEnv("CACHE_INVALIDATION_CHANNEL", &CACHE_INVALIDATION_CHANNEL)
+
+	grest.LoadEnv("FS_DRIVER", &FS_DRIVER)
+	grest.LoadEnv("FS_LOCAL_DIR_PATH", &FS_LOCAL_DIR_PATH)
+	grest.LoadEnv("FS_PUBLIC_DIR_PATH", &FS_PUBLIC_DIR_PATH)
 	grest.LoadEnv("FS_END_POINT", &FS_END_POINT)
 	grest.LoadEnv("FS_PORT", &FS_PORT)
 	grest.LoadEnv("FS_REGION", &FS_REGION)
 	grest.LoadEnv("FS_BUCKET_NAME", &FS_BUCKET_NAME)
 	grest.LoadEnv("FS_ACCESS_KEY", &FS_ACCESS_KEY)
 	grest.LoadEnv("FS_SECRET_KEY", &FS_SECRET_KEY)
+	grest.LoadEnv("FS_IS_PUBLIC", &FS_IS_PUBLIC)
+	grest.LoadEnv("FS_URL_EXPIRY", &FS_URL_EXPIRY)
+	grest.LoadEnv("FS_SIGNING_KEY", &FS_SIGNING_KEY)
+	grest.LoadEnv("FS_SIGNED_URL_PATH", &FS_SIGNED_URL_PATH)
+	grest.LoadEnv("FS_GC_SCHEDULE", &FS_GC_SCHEDULE)
+	grest.LoadEnv("FS_GC_GRACE_PERIOD", &FS_GC_GRACE_PERIOD)
+	grest.LoadEnv("FS_GC_IS_DRY_RUN", &FS_GC_IS_DRY_RUN)
+
+	grest.LoadEnv("FILE_MAX_SIZE", &FILE_MAX_SIZE)
+	grest.LoadEnv("FILE_ALLOWED_MIME_TYPES", &FILE_ALLOWED_MIME_TYPES)
+	grest.LoadEnv("TUS_MAX_SIZE", &TUS_MAX_SIZE)
+	grest.LoadEnv("TUS_EXPIRY", &TUS_EXPIRY)
+	grest.LoadEnv("TUS_PART_SIZE", &TUS_PART_SIZE)
+
+	grest.LoadEnv("IMAGE_RENDITIONS", &IMAGE_RENDITIONS)
+	grest.LoadEnv("IMAGE_JPEG_QUALITY", &IMAGE_JPEG_QUALITY)
+	grest.LoadEnv("IMAGE_MAX_PIXELS", &IMAGE_MAX_PIXELS)
 
 	grest.LoadEnv("TELEGRAM_ALERT_TOKEN", &TELEGRAM_ALERT_TOKEN)
 	grest.LoadEnv("TELEGRAM_ALERT_USER_ID", &TELEGRAM_ALERT_USER_ID)
+
+	grest.LoadEnv("ALERT_ROUTES", &ALERT_ROUTES)
+	grest.LoadEnv("ALERT_DEDUP_WINDOW", &ALERT_DEDUP_WINDOW)
+	grest.LoadEnv("ALERT_ACTIVE_TTL", &ALERT_ACTIVE_TTL)
+	grest.LoadEnv("ALERT_RESOLVE_CHANNEL", &ALERT_RESOLVE_CHANNEL)
+	grest.LoadEnv("ALERT_RATE_LIMIT", &ALERT_RATE_LIMIT)
+	grest.LoadEnv("ALERT_RATE_WINDOW", &ALERT_RATE_WINDOW)
+	grest.LoadEnv("ALERT_5XX_THRESHOLD", &ALERT_5XX_THRESHOLD)
+	grest.LoadEnv("ALERT_5XX_WINDOW", &ALERT_5XX_WINDOW)
+	grest.LoadEnv("ALERT_SLACK_WEBHOOK_URL", &ALERT_SLACK_WEBHOOK_URL)
+	grest.LoadEnv("ALERT_EMAIL_SMTP_HOST", &ALERT_EMAIL_SMTP_HOST)
+	grest.LoadEnv("ALERT_EMAIL_SMTP_PORT", &ALERT_EMAIL_SMTP_PORT)
+	grest.LoadEnv("ALERT_EMAIL_USERNAME", &ALERT_EMAIL_USERNAME)
+	grest.LoadEnv("ALERT_EMAIL_PASSWORD", &ALERT_EMAIL_PASSWORD)
+	grest.LoadEnv("ALERT_EMAIL_FROM", &ALERT_EMAIL_FROM)
+	grest.LoadEnv("ALERT_EMAIL_TO", &ALERT_EMAIL_TO)
+	grest.LoadEnv("ALERT_WEBHOOK_URL", &ALERT_WEBHOOK_URL)
+	grest.LoadEnv("ALERT_WEBHOOK_SECRET", &ALERT_WEBHOOK_SECRET)
+
+	grest.LoadEnv("ERROR_GROUP_ENABLED", &ERROR_GROUP_ENABLED)
+	grest.LoadEnv("ERROR_GROUP_FLUSH_INTERVAL", &ERROR_GROUP_FLUSH_INTERVAL)
+	grest.LoadEnv("ERROR_GROUP_MAX_PENDING", &ERROR_GROUP_MAX_PENDING)
+
+	grest.LoadEnv("OUTBOX_RELAY_INTERVAL", &OUTBOX_RELAY_INTERVAL)
+	grest.LoadEnv("OUTBOX_BATCH_SIZE", &OUTBOX_BATCH_SIZE)
+	grest.LoadEnv("OUTBOX_MAX_ATTEMPTS", &OUTBOX_MAX_ATTEMPTS)
+	grest.LoadEnv("OUTBOX_RETENTION", &OUTBOX_RETENTION)
+	grest.LoadEnv("OUTBOX_LEASE", &OUTBOX_LEASE)
+	grest.LoadEnv("OUTBOX_WEBHOOK_URLS", &OUTBOX_WEBHOOK_URLS)
+	grest.LoadEnv("OUTBOX_WEBHOOK_SECRET", &OUTBOX_WEBHOOK_SECRET)
+	grest.LoadEnv("OUTBOX_REDIS_STREAM", &OUTBOX_REDIS_STREAM)
+	grest.LoadEnv("OUTBOX_REDIS_STREAM_MAX_LEN", &OUTBOX_REDIS_STREAM_MAX_LEN)
+
+	grest.LoadEnv("TENANT_ENABLED", &TENANT_ENABLED)
+	grest.LoadEnv("TENANT_RESOLVERS", &TENANT_RESOLVERS)
+	grest.LoadEnv("TENANT_HEADER", &TENANT_HEADER)
+	grest.LoadEnv("TENANT_BASE_DOMAIN", &TENANT_BASE_DOMAIN)
+	grest.LoadEnv("TENANT_TOKEN_CLAIM", &TENANT_TOKEN_CLAIM)
+	grest.LoadEnv("TENANT_EXCLUDED_PATHS", &TENANT_EXCLUDED_PATHS)
 }
@@ -1,23 +1,32 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"reflect"
-	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 	"grest.dev/grest"
 )
 
 const CtxKey = "ctx"
 
 type Ctx struct {
-	Lang   string // language code
-	Action Action // general request info
+	Lang      string // language code
+	TenantID  string // resolved tenant, empty if multi-tenancy is disabled
+	UserID    string // the subject of the verified bearer token, empty if it is not authenticated
+	RequestID string // the X-Request-ID of the request, see AccessLog
+	Action    Action // general request info
 
-	IsAsync bool     // for async use, autocommit
-	mainTx  *gorm.DB // for normal use, commit & rollback from middleware
+	IsAsync             bool     // for async use, autocommit
+	IsStrongConsistency bool     // read from the primary db, set on write request or by $consistency=strong
+	mainTx              *gorm.DB // for normal use, commit & rollback from middleware
+	parent              context.Context
+
+	afterCommit *[]func() // called after mainTx is committed, the pointer is shared by the copies of the ctx
 }
 
 type Action struct {
@@ -34,19 +43,22 @@ func (c *Ctx) TxBegin() error {
 		return err
 	}
 	c.mainTx = mainTx.Begin()
+	c.afterCommit = &[]func(){}
 	return nil
 }
 
 // TxCommit commits the current transaction if it exists (mainTx is not nil).
 // Called in middleware when there is no error (http status code is 2xx).
 // It does nothing if there is no active transaction.
+// The functions registered by AfterCommit are called only when the commit is succeed.
 func (c *Ctx) TxCommit() {
-	if c.mainTx != nil {
-		c.mainTx.Commit()
+	if c.mainTx != nil && c.mainTx.Commit().Error == nil {
+		c.runAfterCommit()
 	}
 
 	// reset to nil to use gorm autocommit if use goroutine, etc
 	c.mainTx = nil
+	c.afterCommit = nil
 }
 
 // TxRollback rolls back the current transaction if it exists (mainTx is not nil).
@@ -58,6 +70,30 @@ func (c *Ctx) TxRollback() {
 	}
 	// reset to nil to use gorm autocommit if use goroutine, etc
 	c.mainTx = nil
+	c.afterCommit = nil
+}
+
+// AfterCommit registers fn to be called after the transaction of the request is committed, it is dropped on rollback.
+// Use it for the side effects which must not be visible before the changes are, for example the cache invalidation,
+// otherwise the concurrent reads may cache the old data again before the commit.
+// The fn is called immediately if there is no transaction (async or read request).
+func (c Ctx) AfterCommit(fn func()) {
+	if c.IsAsync || c.mainTx == nil || c.afterCommit == nil {
+		fn()
+		return
+	}
+	*c.afterCommit = append(*c.afterCommit, fn)
+}
+
+// runAfterCommit calls the functions registered by AfterCommit in order.
+func (c *Ctx) runAfterCommit() {
+	if c.afterCommit == nil {
+		return
+	}
+	for _, fn := range *c.afterCommit {
+		fn()
+	}
+	*c.afterCommit = nil
 }
 
 // Trans translates a given key using the language specified in the context (c.Lang).
@@ -79,20 +115,76 @@ func (c Ctx) ValidateParam(v any) error {
 	return Validator().ValidateStruct(v, c.Lang)
 }
 
+// SetContext sets the parent of Context(), for example the user context of the fiber request which carries its trace span.
+func (c *Ctx) SetContext(ctx context.Context) {
+	c.parent = ctx
+}
+
+// Context returns the context of the current request.
+// It carries the tenant id so the db session is scoped to the tenant, and the trace span of the request if any, see Trace().
+func (c Ctx) Context() context.Context {
+	parent := c.parent
+	if parent == nil {
+		parent = context.Background()
+	}
+	return Tenant().WithContext(parent, c.TenantID)
+}
+
+// CacheKey returns the key prefixed with the tenant id, so the cached data is never shared across tenants.
+// Use it to build cache keys from EndPoint(), for example c.CacheKey(u.EndPoint()) + "." + id.
+// The key is not prefixed without tenant, so the scan or flush by prefix must require the tenant when the multi-tenancy is enabled.
+func (c Ctx) CacheKey(key string) string {
+	return Tenant().Prefix(c.TenantID, key)
+}
+
+// Remember gets the cached value of the key into val, or loads it using load, see Cache().Remember.
+// The end point and the key are prefixed with the tenant. The cache is bypassed inside the db transaction,
+// since the load reads the uncommitted changes which must never be cached nor shared with the concurrent requests,
+// and on the strong consistency, since the cached value may be older than the write which must be read.
+func (c Ctx) Remember(endPoint, key string, val any, load func() (any, []string, error)) error {
+	if (!c.IsAsync && c.mainTx != nil) || c.IsStrongConsistency {
+		v, _, err := load()
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, val)
+	}
+	return Cache().RememberContext(c.Context(), c.CacheKey(endPoint), c.CacheKey(key), val, true, load)
+}
+
+// FS returns the filesystem utility scoped to the tenant directory, its operations are traced as the child spans of the request.
+func (c Ctx) FS() *fsUtil {
+	return FS().WithPrefix(Tenant().Path(c.TenantID, "")).WithContext(c.Context())
+}
+
 // This method returns the GORM database connection based on the provided connection name (connName).
 // If IS_USE_MOCK_DB is true, it returns the mock database connection.
 // If c.IsAsync is false and there is an active transaction (c.mainTx), it returns the transaction connection.
-// Otherwise, it returns the main database connection.
+// Otherwise, it returns the main database connection, the reads are resolved to the replicas
+// unless c.IsStrongConsistency is true, so the reads after a write never hit a lagging replica.
+// The returned connection carries the context of the current request, so it is scoped to the tenant.
 func (c Ctx) DB(connName ...string) (*gorm.DB, error) {
 	if IS_USE_MOCK_DB {
 		return Mock().DB()
 	}
 	// Control the transaction manually (set begin transaction, commit and rollback on middleware)
 	if !c.IsAsync && c.mainTx != nil {
-		return c.mainTx, nil
+		return c.mainTx.WithContext(c.Context()), nil
 	}
 	// Autocommit if use goroutine, etc
-	return DB().Conn("main")
+	tx, err := DB().Conn("main")
+	if err != nil {
+		return nil, err
+	}
+	tx = tx.WithContext(c.Context())
+	if c.IsStrongConsistency {
+		tx = tx.Clauses(dbresolver.Write)
+	}
+	return tx, nil
 }
 
 // This method checks if the given error is a "record not found" error from GORM.
@@ -112,14 +204,16 @@ func (c Ctx) NotFoundError(err error, entity, key, value string) error {
 	return nil
 }
 
-// This method performs a hook operation, which involves sleeping for 2 seconds and performing some data manipulation based on the provided parameters.
-// It checks if the old value implements the IsFlat() method and determines whether the data is flat.
-// If the data is not flat, it converts the old value to a structured format.
-// You can do anything you want with this method, for example to save user activity log, send callback/webhook, etc.
-func (c Ctx) Hook(method, reason, id string, old any) {
-
-	// kasih jeda 2 detik untuk memastikan db transaction nya sudah di commit
-	time.Sleep(2 * time.Second)
+// Hook records the domain event of the data changes to the outbox in the same transaction as the changes,
+// so the event is published by the outbox relay only after the transaction is committed.
+// The event type is the end point followed by the action, for example "products.created", "products.updated" or "products.deleted".
+// The event payload contains the reason with the old and the new data, you can subscribe to the events with Outbox().Subscribe
+// to save user activity log, send callback/webhook, etc.
+func (c Ctx) Hook(method, reason, id string, old any) error {
+	tx, err := c.DB()
+	if err != nil {
+		return err
+	}
 
 	isFlat := false
 	flat, ok := old.(interface{ IsFlat() bool })
@@ -127,29 +221,38 @@ func (c Ctx) Hook(method, reason, id string, old any) {
 		isFlat = flat.IsFlat()
 	}
 
-	oldData := old
-	if !isFlat {
-		oldData = grest.NewJSON(old).ToStructured().Data
-	}
-	oldJSON, _ := json.MarshalIndent(oldData, "", "  ")
-	newJSON := []byte{}
-
-	model := reflect.ValueOf(old)
-	if m := model.MethodByName("Async"); m.IsValid() {
-		useCase := m.Call([]reflect.Value{reflect.ValueOf(c)})
-		if len(useCase) > 0 {
-			if u := useCase[0].MethodByName("GetByID"); u.IsValid() {
-				val := u.Call([]reflect.Value{reflect.ValueOf(id)})
-				if len(val) > 0 {
-					new := val[0].Interface()
-					if !isFlat {
-						new = grest.NewJSON(new).ToStructured().Data
-					}
-					newJSON, _ = json.MarshalIndent(new, "", "  ")
-				}
-			}
+	// get the new data from the same transaction, it is nil if the data is deleted
+	var newData any
+	model, ok := reflect.New(reflect.Indirect(reflect.ValueOf(old)).Type()).Interface().(ModelInterface)
+	if ok && Query().First(tx, model, url.Values{"id": {id}}) == nil {
+		newData = model
+		if !isFlat {
+			newData = grest.NewJSON(model).ToStructured().Data
+		}
+	}
+
+	var oldData any
+	if method != http.MethodPost {
+		oldData = old
+		if !isFlat {
+			oldData = grest.NewJSON(old).ToStructured().Data
 		}
 	}
-	_ = oldJSON
-	_ = newJSON
+
+	endPoint := ""
+	if e, ok := old.(interface{ EndPoint() string }); ok {
+		endPoint = e.EndPoint()
+	}
+	action := map[string]string{
+		http.MethodPost:   "created",
+		http.MethodPut:    "updated",
+		http.MethodPatch:  "updated",
+		http.MethodDelete: "deleted",
+	}[method]
+	return Outbox().Record(tx, endPoint+"."+action, endPoint, id, map[string]any{
+		"method": method,
+		"reason": reason,
+		"old":    oldData,
+		"new":    newData,
+	})
 }
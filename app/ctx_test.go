@@ -0,0 +1,66 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestCtxAfterCommit(t *testing.T) {
+	calls := []string{}
+	Ctx{}.AfterCommit(func() { calls = append(calls, "no tx") })
+	Ctx{IsAsync: true, mainTx: &gorm.DB{}, afterCommit: &[]func(){}}.AfterCommit(func() { calls = append(calls, "async") })
+	if len(calls) != 2 {
+		t.Errorf("Expected called immediately without tx, got [%v]", calls)
+	}
+
+	// the use cases hold a copy of the ctx of the middleware
+	c := &Ctx{mainTx: &gorm.DB{}, afterCommit: &[]func(){}}
+	copied := *c
+	copied.AfterCommit(func() { calls = append(calls, "first") })
+	copied.AfterCommit(func() { calls = append(calls, "second") })
+	if len(calls) != 2 {
+		t.Errorf("Expected not called before commit, got [%v]", calls)
+	}
+	c.runAfterCommit()
+	c.runAfterCommit()
+	if len(calls) != 4 || calls[2] != "first" || calls[3] != "second" {
+		t.Errorf("Expected called once in order after commit, got [%v]", calls)
+	}
+}
+
+func TestCtxRemember(t *testing.T) {
+	c := newTestCache()
+	c.Exp = time.Hour
+	defer func(old *cacheUtil) { cache = old }(cache)
+	cache = c
+	loads := 0
+	load := func() (any, []string, error) {
+		loads++
+		return map[string]any{"id": "a", "loads": loads}, c.DetailTags("products", "a"), nil
+	}
+
+	// the uncommitted data is never cached
+	res := map[string]any{}
+	err := Ctx{mainTx: &gorm.DB{}}.Remember("products", "products.a", &res, load)
+	if err != nil || res["id"] != "a" {
+		t.Errorf("Expected loaded inside the tx, got [%v] [%v]", res, err)
+	}
+	Ctx{mainTx: &gorm.DB{}}.Remember("products", "products.a", &res, load)
+	if loads != 2 {
+		t.Errorf("Expected the cache bypassed inside the tx, got %v loads", loads)
+	}
+
+	Ctx{}.Remember("products", "products.a", &res, load)
+	Ctx{}.Remember("products", "products.a", &res, load)
+	if loads != 3 {
+		t.Errorf("Expected the cache used outside the tx, got %v loads", loads)
+	}
+
+	// the strong consistency reads the db even if the value is cached
+	Ctx{IsStrongConsistency: true}.Remember("products", "products.a", &res, load)
+	if loads != 4 || res["loads"] != float64(4) {
+		t.Errorf("Expected the cache bypassed on the strong consistency, got %v loads [%v]", loads, res)
+	}
+}
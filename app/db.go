@@ -29,6 +29,7 @@ var db *dbUtil
 // It embeds grest.DB, indicating that dbUtil inherits from grest.DB.
 type dbUtil struct {
 	grest.DB
+	replicas []*replicaPolicy
 }
 
 // configure configures the db utility instance.
@@ -65,6 +66,26 @@ func (d *dbUtil) Connect(connName string, c grest.DBConfig) error {
 		return err
 	}
 
+	err = gormDB.Use(Tenant().Plugin())
+	if err != nil {
+		return err
+	}
+
+	err = gormDB.Use(Metrics().GormPlugin(connName))
+	if err != nil {
+		return err
+	}
+
+	err = gormDB.Use(Trace().GormPlugin(connName))
+	if err != nil {
+		return err
+	}
+
+	err = gormDB.Use(newQueryLogPlugin(connName))
+	if err != nil {
+		return err
+	}
+
 	if DB_IS_DEBUG {
 		gormDB = gormDB.Debug()
 	}
@@ -82,7 +103,15 @@ func (d *dbUtil) Connect(connName string, c grest.DBConfig) error {
 	return nil
 }
 
+// RegisterConn stores the connection based on connName key, and registers its pool stats to the metrics.
+func (d *dbUtil) RegisterConn(connName string, conn *gorm.DB) {
+	d.DB.RegisterConn(connName, conn)
+	Metrics().RegisterDB(connName, conn)
+}
+
 // setupReplicas setup replica to automatic read and write connection switching.
+// The reads are resolved to the healthy replicas only, see replicaPolicy.
+// Use Clauses(dbresolver.Write) to read from the primary, Ctx.DB() does it for the write request or on $consistency=strong.
 func (d *dbUtil) setupReplicas(db *gorm.DB, c grest.DBConfig) {
 	if DB_HOST_READ != "" {
 		dialector := mysql.Open(c.DSN())
@@ -97,14 +126,24 @@ func (d *dbUtil) setupReplicas(db *gorm.DB, c grest.DBConfig) {
 		if len(replicasDialector) == 0 {
 			replicasDialector = sourcesDialector
 		}
+		policy := newReplicaPolicy(db.Config.ConnPool, replicas)
+		d.replicas = append(d.replicas, policy)
 		db.Use(dbresolver.Register(dbresolver.Config{
 			Sources:  sourcesDialector,
 			Replicas: replicasDialector,
-			Policy:   dbresolver.RandomPolicy{},
+			Policy:   policy,
 		}))
 	}
 }
 
+// Close stops the health check of the replicas, then closes all connections.
+func (d *dbUtil) Close() {
+	for _, r := range d.replicas {
+		r.Close()
+	}
+	d.DB.Close()
+}
+
 // IsNotFoundError check if an error is not found error.
 func (*dbUtil) IsNotFoundError(err error) bool {
 	return errors.Is(err, gorm.ErrRecordNotFound)
@@ -73,7 +73,8 @@ func (q queryUtil) First(db *gorm.DB, model ModelInterface, query url.Values) er
 }
 
 // Find get paginated data from database based on model and query.
-func (queryUtil) Find(db *gorm.DB, model ModelInterface, query url.Values) ([]map[string]any, error) {
+func (qu queryUtil) Find(db *gorm.DB, model ModelInterface, query url.Values) ([]map[string]any, error) {
+	qu.scope(db, model)
 	q := &grest.DBQuery{}
 	q.DB = db
 	q.Schema = model.GetSchema()
@@ -82,12 +83,13 @@ func (queryUtil) Find(db *gorm.DB, model ModelInterface, query url.Values) ([]ma
 }
 
 // PaginationInfo get pagination info from database based on model and query.
-func (queryUtil) PaginationInfo(db *gorm.DB, model ModelInterface, query url.Values) (int64, int, int, int, error) {
+func (qu queryUtil) PaginationInfo(db *gorm.DB, model ModelInterface, query url.Values) (int64, int, int, int, error) {
 	var err error
 	count, page, perPage, pageCount := int64(0), 0, 0, 0
 	if query.Get(grest.QueryDisablePagination) == "true" {
 		return count, page, -1, pageCount, err
 	}
+	qu.scope(db, model)
 
 	q := &grest.DBQuery{}
 	q.DB = db
@@ -105,3 +107,19 @@ func (queryUtil) PaginationInfo(db *gorm.DB, model ModelInterface, query url.Val
 	pageCount = int(math.Ceil(float64(count) / float64(perPage)))
 	return count, page, perPage, pageCount, err
 }
+
+// scope adds the tenant filter to the model filters if the db session carries a tenant id,
+// so every model is scoped to the tenant without adding the filter on each GetFilters.
+func (queryUtil) scope(db *gorm.DB, model ModelInterface) {
+	if db == nil || db.Statement == nil || !Tenant().IsScoped(model) {
+		return
+	}
+	tenantID := Tenant().FromContext(db.Statement.Context)
+	if tenantID == "" {
+		return
+	}
+	m, ok := model.(interface{ AddFilter(map[string]any) })
+	if ok {
+		m.AddFilter(map[string]any{"column1": model.TableAliasName() + "." + TenantColumn, "operator": "=", "value": tenantID})
+	}
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// newQueryLogPlugin returns the gorm plugin which logs the slow queries and the repeated queries (N+1) of the request,
+// based on DB_SLOW_QUERY_THRESHOLD and DB_REPEATED_QUERY_THRESHOLD.
+// Unlike DB_IS_DEBUG, only the queries worth to look at are logged, and the bound values are not logged, only their types.
+func newQueryLogPlugin(connName string) *queryLogPlugin {
+	return &queryLogPlugin{
+		connName:        connName,
+		slowThreshold:   DB_SLOW_QUERY_THRESHOLD,
+		repeatThreshold: DB_REPEATED_QUERY_THRESHOLD,
+	}
+}
+
+// queryLogPlugin is the gorm plugin which logs the slow queries and the repeated queries.
+type queryLogPlugin struct {
+	connName        string
+	slowThreshold   time.Duration // 0 to disable the slow query log
+	repeatThreshold int           // 0 to disable the repeated query detection
+}
+
+// queryLogStartKey is the key of the gorm instance which stores the start time of the query.
+const queryLogStartKey = "query_log:start"
+
+// Name returns the name of the plugin.
+func (*queryLogPlugin) Name() string {
+	return "query_log"
+}
+
+// Initialize registers the callbacks around every operation of the gorm.
+func (p *queryLogPlugin) Initialize(db *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(queryLogStartKey, time.Now())
+	}
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("query_log:before_create", before),
+		cb.Create().After("gorm:create").Register("query_log:after_create", p.after),
+		cb.Query().Before("gorm:query").Register("query_log:before_query", before),
+		cb.Query().After("gorm:query").Register("query_log:after_query", p.after),
+		cb.Update().Before("gorm:update").Register("query_log:before_update", before),
+		cb.Update().After("gorm:update").Register("query_log:after_update", p.after),
+		cb.Delete().Before("gorm:delete").Register("query_log:before_delete", before),
+		cb.Delete().After("gorm:delete").Register("query_log:after_delete", p.after),
+		cb.Row().Before("gorm:row").Register("query_log:before_row", before),
+		cb.Row().After("gorm:row").Register("query_log:after_row", p.after),
+		cb.Raw().Before("gorm:raw").Register("query_log:before_raw", before),
+		cb.Raw().After("gorm:raw").Register("query_log:after_raw", p.after),
+	)
+}
+
+// after logs the query if it is slow, and counts it on the request stats to detect the repeated query.
+func (p *queryLogPlugin) after(tx *gorm.DB) {
+	v, ok := tx.InstanceGet(queryLogStartKey)
+	if !ok {
+		return
+	}
+	start, _ := v.(time.Time)
+	latency := time.Since(start)
+	shape := QueryShape(tx.Statement.SQL.String())
+	stats := QueryStatsFromContext(tx.Statement.Context)
+
+	if p.slowThreshold > 0 && latency >= p.slowThreshold {
+		Logger().Module("db").Warn().
+			Str("conn", p.connName).
+			Str("sql", shape).
+			Strs("params", paramShapes(tx.Statement.Vars)).
+			Int64("rows", tx.RowsAffected).
+			Dur("latency", latency).
+			Str("request_id", stats.RequestID()).
+			Msg("Query is slow.")
+	}
+	if n := stats.Add(shape); p.repeatThreshold > 0 && n == p.repeatThreshold+1 {
+		Logger().Module("db").Warn().
+			Str("conn", p.connName).
+			Str("sql", shape).
+			Int("count", n).
+			Str("request_id", stats.RequestID()).
+			Msg("Query is repeated in the request, it may be an N+1 query, consider to preload or to query the ids at once.")
+	}
+}
+
+// inListPattern matches the placeholders of the IN list, so the queries with the different list lengths have the same shape.
+var inListPattern = regexp.MustCompile(`\(\?(\s*,\s*\?)+\)`)
+
+// QueryShape returns the shape of the sql, the placeholders of the IN list are collapsed into one.
+func QueryShape(sql string) string {
+	return inListPattern.ReplaceAllString(sql, "(?...)")
+}
+
+// paramShapes returns the types of the bound values, the values itself are not logged since they may contain personal data.
+func paramShapes(vars []any) []string {
+	res := make([]string, 0, len(vars))
+	for _, v := range vars {
+		res = append(res, fmt.Sprintf("%T", v))
+	}
+	return res
+}
+
+// QueryStats is the count of the queries of the request by the shape, it is carried by the request context, see WithQueryStats.
+type QueryStats struct {
+	requestID string
+	counts    map[string]int
+	total     int
+	mu        sync.Mutex
+}
+
+// queryStatsKey is the context key of the QueryStats.
+type queryStatsKey struct{}
+
+// WithQueryStats returns the context which counts the queries of the request, it is set by the access log middleware.
+func WithQueryStats(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, queryStatsKey{}, &QueryStats{requestID: requestID, counts: map[string]int{}})
+}
+
+// QueryStatsFromContext returns the QueryStats of the context, or nil if the context is not of a request.
+func QueryStatsFromContext(ctx context.Context) *QueryStats {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(queryStatsKey{}).(*QueryStats)
+	return s
+}
+
+// Add counts the query of the shape, it returns the count of the shape.
+func (s *QueryStats) Add(shape string) int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[shape]++
+	s.total++
+	return s.counts[shape]
+}
+
+// Total returns the count of all queries of the request.
+func (s *QueryStats) Total() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+// RequestID returns the id of the request, it is empty if the context is not of a request.
+func (s *QueryStats) RequestID() string {
+	if s == nil {
+		return ""
+	}
+	return s.requestID
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestQueryLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	prev := logger
+	logger = &loggerUtil{Logger: zerolog.New(buf)}
+	defer func() { logger = prev }()
+
+	// the queries are not executed on the dry run, but the callbacks are called
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:1)/db", SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	err = db.Use(&queryLogPlugin{connName: "main", slowThreshold: time.Nanosecond, repeatThreshold: 2})
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+
+	ctx := WithQueryStats(context.Background(), "req-1")
+	for i := 1; i <= 4; i++ {
+		ids := make([]int, i)
+		db.WithContext(ctx).Table("products").Where("id IN ? AND name = ?", ids, "secret").Find(&[]map[string]any{})
+	}
+
+	out := buf.String()
+	if strings.Count(out, "Query is slow.") != 4 || strings.Count(out, "Query is repeated") != 1 {
+		t.Errorf("Expected every query to be slow and the repeated query to be logged once, got [%v]", out)
+	}
+	if !strings.Contains(out, `"sql":"SELECT * FROM `+"`products`"+` WHERE id IN (?...) AND name = ?"`) ||
+		!strings.Contains(out, `"params":["int","int","string"]`) || !strings.Contains(out, `"request_id":"req-1"`) || strings.Contains(out, "secret") {
+		t.Errorf("Expected the query shape and the param types without the values, got [%v]", out)
+	}
+	if n := QueryStatsFromContext(ctx).Total(); n != 4 {
+		t.Errorf("Expected [4] queries of the request, got [%v]", n)
+	}
+}
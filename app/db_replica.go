@@ -0,0 +1,213 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QueryConsistency is the query param to read from the primary db, for example `GET /api/products?$consistency=strong`.
+const QueryConsistency = "$consistency"
+
+// newReplicaPolicy returns a dbresolver.Policy which only resolves to the healthy replicas.
+// The hosts must be in the same order as the replicas dialector, it is used for logging.
+// If all replicas are unhealthy, it resolves to the primary connection pool.
+func newReplicaPolicy(primary gorm.ConnPool, hosts []string) *replicaPolicy {
+	p := &replicaPolicy{
+		primary:  primary,
+		hosts:    hosts,
+		replicas: map[gorm.ConnPool]*replicaHealth{},
+		maxLag:   DB_REPLICA_MAX_LAG,
+		interval: DB_REPLICA_CHECK_INTERVAL,
+		stop:     make(chan struct{}),
+	}
+	p.probe = p.lag
+	return p
+}
+
+// replicaPolicy is a dbresolver.Policy which takes the replicas that fail the health check
+// or exceed the lag threshold out of rotation.
+type replicaPolicy struct {
+	mu       sync.RWMutex
+	primary  gorm.ConnPool
+	hosts    []string
+	pools    []gorm.ConnPool
+	replicas map[gorm.ConnPool]*replicaHealth
+	maxLag   time.Duration
+	interval time.Duration
+	once     sync.Once
+	stop     chan struct{}
+	probe    func(gorm.ConnPool) (time.Duration, error) // returns the replication lag of the replica, it is lag except on the tests
+}
+
+// replicaHealth is the last health check result of a replica.
+type replicaHealth struct {
+	Host      string        `json:"host"`
+	IsHealthy bool          `json:"is_healthy"`
+	Lag       time.Duration `json:"lag"`
+	Error     string        `json:"error,omitempty"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
+
+// Resolve implements dbresolver.Policy.
+// The replicas are registered on the first call since dbresolver doesn't expose its connection pools.
+func (p *replicaPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	p.register(connPools)
+
+	p.mu.RLock()
+	healthy := make([]gorm.ConnPool, 0, len(connPools))
+	for _, c := range connPools {
+		if h, ok := p.replicas[c]; ok && h.IsHealthy {
+			healthy = append(healthy, c)
+		}
+	}
+	p.mu.RUnlock()
+
+	if len(healthy) == 0 {
+		return p.primary
+	}
+	return healthy[rand.Intn(len(healthy))]
+}
+
+// register registers the unknown connection pools as healthy replicas and starts the health check.
+func (p *replicaPolicy) register(connPools []gorm.ConnPool) {
+	p.mu.RLock()
+	isRegistered := len(p.pools) >= len(connPools)
+	p.mu.RUnlock()
+	if isRegistered {
+		return
+	}
+
+	p.mu.Lock()
+	for i, c := range connPools {
+		if _, ok := p.replicas[c]; ok {
+			continue
+		}
+		host := "replica-" + strconv.Itoa(i)
+		if i < len(p.hosts) {
+			host = p.hosts[i]
+		}
+		p.pools = append(p.pools, c)
+		p.replicas[c] = &replicaHealth{Host: host, IsHealthy: true}
+	}
+	p.mu.Unlock()
+
+	p.once.Do(func() {
+		go p.run()
+	})
+}
+
+// run checks the replicas health periodically until Close is called.
+func (p *replicaPolicy) run() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	p.check()
+	for {
+		select {
+		case <-ticker.C:
+			p.check()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// check pings every replica and checks its replication lag, then takes the unhealthy ones out of rotation.
+func (p *replicaPolicy) check() {
+	p.mu.RLock()
+	pools := append([]gorm.ConnPool{}, p.pools...)
+	p.mu.RUnlock()
+
+	for _, c := range pools {
+		h := replicaHealth{CheckedAt: time.Now()}
+		lag, err := p.probe(c)
+		h.Lag = lag
+		h.IsHealthy = err == nil && lag <= p.maxLag
+		if err != nil {
+			h.Error = err.Error()
+		}
+
+		p.mu.Lock()
+		old := p.replicas[c]
+		h.Host = old.Host
+		if old.IsHealthy != h.IsHealthy {
+			if h.IsHealthy {
+				Logger().Module("db").Info().Str("host", h.Host).Dur("lag", h.Lag).Msg("DB replica is back in rotation.")
+			} else {
+				Logger().Module("db").Warn().Str("host", h.Host).Dur("lag", h.Lag).Str("error", h.Error).Msg("DB replica is taken out of rotation.")
+			}
+		}
+		p.replicas[c] = &h
+		p.mu.Unlock()
+	}
+}
+
+// lag pings the replica and returns its replication lag.
+// It returns an error if the replica is unreachable or the replication is not running.
+func (p *replicaPolicy) lag(c gorm.ConnPool) (time.Duration, error) {
+	sqlDB, ok := c.(*sql.DB)
+	if !ok {
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.interval)
+	defer cancel()
+	err := sqlDB.PingContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := sqlDB.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		rows, err = sqlDB.QueryContext(ctx, "SHOW SLAVE STATUS") // mysql < 8.0.22
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return 0, rows.Err() // not a replica, for example the replica is the primary itself
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	values := make([]sql.RawBytes, len(cols))
+	dest := make([]any, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	err = rows.Scan(dest...)
+	if err != nil {
+		return 0, err
+	}
+	for i, col := range cols {
+		if col != "Seconds_Behind_Source" && col != "Seconds_Behind_Master" {
+			continue
+		}
+		if values[i] == nil {
+			return 0, errors.New("replication is not running")
+		}
+		seconds, err := strconv.Atoi(strings.TrimSpace(string(values[i])))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, nil
+}
+
+// Close stops the health check.
+func (p *replicaPolicy) Close() {
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// fakeReplica is the connection pool of the replica on the tests, its health is set by the test.
+type fakeReplica struct {
+	gorm.ConnPool
+	name string
+}
+
+func TestReplicaPolicy(t *testing.T) {
+	primary := &fakeReplica{name: "primary"}
+	r1, r2 := &fakeReplica{name: "r1"}, &fakeReplica{name: "r2"}
+	pools := []gorm.ConnPool{r1, r2}
+
+	lags := map[gorm.ConnPool]time.Duration{}
+	errs := map[gorm.ConnPool]error{}
+	mu := sync.Mutex{}
+	set := func(c gorm.ConnPool, lag time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		lags[c], errs[c] = lag, err
+	}
+
+	p := newReplicaPolicy(primary, []string{"r1", "r2"})
+	p.interval = time.Hour // the health check is triggered by the test
+	p.maxLag = 10 * time.Second
+	p.probe = func(c gorm.ConnPool) (time.Duration, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return lags[c], errs[c]
+	}
+	defer p.Close()
+
+	resolved := func() map[string]int {
+		res := map[string]int{}
+		for i := 0; i < 100; i++ {
+			res[p.Resolve(pools).(*fakeReplica).name]++
+		}
+		return res
+	}
+
+	// the replicas are registered as healthy, the reads are spread over them
+	if res := resolved(); res["r1"] == 0 || res["r2"] == 0 || res["primary"] != 0 {
+		t.Errorf("Expected the reads to be spread over the replicas, got [%v]", res)
+	}
+
+	// the failed replica is taken out of rotation
+	set(r1, 0, errors.New("connection refused"))
+	p.check()
+	if res := resolved(); res["r1"] != 0 || res["r2"] != 100 {
+		t.Errorf("Expected the failed replica to be taken out of rotation, got [%v]", res)
+	}
+
+	// the lagging replica is taken out of rotation too, then the reads fail over to the primary
+	set(r2, time.Minute, nil)
+	p.check()
+	if res := resolved(); res["primary"] != 100 {
+		t.Errorf("Expected the reads to fail over to the primary, got [%v]", res)
+	}
+
+	// the recovered replica is back in rotation
+	set(r1, 0, nil)
+	p.check()
+	if res := resolved(); res["r1"] != 100 {
+		t.Errorf("Expected the recovered replica to be back in rotation, got [%v]", res)
+	}
+}
@@ -1,7 +1,9 @@
 package app
 
 import (
+	"fmt"
 	"net/http"
+	"runtime/debug"
 
 	"github.com/gofiber/fiber/v2"
 	"grest.dev/grest"
@@ -87,46 +89,70 @@ func (errorUtil) TraceSimple(err error) map[string]string {
 
 // Handler handles errors by processing them and returning an appropriate response.
 // It retrieves the language from the context (c) and assigns it to lang.
-// It checks if the error is an instance of grest.Error.
-// If it is not, it sets the error code and message based on the received error.
+// The original error is kept on the fiber locals so it is logged by the access log, the response is written from its copy.
+// If the error is not an instance of grest.Error, it sets the error code and message based on the received error.
 // If the error status code is not in the 4xx or 5xx range, it sets the code to http.StatusInternalServerError.
+// The server error is counted on its error group, see ErrorGroups().
 // If the error status code is http.StatusInternalServerError, it translates the error message and assigns it to e.Message.
 // It returns a JSON response with the error status code and body.
 func (errorUtil) Handler(c *fiber.Ctx, err error) error {
 	lang := "en"
-	ctx, ctxOK := c.Locals("ctx").(*Ctx)
+	ctx, ctxOK := c.Locals(CtxKey).(*Ctx)
 	if ctxOK {
 		lang = ctx.Lang
 	}
-	e, ok := err.(*grest.Error)
-	if !ok {
-		code := http.StatusInternalServerError
+	c.Locals(errorKey, err)
+	e := &grest.Error{}
+	if ge, ok := err.(*grest.Error); ok && ge != nil {
+		*e = *ge
+	} else {
+		e.Code = http.StatusInternalServerError
 		fiberError, isFiberError := err.(*fiber.Error)
 		if isFiberError {
-			code = fiberError.Code
+			e.Code = fiberError.Code
 		}
-		e.Code = code
 		e.Message = err.Error()
 	}
 	if e.StatusCode() < 400 || e.StatusCode() > 599 {
 		e.Code = http.StatusInternalServerError
 	}
+	if e.StatusCode() >= http.StatusInternalServerError {
+		ErrorGroups().CaptureError(c, err)
+	}
 	if e.StatusCode() == http.StatusInternalServerError {
 		e.Message = Translator().Trans(lang, "500_internal_error")
 		if e.Detail == nil {
-			e.Detail = map[string]string{"message": e.Error()}
+			e.Detail = map[string]string{"message": err.Error()}
 		}
 	}
 	return c.Status(e.StatusCode()).JSON(e.Body())
 }
 
 // Recover recovers from a panic during Fiber request processing.
-// It uses a defer statement to catch and recover from panics.
-// Inside the deferred function, there is a placeholder for saving logs and sending alerts.
+// The panic is logged with its stack trace, counted on its error group and alerted,
+// then it is returned as the internal server error so it is handled by the error handler.
+// The panic value and the stack trace are only on the server logs, the client only gets the request id to report it.
 func (errorUtil) Recover(c *fiber.Ctx) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			// todo: save log & send alert to telegram
+			requestID, _ := c.Locals(RequestIDKey).(string)
+			Logger().Module("http").Error().
+				Str("request_id", requestID).
+				Str("method", c.Method()).
+				Str("route", c.Route().Path).
+				Interface("panic", r).
+				Bytes("stack", debug.Stack()).
+				Msg("Panic is recovered.")
+			ErrorGroups().CapturePanic(c, r)
+			Alert().Raise(AlertEvent{
+				Key:      "panic:" + c.Method() + " " + c.Route().Path,
+				Source:   "http",
+				Severity: AlertCritical,
+				Title:    "Panic is recovered",
+				Message:  fmt.Sprint(r),
+				Fields:   map[string]string{"request_id": requestID, "method": c.Method(), "route": c.Route().Path},
+			})
+			err = Error().New(http.StatusInternalServerError, "panic is recovered", map[string]string{"request_id": requestID})
 		}
 	}()
 	return c.Next()
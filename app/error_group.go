@@ -0,0 +1,446 @@
+package app
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+// These are the status of the error group.
+const (
+	ErrorGroupStatusUnresolved = "unresolved"
+	ErrorGroupStatusResolved   = "resolved"
+	ErrorGroupStatusMuted      = "muted"
+)
+
+// ErrorGroups returns a pointer to the errorGroupUtil instance (errorGroups).
+// If errorGroups is not initialized, it creates a new errorGroupUtil instance, configures it, and assigns it to errorGroups.
+// It ensures that only one instance of errorGroupUtil is created and reused.
+func ErrorGroups() *errorGroupUtil {
+	if errorGroups == nil {
+		errorGroups = &errorGroupUtil{}
+		errorGroups.configure()
+	}
+	return errorGroups
+}
+
+// errorGroups is a pointer to an errorGroupUtil instance.
+// It is used to store and access the singleton instance of errorGroupUtil.
+var errorGroups *errorGroupUtil
+
+// errorGroupUtil aggregates the server errors by their fingerprint, so the errors which happen and how often are known without grepping the logs.
+// The errors are counted in memory and flushed to the error_groups table periodically by every instance,
+// so the error handler never waits for the database, see ERROR_GROUP_XXX.
+type errorGroupUtil struct {
+	IsEnabled     bool
+	FlushInterval time.Duration // 0 to disable the periodic flush, Flush must be called explicitly
+	MaxPending    int           // max distinct fingerprints between the flushes, the new fingerprints are dropped when it is reached
+
+	pending   map[string]*ErrorGroup
+	isDropped bool
+	mu        sync.Mutex
+	flushMu   sync.Mutex
+	once      sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// ErrorGroup is the aggregate of the server errors of the same fingerprint, the fingerprint is the id.
+// The fingerprint is the hash of the tenant, the error type, the message template (the message without the variable parts, see ErrorMessageTemplate)
+// and the stack top, so the same error of the different data is grouped together, and the tenant only sees its own errors.
+type ErrorGroup struct {
+	ID            string          `json:"id"                    gorm:"column:id;primaryKey;size:40"`
+	TenantID      string          `json:"tenant_id,omitempty"   gorm:"column:tenant_id;size:64;index"`
+	Type          string          `json:"type"                  gorm:"column:type;size:128"`
+	Message       string          `json:"message"               gorm:"column:message;type:text"`
+	StackTop      string          `json:"stack_top"             gorm:"column:stack_top;size:255"`
+	Status        string          `json:"status"                gorm:"column:status;size:16;index"`
+	Count         int64           `json:"count"                 gorm:"column:count"`
+	FirstSeenAt   time.Time       `json:"first_seen_at"         gorm:"column:first_seen_at"`
+	LastSeenAt    time.Time       `json:"last_seen_at"          gorm:"column:last_seen_at;index"`
+	SampleRequest json.RawMessage `json:"sample_request"        gorm:"column:sample_request;type:json"`
+	SampleTrace   json.RawMessage `json:"sample_trace"          gorm:"column:sample_trace;type:json"`
+	MutedUntil    *time.Time      `json:"muted_until,omitempty" gorm:"column:muted_until"`
+	ResolvedAt    *time.Time      `json:"resolved_at,omitempty" gorm:"column:resolved_at"`
+}
+
+// TableVersion returns the versions of the ErrorGroup table in the database.
+// Change this value with date format YY.MM.DDHHii when any table structure changes.
+func (ErrorGroup) TableVersion() string {
+	return "26.10.192000"
+}
+
+// TableName returns the name of the ErrorGroup table in the database.
+func (ErrorGroup) TableName() string {
+	return "error_groups"
+}
+
+// IsReopened reports whether the new occurrence at the time reopens the group, the resolved group is regressed
+// and the muted group is unmuted after its muted until.
+func (g ErrorGroup) IsReopened(at time.Time) bool {
+	switch g.Status {
+	case ErrorGroupStatusResolved:
+		return true
+	case ErrorGroupStatusMuted:
+		return g.MutedUntil != nil && at.After(*g.MutedUntil)
+	}
+	return false
+}
+
+// configure configures the error group utility instance based on the ERROR_GROUP_XXX environment variables.
+func (e *errorGroupUtil) configure() {
+	e.IsEnabled = ERROR_GROUP_ENABLED
+	e.FlushInterval = ERROR_GROUP_FLUSH_INTERVAL
+	e.MaxPending = ERROR_GROUP_MAX_PENDING
+}
+
+// CaptureError counts the server error of the request, it is called by the error handler.
+// The stack top and the sample trace are taken from Error().Trace, the error which is not a grest.Error has no stack.
+func (e *errorGroupUtil) CaptureError(c *fiber.Ctx, err error) {
+	frames := Error().Trace(err)
+	top := ""
+	if len(frames) > 0 {
+		top = stackTop(frames[0])
+	}
+	e.Capture(c, fmt.Sprintf("%T", err), err.Error(), top, frames)
+}
+
+// CapturePanic counts the recovered panic of the request, it must be called by the deferred func which recovers it,
+// so the stack of the panic is still available.
+func (e *errorGroupUtil) CapturePanic(c *fiber.Ctx, r any) {
+	frames := panicFrames()
+	top := ""
+	if len(frames) > 0 {
+		top = stackTop(frames[0])
+	}
+	e.Capture(c, fmt.Sprintf("panic %T", r), fmt.Sprint(r), top, frames)
+}
+
+// Capture counts the error of the fingerprint of the type, the message template and the stack top.
+// The request and the trace are kept as the sample of the group, the latest one wins. The error is captured once per request.
+func (e *errorGroupUtil) Capture(c *fiber.Ctx, errType, message, top string, frames any) {
+	if !e.IsEnabled {
+		return
+	}
+	if captured, _ := c.Locals(errorGroupKey).(bool); captured {
+		return
+	}
+	c.Locals(errorGroupKey, true)
+
+	template := ErrorMessageTemplate(message)
+	tenantID := ""
+	if ctx, ok := c.Locals(CtxKey).(*Ctx); ok {
+		tenantID = ctx.TenantID
+	}
+	now := time.Now().UTC()
+	sampleRequest, _ := json.Marshal(errorGroupSampleRequest(c))
+	sampleTrace, _ := json.Marshal(frames)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pending == nil {
+		e.pending = map[string]*ErrorGroup{}
+	}
+	id := ErrorFingerprint(tenantID, errType, template, top)
+	g, ok := e.pending[id]
+	if !ok {
+		if e.MaxPending > 0 && len(e.pending) >= e.MaxPending {
+			if !e.isDropped {
+				e.isDropped = true
+				Logger().Module("http").Warn().Int("ERROR_GROUP_MAX_PENDING", e.MaxPending).Msg("Too many error groups since the last flush, the new ones are dropped until the next flush.")
+			}
+			return
+		}
+		g = &ErrorGroup{
+			ID:          id,
+			TenantID:    tenantID,
+			Type:        truncate(errType, 128),
+			Message:     template,
+			StackTop:    truncate(top, 255),
+			Status:      ErrorGroupStatusUnresolved,
+			FirstSeenAt: now,
+		}
+		e.pending[id] = g
+	}
+	g.Count++
+	g.LastSeenAt = now
+	g.SampleRequest = sampleRequest
+	g.SampleTrace = sampleTrace
+	e.start()
+}
+
+// start starts the periodic flush on the first capture.
+func (e *errorGroupUtil) start() {
+	if e.FlushInterval <= 0 {
+		return
+	}
+	e.once.Do(func() {
+		e.stop = make(chan struct{})
+		e.done = make(chan struct{})
+		go e.run()
+	})
+}
+
+// run flushes the errors periodically until Close is called.
+func (e *errorGroupUtil) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.Flush()
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// Close stops the periodic flush and flushes the remaining errors, it is called on shutdown.
+func (e *errorGroupUtil) Close() {
+	e.mu.Lock()
+	stop, done := e.stop, e.done
+	e.stop = nil
+	e.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	e.Flush()
+}
+
+// Pending returns the copy of the errors which are not flushed yet.
+func (e *errorGroupUtil) Pending() []ErrorGroup {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	res := make([]ErrorGroup, 0, len(e.pending))
+	for _, g := range e.pending {
+		res = append(res, *g)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// Flush saves the counted errors to the error_groups table.
+// The new group and the reopened group (see ErrorGroup.IsReopened) raise the alert, the muted group doesn't.
+// The errors which are failed to save are counted again on the next flush.
+func (e *errorGroupUtil) Flush() {
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+
+	e.mu.Lock()
+	pending := e.pending
+	e.pending = nil
+	e.isDropped = false
+	e.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	tx, err := DB().Conn("main")
+	if err != nil {
+		Logger().Module("db").Error().Err(err).Msg("Failed to flush the error groups.")
+		e.restore(pending)
+		return
+	}
+	failed := map[string]*ErrorGroup{}
+	for id, g := range pending {
+		err = e.save(tx, *g)
+		if err != nil {
+			Logger().Module("db").Error().Err(err).Str("id", id).Msg("Failed to save the error group.")
+			failed[id] = g
+		}
+	}
+	e.restore(failed)
+}
+
+// save upserts the group, then reopens it and raises the alert if needed.
+// Both are written to the primary and decided by their affected rows, so the group which is flushed by several instances at once
+// is alerted once, by the instance which inserts or reopens it.
+func (e *errorGroupUtil) save(tx *gorm.DB, g ErrorGroup) error {
+	tx = tx.Clauses(dbresolver.Write)
+	res := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"count":          gorm.Expr("count + ?", g.Count),
+			"last_seen_at":   g.LastSeenAt,
+			"sample_request": g.SampleRequest,
+			"sample_trace":   g.SampleTrace,
+		}),
+	}).Create(&g)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	// mysql affects 1 row on insert and 2 rows on update of the existing row
+	title := "New error"
+	if res.RowsAffected != 1 {
+		title = "Error is reopened"
+		// the same condition as ErrorGroup.IsReopened, the group which is already unresolved is not affected
+		res = tx.Model(&ErrorGroup{}).
+			Where("id = ?", g.ID).
+			Where("status = ? OR (status = ? AND muted_until < ?)", ErrorGroupStatusResolved, ErrorGroupStatusMuted, g.LastSeenAt).
+			Updates(map[string]any{
+				"status":      ErrorGroupStatusUnresolved,
+				"muted_until": nil,
+				"resolved_at": nil,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+	}
+	Alert().Raise(AlertEvent{
+		Key:      "error_group:" + g.ID,
+		Source:   "http",
+		Severity: AlertWarning,
+		Title:    title + ": " + g.Type,
+		Message:  g.Message,
+		Fields:   map[string]string{"id": g.ID, "tenant_id": g.TenantID, "stack_top": g.StackTop, "count": fmt.Sprint(g.Count)},
+	})
+	return nil
+}
+
+// restore puts the groups which are not saved back to the pending ones.
+func (e *errorGroupUtil) restore(groups map[string]*ErrorGroup) {
+	if len(groups) == 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pending == nil {
+		e.pending = map[string]*ErrorGroup{}
+	}
+	for id, g := range groups {
+		p, ok := e.pending[id]
+		if !ok {
+			e.pending[id] = g
+			continue
+		}
+		p.Count += g.Count
+		p.FirstSeenAt = g.FirstSeenAt
+	}
+}
+
+// ErrorFingerprint returns the fingerprint of the tenant, the error type, the message template and the stack top.
+func ErrorFingerprint(tenantID, errType, template, top string) string {
+	h := sha1.Sum([]byte(tenantID + "\n" + errType + "\n" + template + "\n" + top))
+	return hex.EncodeToString(h[:])
+}
+
+// errorMessageVariables are the variable parts of the error message and their placeholders, in order.
+var errorMessageVariables = []struct {
+	pattern     *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "{uuid}"},
+	{regexp.MustCompile(`'[^']*'|"[^"]*"|` + "`[^`]*`"), "{str}"},
+	{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`), "{hex}"},
+	{regexp.MustCompile(`\b\d+(\.\d+)?\b`), "{num}"},
+}
+
+// ErrorMessageTemplate returns the message without the variable parts, for example the ids, the quoted values and the numbers,
+// so the same error of the different data has the same template.
+func ErrorMessageTemplate(message string) string {
+	for _, v := range errorMessageVariables {
+		message = v.pattern.ReplaceAllString(message, v.placeholder)
+	}
+	return message
+}
+
+// stackTop returns the function and the file of the stack frame, the line is omitted so the group survives the unrelated changes of the file.
+func stackTop(frame map[string]any) string {
+	name, file := "", ""
+	for _, k := range []string{"function", "func"} {
+		if v, ok := frame[k]; ok {
+			name = fmt.Sprint(v)
+			break
+		}
+	}
+	if v, ok := frame["file"]; ok {
+		file = path.Base(fmt.Sprint(v))
+	}
+	if name != "" || file != "" {
+		return strings.TrimSpace(name + " " + file)
+	}
+	keys := make([]string, 0, len(frame))
+	for k := range frame {
+		if k != "line" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+fmt.Sprint(frame[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// panicFrames returns the frames of the panicking goroutine from where the panic is raised, it must be called by the deferred func which recovers it.
+func panicFrames() []map[string]any {
+	pc := make([]uintptr, 64)
+	n := runtime.Callers(1, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	res := []map[string]any{}
+	isPanicking := false
+	for {
+		f, more := frames.Next()
+		if isPanicking && !strings.HasPrefix(f.Function, "runtime.") {
+			res = append(res, map[string]any{"file": f.File, "line": f.Line, "function": f.Function})
+			if len(res) >= 20 {
+				break
+			}
+		}
+		if f.Function == "runtime.gopanic" {
+			isPanicking = true
+		}
+		if !more {
+			break
+		}
+	}
+	return res
+}
+
+// errorGroupSampleRequest returns the sample request of the error group, the body and the headers are not kept since they may contain personal data.
+func errorGroupSampleRequest(c *fiber.Ctx) map[string]any {
+	requestID, _ := c.Locals(RequestIDKey).(string)
+	res := map[string]any{
+		"request_id": requestID,
+		"method":     c.Method(),
+		"path":       c.Path(),
+		"route":      c.Route().Path,
+	}
+	if ctx, ok := c.Locals(CtxKey).(*Ctx); ok {
+		res["user_id"] = ctx.UserID
+		res["tenant_id"] = ctx.TenantID
+	}
+	if sc := trace.SpanContextFromContext(c.UserContext()); sc.IsValid() {
+		res["trace_id"] = sc.TraceID().String()
+	}
+	return res
+}
+
+// truncate returns the first n bytes of the s.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
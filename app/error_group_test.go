@@ -0,0 +1,76 @@
+package app
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestErrorMessageTemplate(t *testing.T) {
+	cases := map[string]string{
+		"product 42 is not found":                                        "product {num} is not found",
+		"record 7f3c1a52-8b1e-4c7a-9a3e-2f6b8c1d0e9f is locked":          "record {uuid} is locked",
+		`Error 1062: Duplicate entry 'abc@mail.com' for key 'email'`:     "Error {num}: Duplicate entry {str} for key {str}",
+		"invalid memory address or nil pointer dereference at 0xc000123": "invalid memory address or nil pointer dereference at {hex}",
+	}
+	for message, expected := range cases {
+		if actual := ErrorMessageTemplate(message); actual != expected {
+			t.Errorf("Expected template of [%v] to be [%v], got [%v]", message, expected, actual)
+		}
+	}
+}
+
+func TestErrorGroups(t *testing.T) {
+	prev := errorGroups
+	errorGroups = &errorGroupUtil{IsEnabled: true}
+	defer func() { errorGroups = prev }()
+
+	f := fiber.New(fiber.Config{ErrorHandler: Error().Handler})
+	f.Use(Error().Recover)
+	f.Use(func(c *fiber.Ctx) error {
+		c.Locals(CtxKey, &Ctx{TenantID: c.Get("X-Tenant-ID")})
+		return c.Next()
+	})
+	f.Get("/items/:id", func(c *fiber.Ctx) error {
+		if c.Params("id") == "panic" {
+			var m map[string]int
+			m["a"] = 1
+		}
+		if c.Params("id") == "missing" {
+			return Error().New(fiber.StatusNotFound, "item is not found")
+		}
+		return Error().New(fiber.StatusInternalServerError, "failed to load item "+c.Params("id"))
+	})
+	for _, id := range []string{"1", "2", "3", "missing", "panic", "panic"} {
+		_, err := f.Test(httptest.NewRequest("GET", "/items/"+id, nil))
+		if err != nil {
+			t.Fatalf("Error occurred [%v]", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/items/4", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	_, err := f.Test(req)
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+
+	counts := map[string]int64{}
+	for _, g := range ErrorGroups().Pending() {
+		if g.TenantID == "acme" {
+			if g.Count != 1 || g.ID == ErrorFingerprint("", g.Type, g.Message, g.StackTop) {
+				t.Errorf("Expected the error of the tenant to be grouped separately, got [%+v]", g)
+			}
+			continue
+		}
+		counts[g.Message] = g.Count
+		if strings.HasPrefix(g.Type, "panic") && !strings.Contains(g.StackTop, "TestErrorGroups") {
+			t.Errorf("Expected the stack top of the panic to be the handler, got [%v]", g.StackTop)
+		}
+	}
+	if len(counts) != 2 || counts["failed to load item {num}"] != 3 || counts["assignment to entry in nil map"] != 2 {
+		t.Errorf("Expected the server errors to be grouped by the template, got [%v]", counts)
+	}
+}
@@ -2,20 +2,30 @@ package app
 
 import (
 	"context"
+	"errors"
 	"io"
-	"os"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/jinzhu/copier"
 	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // FS returns the instance of fsUtil (filesystem utility).
-// If the fsClient is nil, it initializes it by calling the configure method of fsUtil.
+// If the fsClient is nil, it initializes it with the driver of FS_DRIVER.
 // It then returns the fsClient.
 func FS() *fsUtil {
 	if fsClient == nil {
-		fsClient = fsUtil{}.configure()
+		fsClient = NewFS()
+
+		// the local and memory drivers sign the urls with hmac, the url would be forgeable with a known key
+		if _, isPresigned := fsClient.driver.(*s3FSDriver); !isPresigned {
+			if _, err := fsSigningKey(); err != nil {
+				Logger().Fatal().Err(err).Str("FS_DRIVER", fsClient.Driver).Send()
+			}
+		}
 	}
 	return fsClient
 }
@@ -24,157 +34,220 @@ func FS() *fsUtil {
 // It is used to access the filesystem utility throughout the package.
 var fsClient *fsUtil
 
-// fsUtil represents a filesystem utility.
-// It contains various fields to store configuration related to the filesystem.
-// It also has a field mClient of type minio.Client to interact with the any S3 compatible object storage server.
-// The type implements several methods to configure the filesystem and perform operations like uploading and deleting files.
-type fsUtil struct {
-	Driver        string
-	LocalDirPath  string
-	PublicDirPath string
-	EndPoint      string
-	Port          int
-	Region        string
-	BucketName    string
-	AccessKey     string
-	SecretKey     string
-	mClient       *minio.Client
-	ctx           context.Context
-	err           error
-}
-
-// configure configures the filesystem utility based on the provided environment variables.
-// It checks the FS_DRIVER environment variable to determine whether to use a local filesystem or a cloud storage like AWS S3.
-// If it's a local filesystem, it sets the necessary fields and returns the updated fsUtil.
-// If it's a cloud storage, it sets the fields required for the MinIO client, creates a bucket if it doesn't exist, and returns the updated fsUtil.
-// If any error occurs during configuration, it falls back to using the local filesystem.
-func (f fsUtil) configure() *fsUtil {
-	if FS_DRIVER == "local" {
-		return f.setLocalFS()
+// NewFS creates a new fsUtil instance with the registered driver of the name, or FS_DRIVER if the name is not provided.
+// The unregistered driver name is treated as an S3 compatible object storage, for example AWS S3, Google Cloud Storage, etc.
+// If the driver fails to be configured, it falls back to using the local filesystem.
+func NewFS(driver ...string) *fsUtil {
+	f := &fsUtil{Driver: FS_DRIVER}
+	if len(driver) > 0 {
+		f.Driver = driver[0]
 	}
 
-	// Cloud Storage Like AWS S3, Google Cloud Storage, etc
-	f.Driver = FS_DRIVER
-	f.EndPoint = FS_END_POINT
-	f.Port = FS_PORT
-	f.Region = FS_REGION
-	f.BucketName = FS_BUCKET_NAME
-	f.AccessKey = FS_ACCESS_KEY
-	f.SecretKey = FS_SECRET_KEY
-	f.mClient, f.err = minio.New(f.EndPoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(f.AccessKey, f.SecretKey, ""),
-		Secure: true,
-	})
-	if f.err != nil {
-		return f.setLocalFS()
+	fsDriversMu.RLock()
+	factory, ok := fsDrivers[f.Driver]
+	if !ok {
+		factory = fsDrivers["s3"]
 	}
-	f.ctx = context.Background()
-	isBucketExists := false
-	isBucketExists, f.err = f.mClient.BucketExists(f.ctx, f.BucketName)
-	if !isBucketExists {
-		f.err = f.mClient.MakeBucket(f.ctx, f.BucketName, minio.MakeBucketOptions{Region: f.Region})
-	}
-	if f.err != nil {
-		return f.setLocalFS()
+	fsDriversMu.RUnlock()
+
+	var err error
+	f.driver, err = factory()
+	if err != nil {
+		Logger().Module("fs").Error().Err(err).Str("FS_DRIVER", f.Driver).Msg("Failed to configure the filesystem driver, local filesystem will be used.")
+		f.Driver = "local"
+		f.driver, _ = newLocalFSDriver()
 	}
-	return &f
+	return f
 }
 
-// setLocalFS sets the fields of fsUtil to use a local filesystem.
-// It handles any error that occurred during configuration and logs an error message.
-// It sets the Driver to "local" and sets the local directory paths for storing files.
-// It also creates the local directory path if it doesn't exist.
-func (f fsUtil) setLocalFS() *fsUtil {
-	if f.err != nil {
-		Logger().Error().Msg(f.err.Error() + ", local filesystem will be used.")
-	}
-	f.Driver = "local"
-	f.LocalDirPath = FS_LOCAL_DIR_PATH
-	if f.LocalDirPath == "" {
-		f.LocalDirPath = "storages"
-	}
-	f.PublicDirPath = FS_PUBLIC_DIR_PATH
-	if f.PublicDirPath == "" {
-		f.PublicDirPath = "storages"
-	}
-	f.createLocalDirPath()
-	return &f
-}
-
-// createLocalDirPath creates the local directory path if it doesn't exist.
-// It checks if the directory path exists and creates it with the appropriate permissions if it doesn't.
-func (f fsUtil) createLocalDirPath() {
-	_, err := os.Stat(f.LocalDirPath)
-	if os.IsNotExist(err) {
-		err = os.Mkdir(f.LocalDirPath, 0755)
-		if err != nil {
-			Logger().Error().
-				Err(err).
-				Str("FS_DRIVER", FS_DRIVER).
-				Str("LocalDirPath", f.LocalDirPath).
-				Msg("Failed to create local dir path.")
-		}
+// fsUtil represents a filesystem utility.
+// It delegates the operations to the storage driver, so the files can be stored on the local filesystem,
+// any S3 compatible object storage server or the memory (for testing), see RegisterFSDriver.
+type fsUtil struct {
+	Driver string
+	driver FSDriver
+	prefix string // prepended to every file name, for example the tenant directory
+	ctx    context.Context
+}
+
+// FSDriver is the interface of the storage driver, the keys are the full file names including the directories.
+type FSDriver interface {
+	Upload(key string, src io.Reader, size int64, opt FileUploadOption) (FileUploadInfo, error)
+	Download(key string) (io.ReadCloser, error)
+	Delete(key string, opt FileDeleteOption) error
+	Exists(key string) (bool, error)
+	Stat(key string) (FileInfo, error)
+	List(prefix string) ([]FileInfo, error)
+	Copy(srcKey, dstKey string) error
+
+	// URL returns the download URL of the key,
+	// it is the public URL if the expiry is 0, otherwise it is signed and expires after the expiry.
+	URL(key string, expiry time.Duration) (string, error)
+
+	// UploadURL returns the signed URL to upload the key with PUT method until the expiry, restricted by the policy.
+	UploadURL(key string, expiry time.Duration, policy FileUploadPolicy) (string, error)
+}
+
+// fsDrivers is the registered storage drivers by name.
+var (
+	fsDrivers = map[string]func() (FSDriver, error){
+		"local":  newLocalFSDriver,
+		"s3":     newS3FSDriver,
+		"memory": newMemoryFSDriver,
 	}
+	fsDriversMu sync.RWMutex
+)
+
+// RegisterFSDriver registers the factory of the storage driver, so it can be used by FS_DRIVER or NewFS.
+// It must be called before the FS() is used, for example on the init of the package which implements the driver.
+func RegisterFSDriver(name string, factory func() (FSDriver, error)) {
+	fsDriversMu.Lock()
+	defer fsDriversMu.Unlock()
+	fsDrivers[name] = factory
+}
+
+// WithPrefix returns a copy of the filesystem utility which prepends the prefix to every file name.
+// It is used to store the files of each tenant on its own directory, see Ctx.FS().
+func (f *fsUtil) WithPrefix(prefix string) *fsUtil {
+	scoped := *f
+	scoped.prefix = f.prefix + prefix
+	return &scoped
+}
+
+// WithContext returns a copy of the filesystem utility which traces its operations as the child spans of the span on the ctx, see Ctx.FS().
+func (f *fsUtil) WithContext(ctx context.Context) *fsUtil {
+	scoped := *f
+	scoped.ctx = ctx
+	return &scoped
+}
+
+// startSpan starts the span of the storage operation of the key, see Trace().StartChild.
+func (f *fsUtil) startSpan(operation, key string) trace.Span {
+	_, span := Trace().StartChild(f.ctx, "fs."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("fs.driver", f.Driver),
+		attribute.String("fs.key", key),
+	))
+	return span
 }
 
 // GetFileUrl constructs and returns the URL for accessing a file.
 // It considers the configuration of the filesystem utility and the provided filename and path.
 // The resulting URL depends on the storage driver and the endpoint being used.
+// The files are private unless FS_IS_PUBLIC is true, so the URL is signed and expires after FS_URL_EXPIRY, see SignedURL.
 func (f *fsUtil) GetFileUrl(fileName string, path ...string) string {
-	res := APP_URL + "/" + f.PublicDirPath
-	if f.EndPoint == "s3.amazonaws.com" {
-		res = "https://" + f.BucketName + ".s3." + f.Region + ".amazonaws.com/"
-	} else if f.Driver != "local" {
-		res = "https://" + f.BucketName + "." + f.EndPoint + "/"
-	}
-
+	key := ""
 	for _, p := range path {
-		res += p + "/"
+		key += p + "/"
 	}
-	res += fileName
+	key += fileName
 
+	expiry := FS_URL_EXPIRY
+	if FS_IS_PUBLIC {
+		expiry = 0
+	}
+	res, err := f.driver.URL(f.prefix+key, expiry)
+	if err != nil {
+		Logger().Module("fs").Error().Err(err).Str("key", f.prefix+key).Msg("Failed to get the file url.")
+	}
 	return res
 }
 
+// SignedURL returns the time-limited download URL of the file key.
+// It is presigned by the S3 compatible storage, or signed with hmac and served by DownloadHandler.
+func (f *fsUtil) SignedURL(key string, expiry time.Duration) (string, error) {
+	return f.driver.URL(f.prefix+key, expiry)
+}
+
+// PresignedUploadURL returns the time-limited URL to upload the file key directly with PUT method, for example from the browser.
+// It is presigned by the S3 compatible storage, or signed with hmac and served by UploadHandler.
+// The content type and the max size of the policy are required, they are signed so the client can't change them.
+func (f *fsUtil) PresignedUploadURL(key string, expiry time.Duration, policy FileUploadPolicy) (string, error) {
+	if policy.ContentType == "" || policy.MaxSize <= 0 {
+		return "", errors.New("the content type and the max size of the upload policy are required")
+	}
+	return f.driver.UploadURL(f.prefix+key, expiry, policy)
+}
+
 // Upload uploads a file to the configured storage.
 // It takes the filename, source reader, file size, and optional upload options.
-// If the storage driver is local, it copies the file from the source reader to the local directory.
-// If it's a cloud storage, it uses the MinIO client to upload the file to the configured bucket.
+// The file is private unless FS_IS_PUBLIC is true.
 func (f *fsUtil) Upload(fileName string, src io.Reader, fileSize int64, opts ...FileUploadOption) (FileUploadInfo, error) {
-	if f.Driver == "local" {
-		dst, err := os.Create(f.LocalDirPath + "/" + fileName)
-		if err != nil {
-			return FileUploadInfo{}, nil
-		}
-		defer dst.Close()
-		_, err = io.Copy(dst, src)
-		return FileUploadInfo{}, err
-	}
-
-	opt := minio.PutObjectOptions{}
-	opt.UserMetadata = map[string]string{"x-amz-acl": "public-read"}
+	opt := FileUploadOption{}
 	if len(opts) > 0 {
-		copier.Copy(opt, opts[0])
+		opt = opts[0]
 	}
-	info, err := f.mClient.PutObject(f.ctx, f.BucketName, fileName, src, fileSize, opt)
-	return FileUploadInfo{info}, err
+	span := f.startSpan("upload", f.prefix+fileName)
+	info, err := f.driver.Upload(f.prefix+fileName, src, fileSize, opt)
+	Trace().End(span, err)
+	Metrics().ObserveFS(f.Driver, "upload", err)
+	return info, err
+}
+
+// Download returns the content of the file, the caller must close it.
+func (f *fsUtil) Download(fileName string) (io.ReadCloser, error) {
+	span := f.startSpan("download", f.prefix+fileName)
+	rc, err := f.driver.Download(f.prefix + fileName)
+	Trace().End(span, err)
+	return rc, err
 }
 
 // Delete deletes a file from the configured storage.
 // It takes the filename and optional delete options.
-// If the storage driver is local, it deletes the file from the local directory.
-// If it's a cloud storage, it uses the MinIO client to remove the file from the configured bucket.
 func (f *fsUtil) Delete(fileName string, opts ...FileDeleteOption) error {
-	if f.Driver == "local" {
-		return os.Remove(f.LocalDirPath + "/" + fileName)
+	opt := FileDeleteOption{}
+	if len(opts) > 0 {
+		opt = opts[0]
 	}
+	span := f.startSpan("delete", f.prefix+fileName)
+	err := f.driver.Delete(f.prefix+fileName, opt)
+	Trace().End(span, err)
+	Metrics().ObserveFS(f.Driver, "delete", err)
+	return err
+}
 
-	opt := minio.RemoveObjectOptions{}
-	opt.GovernanceBypass = true
-	if len(opts) > 0 {
-		copier.Copy(opt, opts[0])
+// Exists returns true if the file is exists.
+func (f *fsUtil) Exists(fileName string) (bool, error) {
+	span := f.startSpan("exists", f.prefix+fileName)
+	ok, err := f.driver.Exists(f.prefix + fileName)
+	Trace().End(span, err)
+	return ok, err
+}
+
+// Stat returns the info of the file, the key of the info is relative to the prefix.
+func (f *fsUtil) Stat(fileName string) (FileInfo, error) {
+	span := f.startSpan("stat", f.prefix+fileName)
+	info, err := f.driver.Stat(f.prefix + fileName)
+	Trace().End(span, err)
+	info.Key = strings.TrimPrefix(info.Key, f.prefix)
+	return info, err
+}
+
+// List returns the info of the files with the prefix (recursively), the keys are relative to the prefix of the filesystem utility.
+func (f *fsUtil) List(prefix string) ([]FileInfo, error) {
+	span := f.startSpan("list", f.prefix+prefix)
+	files, err := f.driver.List(f.prefix + prefix)
+	Trace().End(span, err)
+	for i := range files {
+		files[i].Key = strings.TrimPrefix(files[i].Key, f.prefix)
 	}
-	return f.mClient.RemoveObject(f.ctx, f.BucketName, fileName, opt)
+	return files, err
+}
+
+// Copy copies the file to the destination file name.
+func (f *fsUtil) Copy(srcFileName, dstFileName string) error {
+	span := f.startSpan("copy", f.prefix+srcFileName)
+	err := f.driver.Copy(f.prefix+srcFileName, f.prefix+dstFileName)
+	Trace().End(span, err)
+	return err
+}
+
+// FileInfo is the info of the stored file.
+type FileInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"content_type"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
 }
 
 // This type represents the options for file upload.
@@ -183,10 +256,18 @@ type FileUploadOption struct {
 	minio.PutObjectOptions
 }
 
+// FileUploadPolicy restricts the upload with the presigned upload URL, see PresignedUploadURL.
+type FileUploadPolicy struct {
+	ContentType string // the content type the client must send
+	MaxSize     int64  // the max size of the body in bytes
+}
+
 // FileUploadOption represents the information related to a file upload.
 // It embeds minio.UploadInfo to store upload-specific details.
+// The ContentType is the stored content type, it is detected from the extension or the content if it is not provided on the upload.
 type FileUploadInfo struct {
 	minio.UploadInfo
+	ContentType string
 }
 
 // FileDeleteOption represents the options for file deletion.
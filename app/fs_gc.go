@@ -0,0 +1,214 @@
+package app
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FileGC returns a pointer to the fileGCUtil instance (fileGC).
+// If fileGC is not initialized, it creates a new fileGCUtil instance, configures it, and assigns it to fileGC.
+// It ensures that only one instance of fileGCUtil is created and reused.
+func FileGC() *fileGCUtil {
+	if fileGC == nil {
+		fileGC = &fileGCUtil{}
+		fileGC.configure()
+	}
+	return fileGC
+}
+
+// fileGC is a pointer to a fileGCUtil instance.
+// It is used to store and access the singleton instance of fileGCUtil.
+var fileGC *fileGCUtil
+
+// fileGCUtil represents the garbage collector of the stored files.
+// It collects the stored objects which are not referenced by any metadata row (for example the abandoned uploads),
+// and the metadata rows which have no object behind them or whose owner is hard purged.
+// Only the objects under the prefixes of the registered referrers are collected, so the other objects on the storage are kept.
+type fileGCUtil struct {
+	GracePeriod time.Duration
+	IsDryRun    bool
+	referrers   []FileReferrer
+	mu          sync.RWMutex
+	runMu       sync.Mutex
+}
+
+// FileReferrer is the metadata table which references the stored objects, see FileGC().Register.
+type FileReferrer struct {
+	Name     string
+	Prefixes []string // the prefixes of the keys relative to the tenant directory which are managed by the table, for example "files/"
+
+	// References returns the references of all live rows of all tenants, the soft deleted rows don't reference their objects.
+	References func(tx *gorm.DB) ([]FileReference, error)
+
+	// Delete deletes the row whose object is missing or whose owner is hard purged.
+	Delete func(tx *gorm.DB, ref FileReference) error
+}
+
+// FileReference is the metadata row which references the stored objects.
+type FileReference struct {
+	Referrer  string    `json:"referrer"`
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	Keys      []string  `json:"keys"` // relative to the tenant directory, the first one is the main object which must exist
+	CreatedAt time.Time `json:"created_at"`
+	IsOrphan  bool      `json:"is_orphan"` // the row is not needed anymore, for example its owner is hard purged
+}
+
+// FileGCReport is the report of the garbage collection, nothing is deleted if IsDryRun is true.
+type FileGCReport struct {
+	IsDryRun         bool            `json:"is_dry_run"`
+	OrphanObjects    []FileInfo      `json:"orphan_objects"`
+	MissingObjects   []FileReference `json:"missing_objects"`   // the rows which have no object behind them
+	OrphanReferences []FileReference `json:"orphan_references"` // the rows whose owner is hard purged
+	DeletedObjects   int             `json:"deleted_objects"`
+	DeletedRows      int             `json:"deleted_rows"`
+}
+
+// configure configures the file garbage collector instance based on the FS_GC_XXX environment variables.
+func (g *fileGCUtil) configure() {
+	g.GracePeriod = FS_GC_GRACE_PERIOD
+	g.IsDryRun = FS_GC_IS_DRY_RUN
+}
+
+// Register registers the metadata table which references the stored objects.
+func (g *fileGCUtil) Register(r FileReferrer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.referrers = append(g.referrers, r)
+}
+
+// Collect runs the garbage collection with the configured dry-run mode, it is called periodically by the scheduler.
+func (g *fileGCUtil) Collect() {
+	g.Run(g.IsDryRun)
+}
+
+// Run collects the orphan objects and rows which are older than the grace period, then deletes them unless isDryRun is true.
+// The grace period protects the uploads which are in progress, for example the object is stored before its row is committed.
+// The objects of the storage are listed at once, so it is meant to run periodically off-peak.
+func (g *fileGCUtil) Run(isDryRun bool) (FileGCReport, error) {
+	if !g.runMu.TryLock() {
+		return FileGCReport{IsDryRun: isDryRun}, nil // the previous run is still running
+	}
+	defer g.runMu.Unlock()
+
+	tx, err := DB().Conn("main")
+	if err != nil {
+		Logger().Module("fs").Error().Err(err).Msg("Failed to collect the orphan files.")
+		return FileGCReport{IsDryRun: isDryRun}, err
+	}
+	report, err := g.run(tx, FS(), isDryRun)
+	if err != nil {
+		Logger().Module("fs").Error().Err(err).Msg("Failed to collect the orphan files.")
+		return report, err
+	}
+	Logger().Module("fs").Info().
+		Bool("is_dry_run", report.IsDryRun).
+		Int("orphan_objects", len(report.OrphanObjects)).
+		Int("missing_objects", len(report.MissingObjects)).
+		Int("orphan_references", len(report.OrphanReferences)).
+		Int("deleted_objects", report.DeletedObjects).
+		Int("deleted_rows", report.DeletedRows).
+		Msg("The orphan files are collected.")
+	return report, nil
+}
+
+// run collects the orphan objects of the fs and the orphan rows of the tx.
+func (g *fileGCUtil) run(tx *gorm.DB, fs *fsUtil, isDryRun bool) (FileGCReport, error) {
+	report := FileGCReport{IsDryRun: isDryRun, OrphanObjects: []FileInfo{}, MissingObjects: []FileReference{}, OrphanReferences: []FileReference{}}
+	threshold := time.Now().Add(-g.GracePeriod)
+
+	g.mu.RLock()
+	referrers := append([]FileReferrer{}, g.referrers...)
+	g.mu.RUnlock()
+	if len(referrers) == 0 {
+		return report, nil
+	}
+
+	objects, err := fs.List("")
+	if err != nil {
+		return report, err
+	}
+	isStored := map[string]bool{}
+	for _, o := range objects {
+		isStored[o.Key] = true
+	}
+
+	// the keys of the live rows are referenced, except the orphan rows
+	isReferenced := map[string]bool{}
+	prefixes := []string{}
+	for _, r := range referrers {
+		prefixes = append(prefixes, r.Prefixes...)
+		refs, err := r.References(tx)
+		if err != nil {
+			return report, err
+		}
+		for _, ref := range refs {
+			ref.Referrer = r.Name
+			if ref.IsOrphan {
+				report.OrphanReferences = append(report.OrphanReferences, ref)
+				continue
+			}
+			for _, key := range ref.Keys {
+				isReferenced[Tenant().Path(ref.TenantID, key)] = true
+			}
+			if len(ref.Keys) > 0 && !isStored[Tenant().Path(ref.TenantID, ref.Keys[0])] && ref.CreatedAt.Before(threshold) {
+				report.MissingObjects = append(report.MissingObjects, ref)
+			}
+		}
+	}
+
+	// the unreferenced objects under the managed prefixes are orphan
+	for _, o := range objects {
+		_, fileName := Tenant().SplitPath(o.Key)
+		if isReferenced[o.Key] || !o.LastModified.Before(threshold) || !hasAnyPrefix(fileName, prefixes) {
+			continue
+		}
+		report.OrphanObjects = append(report.OrphanObjects, o)
+	}
+
+	if isDryRun {
+		for _, o := range report.OrphanObjects {
+			Logger().Module("fs").Info().Str("key", o.Key).Int64("size", o.Size).Time("last_modified", o.LastModified).Msg("The orphan object would be deleted.")
+		}
+		for _, ref := range append(report.MissingObjects, report.OrphanReferences...) {
+			Logger().Module("fs").Info().Str("referrer", ref.Referrer).Str("id", ref.ID).Str("tenant_id", ref.TenantID).Strs("keys", ref.Keys).Bool("is_orphan", ref.IsOrphan).Msg("The orphan row would be deleted.")
+		}
+		return report, nil
+	}
+
+	for _, o := range report.OrphanObjects {
+		err = fs.Delete(o.Key)
+		if err != nil {
+			Logger().Module("fs").Error().Err(err).Str("key", o.Key).Msg("Failed to delete the orphan object.")
+			continue
+		}
+		report.DeletedObjects++
+	}
+	for _, ref := range append(report.MissingObjects, report.OrphanReferences...) {
+		for _, r := range referrers {
+			if r.Name != ref.Referrer {
+				continue
+			}
+			err = r.Delete(tx, ref)
+			if err != nil {
+				Logger().Module("fs").Error().Err(err).Str("referrer", ref.Referrer).Str("id", ref.ID).Msg("Failed to delete the orphan row.")
+				continue
+			}
+			report.DeletedRows++
+		}
+	}
+	return report, nil
+}
+
+// hasAnyPrefix returns true if the s starts with one of the prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
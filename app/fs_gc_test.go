@@ -0,0 +1,85 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestFileGC(t *testing.T) {
+	fs := NewFS("memory")
+	for _, key := range []string{
+		"files/a.pdf",                  // referenced
+		"files/b.pdf",                  // abandoned upload
+		"tenants/acme/files/c.pdf",     // referenced by the tenant
+		"tenants/acme/files/a.pdf",     // the same key of another tenant is not referenced
+		"tenants/acme/files/owner.pdf", // referenced by the orphan row
+		"assets/logo.png",              // not managed by any referrer
+	} {
+		fs.Upload(key, strings.NewReader("x"), 1)
+	}
+
+	deleted := []string{}
+	old := time.Now().Add(-48 * time.Hour)
+	g := &fileGCUtil{}
+	g.Register(FileReferrer{
+		Name:     "files",
+		Prefixes: []string{"files/"},
+		References: func(tx *gorm.DB) ([]FileReference, error) {
+			return []FileReference{
+				{ID: "1", Keys: []string{"files/a.pdf"}, CreatedAt: old},
+				{ID: "2", TenantID: "acme", Keys: []string{"files/c.pdf"}, CreatedAt: old},
+				{ID: "3", Keys: []string{"files/missing.pdf"}, CreatedAt: old},
+				{ID: "4", Keys: []string{"files/uploading.pdf"}, CreatedAt: time.Now()},
+				{ID: "5", TenantID: "acme", Keys: []string{"files/owner.pdf"}, CreatedAt: old, IsOrphan: true},
+			}, nil
+		},
+		Delete: func(tx *gorm.DB, ref FileReference) error {
+			deleted = append(deleted, ref.ID)
+			return nil
+		},
+	})
+
+	report, err := g.run(nil, fs, true)
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	keys := []string{}
+	for _, o := range report.OrphanObjects {
+		keys = append(keys, o.Key)
+	}
+	expected := "files/b.pdf,tenants/acme/files/a.pdf,tenants/acme/files/owner.pdf"
+	if strings.Join(keys, ",") != expected {
+		t.Errorf("Expected orphan objects [%v], got [%v]", expected, keys)
+	}
+	if len(report.MissingObjects) != 1 || report.MissingObjects[0].ID != "3" {
+		t.Errorf("Expected missing object of row 3, got [%+v]", report.MissingObjects)
+	}
+	if len(report.OrphanReferences) != 1 || report.OrphanReferences[0].ID != "5" {
+		t.Errorf("Expected orphan row 5, got [%+v]", report.OrphanReferences)
+	}
+	if isExists, _ := fs.Exists("files/b.pdf"); !isExists || len(deleted) != 0 {
+		t.Errorf("Expected nothing to be deleted on dry run")
+	}
+
+	report, err = g.run(nil, fs, false)
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	if report.DeletedObjects != 3 || report.DeletedRows != 2 || strings.Join(deleted, ",") != "3,5" {
+		t.Errorf("Expected 3 objects and rows [3 5] to be deleted, got [%+v] [%v]", report, deleted)
+	}
+	files, _ := fs.List("")
+	if len(files) != 3 {
+		t.Errorf("Expected 3 files to be kept, got [%+v]", files)
+	}
+
+	g.GracePeriod = time.Hour
+	fs.Upload("files/new.pdf", strings.NewReader("x"), 1)
+	report, _ = g.run(nil, fs, true)
+	if len(report.OrphanObjects) != 0 {
+		t.Errorf("Expected the object younger than the grace period to be kept, got [%+v]", report.OrphanObjects)
+	}
+}
@@ -0,0 +1,308 @@
+package app
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrInvalidFileKey is returned when the file key is empty, absolute or escapes the storage directory.
+var ErrInvalidFileKey = errors.New("invalid file key")
+
+// localTempFilePrefix is the prefix of the temporary files of the uploads which are not complete yet.
+const localTempFilePrefix = ".upload-"
+
+// localContentTypeFilePrefix is the prefix of the file next to the uploaded file which keeps its content type,
+// so the file is served with the content type of the upload instead of the one of its extension.
+const localContentTypeFilePrefix = ".content-type-"
+
+// newLocalFSDriver returns the storage driver of the local filesystem, the files are stored on FS_LOCAL_DIR_PATH.
+func newLocalFSDriver() (FSDriver, error) {
+	d := &localFSDriver{
+		DirPath:       FS_LOCAL_DIR_PATH,
+		PublicDirPath: FS_PUBLIC_DIR_PATH,
+	}
+	if d.DirPath == "" {
+		d.DirPath = "storages"
+	}
+	if d.PublicDirPath == "" {
+		d.PublicDirPath = "storages"
+	}
+	d.createDirPath()
+	return d, nil
+}
+
+// localFSDriver is the storage driver of the local filesystem.
+// The private files are served by the signed URLs, see DownloadHandler.
+type localFSDriver struct {
+	DirPath       string
+	PublicDirPath string
+}
+
+// createDirPath creates the local directory path if it doesn't exist.
+func (d *localFSDriver) createDirPath() {
+	_, err := os.Stat(d.DirPath)
+	if os.IsNotExist(err) {
+		err = os.Mkdir(d.DirPath, 0755)
+		if err != nil {
+			Logger().Module("fs").Error().
+				Err(err).
+				Str("FS_DRIVER", FS_DRIVER).
+				Str("DirPath", d.DirPath).
+				Msg("Failed to create local dir path.")
+		}
+	}
+}
+
+// path returns the path of the key on the local directory.
+// The key must be relative and can't escape the local directory, for example "../etc/passwd" is rejected with ErrInvalidFileKey.
+// The internal files of the driver can't be accessed by the key.
+func (d *localFSDriver) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) || isLocalInternalFile(filepath.Base(clean)) {
+		return "", ErrInvalidFileKey
+	}
+	p := filepath.Join(d.DirPath, clean)
+	rel, err := filepath.Rel(d.DirPath, p)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", ErrInvalidFileKey
+	}
+	return p, nil
+}
+
+// Upload writes the file from the source reader to the local directory atomically,
+// it is written to a temporary file on the same directory which is renamed to the key after it is complete,
+// so the readers never see a partially written file. The nested directories of the key are created.
+// If the size is not negative, the written size must be equal to it.
+func (d *localFSDriver) Upload(key string, src io.Reader, size int64, opt FileUploadOption) (FileUploadInfo, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return FileUploadInfo{}, err
+	}
+	err = os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return FileUploadInfo{}, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), localTempFilePrefix+"*")
+	if err != nil {
+		return FileUploadInfo{}, err
+	}
+	defer os.Remove(tmp.Name()) // no-op after it is renamed
+
+	// the content type is sniffed from the head if it is not provided and can't be detected from the extension
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		tmp.Close()
+		return FileUploadInfo{}, err
+	}
+	head = head[:n]
+	contentType := opt.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(key))
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(head)
+	}
+
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmp, md5Hash, sha256Hash), io.MultiReader(bytes.NewReader(head), src))
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("the written size %d is not equal to the size %d", written, size)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), p)
+	}
+	if err == nil {
+		err = os.WriteFile(contentTypePath(p), []byte(contentType), 0644)
+	}
+	if err != nil {
+		return FileUploadInfo{}, err
+	}
+
+	info := FileUploadInfo{ContentType: contentType}
+	info.Key = key
+	info.Size = written
+	info.ETag = hex.EncodeToString(md5Hash.Sum(nil))
+	info.ChecksumSHA256 = base64.StdEncoding.EncodeToString(sha256Hash.Sum(nil))
+	info.LastModified = time.Now().UTC()
+	return info, nil
+}
+
+// Download opens the file of the local directory.
+func (d *localFSDriver) Download(key string) (io.ReadCloser, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// Delete deletes the file and its content type from the local directory.
+func (d *localFSDriver) Delete(key string, opt FileDeleteOption) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(contentTypePath(p))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Exists returns true if the file is exists on the local directory.
+func (d *localFSDriver) Exists(key string) (bool, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return false, err
+	}
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil && !fi.IsDir(), err
+}
+
+// Stat returns the info of the file of the local directory, the content type is the one of the upload,
+// otherwise it is detected from the extension or sniffed from the head.
+func (d *localFSDriver) Stat(key string) (FileInfo, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return FileInfo{Key: key}, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return FileInfo{Key: key}, err
+	}
+	if fi.IsDir() {
+		return FileInfo{Key: key}, os.ErrNotExist
+	}
+	info := d.fileInfo(p, key, fi)
+	if info.ContentType == "" {
+		if f, err := os.Open(p); err == nil {
+			head := make([]byte, 512)
+			n, _ := io.ReadFull(f, head)
+			f.Close()
+			info.ContentType = http.DetectContentType(head[:n])
+		}
+	}
+	return info, nil
+}
+
+// List returns the info of the files with the prefix, recursively, sorted by key. The internal files of the driver are skipped.
+func (d *localFSDriver) List(prefix string) ([]FileInfo, error) {
+	files := []FileInfo{}
+	root := d.DirPath
+	if dir := path.Dir(prefix); prefix != "" && dir != "." {
+		p, err := d.path(dir)
+		if err != nil {
+			return files, err
+		}
+		root = p
+	}
+	err := filepath.WalkDir(root, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if e.IsDir() || isLocalInternalFile(e.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(d.DirPath, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, d.fileInfo(p, key, fi))
+		return nil
+	})
+	return files, err
+}
+
+// Copy copies the file with its content type to the destination key on the local directory.
+func (d *localFSDriver) Copy(srcKey, dstKey string) error {
+	info, err := d.Stat(srcKey)
+	if err != nil {
+		return err
+	}
+	src, err := d.Download(srcKey)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	opt := FileUploadOption{}
+	opt.ContentType = info.ContentType
+	_, err = d.Upload(dstKey, src, -1, opt)
+	return err
+}
+
+// URL returns the public URL of the key if the expiry is 0, otherwise the hmac signed URL served by the FS_SIGNED_URL_PATH route.
+func (d *localFSDriver) URL(key string, expiry time.Duration) (string, error) {
+	if expiry <= 0 {
+		return APP_URL + "/" + d.PublicDirPath + "/" + key, nil
+	}
+	return signURL("GET", key, expiry, FileUploadPolicy{})
+}
+
+// UploadURL returns the hmac signed upload URL served by the FS_SIGNED_URL_PATH route.
+func (d *localFSDriver) UploadURL(key string, expiry time.Duration, policy FileUploadPolicy) (string, error) {
+	return signURL("PUT", key, expiry, policy)
+}
+
+// fileInfo returns the FileInfo of the os.FileInfo of the path p,
+// the content type is the one of the upload, or the one of the extension for the files which are not uploaded by the driver.
+func (*localFSDriver) fileInfo(p, key string, fi os.FileInfo) FileInfo {
+	contentType := mime.TypeByExtension(filepath.Ext(key))
+	if b, err := os.ReadFile(contentTypePath(p)); err == nil && len(b) > 0 {
+		contentType = string(b)
+	}
+	return FileInfo{
+		Key:          key,
+		Size:         fi.Size(),
+		ContentType:  contentType,
+		LastModified: fi.ModTime().UTC(),
+	}
+}
+
+// contentTypePath returns the path of the file which keeps the content type of the file of the path p.
+func contentTypePath(p string) string {
+	return filepath.Join(filepath.Dir(p), localContentTypeFilePrefix+filepath.Base(p))
+}
+
+// isLocalInternalFile returns true if the file name is the internal file of the driver, for example the temporary file of the upload.
+func isLocalInternalFile(name string) bool {
+	return strings.HasPrefix(name, localTempFilePrefix) || strings.HasPrefix(name, localContentTypeFilePrefix)
+}
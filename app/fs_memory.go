@@ -0,0 +1,145 @@
+package app
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// newMemoryFSDriver returns the storage driver which keeps the files in memory, it is used for testing without disk or network.
+func newMemoryFSDriver() (FSDriver, error) {
+	return &memoryFSDriver{files: map[string]memoryFile{}}, nil
+}
+
+// memoryFSDriver is the in-memory storage driver, the files are lost when the instance is stopped.
+type memoryFSDriver struct {
+	mu    sync.RWMutex
+	files map[string]memoryFile
+}
+
+// memoryFile is the file of the memory storage driver.
+type memoryFile struct {
+	data []byte
+	info FileInfo
+}
+
+// Upload reads the file from the source reader into memory.
+func (d *memoryFSDriver) Upload(key string, src io.Reader, size int64, opt FileUploadOption) (FileUploadInfo, error) {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return FileUploadInfo{}, err
+	}
+	sum := md5.Sum(data)
+	info := FileInfo{
+		Key:          key,
+		Size:         int64(len(data)),
+		ContentType:  opt.ContentType,
+		ETag:         hex.EncodeToString(sum[:]),
+		LastModified: time.Now().UTC(),
+	}
+	if info.ContentType == "" {
+		info.ContentType = mime.TypeByExtension(filepath.Ext(key))
+	}
+	if info.ContentType == "" {
+		info.ContentType = http.DetectContentType(data)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.files[key] = memoryFile{data: data, info: info}
+	uploadInfo := minio.UploadInfo{Key: key, Size: info.Size, ETag: info.ETag, LastModified: info.LastModified}
+	return FileUploadInfo{UploadInfo: uploadInfo, ContentType: info.ContentType}, nil
+}
+
+// Download returns the content of the file.
+func (d *memoryFSDriver) Download(key string) (io.ReadCloser, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	f, ok := d.files[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(f.data)), nil
+}
+
+// Delete deletes the file.
+func (d *memoryFSDriver) Delete(key string, opt FileDeleteOption) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.files[key]; !ok {
+		return os.ErrNotExist
+	}
+	delete(d.files, key)
+	return nil
+}
+
+// Exists returns true if the file is exists.
+func (d *memoryFSDriver) Exists(key string) (bool, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	_, ok := d.files[key]
+	return ok, nil
+}
+
+// Stat returns the info of the file.
+func (d *memoryFSDriver) Stat(key string) (FileInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	f, ok := d.files[key]
+	if !ok {
+		return FileInfo{Key: key}, os.ErrNotExist
+	}
+	return f.info, nil
+}
+
+// List returns the info of the files with the prefix, sorted by key.
+func (d *memoryFSDriver) List(prefix string) ([]FileInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	files := []FileInfo{}
+	for key, f := range d.files {
+		if strings.HasPrefix(key, prefix) {
+			files = append(files, f.info)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Key < files[j].Key })
+	return files, nil
+}
+
+// Copy copies the file to the destination key.
+func (d *memoryFSDriver) Copy(srcKey, dstKey string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f, ok := d.files[srcKey]
+	if !ok {
+		return os.ErrNotExist
+	}
+	f.info.Key = dstKey
+	f.info.LastModified = time.Now().UTC()
+	d.files[dstKey] = f
+	return nil
+}
+
+// URL returns the hmac signed URL served by the FS_SIGNED_URL_PATH route, the files in memory can't be public.
+func (d *memoryFSDriver) URL(key string, expiry time.Duration) (string, error) {
+	if expiry <= 0 {
+		expiry = FS_URL_EXPIRY
+	}
+	return signURL("GET", key, expiry, FileUploadPolicy{})
+}
+
+// UploadURL returns the hmac signed upload URL served by the FS_SIGNED_URL_PATH route.
+func (d *memoryFSDriver) UploadURL(key string, expiry time.Duration, policy FileUploadPolicy) (string, error) {
+	return signURL("PUT", key, expiry, policy)
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/jinzhu/copier"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// newS3FSDriver returns the storage driver of any S3 compatible object storage server, using the minio client.
+// It creates the bucket if it doesn't exist.
+func newS3FSDriver() (FSDriver, error) {
+	d := &s3FSDriver{
+		EndPoint:   FS_END_POINT,
+		Port:       FS_PORT,
+		Region:     FS_REGION,
+		BucketName: FS_BUCKET_NAME,
+		ctx:        context.Background(),
+	}
+	var err error
+	d.mClient, err = minio.New(d.EndPoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(FS_ACCESS_KEY, FS_SECRET_KEY, ""),
+		Secure: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	isBucketExists, err := d.mClient.BucketExists(d.ctx, d.BucketName)
+	if err == nil && !isBucketExists {
+		err = d.mClient.MakeBucket(d.ctx, d.BucketName, minio.MakeBucketOptions{Region: d.Region})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// s3FSDriver is the storage driver of any S3 compatible object storage server like AWS S3, Google Cloud Storage, etc.
+type s3FSDriver struct {
+	EndPoint   string
+	Port       int
+	Region     string
+	BucketName string
+	mClient    *minio.Client
+	ctx        context.Context
+}
+
+// Upload uploads the file to the bucket, it is uploaded with public-read acl if FS_IS_PUBLIC is true.
+func (d *s3FSDriver) Upload(key string, src io.Reader, size int64, opt FileUploadOption) (FileUploadInfo, error) {
+	o := minio.PutObjectOptions{}
+	copier.Copy(&o, &opt.PutObjectOptions)
+	if FS_IS_PUBLIC {
+		if o.UserMetadata == nil {
+			o.UserMetadata = map[string]string{}
+		}
+		o.UserMetadata["x-amz-acl"] = "public-read"
+	}
+	if o.ContentType == "" {
+		o.ContentType = mime.TypeByExtension(filepath.Ext(key))
+	}
+	info, err := d.mClient.PutObject(d.ctx, d.BucketName, key, src, size, o)
+	return FileUploadInfo{UploadInfo: info, ContentType: o.ContentType}, err
+}
+
+// Download returns the object of the bucket.
+func (d *s3FSDriver) Download(key string) (io.ReadCloser, error) {
+	return d.mClient.GetObject(d.ctx, d.BucketName, key, minio.GetObjectOptions{})
+}
+
+// Delete removes the object from the bucket.
+func (d *s3FSDriver) Delete(key string, opt FileDeleteOption) error {
+	o := minio.RemoveObjectOptions{}
+	o.GovernanceBypass = true
+	copier.Copy(&o, &opt.RemoveObjectOptions)
+	return d.mClient.RemoveObject(d.ctx, d.BucketName, key, o)
+}
+
+// Exists returns true if the object is exists on the bucket.
+func (d *s3FSDriver) Exists(key string) (bool, error) {
+	_, err := d.mClient.StatObject(d.ctx, d.BucketName, key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Stat returns the info of the object.
+func (d *s3FSDriver) Stat(key string) (FileInfo, error) {
+	oi, err := d.mClient.StatObject(d.ctx, d.BucketName, key, minio.StatObjectOptions{})
+	if err != nil {
+		return FileInfo{Key: key}, err
+	}
+	return d.fileInfo(oi), nil
+}
+
+// List returns the info of the objects with the prefix, recursively.
+func (d *s3FSDriver) List(prefix string) ([]FileInfo, error) {
+	files := []FileInfo{}
+	for oi := range d.mClient.ListObjects(d.ctx, d.BucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if oi.Err != nil {
+			return files, oi.Err
+		}
+		files = append(files, d.fileInfo(oi))
+	}
+	return files, nil
+}
+
+// Copy copies the object to the destination key on the same bucket.
+func (d *s3FSDriver) Copy(srcKey, dstKey string) error {
+	_, err := d.mClient.CopyObject(d.ctx,
+		minio.CopyDestOptions{Bucket: d.BucketName, Object: dstKey},
+		minio.CopySrcOptions{Bucket: d.BucketName, Object: srcKey},
+	)
+	return err
+}
+
+// URL returns the public URL of the key if the expiry is 0, otherwise the presigned download URL.
+func (d *s3FSDriver) URL(key string, expiry time.Duration) (string, error) {
+	if expiry <= 0 {
+		if d.EndPoint == "s3.amazonaws.com" {
+			return "https://" + d.BucketName + ".s3." + d.Region + ".amazonaws.com/" + key, nil
+		}
+		return "https://" + d.BucketName + "." + d.EndPoint + "/" + key, nil
+	}
+
+	u, err := d.mClient.PresignedGetObject(d.ctx, d.BucketName, key, expiry, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// UploadURL returns the presigned PUT URL with the signed content type header.
+// The presigned PUT can't limit the size, so the size of the uploaded object must be checked (Stat) before it is used.
+func (d *s3FSDriver) UploadURL(key string, expiry time.Duration, policy FileUploadPolicy) (string, error) {
+	u, err := d.mClient.PresignHeader(d.ctx, "PUT", d.BucketName, key, expiry, url.Values{}, http.Header{"Content-Type": {policy.ContentType}})
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// fileInfo returns the FileInfo of the minio.ObjectInfo.
+func (*s3FSDriver) fileInfo(oi minio.ObjectInfo) FileInfo {
+	return FileInfo{
+		Key:          oi.Key,
+		Size:         oi.Size,
+		ContentType:  oi.ContentType,
+		ETag:         oi.ETag,
+		LastModified: oi.LastModified.UTC(),
+	}
+}
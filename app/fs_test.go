@@ -0,0 +1,234 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestFSSignedURL(t *testing.T) {
+	f := NewFS("memory").WithPrefix("tenants/acme/")
+	res, err := f.SignedURL("products/a b.pdf", time.Minute)
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	u, err := url.Parse(res)
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	key := strings.TrimPrefix(u.Path, FS_SIGNED_URL_PATH+"/")
+	if key != "tenants/acme/products/a b.pdf" {
+		t.Errorf("Expected key [%v], got [%v]", "tenants/acme/products/a b.pdf", key)
+	}
+	q := u.Query()
+	if !f.VerifySignature(http.MethodGet, key, q.Get("expires"), q.Get("signature")) {
+		t.Errorf("Expected signature to be valid")
+	}
+	if f.VerifySignature(http.MethodPut, key, q.Get("expires"), q.Get("signature")) {
+		t.Errorf("Expected download signature to be invalid for upload")
+	}
+	if f.VerifySignature(http.MethodGet, "tenants/globex/products/a b.pdf", q.Get("expires"), q.Get("signature")) {
+		t.Errorf("Expected signature to be invalid for another key")
+	}
+
+	res, _ = signURL(http.MethodGet, "tenants/acme/products/a.pdf", -time.Minute, FileUploadPolicy{})
+	u, _ = url.Parse(res)
+	if f.VerifySignature(http.MethodGet, "tenants/acme/products/a.pdf", u.Query().Get("expires"), u.Query().Get("signature")) {
+		t.Errorf("Expected expired signature to be invalid")
+	}
+
+	defer func(env string) { APP_ENV = env }(APP_ENV)
+	APP_ENV = "production"
+	if _, err := f.SignedURL("products/a.pdf", time.Minute); !errors.Is(err, errFSSigningKeyRequired) {
+		t.Errorf("Expected signing without FS_SIGNING_KEY to be refused outside local, got [%v]", err)
+	}
+}
+
+func TestFSUploadHandler(t *testing.T) {
+	fsClient = NewFS("memory")
+	s := fiber.New()
+	s.Put(FS_SIGNED_URL_PATH+"/*", FS().UploadHandler)
+	_, err := FS().PresignedUploadURL("products/a.png", time.Minute, FileUploadPolicy{ContentType: "image/png"})
+	if err == nil {
+		t.Errorf("Expected upload url without max size to be refused")
+	}
+	res, _ := FS().PresignedUploadURL("products/a.png", time.Minute, FileUploadPolicy{ContentType: "image/png", MaxSize: 5})
+	u, _ := url.Parse(res)
+	tamperedSize := *u
+	q := u.Query()
+	q.Set("max_size", "1000")
+	tamperedSize.RawQuery = q.Encode()
+
+	tests := []struct {
+		description  string
+		url          string
+		contentType  string
+		body         string
+		expectedCode int
+	}{
+		{"Upload with another content type", u.RequestURI(), "text/html", "hello", http.StatusUnsupportedMediaType},
+		{"Upload larger than the max size", u.RequestURI(), "image/png", "hello world", http.StatusRequestEntityTooLarge},
+		{"Upload with tampered max size", tamperedSize.RequestURI(), "image/png", "hello world", http.StatusForbidden},
+		{"Upload within the policy", u.RequestURI(), "image/png", "hello", http.StatusOK},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPut, test.url, strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		res, err := s.Test(req)
+		if err != nil || res.StatusCode != test.expectedCode {
+			t.Errorf("%v: expected status %v, got [%v] [%v]", test.description, test.expectedCode, res.StatusCode, err)
+		}
+	}
+	if isExists, _ := FS().Exists("products/a.png"); !isExists {
+		t.Errorf("Expected uploaded file to be exists")
+	}
+}
+
+func TestFSDownloadHandler(t *testing.T) {
+	fsClient = NewFS("memory")
+	s := fiber.New()
+	s.Get(FS_SIGNED_URL_PATH+"/*", FS().DownloadHandler)
+	opt := FileUploadOption{}
+	opt.ContentType = "image/png"
+	FS().Upload("products/a.png", strings.NewReader("\x89PNG"), -1, opt)
+	opt.ContentType = "text/plain"
+	FS().Upload("products/b.html", strings.NewReader("<script>alert(1)</script>"), 25, opt)
+
+	tests := []struct {
+		description         string
+		key                 string
+		expectedType        string
+		expectedDisposition string
+	}{
+		{"Download the image inline", "products/a.png", "image/png", ""},
+		{"Download the other file as attachment with its uploaded type", "products/b.html", "text/plain", `attachment; filename=b.html`},
+	}
+	for _, test := range tests {
+		res, _ := FS().SignedURL(test.key, time.Minute)
+		u, _ := url.Parse(res)
+		resp, err := s.Test(httptest.NewRequest(http.MethodGet, u.RequestURI(), nil))
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("%v: expected status %v, got [%v] [%v]", test.description, http.StatusOK, resp.StatusCode, err)
+		}
+		if ct := resp.Header.Get(fiber.HeaderContentType); ct != test.expectedType {
+			t.Errorf("%v: expected content type [%v], got [%v]", test.description, test.expectedType, ct)
+		}
+		if cd := resp.Header.Get(fiber.HeaderContentDisposition); cd != test.expectedDisposition {
+			t.Errorf("%v: expected content disposition [%v], got [%v]", test.description, test.expectedDisposition, cd)
+		}
+		if nosniff := resp.Header.Get(fiber.HeaderXContentTypeOptions); nosniff != "nosniff" {
+			t.Errorf("%v: expected nosniff, got [%v]", test.description, nosniff)
+		}
+	}
+}
+
+func TestFSDriver(t *testing.T) {
+	f := NewFS("memory").WithPrefix("tenants/acme/")
+	_, err := f.Upload("products/a.txt", strings.NewReader("hello"), 5)
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	err = f.Copy("products/a.txt", "products/b.txt")
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+
+	rc, err := f.Download("products/b.txt")
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(b) != "hello" {
+		t.Errorf("Expected [hello], got [%v]", string(b))
+	}
+
+	info, err := f.Stat("products/a.txt")
+	if err != nil || info.Key != "products/a.txt" || info.Size != 5 || info.ContentType != "text/plain; charset=utf-8" {
+		t.Errorf("Expected stat of products/a.txt, got [%+v] [%v]", info, err)
+	}
+	files, _ := f.List("products/")
+	if len(files) != 2 || files[0].Key != "products/a.txt" {
+		t.Errorf("Expected 2 files, got [%+v]", files)
+	}
+
+	f.Delete("products/a.txt")
+	if isExists, _ := f.Exists("products/a.txt"); isExists {
+		t.Errorf("Expected deleted file to be not exists")
+	}
+
+	RegisterFSDriver("custom", newMemoryFSDriver)
+	if NewFS("custom").Driver != "custom" {
+		t.Errorf("Expected registered driver to be used")
+	}
+}
+
+func TestFSLocalDriver(t *testing.T) {
+	d := &localFSDriver{DirPath: t.TempDir()}
+	for _, key := range []string{"../../etc/passwd", "a/../../b.txt", "/etc/passwd", "", "."} {
+		_, err := d.Upload(key, strings.NewReader("x"), 1, FileUploadOption{})
+		if !errors.Is(err, ErrInvalidFileKey) {
+			t.Errorf("Expected key [%v] to be rejected, got [%v]", key, err)
+		}
+	}
+
+	info, err := d.Upload("products/2024/a", strings.NewReader("%PDF-1.4 hello"), 14, FileUploadOption{})
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	if info.Key != "products/2024/a" || info.Size != 14 || info.ContentType != "application/pdf" {
+		t.Errorf("Expected upload info of products/2024/a, got [%+v]", info)
+	}
+	if len(info.ETag) != 32 {
+		t.Errorf("Expected md5 etag, got [%v]", info.ETag)
+	}
+	if info.ChecksumSHA256 == "" {
+		t.Errorf("Expected sha256 checksum")
+	}
+
+	_, err = d.Upload("products/2024/b.txt", strings.NewReader("short"), 10, FileUploadOption{})
+	if err == nil {
+		t.Errorf("Expected size mismatch to fail")
+	}
+	if _, err := os.Stat(filepath.Join(d.DirPath, "products/2024/b.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected failed upload to be not exists, got [%v]", err)
+	}
+	os.WriteFile(filepath.Join(d.DirPath, "products/2024", localTempFilePrefix+"123"), []byte("partial"), 0644)
+
+	files, err := d.List("products/")
+	if err != nil || len(files) != 1 || files[0].Key != "products/2024/a" {
+		t.Errorf("Expected 1 file without the temp files, got [%+v] [%v]", files, err)
+	}
+	stat, err := d.Stat("products/2024/a")
+	if err != nil || stat.Size != 14 || stat.ContentType != "application/pdf" {
+		t.Errorf("Expected stat of products/2024/a, got [%+v] [%v]", stat, err)
+	}
+	if _, err := d.Stat("products/2024"); !os.IsNotExist(err) {
+		t.Errorf("Expected directory to be not exists as a file, got [%v]", err)
+	}
+
+	opt := FileUploadOption{}
+	opt.ContentType = "image/png"
+	d.Upload("products/c.html", strings.NewReader("\x89PNG"), -1, opt)
+	stat, err = d.Stat("products/c.html")
+	if err != nil || stat.ContentType != "image/png" {
+		t.Errorf("Expected the content type of the upload instead of the extension, got [%+v] [%v]", stat, err)
+	}
+	_, err = d.Stat("products/" + localContentTypeFilePrefix + "c.html")
+	if !errors.Is(err, ErrInvalidFileKey) {
+		t.Errorf("Expected the internal file to be rejected, got [%v]", err)
+	}
+	d.Delete("products/c.html", FileDeleteOption{})
+	if _, err := os.Stat(filepath.Join(d.DirPath, "products", localContentTypeFilePrefix+"c.html")); !os.IsNotExist(err) {
+		t.Errorf("Expected the content type to be deleted with the file, got [%v]", err)
+	}
+}
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// VerifySignature verifies the signature of the signed URL of the method, the key and the upload policy, it is invalid after it expires.
+func (f *fsUtil) VerifySignature(method, key, expires, signature string, policy ...FileUploadPolicy) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	p := FileUploadPolicy{}
+	if len(policy) > 0 {
+		p = policy[0]
+	}
+	expected, err := fsSignature(method, key, expires, p)
+	return err == nil && hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// DownloadHandler is the handler of the signed download URL, `GET {FS_SIGNED_URL_PATH}/{key}?expires=&signature=`.
+// The key is the full file name, so the FS() without prefix is used.
+// The file is served with the content type of its upload and is never sniffed by the browser,
+// the file which is not an image is served as an attachment so it can't be rendered on the origin of the app.
+func (f *fsUtil) DownloadHandler(c *fiber.Ctx) error {
+	key, err := f.verifyRequest(c)
+	if err != nil {
+		return Error().Handler(c, err)
+	}
+	info, err := FS().Stat(key)
+	if err != nil {
+		if os.IsNotExist(err) || errors.Is(err, ErrInvalidFileKey) {
+			return Error().Handler(c, Error().New(http.StatusNotFound, Translator().Trans(c.Get("Accept-Language"), "404_not_found")))
+		}
+		return Error().Handler(c, Error().New(http.StatusInternalServerError, err.Error()))
+	}
+	rc, err := FS().Download(key)
+	if err != nil {
+		return Error().Handler(c, Error().New(http.StatusInternalServerError, err.Error()))
+	}
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = fiber.MIMEOctetStream
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	if !isInlineContentType(contentType) {
+		c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(key)}))
+	}
+	return c.SendStream(rc, int(info.Size))
+}
+
+// UploadHandler is the handler of the signed upload URL, `PUT {FS_SIGNED_URL_PATH}/{key}?content_type=&max_size=&expires=&signature=`.
+// The content type and the max size are signed, the request with another content type or a larger body is refused.
+func (f *fsUtil) UploadHandler(c *fiber.Ctx) error {
+	lang := c.Get("Accept-Language")
+	maxSize, _ := strconv.ParseInt(c.Query("max_size"), 10, 64)
+	policy := FileUploadPolicy{ContentType: c.Query("content_type"), MaxSize: maxSize}
+	key, err := f.verifyRequest(c, policy)
+	if err == nil && (policy.ContentType == "" || policy.MaxSize <= 0) {
+		err = Error().New(http.StatusForbidden, Translator().Trans(lang, "invalid_file_signature"))
+	}
+	if err != nil {
+		return Error().Handler(c, err)
+	}
+	contentType := c.Get(fiber.HeaderContentType)
+	if !isSameMediaType(contentType, policy.ContentType) {
+		return Error().Handler(c, Error().New(http.StatusUnsupportedMediaType, Translator().Trans(lang, "file_type_not_allowed", map[string]string{"type": contentType})))
+	}
+	body := c.Body()
+	if int64(c.Request().Header.ContentLength()) > policy.MaxSize || int64(len(body)) > policy.MaxSize {
+		return Error().Handler(c, Error().New(http.StatusRequestEntityTooLarge, Translator().Trans(lang, "file_too_large", map[string]string{"max": strconv.FormatInt(policy.MaxSize, 10)})))
+	}
+	opt := FileUploadOption{}
+	opt.ContentType = policy.ContentType
+	_, err = FS().Upload(key, bytes.NewReader(body), int64(len(body)), opt)
+	if errors.Is(err, ErrInvalidFileKey) {
+		return Error().Handler(c, Error().New(http.StatusBadRequest, err.Error()))
+	}
+	if err != nil {
+		return Error().Handler(c, Error().New(http.StatusInternalServerError, err.Error()))
+	}
+	return c.JSON(map[string]any{"message": "Success"})
+}
+
+// verifyRequest returns the file key of the signed URL request if its signature is valid.
+func (f *fsUtil) verifyRequest(c *fiber.Ctx, policy ...FileUploadPolicy) (string, error) {
+	key, err := url.PathUnescape(c.Params("*"))
+	if err == nil && f.VerifySignature(c.Method(), key, c.Query("expires"), c.Query("signature"), policy...) {
+		return key, nil
+	}
+	return "", Error().New(http.StatusForbidden, Translator().Trans(c.Get("Accept-Language"), "invalid_file_signature"))
+}
+
+// signURL returns the URL of the key which is signed for the method and the upload policy until the expiry, served by the FS_SIGNED_URL_PATH route.
+// It is used by the storage drivers which can't presign the URLs, for example the local and the memory drivers.
+func signURL(method, key string, expiry time.Duration, policy FileUploadPolicy) (string, error) {
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	signature, err := fsSignature(method, key, expires, policy)
+	if err != nil {
+		return "", err
+	}
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	q := url.Values{}
+	if policy.ContentType != "" || policy.MaxSize > 0 {
+		q.Set("content_type", policy.ContentType)
+		q.Set("max_size", strconv.FormatInt(policy.MaxSize, 10))
+	}
+	q.Set("expires", expires)
+	q.Set("signature", signature)
+	return APP_URL + FS_SIGNED_URL_PATH + "/" + strings.Join(segments, "/") + "?" + q.Encode(), nil
+}
+
+// fsSignature returns the hmac sha256 signature of the method, the key, the expires and the upload policy.
+func fsSignature(method, key, expires string, policy FileUploadPolicy) (string, error) {
+	signingKey, err := fsSigningKey()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte(method + "\n" + key + "\n" + expires + "\n" + policy.ContentType + "\n" + strconv.FormatInt(policy.MaxSize, 10)))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// fsSigningKey returns FS_SIGNING_KEY, the signed urls are refused without it outside the local env.
+// The local env uses a random key generated once, so the signed urls are valid until the restart.
+func fsSigningKey() ([]byte, error) {
+	if FS_SIGNING_KEY != "" {
+		return []byte(FS_SIGNING_KEY), nil
+	}
+	if APP_ENV != "local" {
+		return nil, errFSSigningKeyRequired
+	}
+	fsLocalSigningKeyOnce.Do(func() {
+		fsLocalSigningKey = make([]byte, 32)
+		rand.Read(fsLocalSigningKey)
+	})
+	return fsLocalSigningKey, nil
+}
+
+// errFSSigningKeyRequired is returned when the url can't be signed without FS_SIGNING_KEY.
+var errFSSigningKeyRequired = errors.New("FS_SIGNING_KEY is required to sign the file urls")
+
+// fsLocalSigningKey is the random signing key of the local env, see fsSigningKey.
+var (
+	fsLocalSigningKey     []byte
+	fsLocalSigningKeyOnce sync.Once
+)
+
+// isSameMediaType returns true if the content types have the same media type, the parameters are ignored.
+func isSameMediaType(a, b string) bool {
+	ma, _, errA := mime.ParseMediaType(a)
+	mb, _, errB := mime.ParseMediaType(b)
+	return errA == nil && errB == nil && ma == mb
+}
+
+// isInlineContentType returns true if the content type is the image which can be rendered inline safely,
+// the svg is an image which can contain scripts, so it is not.
+func isInlineContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && strings.HasPrefix(mediaType, "image/") && mediaType != "image/svg+xml"
+}
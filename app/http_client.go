@@ -1,6 +1,15 @@
 package app
 
-import "grest.dev/grest"
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"grest.dev/grest"
+)
 
 // HttpClient creates and returns a new instance of httpClientUtil.
 // It takes two parameters: method (HTTP method) and url (URL).
@@ -16,4 +25,44 @@ func HttpClient(method, url string) *httpClientUtil {
 // It embeds the grest.HttpClient type, which provides additional functionality for making HTTP requests.
 type httpClientUtil struct {
 	grest.HttpClient
+	ctx context.Context
+}
+
+// WithContext sets the context of the request, so it is traced as the child span of the span on the ctx, for example c.Context().
+func (hc *httpClientUtil) WithContext(ctx context.Context) *httpClientUtil {
+	hc.ctx = ctx
+	return hc
+}
+
+// Send sends the request as the client span, the trace context is propagated to the server with the W3C traceparent header.
+func (hc *httpClientUtil) Send() (*http.Response, error) {
+	ctx := hc.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := Trace().Tracer.Start(ctx, hc.Method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.HTTPRequestMethodKey.String(hc.Method),
+		semconv.URLFull(hc.Url),
+	))
+	defer span.End()
+
+	carrier := propagation.MapCarrier{}
+	Trace().Propagator.Inject(ctx, carrier)
+	for k, v := range carrier {
+		hc.AddHeader(k, v)
+	}
+
+	res, err := hc.HttpClient.Send()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return res, err
+	}
+	if res != nil {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
+		if res.StatusCode >= http.StatusBadRequest {
+			span.SetStatus(codes.Error, "")
+		}
+	}
+	return res, err
 }
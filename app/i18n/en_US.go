@@ -8,5 +8,30 @@ func EnUS() map[string]string {
 		"404_not_found":                "The resource you have specified cannot be found.",
 		"500_internal_error":           "Failed to connect to the server, please try again later.",
 		"invalid_username_or_password": "Invalid username or password",
+		"cache_flushed":                "The cache of :end_point is flushed.",
+		"cache_key_not_found":          "The cache key :key is not found or it is expired.",
+		"category_has_children":        "The category still has sub categories, please move or delete them first.",
+		"file_required":                "The file is required, please upload it as the `file` field of the multipart form.",
+		"file_too_large":               "The file is too large, the max size is :max bytes.",
+		"file_type_not_allowed":        "The file type :type is not allowed.",
+		"image_too_large":              "The image is too large, the max dimension is :max pixels.",
+		"invalid_image":                "The file is not a valid jpeg, png, gif or webp image.",
+		"invalid_image_order":          "The image ids must be all images of the product without duplicates.",
+		"insufficient_stock":           "The stock is insufficient for this adjustment.",
+		"invalid_category_parent":      "The parent category is not available, or it is the category itself or one of its sub categories.",
+		"invalid_file_owner":           "The owner :end_point :id of the file is not available.",
+		"invalid_file_signature":       "The file url is invalid or it is expired.",
+		"invalid_log_level":            "The log level must be one of trace, debug, info, warn, error, fatal, panic or disabled, and the modules must be like db=debug,cache=warn.",
+		"invalid_log_revert_after":     "The revert_after must be a duration like 30m, up to :max.",
+		"invalid_mute_duration":        "The duration must be like 24h, up to :max, or empty to mute until it is resolved.",
+		"invalid_stock_quantity":       "The quantity must be greater than zero, except for the adjustment type which must not be zero.",
+		"invalid_tenant":               "The tenant is invalid or you do not have access to it.",
+		"invalid_upload_content_type":  "The chunk must be sent with the application/offset+octet-stream content type.",
+		"invalid_upload_length":        "The Upload-Length header must be a non negative number, and the chunks must not exceed it.",
+		"invalid_upload_offset":        "The Upload-Offset header does not match the current offset :offset of the upload.",
+		"stock_read_only":              "The stock can not be updated directly, use POST /products/:id/stock-adjustments instead.",
+		"tenant_required":              "The tenant is required, please specify the tenant of the request.",
+		"unsupported_tus_version":      "The Tus-Resumable header must be :version.",
+		"upload_expired":               "The upload is expired, please start a new upload.",
 	}
 }
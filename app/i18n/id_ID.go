@@ -8,5 +8,30 @@ func IdID() map[string]string {
 		"404_not_found":                "The resource you have specified cannot be found.",
 		"500_internal_error":           "Gagal terhubung ke server, silakan coba lagi nanti.",
 		"invalid_username_or_password": "Username atau kata sandi tidak valid",
+		"cache_flushed":                "Cache :end_point berhasil dihapus.",
+		"cache_key_not_found":          "Cache key :key tidak ditemukan atau sudah kedaluwarsa.",
+		"category_has_children":        "Kategori masih memiliki sub kategori, silakan pindahkan atau hapus terlebih dahulu.",
+		"file_required":                "File wajib diisi, silakan unggah sebagai field `file` dari multipart form.",
+		"file_too_large":               "File terlalu besar, ukuran maksimal :max bytes.",
+		"file_type_not_allowed":        "Tipe file :type tidak diizinkan.",
+		"image_too_large":              "Gambar terlalu besar, dimensi maksimal :max piksel.",
+		"invalid_image":                "File bukan gambar jpeg, png, gif atau webp yang valid.",
+		"invalid_image_order":          "Id gambar harus berisi semua gambar produk tanpa duplikat.",
+		"insufficient_stock":           "Stok tidak mencukupi untuk penyesuaian ini.",
+		"invalid_category_parent":      "Kategori induk tidak tersedia, atau merupakan kategori itu sendiri atau salah satu sub kategorinya.",
+		"invalid_file_owner":           "Pemilik :end_point :id dari file tidak tersedia.",
+		"invalid_file_signature":       "Url file tidak valid atau sudah kedaluwarsa.",
+		"invalid_log_level":            "Level log harus salah satu dari trace, debug, info, warn, error, fatal, panic atau disabled, dan modules harus seperti db=debug,cache=warn.",
+		"invalid_log_revert_after":     "revert_after harus berupa durasi seperti 30m, maksimal :max.",
+		"invalid_mute_duration":        "Durasi harus seperti 24h, maksimal :max, atau kosong untuk membisukan sampai diselesaikan.",
+		"invalid_stock_quantity":       "Jumlah harus lebih dari nol, kecuali untuk tipe adjustment yang tidak boleh nol.",
+		"invalid_tenant":               "Tenant tidak valid atau Anda tidak memiliki akses ke tenant tersebut.",
+		"invalid_upload_content_type":  "Potongan file harus dikirim dengan content type application/offset+octet-stream.",
+		"invalid_upload_length":        "Header Upload-Length harus berupa angka tidak negatif, dan potongan file tidak boleh melebihinya.",
+		"invalid_upload_offset":        "Header Upload-Offset tidak sesuai dengan offset :offset dari unggahan saat ini.",
+		"stock_read_only":              "Stok tidak dapat diubah langsung, gunakan POST /products/:id/stock-adjustments.",
+		"tenant_required":              "Tenant wajib diisi, silakan tentukan tenant dari permintaan.",
+		"unsupported_tus_version":      "Header Tus-Resumable harus :version.",
+		"upload_expired":               "Unggahan sudah kedaluwarsa, silakan mulai unggahan baru.",
 	}
 }
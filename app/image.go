@@ -0,0 +1,292 @@
+package app
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register the webp decoder
+)
+
+// These are the errors of the image decoding.
+var (
+	ErrInvalidImage  = errors.New("the content is not a valid image")
+	ErrImageTooLarge = errors.New("the image dimension is too large")
+)
+
+// Image returns a pointer to the imageUtil instance (img).
+// If img is not initialized, it creates a new imageUtil instance, configures it, and assigns it to img.
+// It ensures that only one instance of imageUtil is created and reused.
+func Image() *imageUtil {
+	if img == nil {
+		img = &imageUtil{}
+		img.configure()
+	}
+	return img
+}
+
+// img is a pointer to an imageUtil instance.
+// It is used to store and access the singleton instance of imageUtil.
+var img *imageUtil
+
+// imageUtil represents an image processing utility.
+// The images are decoded to validate them, the EXIF orientation is applied, then they are re-encoded
+// so the EXIF and any other metadata (location, camera, etc) are stripped.
+type imageUtil struct {
+	Renditions  []ImageRendition
+	JPEGQuality int
+	MaxPixels   int
+}
+
+// ImageRendition is the configured variant of the image, the image is resized to fit within the width and the height.
+type ImageRendition struct {
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Format string `json:"format"` // jpeg, png or webp
+}
+
+// Ext returns the file extension of the rendition format, for example ".jpg".
+func (r ImageRendition) Ext() string {
+	return imageExt(r.Format)
+}
+
+// configure configures the image utility instance based on the IMAGE_XXX environment variables.
+func (i *imageUtil) configure() {
+	i.JPEGQuality = IMAGE_JPEG_QUALITY
+	i.MaxPixels = IMAGE_MAX_PIXELS
+	i.Renditions = []ImageRendition{}
+	for _, r := range splitAndTrim(IMAGE_RENDITIONS) {
+		rendition, err := parseImageRendition(r)
+		if err != nil {
+			Logger().Error().Err(err).Str("IMAGE_RENDITIONS", r).Msg("Failed to parse the image rendition, it is skipped.")
+			continue
+		}
+		i.Renditions = append(i.Renditions, rendition)
+	}
+}
+
+// parseImageRendition parses the rendition with format "name:{width}x{height}:format", for example "thumbnail:150x150:jpeg".
+func parseImageRendition(s string) (ImageRendition, error) {
+	r := ImageRendition{}
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return r, errors.New("the rendition must be formatted as name:{width}x{height}:format")
+	}
+	r.Name, r.Format = parts[0], strings.ToLower(parts[2])
+	size := strings.Split(strings.ToLower(parts[1]), "x")
+	if len(size) != 2 {
+		return r, errors.New("the rendition size must be formatted as {width}x{height}")
+	}
+	var err error
+	r.Width, err = strconv.Atoi(size[0])
+	if err == nil {
+		r.Height, err = strconv.Atoi(size[1])
+	}
+	if err != nil || r.Width <= 0 || r.Height <= 0 {
+		return r, errors.New("the rendition width and height must be positive numbers")
+	}
+	if imageExt(r.Format) == "" {
+		return r, errors.New("the rendition format must be jpeg, png or webp")
+	}
+	return r, nil
+}
+
+// Decode decodes the jpeg, png, gif or webp image and returns it with its format.
+// The dimension is checked before the image is decoded, so the decompression bomb is rejected with ErrImageTooLarge.
+// The EXIF orientation of the jpeg is applied, so the re-encoded image which has no EXIF is displayed correctly.
+func (i *imageUtil) Decode(data []byte) (image.Image, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, "", ErrInvalidImage
+	}
+	if i.MaxPixels > 0 && cfg.Width*cfg.Height > i.MaxPixels {
+		return nil, "", ErrImageTooLarge
+	}
+	m, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrInvalidImage
+	}
+	if format == "jpeg" {
+		m = orientImage(m, exifOrientation(data))
+	}
+	return m, format, nil
+}
+
+// Resize returns the image scaled down to fit within the width and the height, keeping the aspect ratio.
+// The image which already fits is returned as is, it is never scaled up.
+func (*imageUtil) Resize(m image.Image, width, height int) image.Image {
+	b := m.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= width && h <= height {
+		return m
+	}
+	if w*height > h*width {
+		w, h = width, max(1, h*width/w)
+	} else {
+		w, h = max(1, w*height/h), height
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), m, b, draw.Src, nil)
+	return dst
+}
+
+// Encode encodes the image with the format (jpeg, png, gif or webp) to the writer, the metadata is not written.
+// The transparent area is filled with white for jpeg since it has no alpha channel.
+// The webp is encoded lossless.
+func (i *imageUtil) Encode(w io.Writer, m image.Image, format string) error {
+	switch format {
+	case "jpeg", "jpg":
+		b := m.Bounds()
+		dst := image.NewRGBA(b)
+		draw.Draw(dst, b, image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(dst, b, m, b.Min, draw.Over)
+		return jpeg.Encode(w, dst, &jpeg.Options{Quality: i.JPEGQuality})
+	case "png":
+		return png.Encode(w, m)
+	case "gif":
+		return gif.Encode(w, m, nil)
+	case "webp":
+		return nativewebp.Encode(w, m, nil)
+	}
+	return errors.New("unsupported image format " + format)
+}
+
+// Rendition returns the encoded rendition of the image.
+func (i *imageUtil) Rendition(m image.Image, r ImageRendition) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := i.Encode(buf, i.Resize(m, r.Width, r.Height), r.Format)
+	return buf.Bytes(), err
+}
+
+// imageExt returns the file extension of the image format, or empty string if it is not supported.
+func imageExt(format string) string {
+	switch format {
+	case "jpeg", "jpg":
+		return ".jpg"
+	case "png":
+		return ".png"
+	case "gif":
+		return ".gif"
+	case "webp":
+		return ".webp"
+	}
+	return ""
+}
+
+// exifOrientation returns the EXIF orientation (1-8) of the jpeg, or 1 if it has no orientation.
+// It only reads the IFD0 of the APP1 segment, the rest of the EXIF is ignored.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for p := 2; p+4 <= len(data); {
+		if data[p] != 0xFF {
+			return 1
+		}
+		marker := data[p+1]
+		if marker == 0xDA || marker == 0xD9 {
+			return 1 // the image data starts, there is no more metadata
+		}
+		size := int(binary.BigEndian.Uint16(data[p+2:]))
+		if size < 2 || p+2+size > len(data) {
+			return 1
+		}
+		segment := data[p+4 : p+2+size]
+		if marker == 0xE1 && len(segment) > 14 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		p += 2 + size
+	}
+	return 1
+}
+
+// tiffOrientation returns the orientation tag (0x0112) of the IFD0 of the TIFF header of the EXIF.
+func tiffOrientation(tiff []byte) int {
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o < 1 || o > 8 {
+				return 1
+			}
+			return o
+		}
+	}
+	return 1
+}
+
+// orientImage returns the image transformed by the EXIF orientation, so it is displayed upright without the EXIF.
+// The image is converted to NRGBA once, then the pixels are copied 4 bytes at a time between the pixel slices.
+func orientImage(m image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return m
+	}
+	b := m.Bounds()
+	w, h := b.Dx(), b.Dy()
+	src, ok := m.(*image.NRGBA)
+	if !ok || b.Min != (image.Point{}) {
+		src = image.NewNRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(src, src.Bounds(), m, b.Min, draw.Src)
+	}
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w // transposed
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		row := dst.Pix[y*dst.Stride : y*dst.Stride+dw*4]
+		for x := 0; x < dw; x++ {
+			sx, sy := x, y
+			switch orientation {
+			case 2: // mirror horizontal
+				sx, sy = w-1-x, y
+			case 3: // rotate 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirror vertical
+				sx, sy = x, h-1-y
+			case 5: // transpose
+				sx, sy = y, x
+			case 6: // rotate 90 cw
+				sx, sy = y, h-1-x
+			case 7: // transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate 270 cw
+				sx, sy = w-1-y, x
+			}
+			p := sy*src.Stride + sx*4
+			copy(row[x*4:x*4+4], src.Pix[p:p+4])
+		}
+	}
+	return dst
+}
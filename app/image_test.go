@@ -0,0 +1,108 @@
+package app
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestImageDecode(t *testing.T) {
+	i := &imageUtil{JPEGQuality: 85, MaxPixels: 1000}
+	_, _, err := i.Decode([]byte("%PDF-1.4 not an image"))
+	if err != ErrInvalidImage {
+		t.Errorf("Expected [%v], got [%v]", ErrInvalidImage, err)
+	}
+
+	// 40x20 jpeg with the EXIF orientation 6 (rotate 90 cw) is decoded as 20x40
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	buf := &bytes.Buffer{}
+	jpeg.Encode(buf, src, nil)
+	data := withExifOrientation(buf.Bytes(), 6)
+	m, format, err := i.Decode(data)
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	if format != "jpeg" || m.Bounds().Dx() != 20 || m.Bounds().Dy() != 40 {
+		t.Errorf("Expected upright 20x40 jpeg, got [%v] [%v]", format, m.Bounds())
+	}
+
+	i.MaxPixels = 100
+	_, _, err = i.Decode(data)
+	if err != ErrImageTooLarge {
+		t.Errorf("Expected [%v], got [%v]", ErrImageTooLarge, err)
+	}
+}
+
+func TestImageRendition(t *testing.T) {
+	i := &imageUtil{JPEGQuality: 85}
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	for x := 0; x < 400; x++ {
+		src.Set(x, 10, color.NRGBA{R: 255, A: 255})
+	}
+
+	r, err := parseImageRendition("thumbnail:100x100:webp")
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	b, err := i.Rendition(src, r)
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	m, err := webp.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Expected valid webp, got [%v]", err)
+	}
+	if m.Bounds().Dx() != 100 || m.Bounds().Dy() != 50 {
+		t.Errorf("Expected 100x50 keeping the aspect ratio, got [%v]", m.Bounds())
+	}
+	if res := i.Resize(src, 1000, 1000); res != image.Image(src) {
+		t.Errorf("Expected the smaller image to be not scaled up")
+	}
+
+	for _, s := range []string{"thumbnail:100:jpeg", "thumbnail:0x100:jpeg", "thumbnail:100x100:bmp", "thumbnail"} {
+		if _, err := parseImageRendition(s); err == nil {
+			t.Errorf("Expected rendition [%v] to be invalid", s)
+		}
+	}
+}
+
+func TestOrientImage(t *testing.T) {
+	// 3x2 image with the red top left pixel, the expected position of it on the transformed image
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	src.Set(0, 0, color.RGBA{R: 255, A: 255})
+	expected := map[int]image.Point{2: {2, 0}, 3: {2, 1}, 4: {0, 1}, 5: {0, 0}, 6: {1, 0}, 7: {1, 2}, 8: {0, 2}}
+	for orientation, p := range expected {
+		m := orientImage(src, orientation)
+		if r, _, _, _ := m.At(p.X, p.Y).RGBA(); r != 0xFFFF {
+			t.Errorf("Expected the red pixel at [%v] with orientation [%v], got [%v]", p, orientation, m.At(p.X, p.Y))
+		}
+		if orientation >= 5 && (m.Bounds().Dx() != 2 || m.Bounds().Dy() != 3) {
+			t.Errorf("Expected 2x3 with orientation [%v], got [%v]", orientation, m.Bounds())
+		}
+	}
+	if orientImage(src, 1) != image.Image(src) {
+		t.Errorf("Expected the image to be returned as is with orientation 1")
+	}
+}
+
+// withExifOrientation returns the jpeg with the APP1 EXIF segment which only has the orientation tag.
+func withExifOrientation(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3) // SHORT
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	tiff = append(append(tiff, entry...), 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	res := append([]byte{}, data[:2]...)
+	res = append(append(res, app1...), segment...)
+	return append(res, data[2:]...)
+}
@@ -1,8 +1,15 @@
 package app
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -18,8 +25,18 @@ func Logger() *loggerUtil {
 
 var logger *loggerUtil
 
+// loggerUtil is the logger of the app, the events below the level of LOG_LEVEL are discarded.
+// Use Module to log with the level of the module, see LOG_MODULE_LEVELS. The levels can be changed at runtime, see SetLevels.
 type loggerUtil struct {
 	zerolog.Logger
+	root    zerolog.Logger // the logger without the level hook, the module loggers are derived from it
+	levels  atomic.Pointer[LogLevels]
+	modules sync.Map // the *zerolog.Logger of the modules by name
+	revert  *time.Timer
+	mu      sync.Mutex
+	closers []io.Closer // the writers which are closed on shutdown, see Close
+
+	instanceID string // used to ignore the own broadcasted levels, see SubscribeLevels
 }
 
 // configure sets up the logging framework
@@ -35,27 +52,50 @@ type loggerUtil struct {
 // and Kubernetes).
 //
 // The output log file will be located at LOG_FILE_FILENAME and will be rolled according to configuration set.
+// The console log is human readable unless LOG_FORMAT is json, so it can be collected by the log shippers from the container STDERR.
 func (l *loggerUtil) configure() {
 	var writers []io.Writer
 
 	if LOG_CONSOLE_ENABLED {
-		writers = append(writers, zerolog.ConsoleWriter{Out: os.Stderr})
+		if LOG_FORMAT == "json" {
+			writers = append(writers, os.Stderr)
+		} else {
+			writers = append(writers, zerolog.ConsoleWriter{Out: os.Stderr})
+		}
 	}
 	if LOG_FILE_ENABLED && ENV_FILE == "" {
-		writers = append(writers, &lumberjack.Logger{
+		file := &lumberjack.Logger{
 			Filename:   LOG_FILE_FILENAME,
 			MaxSize:    LOG_FILE_MAX_SIZE,
 			MaxAge:     LOG_FILE_MAX_AGE,
 			MaxBackups: LOG_FILE_MAX_BACKUPS,
-		})
+		}
+		writers = append(writers, file)
+		l.closers = append(l.closers, file)
 	}
-	l.Logger = zerolog.New(io.MultiWriter(writers...)).
+	// the fatal log line raises the critical alert before the process exits, see fatalAlertWriter
+	writers = append(writers, fatalAlertWriter{})
+	l.root = zerolog.New(zerolog.MultiLevelWriter(writers...)).
 		With().
 		Timestamp().
 		Logger()
+	l.Logger = l.root.Hook(logLevelHook{logger: l})
+	l.instanceID = NewNullUUID().String
+
+	levels, err := ParseLogLevels(LOG_LEVEL, LOG_MODULE_LEVELS)
+	if err != nil {
+		levels, _ = ParseLogLevels(zerolog.LevelInfoValue, "")
+		l.setLevels(&levels)
+		l.Warn().Err(err).Str("LOG_LEVEL", LOG_LEVEL).Str("LOG_MODULE_LEVELS", LOG_MODULE_LEVELS).Msg("Invalid log level, info level will be used.")
+	} else {
+		l.setLevels(&levels)
+	}
 
 	l.Info().
 		Bool("LOG_CONSOLE_ENABLED", LOG_CONSOLE_ENABLED).
+		Str("LOG_FORMAT", LOG_FORMAT).
+		Str("LOG_LEVEL", LOG_LEVEL).
+		Str("LOG_MODULE_LEVELS", LOG_MODULE_LEVELS).
 		Bool("LOG_FILE_ENABLED", LOG_FILE_ENABLED).
 		Str("LOG_FILE_FILENAME", LOG_FILE_FILENAME).
 		Int("LOG_FILE_MAX_SIZE", LOG_FILE_MAX_SIZE).
@@ -63,3 +103,211 @@ func (l *loggerUtil) configure() {
 		Int("LOG_FILE_MAX_BACKUPS", LOG_FILE_MAX_BACKUPS).
 		Msg("Logging configured")
 }
+
+// Module returns the logger of the module, the events are logged with the module field and filtered by the level of the module.
+// The known modules are http, db, cache, fs and outbox, the other modules use the default level until it is set on LOG_MODULE_LEVELS.
+func (l *loggerUtil) Module(name string) *zerolog.Logger {
+	if m, ok := l.modules.Load(name); ok {
+		return m.(*zerolog.Logger)
+	}
+	base := l.root
+	if l.levels.Load() == nil {
+		base = l.Logger // the logger is not configured, for example it is replaced on the tests
+	}
+	m := base.With().Str("module", name).Logger().Hook(logLevelHook{logger: l, module: name})
+	actual, _ := l.modules.LoadOrStore(name, &m)
+	return actual.(*zerolog.Logger)
+}
+
+// Levels returns the current log levels.
+func (l *loggerUtil) Levels() LogLevels {
+	if levels := l.levels.Load(); levels != nil {
+		return *levels
+	}
+	return LogLevels{Level: zerolog.LevelTraceValue, Modules: map[string]string{}}
+}
+
+// SetLevels changes the log levels of all instances without a restart, they are broadcasted on LOG_LEVEL_CHANNEL,
+// then they are reverted to the configured levels (LOG_LEVEL and LOG_MODULE_LEVELS) after revertAfter on every instance,
+// so the verbose levels which are set to debug an issue are not left on.
+func (l *loggerUtil) SetLevels(levels LogLevels, revertAfter time.Duration) {
+	revertAt := time.Now().Add(revertAfter)
+	l.applyLevels(levels, revertAt)
+	l.publishLevels(logLevelChange{Level: levels.Level, Modules: levels.Modules, RevertAt: &revertAt})
+}
+
+// Reset reverts the log levels of all instances to the configured levels (LOG_LEVEL and LOG_MODULE_LEVELS).
+func (l *loggerUtil) Reset() {
+	l.reset()
+	l.publishLevels(logLevelChange{})
+}
+
+// SubscribeLevels applies the log levels which are changed at runtime by the other instances, see SetLevels.
+// The instance which starts later or misses the broadcast keeps the configured levels.
+func (l *loggerUtil) SubscribeLevels() {
+	Cache().Subscribe(LOG_LEVEL_CHANNEL, func(payload []byte) {
+		change := logLevelChange{}
+		err := json.Unmarshal(payload, &change)
+		if err != nil {
+			l.Warn().Err(err).Str("payload", string(payload)).Msg("Invalid log levels, they are ignored.")
+			return
+		}
+		l.applyChange(change)
+	})
+}
+
+// logLevelChange is the change of the log levels broadcasted on LOG_LEVEL_CHANNEL, the empty level resets them.
+type logLevelChange struct {
+	Origin   string            `json:"origin"`
+	Level    string            `json:"level,omitempty"`
+	Modules  map[string]string `json:"modules,omitempty"`
+	RevertAt *time.Time        `json:"revert_at,omitempty"`
+}
+
+// publishLevels broadcasts the change of the log levels to the other instances.
+func (l *loggerUtil) publishLevels(change logLevelChange) {
+	change.Origin = l.instanceID
+	_, err := Cache().Publish(LOG_LEVEL_CHANNEL, change)
+	if err != nil {
+		l.Warn().Err(err).Msg("Failed to broadcast the log levels, only the current instance is changed.")
+	}
+}
+
+// applyChange applies the change of the log levels broadcasted by the other instance.
+func (l *loggerUtil) applyChange(change logLevelChange) {
+	if change.Origin == l.instanceID {
+		return
+	}
+	if change.Level == "" {
+		l.reset()
+		return
+	}
+	levels, err := NewLogLevels(change.Level, change.Modules)
+	if err != nil || change.RevertAt == nil {
+		l.Warn().Err(err).Str("level", change.Level).Msg("Invalid log levels, they are ignored.")
+		return
+	}
+	if change.RevertAt.After(time.Now()) {
+		l.applyLevels(levels, *change.RevertAt)
+	}
+}
+
+// applyLevels changes the log levels of the current instance until revertAt.
+func (l *loggerUtil) applyLevels(levels LogLevels, revertAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.revert != nil {
+		l.revert.Stop()
+	}
+	levels.RevertAt = &revertAt
+	l.setLevels(&levels)
+	l.revert = time.AfterFunc(time.Until(revertAt), l.reset)
+	l.Warn().Str("level", levels.Level).Interface("modules", levels.Modules).Time("revert_at", revertAt).Msg("Log levels are changed.")
+}
+
+// reset reverts the log levels of the current instance to the configured levels.
+func (l *loggerUtil) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.revert != nil {
+		l.revert.Stop()
+		l.revert = nil
+	}
+	levels, err := ParseLogLevels(LOG_LEVEL, LOG_MODULE_LEVELS)
+	if err != nil {
+		levels, _ = ParseLogLevels(zerolog.LevelInfoValue, "")
+	}
+	l.setLevels(&levels)
+	l.Info().Str("level", levels.Level).Interface("modules", levels.Modules).Msg("Log levels are reverted.")
+}
+
+// Close stops the revert of the log levels and closes the log file, it is the last step of the shutdown.
+// The log file is reopened if something is logged after it is closed.
+func (l *loggerUtil) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.revert != nil {
+		l.revert.Stop()
+		l.revert = nil
+	}
+	var err error
+	for _, c := range l.closers {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// setLevels stores the levels, the global level is set to the lowest one so the events below every level are skipped early.
+func (l *loggerUtil) setLevels(levels *LogLevels) {
+	l.levels.Store(levels)
+	lowest := levels.level
+	for _, lv := range levels.modules {
+		lowest = min(lowest, lv)
+	}
+	zerolog.SetGlobalLevel(lowest)
+}
+
+// level returns the level of the module, or the default level if the module has no level.
+func (l *loggerUtil) level(module string) zerolog.Level {
+	levels := l.levels.Load()
+	if levels == nil {
+		return zerolog.TraceLevel
+	}
+	if lv, ok := levels.modules[module]; ok {
+		return lv
+	}
+	return levels.level
+}
+
+// logLevelHook discards the events below the level of the module, or the default level if the module is empty.
+type logLevelHook struct {
+	logger *loggerUtil
+	module string
+}
+
+// Run discards the event if its level is below the level of the module.
+func (h logLevelHook) Run(e *zerolog.Event, level zerolog.Level, _ string) {
+	if level != zerolog.NoLevel && level < h.logger.level(h.module) {
+		e.Discard()
+	}
+}
+
+// LogLevels is the default log level and the levels of the modules.
+type LogLevels struct {
+	Level    string            `json:"level"`
+	Modules  map[string]string `json:"modules"`
+	RevertAt *time.Time        `json:"revert_at"` // the time the levels are reverted to the configured levels, nil if they are the configured levels
+
+	level   zerolog.Level
+	modules map[string]zerolog.Level
+}
+
+// ParseLogLevels parses the default level and the comma separated module levels, for example "info" and "db=debug,cache=warn".
+func ParseLogLevels(level, modules string) (LogLevels, error) {
+	m := map[string]string{}
+	for _, pair := range splitAndTrim(modules) {
+		name, lv, _ := strings.Cut(pair, "=")
+		m[strings.TrimSpace(name)] = strings.TrimSpace(lv)
+	}
+	return NewLogLevels(level, m)
+}
+
+// NewLogLevels validates the default level and the levels of the modules, the level is one of trace, debug, info, warn, error, fatal, panic or disabled.
+func NewLogLevels(level string, modules map[string]string) (LogLevels, error) {
+	res := LogLevels{Level: strings.ToLower(level), Modules: map[string]string{}, modules: map[string]zerolog.Level{}}
+	var err error
+	res.level, err = zerolog.ParseLevel(res.Level)
+	if err != nil || res.Level == "" {
+		return res, fmt.Errorf("invalid log level %q", level)
+	}
+	for name, lv := range modules {
+		lv = strings.ToLower(lv)
+		parsed, err := zerolog.ParseLevel(lv)
+		if err != nil || name == "" || lv == "" {
+			return res, fmt.Errorf("invalid log level %q of module %q", modules[name], name)
+		}
+		res.Modules[name] = lv
+		res.modules[name] = parsed
+	}
+	return res, nil
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestLoggerLevels(t *testing.T) {
+	prevLogger, prevLevel, prevModules := logger, LOG_LEVEL, LOG_MODULE_LEVELS
+	prevGlobal := zerolog.GlobalLevel()
+	defer func() {
+		logger, LOG_LEVEL, LOG_MODULE_LEVELS = prevLogger, prevLevel, prevModules
+		zerolog.SetGlobalLevel(prevGlobal)
+	}()
+
+	Cache() // the changed levels are broadcasted through the cache, it configures the default logger first
+	buf := &bytes.Buffer{}
+	LOG_LEVEL, LOG_MODULE_LEVELS = "warn", "db=debug"
+	l := &loggerUtil{}
+	l.root = zerolog.New(buf)
+	l.Logger = l.root.Hook(logLevelHook{logger: l})
+	levels, err := ParseLogLevels(LOG_LEVEL, LOG_MODULE_LEVELS)
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	l.setLevels(&levels)
+
+	l.Info().Msg("default info")
+	l.Module("cache").Info().Msg("cache info")
+	l.Module("db").Debug().Msg("db debug")
+	l.Module("cache").Warn().Msg("cache warn")
+	if out := buf.String(); strings.Contains(out, "default info") || strings.Contains(out, "cache info") ||
+		!strings.Contains(out, `"module":"db","message":"db debug"`) || !strings.Contains(out, "cache warn") {
+		t.Errorf("Expected the events to be filtered by the module levels, got [%v]", out)
+	}
+
+	// the changed levels are reverted after the duration
+	changed, _ := NewLogLevels("debug", map[string]string{"cache": "error"})
+	l.SetLevels(changed, 50*time.Millisecond)
+	buf.Reset()
+	l.Debug().Msg("default debug")
+	l.Module("cache").Warn().Msg("cache warn")
+	if out := buf.String(); !strings.Contains(out, "default debug") || strings.Contains(out, "cache warn") || l.Levels().RevertAt == nil {
+		t.Errorf("Expected the changed levels to be used, got [%v]", out)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if lv := l.Levels(); lv.Level != "warn" || lv.Modules["db"] != "debug" || lv.RevertAt != nil {
+		t.Errorf("Expected the levels to be reverted, got [%v]", lv)
+	}
+
+	// the levels broadcasted by the other instance are applied until their revert time, the own ones are ignored
+	revertAt := time.Now().Add(50 * time.Millisecond)
+	l.instanceID = "current"
+	l.applyChange(logLevelChange{Origin: "current", Level: "error", RevertAt: &revertAt})
+	if lv := l.Levels(); lv.Level != "warn" {
+		t.Errorf("Expected the own broadcasted levels to be ignored, got [%v]", lv)
+	}
+	l.applyChange(logLevelChange{Origin: "other", Level: "debug", Modules: map[string]string{"db": "error"}, RevertAt: &revertAt})
+	if lv := l.Levels(); lv.Level != "debug" || lv.Modules["db"] != "error" || lv.RevertAt == nil || !lv.RevertAt.Equal(revertAt) {
+		t.Errorf("Expected the broadcasted levels to be applied, got [%v]", lv)
+	}
+	l.applyChange(logLevelChange{Origin: "other"})
+	if lv := l.Levels(); lv.Level != "warn" || lv.RevertAt != nil {
+		t.Errorf("Expected the broadcasted reset to revert the levels, got [%v]", lv)
+	}
+	expired := time.Now().Add(-time.Second)
+	l.applyChange(logLevelChange{Origin: "other", Level: "debug", RevertAt: &expired})
+	if lv := l.Levels(); lv.Level != "warn" {
+		t.Errorf("Expected the expired broadcasted levels to be ignored, got [%v]", lv)
+	}
+
+	for _, v := range [][2]string{{"verbose", ""}, {"info", "db"}, {"info", "db=loud"}} {
+		if _, err := ParseLogLevels(v[0], v[1]); err == nil {
+			t.Errorf("Expected the invalid levels [%v] to be rejected", v)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package app
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// Metrics returns a pointer to the metricsUtil instance (metrics).
+// If metrics is not initialized, it creates a new metricsUtil instance, configures it, and assigns it to metrics.
+// It ensures that only one instance of metricsUtil is created and reused.
+func Metrics() *metricsUtil {
+	if metrics == nil {
+		metrics = newMetrics()
+		metrics.configure()
+	}
+	return metrics
+}
+
+// metrics is a pointer to a metricsUtil instance.
+// It is used to store and access the singleton instance of metricsUtil.
+var metrics *metricsUtil
+
+// metricsUtil represents the prometheus metrics of the app, they are exposed in the prometheus text format by Handler.
+// The metrics are registered on its own registry, so only the metrics below (and the go runtime and process metrics) are exposed.
+type metricsUtil struct {
+	AllowedIPs []*net.IPNet // the clients which can access the metrics with the token, empty to allow any client
+	Token      string       // the bearer token to access the metrics, the metrics are forbidden if it is empty
+	Registry   *prometheus.Registry
+
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	dbDuration    *prometheus.HistogramVec
+	dbErrors      *prometheus.CounterVec
+	fsOperations  *prometheus.CounterVec
+	cronRuns      *prometheus.CounterVec
+	cronDuration  *prometheus.HistogramVec
+	dbConnections map[string]bool
+	dbMu          sync.Mutex
+}
+
+// newMetrics creates the metrics registered on a new registry.
+func newMetrics() *metricsUtil {
+	m := &metricsUtil{Registry: prometheus.NewRegistry(), dbConnections: map[string]bool{}}
+	m.httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "The number of the handled http requests by the route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+	m.httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "The latency of the handled http requests by the route pattern, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+	m.dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "The duration of the gorm queries by the connection, operation and table.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"conn", "operation", "table"})
+	m.dbErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "The number of the failed gorm queries by the connection, operation and table, the not found error is not counted.",
+	}, []string{"conn", "operation", "table"})
+	m.fsOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "fs_operations_total",
+		Help: "The number of the storage operations by the driver, operation and result.",
+	}, []string{"driver", "operation", "result"})
+	m.cronRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cron_job_runs_total",
+		Help: "The number of the cron job runs by the job name.",
+	}, []string{"job"})
+	m.cronDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cron_job_duration_seconds",
+		Help:    "The duration of the cron job runs by the job name.",
+		Buckets: []float64{.01, .1, .5, 1, 5, 15, 60, 300},
+	}, []string{"job"})
+
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration,
+		m.dbDuration, m.dbErrors,
+		m.fsOperations,
+		m.cronRuns, m.cronDuration,
+		newCacheCollector(),
+	)
+	return m
+}
+
+// configure configures the access of the metrics based on the METRICS_XXX environment variables.
+func (m *metricsUtil) configure() {
+	m.Token = METRICS_TOKEN
+	if METRICS_ENABLED && m.Token == "" {
+		Logger().Warn().Str("METRICS_PATH", METRICS_PATH).Msg("The METRICS_TOKEN is empty, the metrics are forbidden.")
+	}
+	for _, v := range splitAndTrim(METRICS_ALLOWED_IPS) {
+		if !strings.Contains(v, "/") {
+			if strings.Contains(v, ":") {
+				v += "/128"
+			} else {
+				v += "/32"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(v)
+		if err != nil {
+			Logger().Warn().Str("METRICS_ALLOWED_IPS", v).Msg("Invalid ip, it is ignored.")
+			continue
+		}
+		m.AllowedIPs = append(m.AllowedIPs, ipNet)
+	}
+}
+
+// Handler is the REST API handler of METRICS_PATH, the client must send METRICS_TOKEN as the bearer token from METRICS_ALLOWED_IPS.
+func (m *metricsUtil) Handler(c *fiber.Ctx) error {
+	if !m.IsAllowed(c.IP(), c.Get(fiber.HeaderAuthorization)) {
+		return c.SendStatus(http.StatusForbidden)
+	}
+	return adaptor.HTTPHandler(promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{}))(c)
+}
+
+// IsAllowed reports whether the client of the ip with the authorization header can access the metrics.
+// The token is always required, since the ip is the peer address which is the ip of the proxy (for example 127.0.0.1 of the same host proxy)
+// for every client behind it, so the ip alone does not identify the client.
+func (m *metricsUtil) IsAllowed(ip, authorization string) bool {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if m.Token == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(m.Token)) != 1 {
+		return false
+	}
+	if len(m.AllowedIPs) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	for _, n := range m.AllowedIPs {
+		if parsed != nil && n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ObserveRequest records the handled http request, it is called by the access log middleware.
+func (m *metricsUtil) ObserveRequest(route, method string, status int, latency time.Duration) {
+	s := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(route, method, s).Inc()
+	m.httpDuration.WithLabelValues(route, method, s).Observe(latency.Seconds())
+}
+
+// ObserveFS records the storage operation, for example "upload" or "delete".
+func (m *metricsUtil) ObserveFS(driver, operation string, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.fsOperations.WithLabelValues(driver, operation, result).Inc()
+}
+
+// CronJob returns the job which records the runs of the job name, for example c.AddFunc(spec, Metrics().CronJob("outbox_relay", Outbox().Relay)).
+func (m *metricsUtil) CronJob(name string, job func()) func() {
+	return func() {
+		start := time.Now()
+		defer func() {
+			m.cronRuns.WithLabelValues(name).Inc()
+			m.cronDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		}()
+		job()
+	}
+}
+
+// RegisterDB registers the pool stats collector of the connection, it is called by DB().RegisterConn.
+func (m *metricsUtil) RegisterDB(connName string, conn *gorm.DB) {
+	m.dbMu.Lock()
+	defer m.dbMu.Unlock()
+	if m.dbConnections[connName] {
+		return // the stats of the reconnected connection are kept on the previous collector
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return
+	}
+	err = m.Registry.Register(collectors.NewDBStatsCollector(sqlDB, connName))
+	if err != nil {
+		Logger().Warn().Err(err).Str("conn", connName).Msg("Failed to register the db pool metrics.")
+		return
+	}
+	m.dbConnections[connName] = true
+}
+
+// GormPlugin returns the gorm plugin which records the duration and the errors of the queries of the connection.
+func (m *metricsUtil) GormPlugin(connName string) gorm.Plugin {
+	return &metricsPlugin{metrics: m, connName: connName}
+}
+
+// metricsPlugin is the gorm plugin which records the duration and the errors of the queries.
+type metricsPlugin struct {
+	metrics  *metricsUtil
+	connName string
+}
+
+// metricsStartKey is the key of the gorm instance which stores the start time of the query.
+const metricsStartKey = "metrics:start"
+
+// Name returns the name of the plugin.
+func (*metricsPlugin) Name() string {
+	return "metrics"
+}
+
+// Initialize registers the callbacks around every operation of the gorm.
+func (p *metricsPlugin) Initialize(db *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(metricsStartKey, time.Now())
+	}
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("metrics:before_create", before),
+		cb.Create().After("gorm:create").Register("metrics:after_create", p.after("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", before),
+		cb.Query().After("gorm:query").Register("metrics:after_query", p.after("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", before),
+		cb.Update().After("gorm:update").Register("metrics:after_update", p.after("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", before),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", p.after("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", before),
+		cb.Row().After("gorm:row").Register("metrics:after_row", p.after("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", before),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", p.after("raw")),
+	)
+}
+
+// after returns the callback which records the duration and the error of the operation.
+func (p *metricsPlugin) after(op string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(metricsStartKey)
+		if !ok {
+			return
+		}
+		start, _ := v.(time.Time)
+		table := tx.Statement.Table
+		p.metrics.dbDuration.WithLabelValues(p.connName, op, table).Observe(time.Since(start).Seconds())
+		if tx.Error != nil && !DB().IsNotFoundError(tx.Error) {
+			p.metrics.dbErrors.WithLabelValues(p.connName, op, table).Inc()
+		}
+	}
+}
+
+// cacheCollector exposes the hit and miss counters of Cache().Stats().
+type cacheCollector struct {
+	hits   *prometheus.Desc
+	misses *prometheus.Desc
+}
+
+// newCacheCollector creates the collector of the cache hit and miss counters.
+func newCacheCollector() *cacheCollector {
+	return &cacheCollector{
+		hits:   prometheus.NewDesc("cache_hits_total", "The number of the cache hits by the tier.", []string{"tier"}, nil),
+		misses: prometheus.NewDesc("cache_misses_total", "The number of the cache misses by the tier.", []string{"tier"}, nil),
+	}
+}
+
+// Describe sends the descriptors of the cache metrics.
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+}
+
+// Collect sends the current cache counters, nothing is sent before the cache is configured.
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	if cache == nil {
+		return
+	}
+	s := Cache().Stats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(s.LocalHits), "local")
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(s.LocalMisses), "local")
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(s.RedisHits), "redis")
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(s.RedisMisses), "redis")
+}
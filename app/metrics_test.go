@@ -0,0 +1,69 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMetrics(t *testing.T) {
+	m := newMetrics()
+	prev := METRICS_ALLOWED_IPS
+	METRICS_ALLOWED_IPS = "10.0.0.0/8, ::1, invalid"
+	m.configure()
+	METRICS_ALLOWED_IPS = prev
+	m.Token = "secret"
+
+	if !m.IsAllowed("10.1.2.3", "Bearer secret") || !m.IsAllowed("::1", "Bearer secret") {
+		t.Errorf("Expected the allowed ip with the token to be allowed")
+	}
+	if m.IsAllowed("10.1.2.3", "") || m.IsAllowed("::1", "Bearer wrong") || m.IsAllowed("8.8.8.8", "Bearer secret") {
+		t.Errorf("Expected the allowed ip without the token and the other ip to be forbidden")
+	}
+	if open := (&metricsUtil{Token: "secret"}); !open.IsAllowed("8.8.8.8", "Bearer secret") || open.IsAllowed("127.0.0.1", "") {
+		t.Errorf("Expected any ip with the token to be allowed without the allowed ips")
+	}
+	if (&metricsUtil{}).IsAllowed("127.0.0.1", "Bearer ") {
+		t.Errorf("Expected the metrics without the token to be forbidden")
+	}
+
+	m.ObserveRequest("/api/products/:id", "GET", 200, 30*time.Millisecond)
+	m.ObserveFS("memory", "upload", nil)
+	m.ObserveFS("memory", "delete", errors.New("failed"))
+	m.CronJob("outbox_relay", func() {})()
+
+	f := fiber.New()
+	f.Get("/metrics", m.Handler)
+	res, err := f.Test(httptest.NewRequest("GET", "/metrics", nil))
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	if res.StatusCode != fiber.StatusForbidden {
+		t.Errorf("Expected forbidden, got [%v]", res.StatusCode)
+	}
+
+	m.AllowedIPs = nil // the test client is 0.0.0.0
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer secret")
+	res, err = f.Test(req)
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	for _, expected := range []string{
+		`http_requests_total{method="GET",route="/api/products/:id",status="200"} 1`,
+		`http_request_duration_seconds_bucket{method="GET",route="/api/products/:id",status="200",le="0.05"} 1`,
+		`fs_operations_total{driver="memory",operation="delete",result="error"} 1`,
+		`cron_job_runs_total{job="outbox_relay"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected metrics to contain [%v], got [%v]", expected, string(body))
+		}
+	}
+}
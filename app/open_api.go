@@ -135,6 +135,16 @@ Example :
 ` + "`" + `` + "`" + `` + "`" + `
 /products?$group=category.id&$select=category.id,$sum:sold&$sort:-$sum:sold
 ` + "`" + `` + "`" + `` + "`" + `
+
+### Consistency
+
+The ` + "`" + `GET` + "`" + ` method may read from a db replica, so the data you have just written may not be visible yet.
+You can use the ` + "`" + `$consistency=strong` + "`" + ` query parameter to read from the primary db.
+
+Example :
+` + "`" + `` + "`" + `` + "`" + `
+GET /products/{id}?$consistency=strong
+` + "`" + `` + "`" + `` + "`" + `
 `
 
 	o.Info.Version = APP_VERSION
@@ -230,6 +240,14 @@ func (o *openAPIError) Forbidden() map[string]any {
 	return o.Response()
 }
 
+func (o *openAPIError) NotFound() map[string]any {
+	o.StatusCode = 404
+	o.Message = "The resource you have specified cannot be found."
+	o.SchemaName = "Error.NotFound"
+	o.Description = "The resource doesn't exist."
+	return o.Response()
+}
+
 func (o *openAPIError) Response() map[string]any {
 	res := map[string]any{
 		"content": map[string]any{
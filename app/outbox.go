@@ -0,0 +1,344 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"path"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// These are the status of the outbox event.
+const (
+	OutboxStatusPending    = "pending"
+	OutboxStatusDispatched = "dispatched"
+	OutboxStatusFailed     = "failed"
+)
+
+// Outbox returns a pointer to the outboxUtil instance (outbox).
+// If outbox is not initialized, it creates a new outboxUtil instance, configures it, and assigns it to outbox.
+// It ensures that only one instance of outboxUtil is created and reused.
+func Outbox() *outboxUtil {
+	if outbox == nil {
+		outbox = &outboxUtil{}
+		outbox.configure()
+	}
+	return outbox
+}
+
+// outbox is a pointer to an outboxUtil instance.
+// It is used to store and access the singleton instance of outboxUtil.
+var outbox *outboxUtil
+
+// outboxUtil represents a transactional outbox utility.
+// The use cases record the domain events on the outbox table in the same transaction as the data changes,
+// then the relay publishes them to the subscribers, so the event is never lost nor published for a rolled back change.
+// The delivery is at-least-once and ordered per aggregate id, so the subscribers must be idempotent (use the event id).
+type outboxUtil struct {
+	BatchSize   int
+	MaxAttempts int
+	Retention   time.Duration
+	Lease       time.Duration
+	ConnName    string
+	subscribers []outboxSubscriber
+	mu          sync.RWMutex
+	relayMu     sync.Mutex
+}
+
+// outboxSubscriber is a subscriber of the outbox events which match the pattern, see Outbox().Subscribe.
+type outboxSubscriber struct {
+	Name    string
+	Pattern string
+	Handle  func(OutboxEvent) error
+}
+
+// OutboxEvent is a domain event recorded on the outbox table, for example "products.created".
+type OutboxEvent struct {
+	ID            int64           `json:"id"                      gorm:"column:id;primaryKey;autoIncrement"`
+	TenantID      string          `json:"tenant_id,omitempty"     gorm:"column:tenant_id;size:64;index"`
+	Type          string          `json:"type"                    gorm:"column:type;size:128"`
+	AggregateType string          `json:"aggregate_type"          gorm:"column:aggregate_type;size:64"`
+	AggregateID   string          `json:"aggregate_id"            gorm:"column:aggregate_id;size:64;index"`
+	Payload       json.RawMessage `json:"payload"                 gorm:"column:payload;type:json"`
+	Status        string          `json:"-"                       gorm:"column:status;size:16;index"`
+	Attempts      int             `json:"-"                       gorm:"column:attempts"`
+	LastError     string          `json:"-"                       gorm:"column:last_error;type:text"`
+	Delivered     []string        `json:"-"                       gorm:"column:delivered;type:json;serializer:json"`
+	AvailableAt   time.Time       `json:"-"                       gorm:"column:available_at;index"`
+	LockedBy      string          `json:"-"                       gorm:"column:locked_by;size:32;index"`
+	LockedUntil   *time.Time      `json:"-"                       gorm:"column:locked_until"`
+	CreatedAt     time.Time       `json:"created_at"              gorm:"column:created_at"`
+	DispatchedAt  *time.Time      `json:"dispatched_at,omitempty" gorm:"column:dispatched_at;index"`
+}
+
+// TableVersion returns the versions of the OutboxEvent table in the database.
+// Change this value with date format YY.MM.DDHHii when any table structure changes.
+func (OutboxEvent) TableVersion() string {
+	return "26.10.191800"
+}
+
+// TableName returns the name of the OutboxEvent table in the database.
+func (OutboxEvent) TableName() string {
+	return "outbox"
+}
+
+// deliveredJSON returns the name of the subscribers which received the event as json, to be updated using map.
+func (e OutboxEvent) deliveredJSON() string {
+	b, _ := json.Marshal(e.Delivered)
+	return string(b)
+}
+
+// configure configures the outbox utility instance.
+// It subscribes the webhooks and the redis stream based on the OUTBOX_XXX environment variables.
+func (o *outboxUtil) configure() {
+	o.BatchSize = OUTBOX_BATCH_SIZE
+	o.MaxAttempts = OUTBOX_MAX_ATTEMPTS
+	o.Retention = OUTBOX_RETENTION
+	o.Lease = OUTBOX_LEASE
+	for _, url := range splitAndTrim(OUTBOX_WEBHOOK_URLS) {
+		o.Subscribe("webhook:"+url, "*", o.webhook(url))
+	}
+	if OUTBOX_REDIS_STREAM != "" {
+		o.Subscribe("redis:"+OUTBOX_REDIS_STREAM, "*", o.redisStream(OUTBOX_REDIS_STREAM))
+	}
+}
+
+// Subscribe registers the in-process handler of the events with type matching the pattern, for example "products.*".
+// The handler is called by the relay, it must be idempotent since the same event may be delivered more than once.
+func (o *outboxUtil) Subscribe(name, pattern string, handle func(OutboxEvent) error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.subscribers = append(o.subscribers, outboxSubscriber{Name: name, Pattern: pattern, Handle: handle})
+}
+
+// Record records the event on the outbox table using the tx, so it is committed or rolled back with the data changes.
+// The tx must be the one of the current ctx (Ctx.DB()), so the event is scoped to the tenant.
+func (o *outboxUtil) Record(tx *gorm.DB, eventType, aggregateType, aggregateID string, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	e := OutboxEvent{
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       b,
+		Status:        OutboxStatusPending,
+		AvailableAt:   now,
+		CreatedAt:     now,
+	}
+	return tx.Create(&e).Error
+}
+
+// Relay publishes the available pending events to the subscribers, it is called periodically by the scheduler on every main instance.
+// The events are claimed with a lease, so the relays of the other instances skip them, and they are read from the primary since the replica may lag.
+// The events are published in order per aggregate id, the next events of an aggregate wait until
+// the failed one is successfully retried, except it exceeds the max attempts.
+func (o *outboxUtil) Relay() {
+	o.RelayContext(context.Background())
+}
+
+// RelayContext is like Relay but it stops dispatching when the ctx is done, for example on shutdown,
+// the claimed events which are not dispatched yet are released to the next relay.
+func (o *outboxUtil) RelayContext(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+	if !o.relayMu.TryLock() {
+		return // the previous relay is still running
+	}
+	defer o.relayMu.Unlock()
+
+	tx, err := DB().Conn(o.connName())
+	if err != nil {
+		Logger().Module("outbox").Error().Err(err).Msg("Failed to relay the outbox events.")
+		return
+	}
+	tx = tx.Clauses(dbresolver.Write)
+	events, err := o.claim(tx.WithContext(ctx))
+	if err != nil {
+		Logger().Module("outbox").Error().Err(err).Msg("Failed to relay the outbox events.")
+		return
+	}
+
+	blocked := map[string]bool{}
+	for _, e := range events {
+		key := e.TenantID + ":" + e.AggregateType + ":" + e.AggregateID
+		if blocked[key] || ctx.Err() != nil {
+			// keep the order, release it to wait for the previous event of the same aggregate or the next relay
+			tx.Model(&OutboxEvent{}).Where("id = ? AND locked_by = ?", e.ID, e.LockedBy).Update("locked_until", nil)
+			continue
+		}
+		delivered, err := o.dispatch(e)
+		e.Delivered = append(e.Delivered, delivered...)
+		if err != nil {
+			blocked[key] = true
+			o.retry(tx, e, err)
+			continue
+		}
+		dispatchedAt := time.Now().UTC()
+		tx.Model(&OutboxEvent{}).Where("id = ? AND locked_by = ?", e.ID, e.LockedBy).Updates(map[string]any{
+			"status":        OutboxStatusDispatched,
+			"attempts":      e.Attempts + 1,
+			"delivered":     e.deliveredJSON(),
+			"locked_until":  nil,
+			"dispatched_at": dispatchedAt,
+		})
+	}
+}
+
+// claim leases the next batch of the available pending events to this relay until the lease is expired.
+// An event is skipped while an earlier event of the same aggregate waits for its backoff or is leased by other relay, to keep the order.
+func (o *outboxUtil) claim(tx *gorm.DB) ([]OutboxEvent, error) {
+	now := time.Now().UTC()
+	ids := []int64{}
+	err := tx.Model(&OutboxEvent{}).
+		Where("status = ? AND available_at <= ? AND (locked_until IS NULL OR locked_until < ?)", OutboxStatusPending, now, now).
+		Where("NOT EXISTS (SELECT 1 FROM outbox p WHERE p.tenant_id = outbox.tenant_id AND p.aggregate_type = outbox.aggregate_type AND p.aggregate_id = outbox.aggregate_id "+
+			"AND p.id < outbox.id AND p.status = ? AND (p.available_at > ? OR p.locked_until >= ?))", OutboxStatusPending, now, now).
+		Order("id").Limit(o.BatchSize).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	// the update is atomic per row, so the event which is claimed by other relay in the meantime is not claimed again
+	token := NewNullUUID().String
+	err = tx.Model(&OutboxEvent{}).
+		Where("id IN ? AND status = ? AND (locked_until IS NULL OR locked_until < ?)", ids, OutboxStatusPending, now).
+		Updates(map[string]any{"locked_by": token, "locked_until": now.Add(o.Lease)}).Error
+	if err != nil {
+		return nil, err
+	}
+	events := []OutboxEvent{}
+	err = tx.Where("locked_by = ? AND status = ?", token, OutboxStatusPending).Order("id").Find(&events).Error
+	return events, err
+}
+
+// dispatch publishes the event to the matching subscribers which have not received it yet,
+// it returns the name of the subscribers which received it, so they are skipped when the event is retried.
+func (o *outboxUtil) dispatch(e OutboxEvent) ([]string, error) {
+	o.mu.RLock()
+	subscribers := append([]outboxSubscriber{}, o.subscribers...)
+	o.mu.RUnlock()
+
+	delivered := []string{}
+	errs := []error{}
+	for _, s := range subscribers {
+		if ok, _ := path.Match(s.Pattern, e.Type); !ok || slices.Contains(e.Delivered, s.Name) {
+			continue
+		}
+		if err := s.Handle(e); err != nil {
+			errs = append(errs, errors.New(s.Name+": "+err.Error()))
+			continue
+		}
+		delivered = append(delivered, s.Name)
+	}
+	return delivered, errors.Join(errs...)
+}
+
+// retry schedules the failed event with exponential backoff, or marks it as failed if it exceeds the max attempts.
+func (o *outboxUtil) retry(tx *gorm.DB, e OutboxEvent, err error) {
+	attempts := e.Attempts + 1
+	status := OutboxStatusPending
+	if attempts >= o.MaxAttempts {
+		status = OutboxStatusFailed
+		Logger().Module("outbox").Error().Err(err).Int64("id", e.ID).Str("type", e.Type).Str("aggregate_id", e.AggregateID).Msg("Outbox event is failed after max attempts.")
+	} else {
+		Logger().Module("outbox").Warn().Err(err).Int64("id", e.ID).Str("type", e.Type).Int("attempts", attempts).Msg("Failed to dispatch the outbox event, it will be retried.")
+	}
+	backoff := time.Duration(1<<min(attempts, 10)) * time.Second
+	tx.Model(&OutboxEvent{}).Where("id = ? AND locked_by = ?", e.ID, e.LockedBy).Updates(map[string]any{
+		"status":       status,
+		"attempts":     attempts,
+		"last_error":   err.Error(),
+		"delivered":    e.deliveredJSON(),
+		"locked_until": nil,
+		"available_at": time.Now().UTC().Add(backoff),
+	})
+}
+
+// Cleanup deletes the dispatched events older than the retention, it is called periodically by the scheduler.
+func (o *outboxUtil) Cleanup() {
+	tx, err := DB().Conn(o.connName())
+	if err == nil {
+		err = tx.Clauses(dbresolver.Write).
+			Where("status = ? AND dispatched_at < ?", OutboxStatusDispatched, time.Now().UTC().Add(-o.Retention)).
+			Delete(&OutboxEvent{}).Error
+	}
+	if err != nil {
+		Logger().Module("outbox").Error().Err(err).Msg("Failed to cleanup the outbox events.")
+	}
+}
+
+// connName returns the name of the db connection of the outbox table, the tests use their own connection.
+func (o *outboxUtil) connName() string {
+	if o.ConnName == "" {
+		return "main"
+	}
+	return o.ConnName
+}
+
+// webhook returns the handler which posts the event to the url.
+// The body is signed using HMAC-SHA256 with OUTBOX_WEBHOOK_SECRET on X-Signature header if the secret is set.
+func (*outboxUtil) webhook(url string) func(OutboxEvent) error {
+	return func(e OutboxEvent) error {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		hc := HttpClient("POST", url)
+		hc.AddHeader("Content-Type", "application/json")
+		hc.AddHeader("X-Event-ID", strconv.FormatInt(e.ID, 10))
+		hc.AddHeader("X-Event-Type", e.Type)
+		if OUTBOX_WEBHOOK_SECRET != "" {
+			mac := hmac.New(sha256.New, []byte(OUTBOX_WEBHOOK_SECRET))
+			mac.Write(body)
+			hc.AddHeader("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+		}
+		err = hc.AddJsonBody(json.RawMessage(body)) // send the signed bytes as is
+		if err != nil {
+			return err
+		}
+		res, err := hc.Send()
+		if err != nil {
+			return err
+		}
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			return errors.New("webhook responded with status " + strconv.Itoa(res.StatusCode))
+		}
+		return nil
+	}
+}
+
+// redisStream returns the handler which adds the event to the redis stream.
+func (*outboxUtil) redisStream(stream string) func(OutboxEvent) error {
+	return func(e OutboxEvent) error {
+		if !Cache().IsUseRedis {
+			return errors.New("redis is not available")
+		}
+		return Cache().RedisClient.XAdd(context.Background(), &redis.XAddArgs{
+			Stream: stream,
+			MaxLen: int64(OUTBOX_REDIS_STREAM_MAX_LEN),
+			Approx: true,
+			Values: map[string]any{
+				"id":             e.ID,
+				"tenant_id":      e.TenantID,
+				"type":           e.Type,
+				"aggregate_type": e.AggregateType,
+				"aggregate_id":   e.AggregateID,
+				"payload":        string(e.Payload),
+			},
+		}).Err()
+	}
+}
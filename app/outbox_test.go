@@ -0,0 +1,125 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestOutboxDispatch(t *testing.T) {
+	o := &outboxUtil{}
+	received := []string{}
+	o.Subscribe("products", "products.*", func(e OutboxEvent) error {
+		received = append(received, "products:"+e.Type)
+		return nil
+	})
+	o.Subscribe("all", "*", func(e OutboxEvent) error {
+		received = append(received, "all:"+e.Type)
+		return nil
+	})
+	o.Subscribe("failing", "categories.*", func(e OutboxEvent) error {
+		return errors.New("unavailable")
+	})
+
+	if _, err := o.dispatch(OutboxEvent{Type: "products.created"}); err != nil {
+		t.Errorf("Error occurred [%v]", err)
+	}
+	if len(received) != 2 || received[0] != "products:products.created" || received[1] != "all:products.created" {
+		t.Errorf("Expected event received by [products all], got [%v]", received)
+	}
+	if _, err := o.dispatch(OutboxEvent{Type: "categories.deleted"}); err == nil {
+		t.Errorf("Expected error from failing subscriber, got nil")
+	}
+
+	received = []string{}
+	delivered, _ := o.dispatch(OutboxEvent{Type: "products.updated", Delivered: []string{"products"}})
+	if len(received) != 1 || received[0] != "all:products.updated" || !slices.Equal(delivered, []string{"all"}) {
+		t.Errorf("Expected event received only by [all], got [%v]", received)
+	}
+}
+
+func TestOutboxRelay(t *testing.T) {
+	tx := Test().Tx
+	DB().RegisterTable("main", OutboxEvent{})
+	DB().MigrateTable(tx, "main", Setting{})
+	tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&OutboxEvent{})
+
+	o := &outboxUtil{BatchSize: 10, MaxAttempts: 10, Lease: time.Minute, ConnName: testMainDB}
+	received := []string{}
+	isFailing := true
+	o.Subscribe("audit", "*", func(e OutboxEvent) error {
+		received = append(received, "audit:"+e.Type+":"+e.AggregateID)
+		return nil
+	})
+	o.Subscribe("search", "*", func(e OutboxEvent) error {
+		if isFailing && e.Type == "products.created" {
+			return errors.New("unavailable")
+		}
+		received = append(received, "search:"+e.Type+":"+e.AggregateID)
+		return nil
+	})
+	o.Record(tx, "products.created", "products", "1", nil)
+	o.Record(tx, "products.updated", "products", "1", nil)
+	o.Record(tx, "products.updated", "products", "2", nil)
+	o.Record(tx, "products.deleted", "products", "3", nil)
+	tx.Model(&OutboxEvent{}).Where("aggregate_id = ?", "3").Updates(map[string]any{"locked_by": "other", "locked_until": time.Now().UTC().Add(time.Minute)})
+
+	o.Relay()
+	expected := []string{"audit:products.created:1", "audit:products.updated:2", "search:products.updated:2"}
+	if !slices.Equal(received, expected) {
+		t.Errorf("Expected the first relay to deliver [%v], got [%v]", expected, received)
+	}
+
+	// the failed event waits for its backoff, the next event of the same aggregate must wait for it
+	received = []string{}
+	isFailing = false
+	o.Relay()
+	if len(received) != 0 {
+		t.Errorf("Expected nothing delivered while the event waits for its backoff, got [%v]", received)
+	}
+
+	tx.Model(&OutboxEvent{}).Where("aggregate_id = ?", "1").Update("available_at", time.Now().UTC().Add(-time.Second))
+	o.Relay()
+	expected = []string{"search:products.created:1", "audit:products.updated:1", "search:products.updated:1"}
+	if !slices.Equal(received, expected) {
+		t.Errorf("Expected the retry to deliver [%v] only to the failed subscriber then the next event, got [%v]", expected, received)
+	}
+
+	pending := int64(0)
+	tx.Model(&OutboxEvent{}).Where("status = ?", OutboxStatusPending).Count(&pending)
+	if pending != 1 {
+		t.Errorf("Expected only the event leased by other relay to be pending, got [%v]", pending)
+	}
+}
+
+func TestOutboxRelayContext(t *testing.T) {
+	tx := Test().Tx
+	DB().RegisterTable("main", OutboxEvent{})
+	DB().MigrateTable(tx, "main", Setting{})
+	tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&OutboxEvent{})
+
+	o := &outboxUtil{BatchSize: 10, MaxAttempts: 10, Lease: time.Minute, ConnName: testMainDB}
+	ctx, cancel := context.WithCancel(context.Background())
+	received := []string{}
+	o.Subscribe("audit", "*", func(e OutboxEvent) error {
+		received = append(received, e.AggregateID)
+		cancel() // the deadline is reached while the first event is dispatched
+		return nil
+	})
+	o.Record(tx, "products.created", "products", "1", nil)
+	o.Record(tx, "products.created", "products", "2", nil)
+
+	o.RelayContext(ctx)
+	if !slices.Equal(received, []string{"1"}) {
+		t.Errorf("Expected the relay to stop after the ctx is done, got [%v]", received)
+	}
+	released := int64(0)
+	tx.Model(&OutboxEvent{}).Where("status = ? AND locked_until IS NULL", OutboxStatusPending).Count(&released)
+	if released != 1 {
+		t.Errorf("Expected the event which is not dispatched to be released, got [%v]", released)
+	}
+}
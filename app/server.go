@@ -1,7 +1,9 @@
 package app
 
 import (
+	"context"
 	"embed"
+	"io"
 	"io/fs"
 	"net/http"
 	"strings"
@@ -27,17 +29,32 @@ type serverUtil struct {
 	CertFile              string
 	KeyFile               string
 	DisableStartupMessage bool
+	BodyLimit             int
 	Fiber                 *fiber.App
+	streamRoutes          []serverRoute
+}
+
+// serverRoute is the method and the path of the route, the path segment which starts with ":" is the param.
+type serverRoute struct {
+	method string
+	path   string
 }
 
 func (s *serverUtil) configure() {
 	s.Addr = ":" + APP_PORT
+	s.BodyLimit = max(fiber.DefaultBodyLimit, FILE_MAX_SIZE+1<<20) // the uploaded file with the multipart overhead
 	s.Fiber = fiber.New(fiber.Config{
-		ErrorHandler:          Error().Handler,
-		ReadBufferSize:        16384,
-		DisableStartupMessage: true,
+		ErrorHandler:                 Error().Handler,
+		ReadBufferSize:               16384,
+		BodyLimit:                    s.BodyLimit,
+		StreamRequestBody:            true, // for the stream routes, the body of the other routes is limited by limitBody
+		DisablePreParseMultipartForm: true, // the multipart body is parsed on demand, after it is limited by limitBody
+		DisableStartupMessage:        true,
 	})
+	s.AddMiddleware(AccessLog().New)
+	s.AddMiddleware(Trace().New)
 	s.AddMiddleware(Error().Recover)
+	s.AddMiddleware(s.limitBody)
 }
 
 // use grest to add route so it can generate swagger api documentation automatically
@@ -54,6 +71,77 @@ func (s *serverUtil) AddRoute(path, method string, handler fiber.Handler, operat
 	}
 }
 
+// AddStreamRoute adds the route which reads the request body as a stream with c.Context().RequestBodyStream(),
+// so the large body (for example the chunk of the resumable upload) is not buffered in the memory and it is not limited by the BodyLimit.
+func (s *serverUtil) AddStreamRoute(path, method string, handler fiber.Handler, operation OpenAPIOperationInterface) {
+	s.streamRoutes = append(s.streamRoutes, serverRoute{method: method, path: strings.ReplaceAll(strings.ReplaceAll(path, "{", ":"), "}", "")})
+	s.AddRoute(path, method, handler, operation)
+}
+
+// limitBody limits the request body of the routes other than the stream routes to the BodyLimit.
+// The StreamRequestBody makes fasthttp stream the body which is larger than the limit (or chunked) instead of rejecting it,
+// so the larger body is rejected here and the chunked body is read up to the limit.
+func (s *serverUtil) limitBody(c *fiber.Ctx) error {
+	stream := c.Request().BodyStream()
+	if stream == nil {
+		return c.Next()
+	}
+	if s.isStreamRoute(c.Method(), c.Path()) {
+		err := c.Next()
+		if err != nil || c.Response().StatusCode() >= http.StatusBadRequest {
+			c.Context().SetConnectionClose() // the failed stream route may not read the body, so it can not be followed by the next request
+		}
+		return err
+	}
+	length := c.Request().Header.ContentLength()
+	if length > s.BodyLimit {
+		c.Context().SetConnectionClose() // the unread body can not be followed by the next request on the connection
+		return fiber.ErrRequestEntityTooLarge
+	}
+	if length == -1 {
+		body, err := io.ReadAll(io.LimitReader(stream, int64(s.BodyLimit)+1))
+		if err != nil {
+			c.Context().SetConnectionClose()
+			return fiber.ErrBadRequest
+		}
+		if len(body) > s.BodyLimit {
+			c.Context().SetConnectionClose()
+			return fiber.ErrRequestEntityTooLarge
+		}
+		c.Request().SetBodyRaw(body)
+	}
+	return c.Next()
+}
+
+// isStreamRoute returns true if the method and the path match the route added by AddStreamRoute.
+func (s *serverUtil) isStreamRoute(method, path string) bool {
+	for _, r := range s.streamRoutes {
+		if r.method == method && r.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns true if the path matches the route path, the param segment matches any non-empty segment.
+func (r serverRoute) match(path string) bool {
+	routeSegments := strings.Split(strings.Trim(r.path, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(routeSegments) != len(pathSegments) {
+		return false
+	}
+	for i, segment := range routeSegments {
+		if strings.HasPrefix(segment, ":") {
+			if pathSegments[i] == "" {
+				return false
+			}
+		} else if !strings.EqualFold(segment, pathSegments[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *serverUtil) AddStaticRoute(path string, fsConfig filesystem.Config) {
 	s.Fiber.Use(path, filesystem.New(fsConfig))
 }
@@ -92,6 +180,11 @@ func (s *serverUtil) Start() error {
 	return s.Fiber.Listen(s.Addr)
 }
 
+// Shutdown stops accepting the new requests and waits for the in-flight ones until the ctx is done, then the remaining connections are closed.
+func (s *serverUtil) Shutdown(ctx context.Context) error {
+	return s.Fiber.ShutdownWithContext(ctx)
+}
+
 func (s *serverUtil) Test(req *http.Request, msTimeout ...int) (*http.Response, error) {
 	return s.Fiber.Test(req, msTimeout...)
 }
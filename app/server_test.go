@@ -0,0 +1,59 @@
+package app
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestServerLimitBody(t *testing.T) {
+	s := &serverUtil{}
+	s.configure()
+	s.BodyLimit = 10
+	s.AddRoute("/items", "POST", func(c *fiber.Ctx) error {
+		return c.SendString(strconv.Itoa(len(c.Body())))
+	}, nil)
+	s.AddStreamRoute("/items/{id}/content", "PATCH", func(c *fiber.Ctx) error {
+		n, _ := io.Copy(io.Discard, c.Context().RequestBodyStream())
+		return c.SendString(strconv.FormatInt(n, 10))
+	}, nil)
+
+	tests := []struct {
+		method     string
+		path       string
+		size       int
+		isChunked  bool
+		statusCode int
+		body       string
+	}{
+		{"POST", "/items", 5, false, http.StatusOK, "5"},
+		{"POST", "/items", 20, false, http.StatusRequestEntityTooLarge, ""},
+		{"POST", "/items", 5, true, http.StatusOK, "5"},
+		{"POST", "/items", 20, true, http.StatusRequestEntityTooLarge, ""},
+		{"PATCH", "/items/a/content", 20, false, http.StatusOK, "20"},
+		{"PATCH", "/items/a/content", 20, true, http.StatusOK, "20"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(strings.Repeat("x", test.size)))
+		if test.isChunked {
+			req.ContentLength = -1
+			req.TransferEncoding = []string{"chunked"}
+		}
+		res, err := s.Test(req)
+		if err != nil {
+			t.Fatalf("Error occurred [%v %v %v chunked=%v] [%v]", test.method, test.path, test.size, test.isChunked, err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		if res.StatusCode != test.statusCode {
+			t.Errorf("Expected [%v %v %v chunked=%v] status [%v], got [%v]", test.method, test.path, test.size, test.isChunked, test.statusCode, res.StatusCode)
+		}
+		if test.body != "" && string(body) != test.body {
+			t.Errorf("Expected [%v %v %v chunked=%v] body [%v], got [%v]", test.method, test.path, test.size, test.isChunked, test.body, string(body))
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Shutdown flushes the async work and closes the resources in order, it is called after the server is drained and the scheduler is stopped.
+// The error groups and the stale cache refreshes still use the db and the redis, the alerts are flushed after them since they may raise some,
+// then the traces are exported before the redis, the db and finally the log writers are closed.
+// The waits are bounded by the ctx, the remaining steps are still done when it is done so the resources are always released.
+func Shutdown(ctx context.Context) error {
+	steps := []struct {
+		name  string
+		close func() error
+	}{
+		{"error_groups", func() error { ErrorGroups().Close(); return nil }},
+		{"cache_refreshes", func() error { return Cache().Flush(ctx) }},
+		{"alerts", func() error { return Alert().Flush(ctx) }},
+		{"traces", func() error { return Trace().Shutdown(ctx) }},
+		{"cache", Cache().Close},
+		{"db", func() error { DB().Close(); return nil }},
+	}
+	var errs error
+	for _, s := range steps {
+		err := s.close()
+		if err != nil {
+			Logger().Error().Err(err).Str("step", s.name).Msg("Failed to shut down.")
+			errs = errors.Join(errs, err)
+		}
+	}
+	Logger().Info().Msg("Shutdown is completed.")
+	return errors.Join(errs, Logger().Close())
+}
+
+// waitContext waits until the wait group is done, or the ctx is done.
+func waitContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
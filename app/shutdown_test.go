@@ -0,0 +1,30 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWaitContext(t *testing.T) {
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		time.Sleep(10 * time.Millisecond)
+	}()
+	err := waitContext(context.Background(), &wg)
+	if err != nil {
+		t.Errorf("Expected the wait group to be done, got [%v]", err)
+	}
+
+	wg.Add(1)
+	defer wg.Done()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = waitContext(ctx, &wg)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected the wait to be bounded by the ctx, got [%v]", err)
+	}
+}
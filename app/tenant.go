@@ -0,0 +1,294 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TenantColumn is the column name used to scope the data of the tenant-aware tables.
+const TenantColumn = "tenant_id"
+
+// Tenant returns a pointer to the tenantUtil instance (tnt).
+// If tnt is not initialized, it creates a new tenantUtil instance, configures it, and assigns it to tnt.
+// It ensures that only one instance of tenantUtil is created and reused.
+func Tenant() *tenantUtil {
+	if tnt == nil {
+		tnt = &tenantUtil{}
+		tnt.configure()
+	}
+	return tnt
+}
+
+// tnt is a pointer to a tenantUtil instance.
+// It is used to store and access the singleton instance of tenantUtil.
+var tnt *tenantUtil
+
+// tenantUtil represents a multi-tenancy utility.
+// It resolves the tenant of the request and scopes the db queries, cache keys and file paths to that tenant.
+type tenantUtil struct {
+	IsEnabled     bool
+	Resolvers     []string // "header", "subdomain" and/or "token", in order of precedence
+	Header        string
+	BaseDomain    string
+	TokenClaim    string
+	ExcludedPaths []string
+	scoped        sync.Map // map[reflect.Type]bool
+}
+
+// tenantCtxKey is the context key used to carry the tenant id to the db session, etc.
+type tenantCtxKey struct{}
+
+// tenantIDPattern restricts the tenant id since it is used as a cache key and file path prefix.
+var tenantIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// configure configures the tenant utility instance based on the TENANT_XXX environment variables.
+func (t *tenantUtil) configure() {
+	t.IsEnabled = TENANT_ENABLED
+	t.Header = TENANT_HEADER
+	t.BaseDomain = strings.TrimPrefix(TENANT_BASE_DOMAIN, ".")
+	t.TokenClaim = TENANT_TOKEN_CLAIM
+	t.Resolvers = splitAndTrim(TENANT_RESOLVERS)
+	t.ExcludedPaths = splitAndTrim(TENANT_EXCLUDED_PATHS)
+}
+
+// Resolve returns the tenant id of the request based on the configured resolvers.
+// Every resolver that returns a value must agree, for example the header must match the subdomain,
+// and the tenant claim of a verified token must match the resolved tenant whenever the request is authenticated,
+// so a client can't switch to another tenant by sending a header.
+func (t *tenantUtil) Resolve(c *fiber.Ctx) (string, error) {
+	tenantID := ""
+	for _, r := range t.Resolvers {
+		id := ""
+		switch r {
+		case "header":
+			id = c.Get(t.Header)
+		case "subdomain":
+			id = t.fromSubdomain(c.Hostname())
+		case "token":
+			id, _ = t.fromToken(c.Get(fiber.HeaderAuthorization))
+		}
+		if id == "" {
+			continue
+		}
+		if tenantID != "" && id != tenantID {
+			return "", Error().New(http.StatusForbidden, "invalid tenant")
+		}
+		tenantID = id
+	}
+	if tenantID == "" {
+		return "", nil
+	}
+	if !t.IsValidID(tenantID) {
+		return "", Error().New(http.StatusForbidden, "invalid tenant")
+	}
+	if tokenTenantID, isAuthenticated := t.fromToken(c.Get(fiber.HeaderAuthorization)); isAuthenticated && tokenTenantID != tenantID {
+		return "", Error().New(http.StatusForbidden, "invalid tenant")
+	}
+	return tenantID, nil
+}
+
+// fromSubdomain returns the subdomain of the host relative to the BaseDomain, for example "acme" for "acme.example.com".
+func (t *tenantUtil) fromSubdomain(host string) string {
+	if t.BaseDomain == "" || !strings.HasSuffix(host, "."+t.BaseDomain) {
+		return ""
+	}
+	sub := strings.TrimSuffix(host, "."+t.BaseDomain)
+	if i := strings.LastIndex(sub, "."); i >= 0 {
+		sub = sub[i+1:]
+	}
+	return sub
+}
+
+// fromToken returns the tenant claim of the bearer token, and whether the token is verified (the request is authenticated).
+func (t *tenantUtil) fromToken(authorization string) (string, bool) {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	claims := map[string]any{}
+	if err := Crypto().ParseAndVerifyJWT(token, &claims); err != nil {
+		return "", false
+	}
+	id, _ := claims[t.TokenClaim].(string)
+	return id, true
+}
+
+// IsValidID reports whether the tenant id is safe to be used as a cache key or file path prefix.
+func (*tenantUtil) IsValidID(tenantID string) bool {
+	return tenantIDPattern.MatchString(tenantID)
+}
+
+// IsExcludedPath reports whether the path can be accessed without tenant.
+func (t *tenantUtil) IsExcludedPath(path string) bool {
+	for _, p := range t.ExcludedPaths {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithContext returns a copy of ctx that carries the tenant id.
+func (*tenantUtil) WithContext(ctx context.Context, tenantID string) context.Context {
+	if tenantID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, tenantCtxKey{}, tenantID)
+}
+
+// FromContext returns the tenant id carried by ctx, if any.
+func (*tenantUtil) FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenantID, _ := ctx.Value(tenantCtxKey{}).(string)
+	return tenantID
+}
+
+// Prefix returns the key prefixed with the tenant id, used for cache keys.
+func (*tenantUtil) Prefix(tenantID, key string) string {
+	if tenantID == "" {
+		return key
+	}
+	return tenantID + ":" + key
+}
+
+// Path returns the file path prefixed with the tenant directory, used for file storage.
+func (*tenantUtil) Path(tenantID, fileName string) string {
+	if tenantID == "" {
+		return fileName
+	}
+	return "tenants/" + tenantID + "/" + fileName
+}
+
+// SplitPath splits the file path into the tenant id and the file name, it is the reverse of Path.
+func (*tenantUtil) SplitPath(path string) (string, string) {
+	rest, ok := strings.CutPrefix(path, "tenants/")
+	if !ok {
+		return "", path
+	}
+	tenantID, fileName, ok := strings.Cut(rest, "/")
+	if !ok {
+		return "", path
+	}
+	return tenantID, fileName
+}
+
+// IsScoped reports whether the model has the TenantID field, which means its data is scoped per tenant.
+func (t *tenantUtil) IsScoped(model any) bool {
+	typ := reflect.TypeOf(model)
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return false
+	}
+	if isScoped, ok := t.scoped.Load(typ); ok {
+		return isScoped.(bool)
+	}
+	_, isScoped := typ.FieldByName("TenantID")
+	t.scoped.Store(typ, isScoped)
+	return isScoped
+}
+
+// Plugin returns the gorm plugin which scopes every query, update, delete and insert of the tenant-aware
+// tables to the tenant carried by the db session context, so a use case can't access another tenant data
+// even if it forgets to filter.
+func (*tenantUtil) Plugin() gorm.Plugin {
+	return &tenantPlugin{}
+}
+
+// tenantPlugin is a gorm plugin to scope the tenant-aware tables, use Tenant().Plugin() to access it.
+type tenantPlugin struct{}
+
+// Name returns the name of the gorm plugin.
+func (*tenantPlugin) Name() string {
+	return "tenant"
+}
+
+// Initialize registers the tenant callbacks to the gorm db.
+func (p *tenantPlugin) Initialize(db *gorm.DB) error {
+	err := db.Callback().Create().Before("gorm:create").Register("tenant:create", p.create)
+	if err == nil {
+		err = db.Callback().Query().Before("gorm:query").Register("tenant:query", p.filter)
+	}
+	if err == nil {
+		err = db.Callback().Row().Before("gorm:row").Register("tenant:row", p.filter)
+	}
+	if err == nil {
+		err = db.Callback().Update().Before("gorm:update").Register("tenant:update", p.update)
+	}
+	if err == nil {
+		err = db.Callback().Delete().Before("gorm:delete").Register("tenant:delete", p.filter)
+	}
+	return err
+}
+
+// tenantID returns the tenant id of the statement if the statement table is tenant-aware.
+func (*tenantPlugin) tenantID(db *gorm.DB) string {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Schema.LookUpField(TenantColumn) == nil {
+		return ""
+	}
+	return Tenant().FromContext(db.Statement.Context)
+}
+
+// create sets the tenant id of the new records, overriding any value sent by the client.
+func (p *tenantPlugin) create(db *gorm.DB) {
+	tenantID := p.tenantID(db)
+	if tenantID == "" {
+		return
+	}
+	field := db.Statement.Schema.LookUpField(TenantColumn)
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			db.AddError(field.Set(db.Statement.Context, reflect.Indirect(rv.Index(i)), tenantID))
+		}
+	case reflect.Struct:
+		db.AddError(field.Set(db.Statement.Context, rv, tenantID))
+	}
+}
+
+// update prevents the tenant id from being changed, then filters the updated rows.
+func (p *tenantPlugin) update(db *gorm.DB) {
+	if p.tenantID(db) == "" {
+		return
+	}
+	db.Statement.Omits = append(db.Statement.Omits, TenantColumn)
+	p.filter(db)
+}
+
+// filter adds the tenant condition to the statement.
+func (p *tenantPlugin) filter(db *gorm.DB) {
+	tenantID := p.tenantID(db)
+	if tenantID == "" {
+		return
+	}
+	table := db.Statement.Table
+	if f := strings.Fields(table); len(f) > 1 {
+		table = f[len(f)-1] // use the alias, for example "products m" or "products AS m"
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: table, Name: TenantColumn}, Value: tenantID},
+	}})
+}
+
+// splitAndTrim splits the comma separated string and removes the empty values.
+func splitAndTrim(s string) []string {
+	res := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestTenantResolve(t *testing.T) {
+	tu := &tenantUtil{
+		Resolvers:  []string{"header", "subdomain"},
+		Header:     "X-Tenant-ID",
+		BaseDomain: "example.com",
+	}
+	tests := []struct {
+		host     string
+		header   string
+		expected string
+		isErr    bool
+	}{
+		{host: "api.local", expected: ""},
+		{host: "api.local", header: "acme", expected: "acme"},
+		{host: "acme.example.com", expected: "acme"},
+		{host: "acme.example.com", header: "acme", expected: "acme"},
+		{host: "acme.example.com", header: "globex", isErr: true},
+		{host: "api.local", header: "../acme", isErr: true},
+	}
+	for _, test := range tests {
+		f := fiber.New()
+		f.Get("/", func(c *fiber.Ctx) error {
+			tenantID, err := tu.Resolve(c)
+			if (err != nil) != test.isErr {
+				t.Errorf("Expected error [%v], got [%v]", test.isErr, err)
+			}
+			if tenantID != test.expected {
+				t.Errorf("Expected tenant [%v], got [%v]", test.expected, tenantID)
+			}
+			return nil
+		})
+		req := httptest.NewRequest("GET", "http://"+test.host+"/", nil)
+		if test.header != "" {
+			req.Header.Set("X-Tenant-ID", test.header)
+		}
+		_, err := f.Test(req)
+		if err != nil {
+			t.Errorf("Error occurred [%v]", err)
+		}
+	}
+}
+
+func TestTenantResolveToken(t *testing.T) {
+	tu := &tenantUtil{
+		Resolvers:  []string{"header"},
+		Header:     "X-Tenant-ID",
+		TokenClaim: "tenant_id",
+	}
+	acme, err := Crypto().NewJWT(map[string]any{"tenant_id": "acme"})
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	noTenant, err := Crypto().NewJWT(map[string]any{"sub": "1"})
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+	tests := []struct {
+		token  string
+		header string
+		isErr  bool
+	}{
+		{token: acme, header: "acme"},
+		{token: acme, header: "globex", isErr: true},
+		{token: noTenant, header: "acme", isErr: true},
+		{header: "acme"},
+	}
+	for _, test := range tests {
+		f := fiber.New()
+		f.Get("/", func(c *fiber.Ctx) error {
+			_, err := tu.Resolve(c)
+			if (err != nil) != test.isErr {
+				t.Errorf("Expected error [%v] of header [%v], got [%v]", test.isErr, test.header, err)
+			}
+			return nil
+		})
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Tenant-ID", test.header)
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		_, err := f.Test(req)
+		if err != nil {
+			t.Errorf("Error occurred [%v]", err)
+		}
+	}
+}
+
+func TestTenantScope(t *testing.T) {
+	ctx := Tenant().WithContext(context.Background(), "acme")
+	if tenantID := Tenant().FromContext(ctx); tenantID != "acme" {
+		t.Errorf("Expected tenant [acme], got [%v]", tenantID)
+	}
+	if key := Tenant().Prefix("acme", "products.1"); key != "acme:products.1" {
+		t.Errorf("Expected key [acme:products.1], got [%v]", key)
+	}
+	if path := Tenant().Path("acme", "a.png"); path != "tenants/acme/a.png" {
+		t.Errorf("Expected path [tenants/acme/a.png], got [%v]", path)
+	}
+
+	type scoped struct {
+		TenantID *NullString
+	}
+	type embedded struct {
+		scoped
+	}
+	if !Tenant().IsScoped(&embedded{}) {
+		t.Errorf("Expected embedded model to be scoped")
+	}
+	if Tenant().IsScoped(&Setting{}) {
+		t.Errorf("Expected Setting to be not scoped")
+	}
+}
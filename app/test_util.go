@@ -42,6 +42,8 @@ type testUtil struct {
 }
 
 func (t *testUtil) configure() {
+	fsClient = NewFS("memory") // the tests never touch the disk or the network
+
 	var err error
 	conf := grest.DBConfig{}
 	conf.Driver = DB_DRIVER
@@ -74,9 +76,14 @@ func (t *testUtil) NewCtx(aclKeys []string) fiber.Handler {
 				EndPoint: c.Path(),
 			},
 		}
+		ctx.SetContext(c.UserContext())
 
+		// the test tx is autocommit, so the functions registered by AfterCommit are called right after the handler
+		ctx.afterCommit = &[]func(){}
 		c.Locals(CtxKey, &ctx)
-		return c.Next()
+		err := c.Next()
+		ctx.runAfterCommit()
+		return err
 	}
 }
 
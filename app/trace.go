@@ -0,0 +1,349 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"gorm.io/gorm"
+)
+
+// Trace returns a pointer to the traceUtil instance (tracer).
+// If tracer is not initialized, it creates a new traceUtil instance, configures it, and assigns it to tracer.
+// It ensures that only one instance of traceUtil is created and reused.
+func Trace() *traceUtil {
+	if tracer == nil {
+		tracer = &traceUtil{}
+		tracer.configure()
+	}
+	return tracer
+}
+
+// tracer is a pointer to a traceUtil instance.
+// It is used to store and access the singleton instance of traceUtil.
+var tracer *traceUtil
+
+// traceUtil represents the distributed tracing of the app with OpenTelemetry.
+// Every request is a server span, the gorm queries, the redis commands, the storage operations and the outbound http requests
+// are its child spans. The trace context is propagated with the W3C traceparent header.
+type traceUtil struct {
+	Provider   *sdktrace.TracerProvider // nil if the tracing is disabled (TRACE_EXPORTER is none)
+	Tracer     trace.Tracer
+	Propagator propagation.TextMapPropagator
+}
+
+// configure configures the tracer based on the TRACE_XXX environment variables.
+// The new traces are sampled up to TRACE_SAMPLE_RATE_LIMIT per second, the child spans follow the sampling of the parent.
+func (t *traceUtil) configure() {
+	t.Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	t.Tracer = noop.NewTracerProvider().Tracer("")
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch TRACE_EXPORTER {
+	case "otlp":
+		exporter, err = otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(TRACE_OTLP_ENDPOINT))
+	case "stdout":
+		exporter, err = stdouttrace.New()
+	default:
+		return
+	}
+	if err != nil {
+		Logger().Error().Err(err).Str("TRACE_EXPORTER", TRACE_EXPORTER).Msg("Failed to configure the trace exporter, the tracing is disabled.")
+		return
+	}
+	t.SetExporter(exporter, sdktrace.WithBatcher(exporter))
+	Logger().Info().Str("TRACE_EXPORTER", TRACE_EXPORTER).Int("TRACE_SAMPLE_RATE_LIMIT", TRACE_SAMPLE_RATE_LIMIT).Msg("Tracing configured.")
+}
+
+// SetExporter replaces the tracer provider with the new one which exports the spans to the exporter,
+// the spans are exported synchronously unless the exporter option is provided, for example sdktrace.WithBatcher(exporter).
+// It is used by configure and by the tests with tracetest.NewInMemoryExporter.
+func (t *traceUtil) SetExporter(exporter sdktrace.SpanExporter, opts ...sdktrace.TracerProviderOption) {
+	if len(opts) == 0 {
+		opts = append(opts, sdktrace.WithSyncer(exporter))
+	}
+	res, _ := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(TRACE_SERVICE_NAME),
+		semconv.ServiceVersion(APP_VERSION),
+	))
+	root := sdktrace.Sampler(newRateLimitSampler(float64(TRACE_SAMPLE_RATE_LIMIT)))
+	opts = append(opts,
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(root, sdktrace.WithRemoteParentSampled(root))),
+	)
+	t.Provider = sdktrace.NewTracerProvider(opts...)
+	t.Tracer = t.Provider.Tracer("grest-belajar")
+}
+
+// Shutdown flushes the pending spans and stops the tracer provider.
+func (t *traceUtil) Shutdown(ctx context.Context) error {
+	if t.Provider == nil {
+		return nil
+	}
+	return t.Provider.Shutdown(ctx)
+}
+
+// StartChild starts the child span of the span on the ctx, so the background jobs don't start the noisy single span traces.
+// If the ctx has no span, the ctx is returned with the non-recording span.
+func (t *traceUtil) StartChild(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	return t.Tracer.Start(ctx, name, opts...)
+}
+
+// End records the error of the span if any, then ends it.
+func (*traceUtil) End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// New is the middleware which starts the server span of the request, continuing the trace of the traceparent header.
+// It must be placed after AccessLog().New so the status of the error returned by the next handlers is known, see Error().StatusCode.
+// The span is available on c.UserContext() and on Ctx.Context().
+func (t *traceUtil) New(c *fiber.Ctx) error {
+	ctx := t.Propagator.Extract(c.UserContext(), fasthttpHeaderCarrier{&c.Request().Header})
+	ctx, span := t.Tracer.Start(ctx, c.Method(), trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+		semconv.HTTPRequestMethodKey.String(c.Method()),
+		semconv.URLPath(c.Path()),
+	))
+	defer span.End()
+	c.SetUserContext(ctx)
+
+	err := c.Next()
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = Error().StatusCode(err)
+	}
+	if route := AccessLog().route(c); route != "" {
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route))
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= fiber.StatusInternalServerError {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.SetStatus(codes.Error, "")
+	}
+	return err
+}
+
+// fasthttpHeaderCarrier adapts the fasthttp request header to the propagation.TextMapCarrier.
+type fasthttpHeaderCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+// Get returns the value of the header key.
+func (h fasthttpHeaderCarrier) Get(key string) string {
+	return string(h.header.Peek(key))
+}
+
+// Set sets the value of the header key.
+func (h fasthttpHeaderCarrier) Set(key, value string) {
+	h.header.Set(key, value)
+}
+
+// Keys returns the keys of the headers.
+func (h fasthttpHeaderCarrier) Keys() []string {
+	keys := []string{}
+	h.header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// GormPlugin returns the gorm plugin which records the queries of the connection as the child spans of the request, see Ctx.DB().
+func (t *traceUtil) GormPlugin(connName string) gorm.Plugin {
+	return &tracePlugin{trace: t, connName: connName}
+}
+
+// tracePlugin is the gorm plugin which records the queries as the spans.
+type tracePlugin struct {
+	trace    *traceUtil
+	connName string
+}
+
+// traceSpanKey is the key of the gorm instance which stores the span of the query.
+const traceSpanKey = "trace:span"
+
+// Name returns the name of the plugin.
+func (*tracePlugin) Name() string {
+	return "trace"
+}
+
+// Initialize registers the callbacks around every operation of the gorm.
+func (p *tracePlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("trace:before_create", p.before("create")),
+		cb.Create().After("gorm:create").Register("trace:after_create", p.after),
+		cb.Query().Before("gorm:query").Register("trace:before_query", p.before("query")),
+		cb.Query().After("gorm:query").Register("trace:after_query", p.after),
+		cb.Update().Before("gorm:update").Register("trace:before_update", p.before("update")),
+		cb.Update().After("gorm:update").Register("trace:after_update", p.after),
+		cb.Delete().Before("gorm:delete").Register("trace:before_delete", p.before("delete")),
+		cb.Delete().After("gorm:delete").Register("trace:after_delete", p.after),
+		cb.Row().Before("gorm:row").Register("trace:before_row", p.before("row")),
+		cb.Row().After("gorm:row").Register("trace:after_row", p.after),
+		cb.Raw().Before("gorm:raw").Register("trace:before_raw", p.before("raw")),
+		cb.Raw().After("gorm:raw").Register("trace:after_raw", p.after),
+	)
+}
+
+// before returns the callback which starts the span of the operation.
+func (p *tracePlugin) before(op string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		name := "gorm." + op
+		if tx.Statement.Table != "" {
+			name += " " + tx.Statement.Table
+		}
+		_, span := p.trace.StartChild(tx.Statement.Context, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			semconv.DBSystemMySQL,
+			semconv.DBOperationName(op),
+			semconv.DBCollectionName(tx.Statement.Table),
+			attribute.String("db.conn", p.connName),
+		))
+		if span.IsRecording() {
+			tx.InstanceSet(traceSpanKey, span)
+		}
+	}
+}
+
+// after ends the span of the operation with the executed query, the not found error is not recorded.
+func (*tracePlugin) after(tx *gorm.DB) {
+	v, ok := tx.InstanceGet(traceSpanKey)
+	if !ok {
+		return
+	}
+	span, _ := v.(trace.Span)
+	span.SetAttributes(
+		semconv.DBQueryText(tx.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", tx.RowsAffected),
+	)
+	err := tx.Error
+	if DB().IsNotFoundError(err) {
+		err = nil
+	}
+	Trace().End(span, err)
+}
+
+// RedisHook returns the redis hook which records the commands as the child spans of the span on the context of the command.
+func (t *traceUtil) RedisHook() redis.Hook {
+	return traceRedisHook{trace: t}
+}
+
+// traceRedisHook is the redis hook which records the commands as the spans.
+type traceRedisHook struct {
+	trace *traceUtil
+}
+
+// BeforeProcess starts the span of the command.
+func (h traceRedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, _ = h.trace.StartChild(ctx, "redis."+cmd.Name(), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemRedis,
+		semconv.DBOperationName(cmd.Name()),
+	))
+	return ctx, nil
+}
+
+// AfterProcess ends the span of the command, the nil reply is not recorded as an error.
+func (h traceRedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	err := cmd.Err()
+	if err == redis.Nil {
+		err = nil
+	}
+	h.trace.End(trace.SpanFromContext(ctx), err)
+	return nil
+}
+
+// BeforeProcessPipeline starts the span of the pipelined commands.
+func (h traceRedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	names := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		names = append(names, cmd.Name())
+	}
+	ctx, _ = h.trace.StartChild(ctx, "redis.pipeline", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemRedis,
+		semconv.DBOperationName("pipeline"),
+		attribute.StringSlice("db.redis.commands", names),
+	))
+	return ctx, nil
+}
+
+// AfterProcessPipeline ends the span of the pipelined commands with the first error, the nil reply is not recorded as an error.
+func (h traceRedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && cmd.Err() != redis.Nil {
+			err = cmd.Err()
+			break
+		}
+	}
+	h.trace.End(trace.SpanFromContext(ctx), err)
+	return nil
+}
+
+// rateLimitSampler samples up to the limit of the new traces per second (token bucket with the burst of one second),
+// so the tracing overhead and the exported data are bounded on the high traffic. The limit 0 or less samples every trace.
+type rateLimitSampler struct {
+	limit  float64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// newRateLimitSampler creates the sampler of the limit of the traces per second.
+func newRateLimitSampler(limit float64) *rateLimitSampler {
+	return &rateLimitSampler{limit: limit, tokens: limit, last: time.Now()}
+}
+
+// ShouldSample samples the trace if the token is available.
+func (s *rateLimitSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	res := sdktrace.SamplingResult{Decision: sdktrace.Drop, Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState()}
+	if s.allow() {
+		res.Decision = sdktrace.RecordAndSample
+	}
+	return res
+}
+
+// allow takes a token from the bucket, it is refilled by the limit per second.
+func (s *rateLimitSampler) allow() bool {
+	if s.limit <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.tokens = min(s.limit, s.tokens+now.Sub(s.last).Seconds()*s.limit)
+	s.last = now
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+// Description returns the description of the sampler.
+func (s *rateLimitSampler) Description() string {
+	return "RateLimitSampler{" + strconv.FormatFloat(s.limit, 'f', -1, 64) + "}"
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	prev := tracer
+	tracer = &traceUtil{Propagator: propagation.TraceContext{}}
+	tracer.SetExporter(exporter)
+	defer func() { tracer = prev }()
+
+	f := fiber.New(fiber.Config{ErrorHandler: Error().Handler})
+	f.Use(Trace().New)
+	f.Post("/items/:id", func(c *fiber.Ctx) error {
+		ctx := Ctx{}
+		ctx.SetContext(c.UserContext())
+		_, err := NewFS("memory").WithContext(ctx.Context()).Upload("items/"+c.Params("id"), strings.NewReader("a"), 1)
+		if err != nil {
+			return err
+		}
+		return Error().New(fiber.StatusInternalServerError, "failed")
+	})
+
+	// the background operation without the request span is not traced
+	NewFS("memory").Upload("items/background", strings.NewReader("a"), 1)
+
+	req := httptest.NewRequest("POST", "/items/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, err := f.Test(req)
+	if err != nil {
+		t.Fatalf("Error occurred [%v]", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected the server span and the storage span, got [%v]", len(spans))
+	}
+	upload, server := spans[0], spans[1]
+	if server.Name != "POST /items/:id" || server.SpanKind != trace.SpanKindServer ||
+		server.Parent.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || !server.Parent.IsRemote() {
+		t.Errorf("Expected the server span continuing the traceparent, got [%v] [%v]", server.Name, server.Parent)
+	}
+	if server.Status.Code.String() != "Error" {
+		t.Errorf("Expected the server error to be recorded, got [%v]", server.Status)
+	}
+	if upload.Name != "fs.upload" || upload.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("Expected the storage span to be the child of the server span, got [%v] [%v]", upload.Name, upload.Parent)
+	}
+}
+
+func TestRateLimitSampler(t *testing.T) {
+	s := newRateLimitSampler(2)
+	sampled := 0
+	for i := 0; i < 10; i++ {
+		if s.allow() {
+			sampled++
+		}
+	}
+	if sampled != 2 {
+		t.Errorf("Expected [2] traces to be sampled, got [%v]", sampled)
+	}
+	if !newRateLimitSampler(0).allow() {
+		t.Errorf("Expected every trace to be sampled without the limit")
+	}
+}
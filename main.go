@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"grest-belajar/app"
 	"grest-belajar/src"
@@ -22,11 +25,12 @@ func main() {
 
 	app.Logger()
 	app.Cache()
+	app.Logger().SubscribeLevels()
+	app.Alert().SubscribeResolved()
 	app.Validator()
 	app.Translator()
 	app.FS()
 	app.DB()
-	defer app.DB().Close()
 	app.Server()
 
 	src.Middleware()
@@ -38,8 +42,41 @@ func main() {
 	src.Migrator()
 	src.Seeder()
 	src.Scheduler()
-	err := app.Server().Start()
+	go func() {
+		err := app.Server().Start()
+		if err != nil {
+			app.Logger().Fatal().Err(err).Send()
+		}
+	}()
+
+	// on SIGINT or SIGTERM, drain the in-flight requests and the running jobs, then flush and close the resources in order
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	received := <-sig
+	signal.Stop(sig) // the second signal terminates the process immediately
+	app.Logger().Info().Str("signal", received.String()).Dur("timeout", app.APP_SHUTDOWN_TIMEOUT).Msg("Shutting down.")
+	ctx, cancel := context.WithTimeout(context.Background(), app.APP_SHUTDOWN_TIMEOUT)
+	defer cancel()
+
+	isDrained := true
+	err := app.Server().Shutdown(ctx)
+	if err != nil {
+		isDrained = false
+		app.Logger().Error().Err(err).Msg("Failed to drain the requests.")
+	}
+	err = src.Scheduler().Stop(ctx)
+	if err != nil {
+		isDrained = false
+		app.Logger().Error().Err(err).Msg("Failed to wait for the running jobs.")
+	}
+	if !isDrained {
+		// the requests or the jobs which are still running use the resources, they are released by the process exit instead
+		app.Logger().Warn().Msg("The resources are not closed since the requests or the jobs are still running.")
+		app.Logger().Close()
+		os.Exit(1)
+	}
+	err = app.Shutdown(ctx)
 	if err != nil {
-		app.Logger().Fatal().Err(err).Send()
+		os.Exit(1)
 	}
 }
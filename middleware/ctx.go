@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 
 	"grest-belajar/app"
@@ -25,6 +27,14 @@ func (*ctxHandler) New(c *fiber.Ctx) error {
 	ctx := app.Ctx{
 		Lang: lang,
 	}
+	ctx.RequestID, _ = c.Locals(app.RequestIDKey).(string)
+	ctx.SetContext(c.UserContext())
+	if token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); ok {
+		claims := map[string]any{}
+		if app.Crypto().ParseAndVerifyJWT(token, &claims) == nil {
+			ctx.UserID, _ = claims["sub"].(string)
+		}
+	}
 	c.Locals("ctx", &ctx)
 	return c.Next()
 }
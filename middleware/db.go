@@ -19,11 +19,23 @@ var dbh *dbHandler
 
 type dbHandler struct{}
 
+// New begins the transaction for the write request, then commit or rollback based on the response status code.
+// The read request is not wrapped on the transaction so the reads can be resolved to the db replicas,
+// unless the client asks for strong consistency using `$consistency=strong` query param.
 func (*dbHandler) New(c *fiber.Ctx) error {
 	ctx, ok := c.Locals(app.CtxKey).(*app.Ctx)
 	if !ok {
 		return app.Error().New(http.StatusInternalServerError, "ctx is not found")
 	}
+	if c.Query(app.QueryConsistency) == "strong" {
+		ctx.IsStrongConsistency = true
+	}
+	if c.Method() == http.MethodGet || c.Method() == http.MethodHead || c.Method() == http.MethodOptions {
+		return c.Next()
+	}
+
+	// the reads inside the write request stick to the primary db, even the async ones
+	ctx.IsStrongConsistency = true
 	ctx.TxBegin()
 	err := c.Next()
 	if err != nil || (c.Response().StatusCode() >= http.StatusBadRequest || c.Response().StatusCode() < http.StatusOK) {
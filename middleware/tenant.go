@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"grest-belajar/app"
+)
+
+func Tenant() *tenantHandler {
+	if th == nil {
+		th = &tenantHandler{}
+	}
+	return th
+}
+
+var th *tenantHandler
+
+type tenantHandler struct{}
+
+func (*tenantHandler) New(c *fiber.Ctx) error {
+	if !app.Tenant().IsEnabled {
+		return c.Next()
+	}
+	ctx, ok := c.Locals(app.CtxKey).(*app.Ctx)
+	if !ok {
+		return app.Error().New(http.StatusInternalServerError, "ctx is not found")
+	}
+	tenantID, err := app.Tenant().Resolve(c)
+	if err != nil {
+		return app.Error().New(http.StatusForbidden, ctx.Trans("invalid_tenant"))
+	}
+	if tenantID == "" && !app.Tenant().IsExcludedPath(c.Path()) {
+		return app.Error().New(http.StatusBadRequest, ctx.Trans("tenant_required"))
+	}
+	ctx.TenantID = tenantID
+	return c.Next()
+}
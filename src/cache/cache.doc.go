@@ -0,0 +1,2 @@
+// cache is a package related to the cache administration, to inspect and flush the cached data without shelling into redis.
+package cache
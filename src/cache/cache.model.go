@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"encoding/json"
+	"time"
+
+	"grest-belajar/app"
+)
+
+// MaxScanKeys is the maximum number of the keys which are scanned to count the entries per end point.
+const MaxScanKeys = 100000
+
+// Stats is the cache stats of the current instance, the counters are reset when the instance is restarted.
+type Stats struct {
+	app.CacheStats
+	HitRatio      float64         `json:"hit_ratio"`       // the hits of any tier per gets
+	LocalHitRatio float64         `json:"local_hit_ratio"` // the hits of the in-process tier per gets
+	EndPoints     []EndPointCount `json:"end_points"`
+	IsTruncated   bool            `json:"is_truncated"` // true if there are more than MaxScanKeys keys, so the counts are partial
+}
+
+// OpenAPISchemaName returns the name of the Stats schema in the open api documentation.
+func (Stats) OpenAPISchemaName() string {
+	return "CacheStats"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the Stats in the open api documentation.
+func (Stats) GetOpenAPISchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"is_use_redis":    map[string]any{"type": "boolean"},
+			"local_entries":   map[string]any{"type": "integer"},
+			"local_hits":      map[string]any{"type": "integer"},
+			"local_misses":    map[string]any{"type": "integer"},
+			"redis_hits":      map[string]any{"type": "integer"},
+			"redis_misses":    map[string]any{"type": "integer"},
+			"hit_ratio":       map[string]any{"type": "number"},
+			"local_hit_ratio": map[string]any{"type": "number"},
+			"is_truncated":    map[string]any{"type": "boolean"},
+			"end_points": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"prefix": map[string]any{"type": "string", "example": "products"},
+						"count":  map[string]any{"type": "integer"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// EndPointCount is the number of the cache entries of the (tenant-prefixed) end point.
+type EndPointCount struct {
+	Prefix string `json:"prefix"`
+	Count  int    `json:"count"`
+}
+
+// Key is the detail of the cache entry.
+type Key struct {
+	Key        string          `json:"key"`
+	Tiers      []string        `json:"tiers"`       // "local" and/or "redis"
+	TTL        float64         `json:"ttl"`         // the remaining ttl in seconds
+	FreshUntil *time.Time      `json:"fresh_until"` // set for the entries cached with the stale window
+	Value      json.RawMessage `json:"value"`
+}
+
+// OpenAPISchemaName returns the name of the Key schema in the open api documentation.
+func (Key) OpenAPISchemaName() string {
+	return "CacheKey"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the Key in the open api documentation.
+func (Key) GetOpenAPISchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"key":         map[string]any{"type": "string", "example": "products.{id}"},
+			"tiers":       map[string]any{"type": "array", "items": map[string]any{"type": "string", "enum": []string{"local", "redis"}}},
+			"ttl":         map[string]any{"type": "number"},
+			"fresh_until": map[string]any{"type": "string", "format": "date-time"},
+			"value":       map[string]any{"type": "object"},
+		},
+	}
+}
+
+// KeyList is the list of the cache keys.
+type KeyList struct {
+	Count   int      `json:"count"`
+	Results []string `json:"results"`
+}
+
+// OpenAPISchemaName returns the name of the KeyList schema in the open api documentation.
+func (KeyList) OpenAPISchemaName() string {
+	return "CacheKeyList"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the KeyList in the open api documentation.
+func (KeyList) GetOpenAPISchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"count":   map[string]any{"type": "integer"},
+			"results": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+	}
+}
+
+// ParamFlush is the expected parameters for flush the cache of the end point, or only the entries of the id if it is set.
+type ParamFlush struct {
+	EndPoint app.NullString `json:"end_point" validate:"required"`
+	ID       app.NullString `json:"id"`
+	Reason   app.NullString `json:"reason"    validate:"required"`
+}
+
+// OpenAPISchemaName returns the name of the ParamFlush schema in the open api documentation.
+func (ParamFlush) OpenAPISchemaName() string {
+	return "CacheParamFlush"
+}
@@ -0,0 +1,89 @@
+package cache
+
+import "grest-belajar/app"
+
+// OpenAPI is constructor for *openAPI, to autogenerate open api document.
+func OpenAPI() *OpenAPIOperation {
+	return &OpenAPIOperation{}
+}
+
+// OpenAPIOperation embed from app.OpenAPIOperation for simplicity, used for autogenerate open api document.
+type OpenAPIOperation struct {
+	app.OpenAPIOperation
+}
+
+// Base is common detail of cache open api document component.
+func (o *OpenAPIOperation) Base() {
+	o.Tags = []string{"Cache"}
+	o.HeaderParams = []map[string]any{{"$ref": "#/components/parameters/headerParam.Accept-Language"}}
+	o.Responses = map[string]map[string]any{
+		"200": {
+			"description": "Success",
+			"content":     map[string]any{"application/json": &Key{}},
+		},
+		"400": app.OpenAPIError().BadRequest(),
+		"401": app.OpenAPIError().Unauthorized(),
+		"403": app.OpenAPIError().Forbidden(),
+	}
+	o.Securities = []map[string][]string{}
+}
+
+// GetStats is detail of `GET /api/cache/stats` open api document component.
+func (o *OpenAPIOperation) GetStats() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Get Cache Stats"
+	o.Description = "Use this method to get the cache hit ratio of the instance and the number of the entries per end point"
+	o.Responses["200"] = map[string]any{
+		"description": "Success",
+		"content":     map[string]any{"application/json": &Stats{}},
+	}
+	return o
+}
+
+// GetKeys is detail of `GET /api/cache/keys` open api document component.
+func (o *OpenAPIOperation) GetKeys() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Get Cache Keys"
+	o.Description = "Use this method to browse the cache keys, filtered by the `prefix` query (for example `products.`) and limited by the `$per_page` query"
+	o.QueryParams = []map[string]any{{"$ref": "#/components/parameters/queryParam.Any"}}
+	o.Responses["200"] = map[string]any{
+		"description": "Success",
+		"content":     map[string]any{"application/json": &KeyList{}},
+	}
+	return o
+}
+
+// GetByKey is detail of `GET /api/cache/keys/{key}` open api document component.
+func (o *OpenAPIOperation) GetByKey() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Get Cache Key"
+	o.Description = "Use this method to inspect the value and the ttl of the url encoded cache key"
+	o.PathParams = []map[string]any{{"in": "path", "name": "key", "required": true, "schema": map[string]any{"type": "string"}}}
+	o.Responses["404"] = app.OpenAPIError().NotFound()
+	return o
+}
+
+// Flush is detail of `POST /api/cache/flush` open api document component.
+func (o *OpenAPIOperation) Flush() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Flush Cache"
+	o.Description = "Use this method to flush the cache of the end point (and its dependents), or only the entries of the id if it is set"
+	o.Body = map[string]any{"application/json": &ParamFlush{}}
+	return o
+}
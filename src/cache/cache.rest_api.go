@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+	"grest.dev/grest"
+
+	"grest-belajar/app"
+)
+
+// REST returns a *RESTAPIHandler.
+func REST() *RESTAPIHandler {
+	return &RESTAPIHandler{}
+}
+
+// RESTAPIHandler provides a convenient interface for cache administration REST API handler.
+type RESTAPIHandler struct {
+	UseCase UseCaseHandler
+}
+
+// injectDeps inject the dependencies of the cache administration REST API handler.
+func (r *RESTAPIHandler) injectDeps(c *fiber.Ctx) error {
+	ctx, ok := c.Locals(app.CtxKey).(*app.Ctx)
+	if !ok {
+		return app.Error().New(http.StatusInternalServerError, "ctx is not found")
+	}
+	r.UseCase = UseCase(*ctx, app.Query().Parse(c.OriginalURL()))
+	return nil
+}
+
+// GetStats is the REST API handler for `GET /api/cache/stats`.
+func (r *RESTAPIHandler) GetStats(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res, err := r.UseCase.GetStats()
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	return c.JSON(res)
+}
+
+// GetKeys is the REST API handler for `GET /api/cache/keys`.
+func (r *RESTAPIHandler) GetKeys(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res, err := r.UseCase.GetKeys()
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	return c.JSON(res)
+}
+
+// GetByKey is the REST API handler for `GET /api/cache/keys/{key}`, the key must be url encoded.
+func (r *RESTAPIHandler) GetByKey(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	key, err := url.PathUnescape(c.Params("key"))
+	if err != nil {
+		return app.Error().Handler(c, app.Error().New(http.StatusBadRequest, err.Error()))
+	}
+	res, err := r.UseCase.GetByKey(key)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	return c.JSON(res)
+}
+
+// Flush is the REST API handler for `POST /api/cache/flush`.
+func (r *RESTAPIHandler) Flush(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	p := ParamFlush{}
+	err = grest.NewJSON(c.Body()).ToFlat().Unmarshal(&p)
+	if err != nil {
+		return app.Error().Handler(c, app.Error().New(http.StatusBadRequest, err.Error()))
+	}
+	err = r.UseCase.Flush(&p)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res := map[string]any{
+		"code":    http.StatusOK,
+		"message": r.UseCase.Ctx.Trans("cache_flushed", map[string]string{"end_point": p.EndPoint.String}),
+	}
+	return c.JSON(res)
+}
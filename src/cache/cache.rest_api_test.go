@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+
+	"grest-belajar/app"
+)
+
+// prepareTest prepares the test.
+func prepareTest(tb testing.TB) {
+	app.Test()
+	app.Cache().FlushEndPoint("tests")
+	app.Cache().SetWithTags("tests.a", map[string]any{"id": "a"}, time.Hour, app.Cache().DetailTags("tests", "a")...)
+	app.Cache().SetWithTags("tests?$page=1", map[string]any{"count": 1}, time.Hour, app.Cache().ListTags("tests", nil)...)
+
+	app.Server().AddMiddleware(app.Test().NewCtx([]string{
+		"cache.detail",
+		"cache.list",
+		"cache.delete",
+	}))
+	app.Server().AddRoute("/cache/stats", "GET", REST().GetStats, nil)
+	app.Server().AddRoute("/cache/keys", "GET", REST().GetKeys, nil)
+	app.Server().AddRoute("/cache/keys/:key", "GET", REST().GetByKey, nil)
+	app.Server().AddRoute("/cache/flush", "POST", REST().Flush, nil)
+}
+
+// tests is test scenario.
+var tests = []struct {
+	description  string // description of the test case
+	method       string // method to test
+	path         string // route path to test
+	token        string // token to test
+	bodyRequest  string // body to test
+	expectedCode int    // expected HTTP status code
+	expectedBody string // expected body response
+}{
+	{
+		description:  "Get cache stats",
+		method:       "GET",
+		path:         "/cache/stats",
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusOK,
+	},
+	{
+		description:  "Get cache keys with prefix",
+		method:       "GET",
+		path:         "/cache/keys?prefix=tests.",
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusOK,
+		expectedBody: `{"count":1,"results":["tests.a"]}`,
+	},
+	{
+		description:  "Get cache key",
+		method:       "GET",
+		path:         "/cache/keys/tests.a",
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusOK,
+		expectedBody: `{"key":"tests.a","value":{"id":"a"}}`,
+	},
+	{
+		description:  "Get cache key with encoded query",
+		method:       "GET",
+		path:         "/cache/keys/tests%3F%24page%3D1",
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusOK,
+		expectedBody: `{"key":"tests?$page=1","value":{"count":1}}`,
+	},
+	{
+		description:  "Flush cache without end point",
+		method:       "POST",
+		path:         "/cache/flush",
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"reason":"Flush cache"}`,
+		expectedCode: http.StatusBadRequest,
+	},
+	{
+		description:  "Flush cache of the id",
+		method:       "POST",
+		path:         "/cache/flush",
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"end_point":"tests","id":"a","reason":"Flush cache"}`,
+		expectedCode: http.StatusOK,
+		expectedBody: `{"code":200}`,
+	},
+	{
+		description:  "Get flushed cache key",
+		method:       "GET",
+		path:         "/cache/keys/tests.a",
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusNotFound,
+	},
+}
+
+// TestCacheREST tests the REST API of cache administration with specified scenario.
+func TestCacheREST(t *testing.T) {
+	prepareTest(t)
+
+	// Iterate through test single test cases
+	for _, test := range tests {
+		// Create a new http request with the route from the test case
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.bodyRequest))
+		req.Header.Add("Authorization", "Bearer "+test.token)
+		req.Header.Add("Content-Type", "application/json")
+
+		// Perform the request plain with the app, the second argument is a request latency (set to -1 for no latency)
+		res, err := app.Server().Test(req)
+
+		// Verify if the status code is as expected
+		utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+		utils.AssertEqual(t, test.expectedCode, res.StatusCode, test.description)
+
+		// Verify if the body response is as expected
+		body, err := io.ReadAll(res.Body)
+		utils.AssertEqual(t, nil, err, "io.ReadAll(res.Body)")
+		app.Test().AssertMatchJSONElement(t, []byte(test.expectedBody), body, test.description)
+		res.Body.Close()
+	}
+}
+
+// TestCacheRESTWithoutTenant tests the cache administration is refused without tenant when the multi-tenancy is enabled.
+func TestCacheRESTWithoutTenant(t *testing.T) {
+	prepareTest(t)
+	app.Tenant().IsEnabled = true
+	defer func() { app.Tenant().IsEnabled = false }()
+
+	for _, path := range []string{"/cache/stats", "/cache/keys", "/cache/keys/tests.a"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Add("Authorization", "Bearer "+app.TestFullAccessToken)
+		res, err := app.Server().Test(req)
+		utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+		utils.AssertEqual(t, http.StatusBadRequest, res.StatusCode, "Get "+path+" without tenant")
+	}
+}
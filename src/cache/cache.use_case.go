@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"grest-belajar/app"
+)
+
+// UseCase returns a UseCaseHandler for expected use case functional.
+func UseCase(ctx app.Ctx, query ...url.Values) UseCaseHandler {
+	u := UseCaseHandler{
+		Ctx:   &ctx,
+		Query: url.Values{},
+	}
+	if len(query) > 0 {
+		u.Query = query[0]
+	}
+	return u
+}
+
+// UseCaseHandler provides a convenient interface for cache administration use case, use UseCase to access UseCaseHandler.
+// The keys are scoped to the tenant of the ctx, and it works the same for redis and the in-memory fallback.
+type UseCaseHandler struct {
+	// injectable dependencies
+	Ctx   *app.Ctx   `json:"-" db:"-" gorm:"-"`
+	Query url.Values `json:"-" db:"-" gorm:"-"`
+}
+
+// GetStats returns the hit ratio of the current instance and the number of the entries per end point.
+func (u UseCaseHandler) GetStats() (Stats, error) {
+	res := Stats{EndPoints: []EndPointCount{}}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("cache.detail")
+	if err != nil {
+		return res, err
+	}
+	err = u.validateTenant()
+	if err != nil {
+		return res, err
+	}
+
+	res.CacheStats = app.Cache().Stats()
+	if gets := res.LocalHits + res.LocalMisses; gets > 0 {
+		res.HitRatio = float64(res.LocalHits+res.RedisHits) / float64(gets)
+		res.LocalHitRatio = float64(res.LocalHits) / float64(gets)
+	}
+
+	keys, err := app.Cache().Keys(u.Ctx.CacheKey(""), MaxScanKeys+1)
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	if len(keys) > MaxScanKeys {
+		keys, res.IsTruncated = keys[:MaxScanKeys], true
+	}
+	counts := map[string]int{}
+	for _, key := range keys {
+		counts[prefixOf(key)]++
+	}
+	for prefix, count := range counts {
+		res.EndPoints = append(res.EndPoints, EndPointCount{Prefix: prefix, Count: count})
+	}
+	sort.Slice(res.EndPoints, func(i, j int) bool {
+		return res.EndPoints[i].Prefix < res.EndPoints[j].Prefix
+	})
+	return res, nil
+}
+
+// GetKeys returns the cache keys with the prefix of the `prefix` query, up to the `$per_page` query (default 100, max 1000).
+func (u UseCaseHandler) GetKeys() (KeyList, error) {
+	res := KeyList{Results: []string{}}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("cache.list")
+	if err != nil {
+		return res, err
+	}
+	err = u.validateTenant()
+	if err != nil {
+		return res, err
+	}
+
+	limit, err := strconv.Atoi(u.Query.Get("$per_page"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	limit = min(limit, 1000)
+	res.Results, err = app.Cache().Keys(u.Ctx.CacheKey(u.Query.Get("prefix")), limit)
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	sort.Strings(res.Results)
+	res.Count = len(res.Results)
+	return res, nil
+}
+
+// GetByKey returns the value and the ttl of the cache key, the key is relative to the tenant of the ctx.
+func (u UseCaseHandler) GetByKey(key string) (Key, error) {
+	res := Key{}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("cache.detail")
+	if err != nil {
+		return res, err
+	}
+	err = u.validateTenant()
+	if err != nil {
+		return res, err
+	}
+
+	info, err := app.Cache().Inspect(u.Ctx.CacheKey(key))
+	if err != nil {
+		return res, app.Error().New(http.StatusNotFound, u.Ctx.Trans("cache_key_not_found", map[string]string{"key": key}))
+	}
+	res.Key = key
+	res.Tiers = info.Tiers
+	res.TTL = info.TTL.Seconds()
+	res.FreshUntil = info.FreshUntil
+	res.Value = info.Value
+	return res, nil
+}
+
+// Flush deletes the cache entries of the end point (and its dependents), or only the entries of the id if it is set.
+func (u UseCaseHandler) Flush(p *ParamFlush) error {
+
+	// check permission
+	err := u.Ctx.ValidatePermission("cache.delete")
+	if err != nil {
+		return err
+	}
+	err = u.validateTenant()
+	if err != nil {
+		return err
+	}
+
+	// validate param
+	err = u.Ctx.ValidateParam(p)
+	if err != nil {
+		return err
+	}
+
+	endPoint := u.Ctx.CacheKey(p.EndPoint.String)
+	if p.ID.Valid && p.ID.String != "" {
+		app.Cache().Invalidate(endPoint, p.ID.String)
+	} else {
+		err = app.Cache().FlushEndPoint(endPoint)
+		if err != nil {
+			return app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	app.Logger().Info().
+		Str("end_point", endPoint).
+		Str("id", p.ID.String).
+		Str("reason", p.Reason.String).
+		Msg("Cache is flushed.")
+	return nil
+}
+
+// validateTenant refuses the request without tenant when the multi-tenancy is enabled,
+// since the keys without tenant prefix match the keys of every tenant.
+func (u UseCaseHandler) validateTenant() error {
+	if app.Tenant().IsEnabled && u.Ctx.TenantID == "" {
+		return app.Error().New(http.StatusBadRequest, u.Ctx.Trans("tenant_required"))
+	}
+	return nil
+}
+
+// prefixOf returns the (tenant-prefixed) end point of the cache key, for example "acme:products" of "acme:products.{id}".
+func prefixOf(key string) string {
+	if i := strings.IndexAny(key, ".?"); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
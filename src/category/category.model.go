@@ -2,15 +2,28 @@ package category
 
 import "grest-belajar/app"
 
+// MaxDepth is the maximum depth of the Category tree, it is limited by the size of the indexed path column.
+const MaxDepth = 20
+
+// BreadcrumbSeparator is the separator of the Category names on the breadcrumb, for example "Electronics > Phones".
+const BreadcrumbSeparator = " > "
+
 // Category is the main model of Category data. It provides a convenient interface for app.ModelInterface
+// The categories are nested using the parent, the Path is the materialized ids from the root to the category itself,
+// for example "/{electronics_id}/{phones_id}/", and the Breadcrumb is the materialized names, for example "Electronics > Phones".
 type Category struct {
 	app.Model
-	ID        app.NullUUID      `json:"id"                   db:"m.id"              gorm:"column:id;primaryKey"`
-	Name      app.NullString    `json:"name"                 db:"m.name"            gorm:"column:name"`
-	Products  []Products        `json:"products"             db:"category_id={id}"  gorm:"-"`
-	CreatedAt app.NullDateTime  `json:"created_at"           db:"m.created_at"      gorm:"column:created_at"`
-	UpdatedAt app.NullDateTime  `json:"updated_at"           db:"m.updated_at"      gorm:"column:updated_at"`
-	DeletedAt *app.NullDateTime `json:"deleted_at,omitempty" db:"m.deleted_at,hide" gorm:"column:deleted_at"`
+	ID         app.NullUUID      `json:"id"                   db:"m.id"              gorm:"column:id;primaryKey"`
+	TenantID   *app.NullString   `json:"tenant_id,omitempty"  db:"m.tenant_id,hide"  gorm:"column:tenant_id;size:64;index"`
+	Name       app.NullString    `json:"name"                 db:"m.name"            gorm:"column:name"`
+	ParentID   app.NullUUID      `json:"parent.id"            db:"m.parent_id"       gorm:"column:parent_id;index"`
+	ParentName app.NullString    `json:"parent.name"          db:"parent.name"       gorm:"-"`
+	Path       app.NullString    `json:"path"                 db:"m.path"            gorm:"column:path;size:760;index"`
+	Breadcrumb app.NullText      `json:"breadcrumb"           db:"m.breadcrumb"      gorm:"column:breadcrumb"`
+	Products   []Products        `json:"products"             db:"category_id={id}"  gorm:"-"`
+	CreatedAt  app.NullDateTime  `json:"created_at"           db:"m.created_at"      gorm:"column:created_at"`
+	UpdatedAt  app.NullDateTime  `json:"updated_at"           db:"m.updated_at"      gorm:"column:updated_at"`
+	DeletedAt  *app.NullDateTime `json:"deleted_at,omitempty" db:"m.deleted_at,hide" gorm:"column:deleted_at"`
 }
 
 // EndPoint returns the Category end point, it used for cache key, etc.
@@ -21,7 +34,13 @@ func (Category) EndPoint() string {
 // TableVersion returns the versions of the Category table in the database.
 // Change this value with date format YY.MM.DDHHii when any table structure changes.
 func (Category) TableVersion() string {
-	return "28.06.291152"
+	return "26.10.191900"
+}
+
+// CacheDependsOn returns the end points the cached Category payloads depend on,
+// the parent name is joined from the categories and the products are embedded on the detail.
+func (Category) CacheDependsOn() []string {
+	return []string{"categories", "products"}
 }
 
 // TableName returns the name of the Category table in the database.
@@ -36,6 +55,7 @@ func (Category) TableAliasName() string {
 
 // GetRelations returns the relations of the Category data in the database, used for querying.
 func (m *Category) GetRelations() map[string]map[string]any {
+	m.AddRelation("left", "categories", "parent", []map[string]any{{"column1": "parent.id", "column2": "m.parent_id"}})
 	// m.AddRelation("left", "products", "p", []map[string]any{{"column1": "p.category_id", "column2": "m.id"}})
 	// m.AddRelation("left", "users", "uu", []map[string]any{{"column1": "uu.id", "column2": "m.updated_by_user_id"}})
 	return m.Relations
@@ -74,6 +94,37 @@ func (m *Category) GetOpenAPISchema() map[string]any {
 	return m.SetOpenAPISchema(m)
 }
 
+// CategoryTree is the node of the Category tree, see UseCaseHandler.GetTree.
+type CategoryTree struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Path       string          `json:"path"`
+	Breadcrumb string          `json:"breadcrumb"`
+	Children   []*CategoryTree `json:"children"`
+}
+
+// OpenAPISchemaName returns the name of the CategoryTree schema in the open api documentation.
+func (CategoryTree) OpenAPISchemaName() string {
+	return "CategoryTree"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the CategoryTree in the open api documentation.
+func (CategoryTree) GetOpenAPISchema() map[string]any {
+	return map[string]any{
+		"type": "array",
+		"items": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id":         map[string]any{"type": "string", "format": "uuid"},
+				"name":       map[string]any{"type": "string"},
+				"path":       map[string]any{"type": "string"},
+				"breadcrumb": map[string]any{"type": "string"},
+				"children":   map[string]any{"type": "array", "items": map[string]any{"$ref": "#/components/schemas/CategoryTree"}},
+			},
+		},
+	}
+}
+
 type CategoryList struct {
 	app.ListModel
 }
@@ -132,13 +183,15 @@ type ParamCreate struct {
 // ParamUpdate is the expected parameters for update the Category data.
 type ParamUpdate struct {
 	UseCaseHandler
-	Reason app.NullString `json:"reason" gorm:"-" validate:"required"`
+	IsMoveToRoot app.NullBool   `json:"is_move_to_root" gorm:"-"` // move the category (and its subtree) to the root
+	Reason       app.NullString `json:"reason"          gorm:"-" validate:"required"`
 }
 
 // ParamPartiallyUpdate is the expected parameters for partially update the Category data.
 type ParamPartiallyUpdate struct {
 	UseCaseHandler
-	Reason app.NullString `json:"reason" gorm:"-" validate:"required"`
+	IsMoveToRoot app.NullBool   `json:"is_move_to_root" gorm:"-"` // move the category (and its subtree) to the root
+	Reason       app.NullString `json:"reason"          gorm:"-" validate:"required"`
 }
 
 // ParamDelete is the expected parameters for delete the Category data.
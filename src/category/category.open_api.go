@@ -50,6 +50,22 @@ func (o *OpenAPIOperation) Get() *OpenAPIOperation {
 	return o
 }
 
+// GetTree is detail of `GET /api/v3/categories/tree` open api document component.
+func (o *OpenAPIOperation) GetTree() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Get Category Tree"
+	o.Description = "Use this method to get the nested Category, from the roots to the leaves"
+	o.Responses["200"] = map[string]any{
+		"description": "Success",
+		"content":     map[string]any{"application/json": &CategoryTree{}},
+	}
+	return o
+}
+
 // GetByID is detail of `GET /api/v3/categories/{id}` open api document component.
 func (o *OpenAPIOperation) GetByID() *OpenAPIOperation {
 	if !app.IS_GENERATE_OPEN_API_DOC {
@@ -62,6 +62,19 @@ func (r *RESTAPIHandler) Get(c *fiber.Ctx) error {
 	return c.JSON(grest.NewJSON(res).ToStructured().Data)
 }
 
+// GetTree is the REST API handler for `GET /api/categories/tree`.
+func (r *RESTAPIHandler) GetTree(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res, err := r.UseCase.GetTree()
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	return c.JSON(res)
+}
+
 // Create is the REST API handler for `POST /api/categories`.
 func (r *RESTAPIHandler) Create(c *fiber.Ctx) error {
 	err := r.injectDeps(c)
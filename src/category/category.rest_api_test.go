@@ -1,6 +1,7 @@
 package category
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -18,6 +19,7 @@ func prepareTest(tb testing.TB) {
 	app.Test()
 	tx := app.Test().Tx
 	app.DB().RegisterTable("main", Category{})
+	app.DB().RegisterTable("main", app.OutboxEvent{})
 	app.DB().MigrateTable(tx, "main", app.Setting{})
 	tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Category{})
 
@@ -30,6 +32,7 @@ func prepareTest(tb testing.TB) {
 	}))
 	app.Server().AddRoute("/categories", "POST", REST().Create, nil)
 	app.Server().AddRoute("/categories", "GET", REST().Get, nil)
+	app.Server().AddRoute("/categories/tree", "GET", REST().GetTree, nil)
 	app.Server().AddRoute("/categories/:id", "GET", REST().GetByID, nil)
 	app.Server().AddRoute("/categories/:id", "PUT", REST().UpdateByID, nil)
 	app.Server().AddRoute("/categories/:id", "PATCH", REST().PartiallyUpdateByID, nil)
@@ -68,6 +71,29 @@ var tests = []struct {
 		expectedCode: http.StatusCreated,
 		expectedBody: `{"name":"Kilogram"}`,
 	},
+	{
+		description:  "Create Category with unavailable parent",
+		method:       "POST",
+		path:         "/categories",
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"name":"Phones","parent":{"id":"00000000-0000-0000-0000-000000000000"}}`,
+		expectedCode: http.StatusBadRequest,
+	},
+	{
+		description:  "Get Category tree",
+		method:       "GET",
+		path:         "/categories/tree",
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusOK,
+	},
+	{
+		description:  "Move Category under itself",
+		method:       "PATCH",
+		path:         "/categories/" + getTestCategoryID(),
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"reason":"Move Category under itself","parent":{"id":"` + getTestCategoryID() + `"}}`,
+		expectedCode: http.StatusBadRequest,
+	},
 	{
 		description:  "Get Category by ID",
 		method:       "GET",
@@ -132,6 +158,88 @@ func TestCategoryREST(t *testing.T) {
 	}
 }
 
+// newJSONRequest returns the json request with the full access token.
+func newJSONRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Add("Authorization", "Bearer "+app.TestFullAccessToken)
+	req.Header.Add("Content-Type", "application/json")
+	return req
+}
+
+// createTestCategory creates the Category under the parent, or as the root if the parent is empty, then returns it.
+func createTestCategory(t *testing.T, name, parentID string) Category {
+	body := `{"name":"` + name + `"}`
+	if parentID != "" {
+		body = `{"name":"` + name + `","parent":{"id":"` + parentID + `"}}`
+	}
+	res, err := app.Server().Test(newJSONRequest("POST", "/categories", body))
+	utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+	utils.AssertEqual(t, http.StatusCreated, res.StatusCode, "Create Category "+name)
+	c := Category{}
+	json.NewDecoder(res.Body).Decode(&c)
+	res.Body.Close()
+	return c
+}
+
+// TestCategoryHierarchy tests the path, the breadcrumb and the tree of the nested categories, and the move which would create a cycle.
+func TestCategoryHierarchy(t *testing.T) {
+	prepareTest(t)
+	electronics := createTestCategory(t, "Electronics", "")
+	phones := createTestCategory(t, "Phones", electronics.ID.String)
+	android := createTestCategory(t, "Android", phones.ID.String)
+	utils.AssertEqual(t, "/"+electronics.ID.String+"/", electronics.Path.String, "root path")
+	utils.AssertEqual(t, "/"+electronics.ID.String+"/"+phones.ID.String+"/"+android.ID.String+"/", android.Path.String, "leaf path")
+	utils.AssertEqual(t, "Electronics > Phones > Android", android.Breadcrumb.String, "leaf breadcrumb")
+
+	res, err := app.Server().Test(newJSONRequest("GET", "/categories/tree", ""))
+	utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+	tree := []*CategoryTree{}
+	json.NewDecoder(res.Body).Decode(&tree)
+	res.Body.Close()
+	utils.AssertEqual(t, 1, len(tree), "tree roots")
+	utils.AssertEqual(t, electronics.ID.String, tree[0].ID, "tree root")
+	utils.AssertEqual(t, 1, len(tree[0].Children), "tree root children")
+	utils.AssertEqual(t, phones.ID.String, tree[0].Children[0].ID, "tree child")
+	utils.AssertEqual(t, 1, len(tree[0].Children[0].Children), "tree child children")
+	utils.AssertEqual(t, android.Breadcrumb.String, tree[0].Children[0].Children[0].Breadcrumb, "tree leaf breadcrumb")
+
+	// the category can't be moved under its descendant
+	res, err = app.Server().Test(newJSONRequest("PATCH", "/categories/"+electronics.ID.String, `{"reason":"cycle","parent":{"id":"`+android.ID.String+`"}}`))
+	utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+	utils.AssertEqual(t, http.StatusBadRequest, res.StatusCode, "Move Category under its descendant")
+	res.Body.Close()
+
+	// the subtree is moved with the category
+	res, err = app.Server().Test(newJSONRequest("PATCH", "/categories/"+phones.ID.String, `{"reason":"move","is_move_to_root":true}`))
+	utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+	utils.AssertEqual(t, http.StatusOK, res.StatusCode, "Move Category to the root")
+	res.Body.Close()
+	moved := Category{}
+	app.Test().Tx.Where("id = ?", android.ID).Take(&moved)
+	utils.AssertEqual(t, "/"+phones.ID.String+"/"+android.ID.String+"/", moved.Path.String, "moved leaf path")
+	utils.AssertEqual(t, "Phones > Android", moved.Breadcrumb.String, "moved leaf breadcrumb")
+}
+
+// TestPathSeeder tests the backfill of the path and breadcrumb of the categories created before they are introduced.
+func TestPathSeeder(t *testing.T) {
+	prepareTest(t)
+	tx := app.Test().Tx
+	root := Category{ID: app.NewNullUUID(), Name: app.NewNullString("Electronics")}
+	child := Category{ID: app.NewNullUUID(), Name: app.NewNullString("Phones"), ParentID: root.ID}
+	orphan := Category{ID: app.NewNullUUID(), Name: app.NewNullString("Cables"), ParentID: app.NewNullUUID()}
+	tx.Create(&[]Category{root, child, orphan})
+
+	utils.AssertEqual(t, nil, PathSeeder{}.Run(tx), "PathSeeder{}.Run(tx)")
+	res := Category{}
+	tx.Where("id = ?", child.ID).Take(&res)
+	utils.AssertEqual(t, "/"+root.ID.String+"/"+child.ID.String+"/", res.Path.String, "child path")
+	utils.AssertEqual(t, "Electronics > Phones", res.Breadcrumb.String, "child breadcrumb")
+	res = Category{}
+	tx.Where("id = ?", orphan.ID).Take(&res)
+	utils.AssertEqual(t, "/"+orphan.ID.String+"/", res.Path.String, "orphan path")
+	utils.AssertEqual(t, "Cables", res.Breadcrumb.String, "orphan breadcrumb")
+}
+
 // BenchmarkCategoryREST tests the REST API of Category data with specified scenario.
 func BenchmarkCategoryREST(b *testing.B) {
 	b.ReportAllocs()
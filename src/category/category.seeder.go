@@ -0,0 +1,54 @@
+package category
+
+import (
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// PathSeeder backfills the path and breadcrumb of the categories which are created before they are introduced,
+// it is registered on the seeder so it is executed once per database.
+type PathSeeder struct{}
+
+// Run sets the path and breadcrumb of every category from its ancestors.
+// The category with a missing parent, or a parent on a cycle, is treated as the root.
+func (PathSeeder) Run(tx *gorm.DB) error {
+	rows := []struct {
+		ID       string
+		ParentID string
+		Name     string
+	}{}
+	err := tx.Table(Category{}.TableName()).
+		Select("id, COALESCE(parent_id, '') AS parent_id, COALESCE(name, '') AS name").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	parents := map[string]string{}
+	names := map[string]string{}
+	for _, r := range rows {
+		parents[r.ID] = r.ParentID
+		names[r.ID] = r.Name
+	}
+
+	for _, r := range rows {
+		ids := []string{r.ID}
+		breadcrumb := []string{r.Name}
+		for id := parents[r.ID]; id != "" && len(ids) < MaxDepth; id = parents[id] {
+			if _, ok := names[id]; !ok || slices.Contains(ids, id) {
+				break
+			}
+			ids = append([]string{id}, ids...)
+			breadcrumb = append([]string{names[id]}, breadcrumb...)
+		}
+		err = tx.Table(Category{}.TableName()).Where("id = ?", r.ID).Updates(map[string]any{
+			"path":       "/" + strings.Join(ids, "/") + "/",
+			"breadcrumb": strings.Join(breadcrumb, BreadcrumbSeparator),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
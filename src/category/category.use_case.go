@@ -3,7 +3,12 @@ package category
 import (
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"grest-belajar/app"
 )
@@ -45,32 +50,32 @@ func (u UseCaseHandler) GetByID(id string) (Category, error) {
 		return res, err
 	}
 
-	// get from cache and return if exists
-	cacheKey := u.EndPoint() + "." + id
-	app.Cache().Get(cacheKey, &res)
-	if res.ID.Valid {
-		return res, err
-	}
-
-	// prepare db for current ctx
-	tx, err := u.Ctx.DB()
-	if err != nil {
-		return res, app.Error().New(http.StatusInternalServerError, err.Error())
-	}
-
-	// get from db
-	key := "id"
-	if !app.Validator().IsValid(id, "uuid") {
-		key = "code"
-	}
-	u.Query.Add(key, id)
-	err = app.Query().First(tx, &res, u.Query)
-	if err != nil {
-		return res, u.Ctx.NotFoundError(err, u.EndPoint(), key, id)
-	}
-
-	// save to cache and return if exists
-	app.Cache().Set(cacheKey, res)
+	// get from cache, or get from db and save to cache, the concurrent requests of the same id are coalesced
+	err = u.Ctx.Remember(u.EndPoint(), u.EndPoint()+"."+id, &res, func() (any, []string, error) {
+
+		// prepare db for current ctx
+		tx, err := u.Ctx.DB()
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+
+		// get from db
+		key := "id"
+		if !app.Validator().IsValid(id, "uuid") {
+			key = "code"
+		}
+		query := url.Values{}
+		for k, v := range u.Query {
+			query[k] = v
+		}
+		query.Set(key, id)
+		data := Category{}
+		err = app.Query().First(tx, &data, query)
+		if err != nil {
+			return nil, nil, u.Ctx.NotFoundError(err, u.EndPoint(), key, id)
+		}
+		return data, app.Cache().DetailTags(u.Ctx.CacheKey(u.EndPoint()), data.ID.String), nil
+	})
 	return res, err
 }
 
@@ -83,42 +88,85 @@ func (u UseCaseHandler) Get() (app.ListModel, error) {
 	if err != nil {
 		return res, err
 	}
-	// get from cache and return if exists
-	cacheKey := u.EndPoint() + "?" + u.Query.Encode()
-	err = app.Cache().Get(cacheKey, &res)
-	if err == nil {
-		return res, err
-	}
+	// get from cache, or get from db and save to cache, the concurrent requests of the same query are coalesced
+	err = u.Ctx.Remember(u.EndPoint(), u.EndPoint()+"?"+u.Query.Encode(), &res, func() (any, []string, error) {
+		data := app.ListModel{}
+
+		// prepare db for current ctx
+		tx, err := u.Ctx.DB()
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+
+		// set pagination info
+		data.Count,
+			data.PageContext.Page,
+			data.PageContext.PerPage,
+			data.PageContext.PageCount,
+			err = app.Query().PaginationInfo(tx, &Category{}, u.Query)
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		// return data count if $per_page set to 0
+		if data.PageContext.PerPage == 0 {
+			return data, app.Cache().ListTags(u.Ctx.CacheKey(u.EndPoint()), nil), nil
+		}
+
+		// find data
+		rows, err := app.Query().Find(tx, &Category{}, u.Query)
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		data.SetData(rows, u.Query)
+		return data, app.Cache().ListTags(u.Ctx.CacheKey(u.EndPoint()), data.Data), nil
+	})
+	return res, err
+}
 
-	// prepare db for current ctx
-	tx, err := u.Ctx.DB()
-	if err != nil {
-		return res, app.Error().New(http.StatusInternalServerError, err.Error())
-	}
+// GetTree returns the nested Category data, from the roots to the leaves.
+func (u UseCaseHandler) GetTree() ([]*CategoryTree, error) {
+	res := []*CategoryTree{}
 
-	// set pagination info
-	res.Count,
-		res.PageContext.Page,
-		res.PageContext.PerPage,
-		res.PageContext.PageCount,
-		err = app.Query().PaginationInfo(tx, &Category{}, u.Query)
+	// check permission
+	err := u.Ctx.ValidatePermission("categories.list")
 	if err != nil {
-		return res, app.Error().New(http.StatusInternalServerError, err.Error())
-	}
-	// return data count if $per_page set to 0
-	if res.PageContext.PerPage == 0 {
 		return res, err
 	}
 
-	// find data
-	data, err := app.Query().Find(tx, &Category{}, u.Query)
-	if err != nil {
-		return res, app.Error().New(http.StatusInternalServerError, err.Error())
-	}
-	res.SetData(data, u.Query)
-
-	// save to cache and return if exists
-	app.Cache().Set(cacheKey, res)
+	// get from cache, or get from db and save to cache
+	err = u.Ctx.Remember(u.EndPoint(), u.EndPoint()+".tree", &res, func() (any, []string, error) {
+		roots := []*CategoryTree{}
+
+		// prepare db for current ctx
+		tx, err := u.Ctx.DB()
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+
+		// find data, ordered by path so the parent is always before its children
+		data := []Category{}
+		err = tx.Model(&Category{}).Where("deleted_at IS NULL").Order("path").Find(&data).Error
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		nodes := map[string]*CategoryTree{}
+		for _, d := range data {
+			node := &CategoryTree{
+				ID:         d.ID.String,
+				Name:       d.Name.String,
+				Path:       d.Path.String,
+				Breadcrumb: d.Breadcrumb.String,
+				Children:   []*CategoryTree{},
+			}
+			nodes[node.ID] = node
+			if parent, ok := nodes[d.ParentID.String]; ok && d.ParentID.Valid {
+				parent.Children = append(parent.Children, node)
+			} else {
+				roots = append(roots, node)
+			}
+		}
+		return roots, app.Cache().ListTags(u.Ctx.CacheKey(u.EndPoint()), nil), nil
+	})
 	return res, err
 }
 
@@ -149,6 +197,12 @@ func (u UseCaseHandler) Create(p *ParamCreate) error {
 		return app.Error().New(http.StatusInternalServerError, err.Error())
 	}
 
+	// set the path and breadcrumb based on the parent
+	err = p.setPath(tx, &Category{}, false)
+	if err != nil {
+		return err
+	}
+
 	// save data to db
 	err = tx.Model(&p).Create(&p).Error
 	if err != nil {
@@ -156,10 +210,13 @@ func (u UseCaseHandler) Create(p *ParamCreate) error {
 	}
 
 	// invalidate cache
-	app.Cache().Invalidate(u.EndPoint())
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint())) })
 
-	// save history (user activity), send webhook, etc
-	go u.Ctx.Hook("POST", "create", p.ID.String, p)
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("POST", "create", p.ID.String, p)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
 	return nil
 }
 
@@ -196,17 +253,32 @@ func (u UseCaseHandler) UpdateByID(id string, p *ParamUpdate) error {
 		return app.Error().New(http.StatusInternalServerError, err.Error())
 	}
 
+	// set the path and breadcrumb based on the new parent, it prevents the cycle
+	err = p.setPath(tx, &old, p.IsMoveToRoot.Bool)
+	if err != nil {
+		return err
+	}
+
 	// update data on the db
-	err = tx.Model(&p).Where("id = ?", old.ID).Updates(p).Error
+	err = tx.Model(&p).Where("id = ?", old.ID).Omit("parent_id", "path", "breadcrumb").Updates(p).Error
 	if err != nil {
 		return app.Error().New(http.StatusInternalServerError, err.Error())
 	}
 
+	// move the subtree, it also invalidates the affected cache
+	err = u.moveSubtree(tx, old, p.Category)
+	if err != nil {
+		return err
+	}
+
 	// invalidate cache
-	app.Cache().Invalidate(u.EndPoint(), old.ID.String)
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String) })
 
-	// save history (user activity), send webhook, etc
-	go u.Ctx.Hook("PUT", p.Reason.String, old.ID.String, old)
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("PUT", p.Reason.String, old.ID.String, old)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
 	return nil
 }
 
@@ -243,17 +315,32 @@ func (u UseCaseHandler) PartiallyUpdateByID(id string, p *ParamPartiallyUpdate)
 		return app.Error().New(http.StatusInternalServerError, err.Error())
 	}
 
+	// set the path and breadcrumb based on the new parent, it prevents the cycle
+	err = p.setPath(tx, &old, p.IsMoveToRoot.Bool)
+	if err != nil {
+		return err
+	}
+
 	// update data on the db
-	err = tx.Model(&p).Where("id = ?", old.ID).Updates(p).Error
+	err = tx.Model(&p).Where("id = ?", old.ID).Omit("parent_id", "path", "breadcrumb").Updates(p).Error
 	if err != nil {
 		return app.Error().New(http.StatusInternalServerError, err.Error())
 	}
 
+	// move the subtree, it also invalidates the affected cache
+	err = u.moveSubtree(tx, old, p.Category)
+	if err != nil {
+		return err
+	}
+
 	// invalidate cache
-	app.Cache().Invalidate(u.EndPoint(), old.ID.String)
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String) })
 
-	// save history (user activity), send webhook, etc
-	go u.Ctx.Hook("PATCH", p.Reason.String, old.ID.String, old)
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("PATCH", p.Reason.String, old.ID.String, old)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
 	return nil
 }
 
@@ -284,6 +371,16 @@ func (u UseCaseHandler) DeleteByID(id string, p *ParamDelete) error {
 		return app.Error().New(http.StatusInternalServerError, err.Error())
 	}
 
+	// check if the category still has children
+	var childCount int64
+	err = tx.Model(&Category{}).Where("parent_id = ? AND deleted_at IS NULL", old.ID).Count(&childCount).Error
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	if childCount > 0 {
+		return app.Error().New(http.StatusBadRequest, u.Ctx.Trans("category_has_children"))
+	}
+
 	// update data on the db
 	err = tx.Model(&p).Where("id = ?", old.ID).Update("deleted_at", time.Now().UTC()).Error
 	if err != nil {
@@ -291,10 +388,13 @@ func (u UseCaseHandler) DeleteByID(id string, p *ParamDelete) error {
 	}
 
 	// invalidate cache
-	app.Cache().Invalidate(u.EndPoint(), old.ID.String)
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String) })
 
-	// save history (user activity), send webhook, etc
-	go u.Ctx.Hook("DELETE", p.Reason.String, old.ID.String, old)
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("DELETE", p.Reason.String, old.ID.String, old)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
 	return nil
 }
 
@@ -308,3 +408,102 @@ func (u *UseCaseHandler) setDefaultValue(old Category) error {
 
 	return nil
 }
+
+// setPath set the parent, path and breadcrumb of the Category based on the requested parent.
+// The parent is unchanged if it is not requested, except isMoveToRoot is true.
+// It refuses the parent which is the category itself or one of its descendants to prevent the cycle.
+// The category and its parent are locked, so the concurrent moves are serialized and the old path is refreshed to the committed one.
+func (u *UseCaseHandler) setPath(tx *gorm.DB, old *Category, isMoveToRoot bool) error {
+	if !u.ParentID.Valid {
+		u.ParentID = old.ParentID
+	}
+	if isMoveToRoot {
+		u.ParentID = app.NullUUID{}
+	}
+	if !u.Name.Valid {
+		u.Name = old.Name
+	}
+
+	// lock in the id order, so the moves of A under B and B under A wait for each other instead of both committing a cycle
+	ids := []string{}
+	if u.ParentID.Valid {
+		ids = append(ids, u.ParentID.String)
+	}
+	if old.ID.Valid {
+		ids = append(ids, old.ID.String)
+	}
+	locked := []Category{}
+	if len(ids) > 0 {
+		err := tx.Model(&Category{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND deleted_at IS NULL", ids).Order("id").Find(&locked).Error
+		if err != nil {
+			return app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+	}
+	parent := Category{}
+	for _, c := range locked {
+		if c.ID.String == u.ParentID.String {
+			parent = c
+		}
+		if c.ID.String == old.ID.String {
+			old.Path, old.Breadcrumb = c.Path, c.Breadcrumb
+		}
+	}
+
+	u.Path = app.NewNullString("/" + u.ID.String + "/")
+	u.Breadcrumb = app.NewNullText(u.Name.String)
+	if !u.ParentID.Valid {
+		return nil
+	}
+	if !parent.ID.Valid || parent.ID.String == u.ID.String || strings.Contains(parent.Path.String, "/"+u.ID.String+"/") {
+		return app.Error().New(http.StatusBadRequest, u.Ctx.Trans("invalid_category_parent"))
+	}
+
+	// the depth of the subtree is increased by the depth of the new parent
+	depth := strings.Count(parent.Path.String, "/") - 1
+	if old.Path.Valid {
+		var maxSlash int
+		err := tx.Model(&Category{}).Select("COALESCE(MAX(LENGTH(path) - LENGTH(REPLACE(path, '/', ''))), 0)").
+			Where("path LIKE ? AND deleted_at IS NULL", old.Path.String+"%").Scan(&maxSlash).Error
+		if err != nil {
+			return app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		depth += max(maxSlash-strings.Count(old.Path.String, "/"), 0)
+	}
+	if depth+1 > MaxDepth {
+		return app.Error().New(http.StatusBadRequest, u.Ctx.Trans("invalid_category_parent"))
+	}
+
+	u.Path = app.NewNullString(parent.Path.String + u.ID.String + "/")
+	u.Breadcrumb = app.NewNullText(parent.Breadcrumb.String + BreadcrumbSeparator + u.Name.String)
+	return nil
+}
+
+// moveSubtree saves the parent, path and breadcrumb of the Category, then updates the path and breadcrumb of its descendants.
+// The cache of the descendants and the products is invalidated by the cache dependencies, see Category.CacheDependsOn.
+func (u UseCaseHandler) moveSubtree(tx *gorm.DB, old, new Category) error {
+	err := tx.Model(&Category{}).Where("id = ?", old.ID).Updates(map[string]any{
+		"parent_id":  new.ParentID,
+		"path":       new.Path,
+		"breadcrumb": new.Breadcrumb,
+	}).Error
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	if old.Path.String == new.Path.String && old.Breadcrumb.String == new.Breadcrumb.String {
+		return nil
+	}
+
+	if old.Path.Valid {
+		err = tx.Model(&Category{}).
+			Where("path LIKE ? AND id <> ?", old.Path.String+"%", old.ID).
+			Updates(map[string]any{
+				"path":       gorm.Expr("CONCAT(?, SUBSTRING(path, ?))", new.Path.String, len(old.Path.String)+1),
+				"breadcrumb": gorm.Expr("CONCAT(?, SUBSTRING(breadcrumb, ?))", new.Breadcrumb.String, utf8.RuneCountInString(old.Breadcrumb.String)+1),
+			}).Error
+		if err != nil {
+			return app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+	}
+	return nil
+}
@@ -5,7 +5,8 @@ import "grest-belajar/app"
 // CodeGenTemplate is the main model of CodeGenTemplate data. It provides a convenient interface for app.ModelInterface
 type CodeGenTemplate struct {
 	app.Model
-	ID app.NullUUID `json:"id"                   db:"m.id"              gorm:"column:id;primaryKey"`
+	ID       app.NullUUID    `json:"id"                   db:"m.id"              gorm:"column:id;primaryKey"`
+	TenantID *app.NullString `json:"tenant_id,omitempty"  db:"m.tenant_id,hide"  gorm:"column:tenant_id;size:64;index"`
 	// AddField : DONT REMOVE THIS COMMENT
 	CreatedAt app.NullDateTime  `json:"created_at"           db:"m.created_at"      gorm:"column:created_at"`
 	UpdatedAt app.NullDateTime  `json:"updated_at"           db:"m.updated_at"      gorm:"column:updated_at"`
@@ -20,7 +21,7 @@ func (CodeGenTemplate) EndPoint() string {
 // TableVersion returns the versions of the CodeGenTemplate table in the database.
 // Change this value with date format YY.MM.DDHHii when any table structure changes.
 func (CodeGenTemplate) TableVersion() string {
-	return "28.06.291152"
+	return "26.10.191000"
 }
 
 // TableName returns the name of the CodeGenTemplate table in the database.
@@ -0,0 +1,4 @@
+// errorgroup is a package related to error group data, the server errors aggregated by their fingerprint, see app.ErrorGroups().
+// The groups are listed to know which errors happen and how often, then they are resolved or muted once they are handled.
+// The groups are scoped to the tenant of the request which raised the error, so the tenant only sees its own errors.
+package errorgroup
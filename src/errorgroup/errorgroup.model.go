@@ -0,0 +1,129 @@
+package errorgroup
+
+import (
+	"time"
+
+	"grest-belajar/app"
+)
+
+// MaxMuteDuration is the maximum duration of the muted ErrorGroup, without the duration it is muted until it is resolved.
+const MaxMuteDuration = 90 * 24 * time.Hour
+
+// ErrorGroup is the main model of ErrorGroup data. It provides a convenient interface for app.ModelInterface
+// The table is migrated and written by app.ErrorGroups(), this model is used for querying and changing its status.
+type ErrorGroup struct {
+	app.Model
+	ID            app.NullString   `json:"id"                  db:"m.id"             gorm:"column:id;primaryKey"`
+	TenantID      *app.NullString  `json:"tenant_id,omitempty" db:"m.tenant_id,hide" gorm:"column:tenant_id"`
+	Type          app.NullString   `json:"type"                db:"m.type"           gorm:"column:type"`
+	Message       app.NullText     `json:"message"             db:"m.message"        gorm:"column:message"`
+	StackTop      app.NullString   `json:"stack_top"           db:"m.stack_top"      gorm:"column:stack_top"`
+	Status        app.NullString   `json:"status"              db:"m.status"         gorm:"column:status"`
+	Count         app.NullInt64    `json:"count"               db:"m.count"          gorm:"column:count"`
+	FirstSeenAt   app.NullDateTime `json:"first_seen_at"       db:"m.first_seen_at"  gorm:"column:first_seen_at"`
+	LastSeenAt    app.NullDateTime `json:"last_seen_at"        db:"m.last_seen_at"   gorm:"column:last_seen_at"`
+	SampleRequest app.NullJSON     `json:"sample_request"      db:"m.sample_request" gorm:"column:sample_request"`
+	SampleTrace   app.NullJSON     `json:"sample_trace"        db:"m.sample_trace"   gorm:"column:sample_trace"`
+	MutedUntil    app.NullDateTime `json:"muted_until"         db:"m.muted_until"    gorm:"column:muted_until"`
+	ResolvedAt    app.NullDateTime `json:"resolved_at"         db:"m.resolved_at"    gorm:"column:resolved_at"`
+}
+
+// EndPoint returns the ErrorGroup end point, it used for cache key, etc.
+func (ErrorGroup) EndPoint() string {
+	return "error-groups"
+}
+
+// TableName returns the name of the ErrorGroup table in the database.
+func (ErrorGroup) TableName() string {
+	return app.ErrorGroup{}.TableName()
+}
+
+// TableAliasName returns the table alias name of the ErrorGroup table, used for querying.
+func (ErrorGroup) TableAliasName() string {
+	return "m"
+}
+
+// GetRelations returns the relations of the ErrorGroup data in the database, used for querying.
+func (m *ErrorGroup) GetRelations() map[string]map[string]any {
+	return m.Relations
+}
+
+// GetFilters returns the filter of the ErrorGroup data in the database, used for querying.
+func (m *ErrorGroup) GetFilters() []map[string]any {
+	return m.Filters
+}
+
+// GetSorts returns the default sort of the ErrorGroup data in the database, used for querying.
+func (m *ErrorGroup) GetSorts() []map[string]any {
+	m.AddSort(map[string]any{"column": "m.last_seen_at", "direction": "desc"})
+	return m.Sorts
+}
+
+// GetFields returns list of the field of the ErrorGroup data in the database, used for querying.
+func (m *ErrorGroup) GetFields() map[string]map[string]any {
+	m.SetFields(m)
+	return m.Fields
+}
+
+// GetSchema returns the ErrorGroup schema, used for querying.
+func (m *ErrorGroup) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+// OpenAPISchemaName returns the name of the ErrorGroup schema in the open api documentation.
+func (ErrorGroup) OpenAPISchemaName() string {
+	return "ErrorGroup"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the ErrorGroup in the open api documentation.
+func (m *ErrorGroup) GetOpenAPISchema() map[string]any {
+	return m.SetOpenAPISchema(m)
+}
+
+type ErrorGroupList struct {
+	app.ListModel
+}
+
+// OpenAPISchemaName returns the name of the ErrorGroupList schema in the open api documentation.
+func (ErrorGroupList) OpenAPISchemaName() string {
+	return "ErrorGroupList"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the ErrorGroupList in the open api documentation.
+func (p *ErrorGroupList) GetOpenAPISchema() map[string]any {
+	return p.SetOpenAPISchema(&ErrorGroup{})
+}
+
+// ParamResolve is the expected parameters for resolve the ErrorGroup, the group is reopened when the error happens again.
+type ParamResolve struct {
+	app.Model
+	Reason app.NullString `json:"reason"`
+}
+
+// OpenAPISchemaName returns the name of the ParamResolve schema in the open api documentation.
+func (ParamResolve) OpenAPISchemaName() string {
+	return "ErrorGroupParamResolve"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the ParamResolve in the open api documentation.
+func (p *ParamResolve) GetOpenAPISchema() map[string]any {
+	return p.SetOpenAPISchema(p)
+}
+
+// ParamMute is the expected parameters for mute the ErrorGroup, the muted group is still counted but it doesn't raise the alert.
+// The duration is like "24h", up to MaxMuteDuration, without it the group is muted until it is resolved.
+type ParamMute struct {
+	app.Model
+	Duration app.NullString `json:"duration"`
+	Reason   app.NullString `json:"reason"`
+}
+
+// OpenAPISchemaName returns the name of the ParamMute schema in the open api documentation.
+func (ParamMute) OpenAPISchemaName() string {
+	return "ErrorGroupParamMute"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the ParamMute in the open api documentation.
+func (p *ParamMute) GetOpenAPISchema() map[string]any {
+	return p.SetOpenAPISchema(p)
+}
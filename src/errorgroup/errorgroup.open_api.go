@@ -0,0 +1,89 @@
+package errorgroup
+
+import "grest-belajar/app"
+
+// OpenAPI is constructor for *openAPI, to autogenerate open api document.
+func OpenAPI() *OpenAPIOperation {
+	return &OpenAPIOperation{}
+}
+
+// OpenAPIOperation embed from app.OpenAPIOperation for simplicity, used for autogenerate open api document.
+type OpenAPIOperation struct {
+	app.OpenAPIOperation
+}
+
+// Base is common detail of error groups open api document component.
+func (o *OpenAPIOperation) Base() {
+	o.Tags = []string{"Error Group"}
+	o.HeaderParams = []map[string]any{{"$ref": "#/components/parameters/headerParam.Accept-Language"}}
+	o.Responses = map[string]map[string]any{
+		"200": {
+			"description": "Success",
+			"content":     map[string]any{"application/json": &ErrorGroup{}}, // will auto create schema $ref: '#/components/schemas/ErrorGroup' if not exists
+		},
+		"400": app.OpenAPIError().BadRequest(),
+		"401": app.OpenAPIError().Unauthorized(),
+		"403": app.OpenAPIError().Forbidden(),
+	}
+	o.Securities = []map[string][]string{}
+}
+
+// Get is detail of `GET /api/v3/error-groups` open api document component.
+func (o *OpenAPIOperation) Get() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Get Error Group"
+	o.Description = "Use this method to get list of Error Group, the server errors aggregated by their fingerprint, the most recent first. " +
+		"Use `status=unresolved` to get the errors which are not handled yet."
+	o.QueryParams = []map[string]any{{"$ref": "#/components/parameters/queryParam.Any"}}
+	o.Responses["200"] = map[string]any{
+		"description": "Success",
+		"content":     map[string]any{"application/json": &ErrorGroupList{}}, // will auto create schema $ref: '#/components/schemas/ErrorGroupList' if not exists
+	}
+	return o
+}
+
+// GetByID is detail of `GET /api/v3/error-groups/{id}` open api document component.
+func (o *OpenAPIOperation) GetByID() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Get Error Group By ID"
+	o.Description = "Use this method to get Error Group by id, including its sample request and sample trace"
+	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
+	return o
+}
+
+// ResolveByID is detail of `POST /api/v3/error-groups/{id}/resolve` open api document component.
+func (o *OpenAPIOperation) ResolveByID() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Resolve Error Group"
+	o.Description = "Use this method to resolve Error Group by id, it is reopened and alerted again when the error happens after it is resolved"
+	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
+	o.Body = map[string]any{"application/json": &ParamResolve{}}
+	return o
+}
+
+// MuteByID is detail of `POST /api/v3/error-groups/{id}/mute` open api document component.
+func (o *OpenAPIOperation) MuteByID() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Mute Error Group"
+	o.Description = "Use this method to mute Error Group by id, it is still counted but it doesn't raise the alert. " +
+		"It is muted for the duration, for example `24h`, or until it is resolved if the duration is empty."
+	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
+	o.Body = map[string]any{"application/json": &ParamMute{}}
+	return o
+}
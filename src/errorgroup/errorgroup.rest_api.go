@@ -0,0 +1,109 @@
+package errorgroup
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"grest.dev/grest"
+
+	"grest-belajar/app"
+)
+
+// REST returns a *RESTAPIHandler.
+func REST() *RESTAPIHandler {
+	return &RESTAPIHandler{}
+}
+
+// RESTAPIHandler provides a convenient interface for ErrorGroup REST API handler.
+type RESTAPIHandler struct {
+	UseCase UseCaseHandler
+}
+
+// injectDeps inject the dependencies of the ErrorGroup REST API handler.
+func (r *RESTAPIHandler) injectDeps(c *fiber.Ctx) error {
+	ctx, ok := c.Locals(app.CtxKey).(*app.Ctx)
+	if !ok {
+		return app.Error().New(http.StatusInternalServerError, "ctx is not found")
+	}
+	r.UseCase = UseCase(*ctx, app.Query().Parse(c.OriginalURL()))
+	return nil
+}
+
+// GetByID is the REST API handler for `GET /api/error-groups/{id}`.
+func (r *RESTAPIHandler) GetByID(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res, err := r.UseCase.GetByID(c.Params("id"))
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	if r.UseCase.IsFlat() {
+		return c.JSON(res)
+	}
+	return c.JSON(grest.NewJSON(res).ToStructured().Data)
+}
+
+// Get is the REST API handler for `GET /api/error-groups`.
+func (r *RESTAPIHandler) Get(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res, err := r.UseCase.Get()
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res.SetLink(c)
+	if r.UseCase.IsFlat() {
+		return c.JSON(res)
+	}
+	return c.JSON(grest.NewJSON(res).ToStructured().Data)
+}
+
+// ResolveByID is the REST API handler for `POST /api/error-groups/{id}/resolve`.
+func (r *RESTAPIHandler) ResolveByID(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	p := ParamResolve{}
+	if len(c.Body()) > 0 {
+		err = grest.NewJSON(c.Body()).ToFlat().Unmarshal(&p)
+		if err != nil {
+			return app.Error().Handler(c, app.Error().New(http.StatusBadRequest, err.Error()))
+		}
+	}
+	res, err := r.UseCase.ResolveByID(c.Params("id"), &p)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	if r.UseCase.IsFlat() {
+		return c.JSON(res)
+	}
+	return c.JSON(grest.NewJSON(res).ToStructured().Data)
+}
+
+// MuteByID is the REST API handler for `POST /api/error-groups/{id}/mute`.
+func (r *RESTAPIHandler) MuteByID(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	p := ParamMute{}
+	if len(c.Body()) > 0 {
+		err = grest.NewJSON(c.Body()).ToFlat().Unmarshal(&p)
+		if err != nil {
+			return app.Error().Handler(c, app.Error().New(http.StatusBadRequest, err.Error()))
+		}
+	}
+	res, err := r.UseCase.MuteByID(c.Params("id"), &p)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	if r.UseCase.IsFlat() {
+		return c.JSON(res)
+	}
+	return c.JSON(grest.NewJSON(res).ToStructured().Data)
+}
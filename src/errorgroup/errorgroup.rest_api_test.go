@@ -0,0 +1,164 @@
+package errorgroup
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/gorm"
+
+	"grest-belajar/app"
+)
+
+// prepareTest prepares the test.
+func prepareTest(tb testing.TB) {
+	app.Test()
+	tx := app.Test().Tx
+	app.DB().RegisterTable("main", app.ErrorGroup{})
+	app.DB().RegisterTable("main", app.OutboxEvent{})
+	app.DB().MigrateTable(tx, "main", app.Setting{})
+	tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&app.ErrorGroup{})
+
+	now := time.Now().UTC()
+	tx.Create(&app.ErrorGroup{
+		ID:            getTestErrorGroupID(),
+		Type:          "*grest.Error",
+		Message:       "failed to load item {num}",
+		Status:        app.ErrorGroupStatusUnresolved,
+		Count:         3,
+		FirstSeenAt:   now,
+		LastSeenAt:    now,
+		SampleRequest: json.RawMessage(`{"method":"GET","path":"/api/items/1"}`),
+		SampleTrace:   json.RawMessage(`[]`),
+	})
+
+	app.Server().AddMiddleware(app.Test().NewCtx([]string{
+		"error_groups.detail",
+		"error_groups.list",
+		"error_groups.edit",
+	}))
+	app.Server().AddRoute("/error-groups", "GET", REST().Get, nil)
+	app.Server().AddRoute("/error-groups/:id", "GET", REST().GetByID, nil)
+	app.Server().AddRoute("/error-groups/:id/resolve", "POST", REST().ResolveByID, nil)
+	app.Server().AddRoute("/error-groups/:id/mute", "POST", REST().MuteByID, nil)
+}
+
+// getTestErrorGroupID returns an available ErrorGroup ID.
+func getTestErrorGroupID() string {
+	return app.ErrorFingerprint("", "*grest.Error", "failed to load item {num}", "")
+}
+
+// tests is test scenario.
+var tests = []struct {
+	description  string // description of the test case
+	method       string // method to test
+	path         string // route path to test
+	token        string // token to test
+	bodyRequest  string // body to test
+	expectedCode int    // expected HTTP status code
+	expectedBody string // expected body response
+}{
+	{
+		description:  "Get list of unresolved ErrorGroup",
+		method:       "GET",
+		path:         "/error-groups?status=unresolved",
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusOK,
+		expectedBody: `{"count":1}`,
+	},
+	{
+		description:  "Get ErrorGroup by ID",
+		method:       "GET",
+		path:         "/error-groups/" + getTestErrorGroupID(),
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusOK,
+		expectedBody: `{"message":"failed to load item {num}","count":3,"status":"unresolved"}`,
+	},
+	{
+		description:  "Get unavailable ErrorGroup",
+		method:       "GET",
+		path:         "/error-groups/unavailable",
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusNotFound,
+	},
+	{
+		description:  "Mute ErrorGroup with invalid duration",
+		method:       "POST",
+		path:         "/error-groups/" + getTestErrorGroupID() + "/mute",
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"duration":"forever"}`,
+		expectedCode: http.StatusBadRequest,
+	},
+	{
+		description:  "Mute ErrorGroup",
+		method:       "POST",
+		path:         "/error-groups/" + getTestErrorGroupID() + "/mute",
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"duration":"24h","reason":"Known issue of the payment gateway"}`,
+		expectedCode: http.StatusOK,
+		expectedBody: `{"status":"muted"}`,
+	},
+	{
+		description:  "Resolve ErrorGroup",
+		method:       "POST",
+		path:         "/error-groups/" + getTestErrorGroupID() + "/resolve",
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"reason":"Fixed on the latest release"}`,
+		expectedCode: http.StatusOK,
+		expectedBody: `{"status":"resolved","muted_until":null}`,
+	},
+	{
+		description:  "Get list of unresolved ErrorGroup after resolved",
+		method:       "GET",
+		path:         "/error-groups?status=unresolved",
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusOK,
+		expectedBody: `{"count":0,"results":[]}`,
+	},
+}
+
+// TestErrorGroupREST tests the REST API of ErrorGroup data with specified scenario.
+func TestErrorGroupREST(t *testing.T) {
+	prepareTest(t)
+
+	// Iterate through test single test cases
+	for _, test := range tests {
+
+		// Create a new http request with the route from the test case
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.bodyRequest))
+		req.Header.Add("Authorization", "Bearer "+test.token)
+		req.Header.Add("Content-Type", "application/json")
+
+		// Perform the request plain with the app, the second argument is a request latency (set to -1 for no latency)
+		res, err := app.Server().Test(req)
+
+		// Verify if the status code is as expected
+		utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+		utils.AssertEqual(t, test.expectedCode, res.StatusCode, test.description)
+
+		// Verify if the body response is as expected
+		body, err := io.ReadAll(res.Body)
+		utils.AssertEqual(t, nil, err, "io.ReadAll(res.Body)")
+		app.Test().AssertMatchJSONElement(t, []byte(test.expectedBody), body, test.description)
+		res.Body.Close()
+	}
+}
+
+// BenchmarkErrorGroupREST tests the REST API of ErrorGroup data with specified scenario.
+func BenchmarkErrorGroupREST(b *testing.B) {
+	b.ReportAllocs()
+	prepareTest(b)
+	for i := 0; i < b.N; i++ {
+		for _, test := range tests {
+			req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.bodyRequest))
+			req.Header.Add("Authorization", "Bearer "+test.token)
+			req.Header.Add("Content-Type", "application/json")
+			app.Server().Test(req)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package errorgroup
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"grest-belajar/app"
+)
+
+// UseCase returns a UseCaseHandler for expected use case functional.
+func UseCase(ctx app.Ctx, query ...url.Values) UseCaseHandler {
+	u := UseCaseHandler{
+		Ctx:   &ctx,
+		Query: url.Values{},
+	}
+	if len(query) > 0 {
+		u.Query = query[0]
+	}
+	return u
+}
+
+// UseCaseHandler provides a convenient interface for ErrorGroup use case, use UseCase to access UseCaseHandler.
+// The ErrorGroup data is not cached since its count is changed by every instance on every flush.
+type UseCaseHandler struct {
+	ErrorGroup
+
+	// injectable dependencies
+	Ctx   *app.Ctx   `json:"-" db:"-" gorm:"-"`
+	Query url.Values `json:"-" db:"-" gorm:"-"`
+}
+
+// GetByID returns the ErrorGroup data for the specified ID.
+func (u UseCaseHandler) GetByID(id string) (ErrorGroup, error) {
+	res := ErrorGroup{}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("error_groups.detail")
+	if err != nil {
+		return res, err
+	}
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// get from db
+	query := url.Values{}
+	for k, v := range u.Query {
+		query[k] = v
+	}
+	query.Set("id", id)
+	err = app.Query().First(tx, &res, query)
+	if err != nil {
+		return res, u.Ctx.NotFoundError(err, u.EndPoint(), "id", id)
+	}
+	return res, nil
+}
+
+// Get returns the list of ErrorGroup data, the most recent first, filter it by the status to get the unresolved ones, for example.
+func (u UseCaseHandler) Get() (app.ListModel, error) {
+	res := app.ListModel{}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("error_groups.list")
+	if err != nil {
+		return res, err
+	}
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// set pagination info
+	res.Count,
+		res.PageContext.Page,
+		res.PageContext.PerPage,
+		res.PageContext.PageCount,
+		err = app.Query().PaginationInfo(tx, &ErrorGroup{}, u.Query)
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	// return data count if $per_page set to 0
+	if res.PageContext.PerPage == 0 {
+		return res, nil
+	}
+
+	// find data
+	rows, err := app.Query().Find(tx, &ErrorGroup{}, u.Query)
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	res.SetData(rows, u.Query)
+	return res, nil
+}
+
+// ResolveByID resolves the ErrorGroup for the specified ID, its alert is resolved too.
+// The group is reopened and alerted again when the error happens after it is resolved.
+func (u UseCaseHandler) ResolveByID(id string, p *ParamResolve) (ErrorGroup, error) {
+
+	// check permission
+	err := u.Ctx.ValidatePermission("error_groups.edit")
+	if err != nil {
+		return ErrorGroup{}, err
+	}
+
+	// validate param
+	err = u.Ctx.ValidateParam(p)
+	if err != nil {
+		return ErrorGroup{}, err
+	}
+
+	// get previous data
+	old, err := u.GetByID(id)
+	if err != nil {
+		return ErrorGroup{}, err
+	}
+
+	err = u.updateStatus(old, map[string]any{
+		"status":      app.ErrorGroupStatusResolved,
+		"resolved_at": time.Now().UTC(),
+		"muted_until": nil,
+	}, p.Reason.String)
+	if err != nil {
+		return ErrorGroup{}, err
+	}
+
+	// the alert is raised by the instance which flushes the group, so the resolve is broadcasted to all instances
+	u.Ctx.AfterCommit(func() {
+		app.Alert().BroadcastResolve("error_group:"+old.ID.String, "The error group is resolved.")
+	})
+	return u.GetByID(id)
+}
+
+// MuteByID mutes the ErrorGroup for the specified ID, the muted group is still counted but it doesn't raise the alert.
+// The group is muted until the duration passes, or until it is resolved if the duration is empty.
+func (u UseCaseHandler) MuteByID(id string, p *ParamMute) (ErrorGroup, error) {
+
+	// check permission
+	err := u.Ctx.ValidatePermission("error_groups.edit")
+	if err != nil {
+		return ErrorGroup{}, err
+	}
+
+	// validate param
+	err = u.Ctx.ValidateParam(p)
+	if err != nil {
+		return ErrorGroup{}, err
+	}
+	var mutedUntil *time.Time
+	if d := strings.TrimSpace(p.Duration.String); d != "" {
+		duration, err := time.ParseDuration(d)
+		if err != nil || duration <= 0 || duration > MaxMuteDuration {
+			return ErrorGroup{}, app.Error().New(http.StatusBadRequest, u.Ctx.Trans("invalid_mute_duration", map[string]string{"max": strconv.Itoa(int(MaxMuteDuration.Hours())) + "h"}))
+		}
+		until := time.Now().UTC().Add(duration)
+		mutedUntil = &until
+	}
+
+	// get previous data
+	old, err := u.GetByID(id)
+	if err != nil {
+		return ErrorGroup{}, err
+	}
+
+	err = u.updateStatus(old, map[string]any{
+		"status":      app.ErrorGroupStatusMuted,
+		"muted_until": mutedUntil,
+		"resolved_at": nil,
+	}, p.Reason.String)
+	if err != nil {
+		return ErrorGroup{}, err
+	}
+	return u.GetByID(id)
+}
+
+// updateStatus saves the status of the ErrorGroup, then records the event.
+func (u UseCaseHandler) updateStatus(old ErrorGroup, values map[string]any, reason string) error {
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// update data on the db
+	err = tx.Model(&ErrorGroup{}).Where("id = ?", old.ID).Updates(values).Error
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("PATCH", reason, old.ID.String, old)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,2 @@
+// file is a package related to file data, the metadata of the uploaded files which can be attached to any entity.
+package file
@@ -0,0 +1,129 @@
+package file
+
+import "grest-belajar/app"
+
+// File is the main model of File data. It provides a convenient interface for app.ModelInterface
+// The content is stored through the tenant scoped app.Ctx.FS() on the Key, the URL is generated on every read since it expires.
+// The owner is the entity the file is attached to, for example the "products" end point and the product id.
+type File struct {
+	app.Model
+	ID               app.NullUUID      `json:"id"                   db:"m.id"                  gorm:"column:id;primaryKey"`
+	TenantID         *app.NullString   `json:"tenant_id,omitempty"  db:"m.tenant_id,hide"      gorm:"column:tenant_id;size:64;index"`
+	Name             app.NullString    `json:"name"                 db:"m.name"                gorm:"column:name"`
+	Key              app.NullString    `json:"key"                  db:"m.key"                 gorm:"column:key;size:760;index"`
+	URL              app.NullString    `json:"url"                  db:"-"                     gorm:"-"`
+	Size             app.NullInt64     `json:"size"                 db:"m.size"                gorm:"column:size"`
+	Checksum         app.NullString    `json:"checksum"             db:"m.checksum"            gorm:"column:checksum;size:64"`
+	MimeType         app.NullString    `json:"mime_type"            db:"m.mime_type"           gorm:"column:mime_type;size:255"`
+	OwnerEndPoint    app.NullString    `json:"owner.end_point"      db:"m.owner_end_point"     gorm:"column:owner_end_point;size:64;index:idx_files_owner"`
+	OwnerID          app.NullString    `json:"owner.id"             db:"m.owner_id"            gorm:"column:owner_id;size:64;index:idx_files_owner"`
+	UploadedByUserID app.NullString    `json:"uploaded_by.id"       db:"m.uploaded_by_user_id" gorm:"column:uploaded_by_user_id;size:64"`
+	CreatedAt        app.NullDateTime  `json:"created_at"           db:"m.created_at"          gorm:"column:created_at"`
+	UpdatedAt        app.NullDateTime  `json:"updated_at"           db:"m.updated_at"          gorm:"column:updated_at"`
+	DeletedAt        *app.NullDateTime `json:"deleted_at,omitempty" db:"m.deleted_at,hide"     gorm:"column:deleted_at"`
+}
+
+// EndPoint returns the File end point, it used for cache key, etc.
+func (File) EndPoint() string {
+	return "files"
+}
+
+// TableVersion returns the versions of the File table in the database.
+// Change this value with date format YY.MM.DDHHii when any table structure changes.
+func (File) TableVersion() string {
+	return "26.10.191400"
+}
+
+// TableName returns the name of the File table in the database.
+func (File) TableName() string {
+	return "files"
+}
+
+// TableAliasName returns the table alias name of the File table, used for querying.
+func (File) TableAliasName() string {
+	return "m"
+}
+
+// GetRelations returns the relations of the File data in the database, used for querying.
+func (m *File) GetRelations() map[string]map[string]any {
+	// m.AddRelation("left", "users", "uu", []map[string]any{{"column1": "uu.id", "column2": "m.uploaded_by_user_id"}})
+	return m.Relations
+}
+
+// GetFilters returns the filter of the File data in the database, used for querying.
+func (m *File) GetFilters() []map[string]any {
+	m.AddFilter(map[string]any{"column1": "m.deleted_at", "operator": "=", "value": nil})
+	return m.Filters
+}
+
+// GetSorts returns the default sort of the File data in the database, used for querying.
+func (m *File) GetSorts() []map[string]any {
+	m.AddSort(map[string]any{"column": "m.created_at", "direction": "desc"})
+	return m.Sorts
+}
+
+// GetFields returns list of the field of the File data in the database, used for querying.
+func (m *File) GetFields() map[string]map[string]any {
+	m.SetFields(m)
+	return m.Fields
+}
+
+// GetSchema returns the File schema, used for querying.
+func (m *File) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+// OpenAPISchemaName returns the name of the File schema in the open api documentation.
+func (File) OpenAPISchemaName() string {
+	return "File"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the File in the open api documentation.
+func (m *File) GetOpenAPISchema() map[string]any {
+	return m.SetOpenAPISchema(m)
+}
+
+type FileList struct {
+	app.ListModel
+}
+
+// OpenAPISchemaName returns the name of the FileList schema in the open api documentation.
+func (FileList) OpenAPISchemaName() string {
+	return "FileList"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the FileList in the open api documentation.
+func (p *FileList) GetOpenAPISchema() map[string]any {
+	return p.SetOpenAPISchema(&File{})
+}
+
+// ParamCreate is the expected parameters for upload a new File, sent as the multipart form fields along with the `file` field.
+type ParamCreate struct {
+	UseCaseHandler
+	OwnerEndPoint app.NullString `json:"owner.end_point" gorm:"column:owner_end_point" validate:"required_with=OwnerID"`
+	OwnerID       app.NullString `json:"owner.id"        gorm:"column:owner_id"        validate:"required_with=OwnerEndPoint"`
+}
+
+// OpenAPISchemaName returns the name of the ParamCreate schema in the open api documentation.
+func (ParamCreate) OpenAPISchemaName() string {
+	return "FileParamCreate"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the ParamCreate in the open api documentation.
+func (ParamCreate) GetOpenAPISchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"file":            map[string]any{"type": "string", "format": "binary"},
+			"owner.end_point": map[string]any{"type": "string", "example": "products"},
+			"owner.id":        map[string]any{"type": "string"},
+		},
+		"required": []string{"file"},
+	}
+}
+
+// ParamDelete is the expected parameters for delete the File data.
+type ParamDelete struct {
+	UseCaseHandler
+	Reason app.NullString `json:"reason" gorm:"-" validate:"required"`
+}
@@ -0,0 +1,91 @@
+package file
+
+import "grest-belajar/app"
+
+// OpenAPI is constructor for *openAPI, to autogenerate open api document.
+func OpenAPI() *OpenAPIOperation {
+	return &OpenAPIOperation{}
+}
+
+// OpenAPIOperation embed from app.OpenAPIOperation for simplicity, used for autogenerate open api document.
+type OpenAPIOperation struct {
+	app.OpenAPIOperation
+}
+
+// Base is common detail of files open api document component.
+func (o *OpenAPIOperation) Base() {
+	o.Tags = []string{"File"}
+	o.HeaderParams = []map[string]any{{"$ref": "#/components/parameters/headerParam.Accept-Language"}}
+	o.Responses = map[string]map[string]any{
+		"200": {
+			"description": "Success",
+			"content":     map[string]any{"application/json": &File{}}, // will auto create schema $ref: '#/components/schemas/File' if not exists
+		},
+		"400": app.OpenAPIError().BadRequest(),
+		"401": app.OpenAPIError().Unauthorized(),
+		"403": app.OpenAPIError().Forbidden(),
+	}
+	o.Securities = []map[string][]string{}
+}
+
+// Get is detail of `GET /api/files` open api document component.
+func (o *OpenAPIOperation) Get() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Get File"
+	o.Description = "Use this method to get list of File, for example the files of the product with `?owner.end_point=products&owner.id={id}`"
+	o.QueryParams = []map[string]any{{"$ref": "#/components/parameters/queryParam.Any"}}
+	o.Responses = map[string]map[string]any{
+		"200": {
+			"description": "Success",
+			"content":     map[string]any{"application/json": &FileList{}}, // will auto create schema $ref: '#/components/schemas/File.List' if not exists
+		},
+		"400": app.OpenAPIError().BadRequest(),
+		"401": app.OpenAPIError().Unauthorized(),
+		"403": app.OpenAPIError().Forbidden(),
+	}
+	return o
+}
+
+// GetByID is detail of `GET /api/files/{id}` open api document component.
+func (o *OpenAPIOperation) GetByID() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Get File By ID"
+	o.Description = "Use this method to get File by id, the url expires after a while so it must not be stored"
+	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
+	return o
+}
+
+// Create is detail of `POST /api/files` open api document component.
+func (o *OpenAPIOperation) Create() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Upload File"
+	o.Description = "Use this method to upload File as multipart form, optionally attached to the owner entity (products or categories) which must be accessible by the user"
+	o.Body = map[string]any{"multipart/form-data": &ParamCreate{}}
+	return o
+}
+
+// DeleteByID is detail of `DELETE /api/files/{id}` open api document component.
+func (o *OpenAPIOperation) DeleteByID() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Delete File By ID"
+	o.Description = "Use this method to delete File by id, the content is deleted from the storage"
+	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
+	o.Body = map[string]any{"application/json": &ParamDelete{}}
+	return o
+}
@@ -0,0 +1,116 @@
+package file
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"grest.dev/grest"
+
+	"grest-belajar/app"
+)
+
+// REST returns a *RESTAPIHandler.
+func REST() *RESTAPIHandler {
+	return &RESTAPIHandler{}
+}
+
+// RESTAPIHandler provides a convenient interface for File REST API handler.
+type RESTAPIHandler struct {
+	UseCase UseCaseHandler
+}
+
+// injectDeps inject the dependencies of the File REST API handler.
+func (r *RESTAPIHandler) injectDeps(c *fiber.Ctx) error {
+	ctx, ok := c.Locals(app.CtxKey).(*app.Ctx)
+	if !ok {
+		return app.Error().New(http.StatusInternalServerError, "ctx is not found")
+	}
+	r.UseCase = UseCase(*ctx, app.Query().Parse(c.OriginalURL()))
+	return nil
+}
+
+// GetByID is the REST API handler for `GET /api/files/{id}`.
+func (r *RESTAPIHandler) GetByID(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res, err := r.UseCase.GetByID(c.Params("id"))
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	if r.UseCase.IsFlat() {
+		return c.JSON(res)
+	}
+	return c.JSON(grest.NewJSON(res).ToStructured().Data)
+}
+
+// Get is the REST API handler for `GET /api/files`.
+func (r *RESTAPIHandler) Get(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res, err := r.UseCase.Get()
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res.SetLink(c)
+	if r.UseCase.IsFlat() {
+		return c.JSON(res)
+	}
+	return c.JSON(grest.NewJSON(res).ToStructured().Data)
+}
+
+// Create is the REST API handler for `POST /api/files`, the file is uploaded as the `file` field of the multipart form.
+func (r *RESTAPIHandler) Create(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	p := ParamCreate{}
+	if v := c.FormValue("owner.end_point"); v != "" {
+		p.OwnerEndPoint = app.NewNullString(v)
+	}
+	if v := c.FormValue("owner.id"); v != "" {
+		p.OwnerID = app.NewNullString(v)
+	}
+	fh, _ := c.FormFile("file") // the missing file is validated by the use case
+	err = r.UseCase.Create(&p, fh)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res, err := r.UseCase.GetByID(p.ID.String)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	if r.UseCase.IsFlat() {
+		return c.Status(http.StatusCreated).JSON(res)
+	}
+	return c.Status(http.StatusCreated).JSON(grest.NewJSON(res).ToStructured().Data)
+}
+
+// DeleteByID is the REST API handler for `DELETE /api/files/{id}`.
+func (r *RESTAPIHandler) DeleteByID(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	p := ParamDelete{}
+	err = grest.NewJSON(c.Body()).ToFlat().Unmarshal(&p)
+	if err != nil {
+		return app.Error().Handler(c, app.Error().New(http.StatusBadRequest, err.Error()))
+	}
+	err = r.UseCase.DeleteByID(c.Params("id"), &p)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res := map[string]any{
+		"code": http.StatusOK,
+		"message": r.UseCase.Ctx.Trans("deleted", map[string]string{
+			"files": p.EndPoint(),
+			"id":    c.Params("id"),
+		}),
+	}
+	return c.JSON(res)
+}
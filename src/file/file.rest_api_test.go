@@ -0,0 +1,177 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/gorm"
+
+	"grest-belajar/app"
+)
+
+// prepareTest prepares the test, the files are stored on the memory driver.
+func prepareTest(tb testing.TB) {
+	app.Test()
+	tx := app.Test().Tx
+	app.DB().RegisterTable("main", File{})
+	app.DB().RegisterTable("main", app.OutboxEvent{})
+	app.DB().MigrateTable(tx, "main", app.Setting{})
+	tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&File{})
+
+	RegisterOwner("products", func(ctx app.Ctx, id string) error {
+		if id != "a" {
+			return app.Error().New(http.StatusNotFound, "product is not found")
+		}
+		return nil
+	})
+
+	app.Server().AddMiddleware(app.Test().NewCtx([]string{
+		"files.detail",
+		"files.list",
+		"files.create",
+		"files.delete",
+	}))
+	app.Server().AddRoute("/files", "POST", REST().Create, nil)
+	app.Server().AddRoute("/files", "GET", REST().Get, nil)
+	app.Server().AddRoute("/files/:id", "GET", REST().GetByID, nil)
+	app.Server().AddRoute("/files/:id", "DELETE", REST().DeleteByID, nil)
+}
+
+// newUploadRequest returns the multipart request to upload the file with the form fields.
+func newUploadRequest(fileName string, content []byte, fields map[string]string) *http.Request {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	if fileName != "" {
+		fw, _ := w.CreateFormFile("file", fileName)
+		fw.Write(content)
+	}
+	w.Close()
+	req := httptest.NewRequest("POST", "/files", body)
+	req.Header.Add("Authorization", "Bearer "+app.TestFullAccessToken)
+	req.Header.Add("Content-Type", w.FormDataContentType())
+	return req
+}
+
+// uploadTests is the upload test scenario.
+var uploadTests = []struct {
+	description  string            // description of the test case
+	fileName     string            // file name to upload
+	content      []byte            // file content to upload
+	fields       map[string]string // form fields to upload
+	expectedCode int               // expected HTTP status code
+	expectedBody string            // expected body response
+}{
+	{
+		description:  "Upload File without file",
+		fields:       map[string]string{"owner.end_point": "products", "owner.id": "a"},
+		expectedCode: http.StatusBadRequest,
+	},
+	{
+		description:  "Upload File with not allowed type",
+		fileName:     "run.exe",
+		content:      []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xff\xff\x00\x00"),
+		expectedCode: http.StatusBadRequest,
+	},
+	{
+		description:  "Upload File with owner id only",
+		fileName:     "catalog.csv",
+		content:      []byte("name,stock\nApple,10\n"),
+		fields:       map[string]string{"owner.id": "a"},
+		expectedCode: http.StatusBadRequest,
+	},
+	{
+		description:  "Upload File with unregistered owner",
+		fileName:     "catalog.csv",
+		content:      []byte("name,stock\nApple,10\n"),
+		fields:       map[string]string{"owner.end_point": "users", "owner.id": "a"},
+		expectedCode: http.StatusBadRequest,
+	},
+	{
+		description:  "Upload File with unavailable owner",
+		fileName:     "catalog.csv",
+		content:      []byte("name,stock\nApple,10\n"),
+		fields:       map[string]string{"owner.end_point": "products", "owner.id": "b"},
+		expectedCode: http.StatusBadRequest,
+	},
+	{
+		description:  "Upload File",
+		fileName:     "catalog.csv",
+		content:      []byte("name,stock\nApple,10\n"),
+		fields:       map[string]string{"owner.end_point": "products", "owner.id": "a"},
+		expectedCode: http.StatusCreated,
+		expectedBody: `{"name":"catalog.csv","size":20,"mime_type":"text/csv","owner":{"end_point":"products","id":"a"}}`,
+	},
+}
+
+// TestFileREST tests the REST API of File data with specified scenario.
+func TestFileREST(t *testing.T) {
+	prepareTest(t)
+
+	id := ""
+	for _, test := range uploadTests {
+		res, err := app.Server().Test(newUploadRequest(test.fileName, test.content, test.fields))
+		utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+		utils.AssertEqual(t, test.expectedCode, res.StatusCode, test.description)
+		body, err := io.ReadAll(res.Body)
+		utils.AssertEqual(t, nil, err, "io.ReadAll(res.Body)")
+		app.Test().AssertMatchJSONElement(t, []byte(test.expectedBody), body, test.description)
+		res.Body.Close()
+
+		if res.StatusCode == http.StatusCreated {
+			data := map[string]any{}
+			json.Unmarshal(body, &data)
+			id, _ = data["id"].(string)
+			if url, _ := data["url"].(string); !strings.Contains(url, "signature=") {
+				t.Errorf("Expected signed url, got [%v]", url)
+			}
+		}
+	}
+
+	tests := []struct {
+		description  string
+		method       string
+		path         string
+		bodyRequest  string
+		expectedCode int
+		expectedBody string
+	}{
+		{"Get File by ID", "GET", "/files/" + id, "", http.StatusOK, `{"name":"catalog.csv","mime_type":"text/csv"}`},
+		{"Get File of the owner", "GET", "/files?owner.end_point=products&owner.id=a", "", http.StatusOK, `{"count":1}`},
+		{"Delete File by ID", "DELETE", "/files/" + id, `{"reason":"Delete File by ID"}`, http.StatusOK, `{"code":200}`},
+		{"Get deleted File by ID", "GET", "/files/" + id, "", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.bodyRequest))
+		req.Header.Add("Authorization", "Bearer "+app.TestFullAccessToken)
+		req.Header.Add("Content-Type", "application/json")
+		res, err := app.Server().Test(req)
+		utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+		utils.AssertEqual(t, test.expectedCode, res.StatusCode, test.description)
+		body, err := io.ReadAll(res.Body)
+		utils.AssertEqual(t, nil, err, "io.ReadAll(res.Body)")
+		app.Test().AssertMatchJSONElement(t, []byte(test.expectedBody), body, test.description)
+		res.Body.Close()
+	}
+}
+
+func TestExtensionByMimeType(t *testing.T) {
+	tests := map[string]string{
+		"image/jpeg":            ".jpg",
+		"text/plain":            ".txt",
+		"application/pdf":       ".pdf",
+		"application/x-unknown": "",
+	}
+	for mimeType, expected := range tests {
+		utils.AssertEqual(t, expected, extensionByMimeType(mimeType), mimeType)
+	}
+}
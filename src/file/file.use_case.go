@@ -0,0 +1,479 @@
+package file
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"grest-belajar/app"
+)
+
+// UseCase returns a UseCaseHandler for expected use case functional.
+func UseCase(ctx app.Ctx, query ...url.Values) UseCaseHandler {
+	u := UseCaseHandler{
+		Ctx:   &ctx,
+		Query: url.Values{},
+	}
+	if len(query) > 0 {
+		u.Query = query[0]
+	}
+	return u
+}
+
+// UseCaseHandler provides a convenient interface for File use case, use UseCase to access UseCaseHandler.
+type UseCaseHandler struct {
+	File
+
+	// injectable dependencies
+	Ctx   *app.Ctx   `json:"-" db:"-" gorm:"-"`
+	Query url.Values `json:"-" db:"-" gorm:"-"`
+}
+
+// Async return UseCaseHandler with async process.
+func (u UseCaseHandler) Async(ctx app.Ctx, query ...url.Values) UseCaseHandler {
+	ctx.IsAsync = true
+	return UseCase(ctx, query...)
+}
+
+// GetByID returns the File data for the specified ID, with the URL which is valid for FS_URL_EXPIRY.
+func (u UseCaseHandler) GetByID(id string) (File, error) {
+	res := File{}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("files.detail")
+	if err != nil {
+		return res, err
+	}
+
+	// get from cache, or get from db and save to cache, the concurrent requests of the same id are coalesced
+	err = u.Ctx.Remember(u.EndPoint(), u.EndPoint()+"."+id, &res, func() (any, []string, error) {
+
+		// prepare db for current ctx
+		tx, err := u.Ctx.DB()
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+
+		// get from db
+		query := url.Values{}
+		for k, v := range u.Query {
+			query[k] = v
+		}
+		query.Set("id", id)
+		data := File{}
+		err = app.Query().First(tx, &data, query)
+		if err != nil {
+			return nil, nil, u.Ctx.NotFoundError(err, u.EndPoint(), "id", id)
+		}
+		return data, app.Cache().DetailTags(u.Ctx.CacheKey(u.EndPoint()), data.ID.String), nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	// the url is not cached since it expires
+	res.URL = app.NewNullString(u.Ctx.FS().GetFileUrl(res.Key.String))
+	return res, nil
+}
+
+// Get returns the list of File data, for example the files of the entity with `?owner.end_point=products&owner.id={id}`.
+func (u UseCaseHandler) Get() (app.ListModel, error) {
+	res := app.ListModel{}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("files.list")
+	if err != nil {
+		return res, err
+	}
+
+	// get from cache, or get from db and save to cache, the concurrent requests of the same query are coalesced
+	err = u.Ctx.Remember(u.EndPoint(), u.EndPoint()+"?"+u.Query.Encode(), &res, func() (any, []string, error) {
+		data := app.ListModel{}
+
+		// prepare db for current ctx
+		tx, err := u.Ctx.DB()
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+
+		// set pagination info
+		data.Count,
+			data.PageContext.Page,
+			data.PageContext.PerPage,
+			data.PageContext.PageCount,
+			err = app.Query().PaginationInfo(tx, &File{}, u.Query)
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		// return data count if $per_page set to 0
+		if data.PageContext.PerPage == 0 {
+			return data, app.Cache().ListTags(u.Ctx.CacheKey(u.EndPoint()), nil), nil
+		}
+
+		// find data
+		rows, err := app.Query().Find(tx, &File{}, u.Query)
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		data.SetData(rows, u.Query)
+		return data, app.Cache().ListTags(u.Ctx.CacheKey(u.EndPoint()), data.Data), nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	// the urls are not cached since they expire
+	for _, d := range res.Data {
+		if key, ok := d["key"].(string); ok {
+			d["url"] = u.Ctx.FS().GetFileUrl(key)
+		}
+	}
+	return res, nil
+}
+
+// Create uploads the file of the multipart form to the storage, then saves its metadata.
+// The mime type is sniffed from the content, the size and the mime type must be allowed by FILE_MAX_SIZE and FILE_ALLOWED_MIME_TYPES.
+func (u UseCaseHandler) Create(p *ParamCreate, fh *multipart.FileHeader) error {
+	if fh == nil {
+		return app.Error().New(http.StatusBadRequest, u.Ctx.Trans("file_required"))
+	}
+	if fh.Size > int64(app.FILE_MAX_SIZE) {
+		return app.Error().New(http.StatusBadRequest, u.Ctx.Trans("file_too_large", map[string]string{"max": strconv.Itoa(app.FILE_MAX_SIZE)}))
+	}
+	src, err := fh.Open()
+	if err != nil {
+		return app.Error().New(http.StatusBadRequest, err.Error())
+	}
+	defer src.Close()
+	return u.CreateFromReader(p, fh.Filename, src, fh.Size)
+}
+
+// CreateFromReader uploads the content of the reader with the file name and the size to the storage, then saves its metadata.
+// It is used by the other upload flows, for example the resumable uploads, so the size limit is checked by the caller.
+// The mime type is sniffed from the content, it must be allowed by FILE_ALLOWED_MIME_TYPES.
+func (u UseCaseHandler) CreateFromReader(p *ParamCreate, fileName string, src io.Reader, size int64) error {
+
+	// check permission
+	err := u.Ctx.ValidatePermission("files.create")
+	if err != nil {
+		return err
+	}
+
+	// validate param
+	err = u.Ctx.ValidateParam(p)
+	if err != nil {
+		return err
+	}
+	err = u.ValidateOwner(p.OwnerEndPoint.String, p.OwnerID.String)
+	if err != nil {
+		return err
+	}
+
+	// sniff the mime type from the first 512 bytes
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return app.Error().New(http.StatusBadRequest, err.Error())
+	}
+	head = head[:n]
+	mimeType := DetectMimeType(head, fileName)
+	if !IsAllowedMimeType(mimeType) {
+		return app.Error().New(http.StatusBadRequest, u.Ctx.Trans("file_type_not_allowed", map[string]string{"type": mimeType}))
+	}
+
+	// set default value for undefined field
+	err = p.setDefaultValue(File{})
+	if err != nil {
+		return err
+	}
+	p.Name = app.NewNullString(filepath.Base(strings.ReplaceAll(fileName, `\`, "/")))
+	p.Key = app.NewNullString(u.EndPoint() + "/" + p.ID.String + extensionByMimeType(mimeType))
+	p.MimeType = app.NewNullString(mimeType)
+	if u.Ctx.UserID != "" {
+		p.UploadedByUserID = app.NewNullString(u.Ctx.UserID)
+	}
+
+	// upload the content to the storage while the checksum is calculated
+	hash := sha256.New()
+	opt := app.FileUploadOption{}
+	opt.ContentType = mimeType
+	info, err := u.Ctx.FS().Upload(p.Key.String, io.TeeReader(io.MultiReader(bytes.NewReader(head), src), hash), size, opt)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	p.Size = app.NewNullInt64(size)
+	if info.Size > 0 {
+		p.Size = app.NewNullInt64(info.Size)
+	}
+	p.Checksum = app.NewNullString(hex.EncodeToString(hash.Sum(nil)))
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		u.Ctx.FS().Delete(p.Key.String)
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// save data to db
+	err = tx.Model(&p).Create(&p).Error
+	if err != nil {
+		u.Ctx.FS().Delete(p.Key.String)
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// invalidate cache
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint())) })
+
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("POST", "create", p.ID.String, p)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	return nil
+}
+
+// DeleteByID deletes the File data for the specified ID, and its content from the storage.
+func (u UseCaseHandler) DeleteByID(id string, p *ParamDelete) error {
+
+	// check permission
+	err := u.Ctx.ValidatePermission("files.delete")
+	if err != nil {
+		return err
+	}
+
+	// validate param
+	err = u.Ctx.ValidateParam(p)
+	if err != nil {
+		return err
+	}
+
+	// get previous data
+	old, err := u.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// update data on the db
+	err = tx.Model(&p).Where("id = ?", old.ID).Update("deleted_at", time.Now().UTC()).Error
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// invalidate cache and delete the content after commit, so the content of the rolled back deletion is kept,
+	// the content which fails to be deleted is orphan and deleted later by app.FileGC()
+	u.Ctx.AfterCommit(func() {
+		app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String)
+		err := u.Ctx.FS().Delete(old.Key.String)
+		if err != nil {
+			app.Logger().Module("fs").Error().Err(err).Str("key", old.Key.String).Msg("Failed to delete the content of the deleted file.")
+		}
+	})
+
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("DELETE", p.Reason.String, old.ID.String, old)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	return nil
+}
+
+// ValidateOwner validates the owner the file is attached to, it must be registered by RegisterOwner and accessible by the ctx.
+// The file without owner is valid.
+func (u UseCaseHandler) ValidateOwner(endPoint, id string) error {
+	if endPoint == "" && id == "" {
+		return nil
+	}
+	ownersMu.RLock()
+	resolve, ok := owners[endPoint]
+	ownersMu.RUnlock()
+	if ok {
+		err := resolve(*u.Ctx, id)
+		if err == nil {
+			return nil
+		}
+		if app.Error().StatusCode(err) != http.StatusNotFound {
+			return err
+		}
+	}
+	return app.Error().New(http.StatusBadRequest, u.Ctx.Trans("invalid_file_owner", map[string]string{"end_point": endPoint, "id": id}))
+}
+
+// RegisterOwner registers the resolver of the owner end point, the files can only be attached to the registered end points.
+// The resolver returns the not found error if the owner is not exists, for example using the GetByID of the owner use case
+// which also checks the permission and the tenant of the ctx.
+func RegisterOwner(endPoint string, resolve func(ctx app.Ctx, id string) error) {
+	ownersMu.Lock()
+	defer ownersMu.Unlock()
+	owners[endPoint] = resolve
+}
+
+// owners is the registered resolvers of the owner end points, see RegisterOwner.
+var (
+	owners   = map[string]func(ctx app.Ctx, id string) error{}
+	ownersMu sync.RWMutex
+)
+
+// setDefaultValue set default value of undefined field when create File data.
+func (u *UseCaseHandler) setDefaultValue(old File) error {
+	if !old.ID.Valid {
+		u.ID = app.NewNullUUID()
+	} else {
+		u.ID = old.ID
+	}
+
+	return nil
+}
+
+// FileReferrer returns the referrer of the files for the orphan files garbage collection, see app.FileGC().
+// The file whose owner is hard purged is orphan, the soft deleted owner is kept since it can be restored.
+func FileReferrer() app.FileReferrer {
+	return app.FileReferrer{
+		Name:     File{}.TableName(),
+		Prefixes: []string{File{}.EndPoint() + "/"},
+		References: func(tx *gorm.DB) ([]app.FileReference, error) {
+			files := []File{}
+			err := tx.Select("id", "tenant_id", "key", "owner_end_point", "owner_id", "created_at").Where("deleted_at IS NULL").Find(&files).Error
+			if err != nil {
+				return nil, err
+			}
+			purged, err := purgedOwners(tx, files)
+			if err != nil {
+				return nil, err
+			}
+			refs := []app.FileReference{}
+			for _, f := range files {
+				ref := app.FileReference{ID: f.ID.String, Keys: []string{f.Key.String}, CreatedAt: f.CreatedAt.Time}
+				if f.TenantID != nil {
+					ref.TenantID = f.TenantID.String
+				}
+				ref.IsOrphan = purged[f.OwnerEndPoint.String+"."+f.OwnerID.String]
+				refs = append(refs, ref)
+			}
+			return refs, nil
+		},
+		Delete: func(tx *gorm.DB, ref app.FileReference) error {
+			err := tx.Model(&File{}).Where("id = ?", ref.ID).Update("deleted_at", time.Now().UTC()).Error
+			if err != nil {
+				return err
+			}
+			app.Cache().Invalidate(app.Tenant().Prefix(ref.TenantID, File{}.EndPoint()), ref.ID)
+			return nil
+		},
+	}
+}
+
+// ownerEndPointPattern is the pattern of the owner end point which can be used as the table name of the owner.
+var ownerEndPointPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// purgedOwners returns the owners of the files which are not exists on their table anymore, by "{end_point}.{id}".
+// The end point is the table name of the owner, the owner of the unknown table is never treated as purged.
+func purgedOwners(tx *gorm.DB, files []File) (map[string]bool, error) {
+	ids := map[string][]string{}
+	for _, f := range files {
+		if f.OwnerEndPoint.String != "" && f.OwnerID.String != "" {
+			ids[f.OwnerEndPoint.String] = append(ids[f.OwnerEndPoint.String], f.OwnerID.String)
+		}
+	}
+	purged := map[string]bool{}
+	for endPoint, ownerIDs := range ids {
+		if !ownerEndPointPattern.MatchString(endPoint) || !tx.Migrator().HasTable(endPoint) {
+			continue
+		}
+		isExists := map[string]bool{}
+		for start := 0; start < len(ownerIDs); start += 500 {
+			existing := []string{}
+			err := tx.Table(endPoint).Where("id IN ?", ownerIDs[start:min(start+500, len(ownerIDs))]).Pluck("id", &existing).Error
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range existing {
+				isExists[id] = true
+			}
+		}
+		for _, id := range ownerIDs {
+			if !isExists[id] {
+				purged[endPoint+"."+id] = true
+			}
+		}
+	}
+	return purged, nil
+}
+
+// DetectMimeType returns the mime type sniffed from the head of the content, without the parameters.
+// The generic text is refined by the extension of the file name, for example "text/csv".
+func DetectMimeType(head []byte, fileName string) string {
+	mimeType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if mimeType == "text/plain" {
+		byExt, _, _ := mime.ParseMediaType(mime.TypeByExtension(extension(fileName)))
+		if strings.HasPrefix(byExt, "text/") {
+			return byExt
+		}
+	}
+	return mimeType
+}
+
+// IsAllowedMimeType returns true if the mime type matches one of FILE_ALLOWED_MIME_TYPES, for example "image/png" matches "image/*".
+func IsAllowedMimeType(mimeType string) bool {
+	for _, allowed := range strings.Split(app.FILE_ALLOWED_MIME_TYPES, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == mimeType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidExtensionChars is the characters which are not allowed on the extension of the stored key.
+var invalidExtensionChars = regexp.MustCompile(`[^a-z0-9]`)
+
+// mimeTypeExtensions is the preferred extension of the common mime types, the others are looked up on the mime package.
+var mimeTypeExtensions = map[string]string{
+	"application/pdf": ".pdf",
+	"image/gif":       ".gif",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"text/csv":        ".csv",
+	"text/plain":      ".txt",
+}
+
+// extensionByMimeType returns the extension of the stored key of the sniffed mime type, for example ".pdf".
+// The extension of the client file name is never used, so the stored file can't be served as another type, for example "image.html".
+func extensionByMimeType(mimeType string) string {
+	if ext, ok := mimeTypeExtensions[mimeType]; ok {
+		return ext
+	}
+	exts, _ := mime.ExtensionsByType(mimeType)
+	if len(exts) == 0 {
+		return ""
+	}
+	return extension(exts[0])
+}
+
+// extension returns the sanitized lower case extension of the file name, for example ".pdf".
+func extension(fileName string) string {
+	ext := invalidExtensionChars.ReplaceAllString(strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), ".")), "")
+	if ext == "" {
+		return ""
+	}
+	return "." + ext
+}
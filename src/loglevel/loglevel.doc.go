@@ -0,0 +1,3 @@
+// loglevel is a package related to the runtime log levels, to turn on the verbose logs of a module while debugging without a restart.
+// The levels are broadcasted to all instances through redis (see app.LOG_LEVEL_CHANNEL), and they are reverted to the configured levels after a while on every instance.
+package loglevel
@@ -0,0 +1,46 @@
+package loglevel
+
+import (
+	"time"
+
+	"grest-belajar/app"
+)
+
+// MaxRevertAfter is the maximum duration of the changed log levels.
+const MaxRevertAfter = 24 * time.Hour
+
+// Levels is the log levels of the instance.
+type Levels struct {
+	app.LogLevels
+}
+
+// OpenAPISchemaName returns the name of the Levels schema in the open api documentation.
+func (Levels) OpenAPISchemaName() string {
+	return "LogLevels"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the Levels in the open api documentation.
+func (Levels) GetOpenAPISchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"level":     map[string]any{"type": "string", "example": "info"},
+			"modules":   map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}, "example": map[string]any{"db": "debug"}},
+			"revert_at": map[string]any{"type": "string", "format": "date-time"},
+		},
+	}
+}
+
+// ParamUpdate is the expected parameters for change the log levels of the instance.
+// The modules is the comma separated module levels like LOG_MODULE_LEVELS, for example "db=debug,cache=warn",
+// the revert_after is the duration like "30m", it is LOG_LEVEL_REVERT_AFTER by default.
+type ParamUpdate struct {
+	Level       app.NullString `json:"level"        validate:"required"`
+	Modules     app.NullString `json:"modules"`
+	RevertAfter app.NullString `json:"revert_after"`
+}
+
+// OpenAPISchemaName returns the name of the ParamUpdate schema in the open api documentation.
+func (ParamUpdate) OpenAPISchemaName() string {
+	return "LogLevelsParamUpdate"
+}
@@ -0,0 +1,67 @@
+package loglevel
+
+import "grest-belajar/app"
+
+// OpenAPI is constructor for *openAPI, to autogenerate open api document.
+func OpenAPI() *OpenAPIOperation {
+	return &OpenAPIOperation{}
+}
+
+// OpenAPIOperation embed from app.OpenAPIOperation for simplicity, used for autogenerate open api document.
+type OpenAPIOperation struct {
+	app.OpenAPIOperation
+}
+
+// Base is common detail of log levels open api document component.
+func (o *OpenAPIOperation) Base() {
+	o.Tags = []string{"Log"}
+	o.HeaderParams = []map[string]any{{"$ref": "#/components/parameters/headerParam.Accept-Language"}}
+	o.Responses = map[string]map[string]any{
+		"200": {
+			"description": "Success",
+			"content":     map[string]any{"application/json": &Levels{}},
+		},
+		"400": app.OpenAPIError().BadRequest(),
+		"401": app.OpenAPIError().Unauthorized(),
+		"403": app.OpenAPIError().Forbidden(),
+	}
+	o.Securities = []map[string][]string{}
+}
+
+// Get is detail of `GET /api/log/levels` open api document component.
+func (o *OpenAPIOperation) Get() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Get Log Levels"
+	o.Description = "Use this method to get the default log level and the log levels of the modules of the instance"
+	return o
+}
+
+// Update is detail of `PUT /api/log/levels` open api document component.
+func (o *OpenAPIOperation) Update() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Update Log Levels"
+	o.Description = "Use this method to change the log levels of all instances without a restart, for example `{\"level\":\"info\",\"modules\":\"db=debug\",\"revert_after\":\"30m\"}`. " +
+		"The levels are reverted to the configured levels after the revert_after (max 24h)"
+	o.Body = map[string]any{"application/json": &ParamUpdate{}}
+	return o
+}
+
+// Reset is detail of `DELETE /api/log/levels` open api document component.
+func (o *OpenAPIOperation) Reset() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Reset Log Levels"
+	o.Description = "Use this method to revert the log levels of all instances to the configured levels immediately"
+	return o
+}
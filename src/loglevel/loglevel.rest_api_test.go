@@ -0,0 +1,107 @@
+package loglevel
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2/utils"
+
+	"grest-belajar/app"
+)
+
+// prepareTest prepares the test.
+func prepareTest(tb testing.TB) {
+	app.Test()
+	app.Logger().Reset()
+
+	app.Server().AddMiddleware(app.Test().NewCtx([]string{
+		"log_levels.detail",
+		"log_levels.edit",
+		"log_levels.delete",
+	}))
+	app.Server().AddRoute("/log/levels", "GET", REST().Get, nil)
+	app.Server().AddRoute("/log/levels", "PUT", REST().Update, nil)
+	app.Server().AddRoute("/log/levels", "DELETE", REST().Reset, nil)
+}
+
+// tests is test scenario.
+var tests = []struct {
+	description  string // description of the test case
+	method       string // method to test
+	path         string // route path to test
+	token        string // token to test
+	bodyRequest  string // body to test
+	expectedCode int    // expected HTTP status code
+	expectedBody string // expected body response
+}{
+	{
+		description:  "Update log levels with invalid level",
+		method:       "PUT",
+		path:         "/log/levels",
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"level":"verbose"}`,
+		expectedCode: http.StatusBadRequest,
+	},
+	{
+		description:  "Update log levels with too long revert after",
+		method:       "PUT",
+		path:         "/log/levels",
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"level":"debug","revert_after":"48h"}`,
+		expectedCode: http.StatusBadRequest,
+	},
+	{
+		description:  "Update log levels",
+		method:       "PUT",
+		path:         "/log/levels",
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"level":"info","modules":"db=debug","revert_after":"5m"}`,
+		expectedCode: http.StatusOK,
+		expectedBody: `{"level":"info","modules":{"db":"debug"}}`,
+	},
+	{
+		description:  "Get log levels",
+		method:       "GET",
+		path:         "/log/levels",
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusOK,
+		expectedBody: `{"level":"info","modules":{"db":"debug"}}`,
+	},
+	{
+		description:  "Reset log levels",
+		method:       "DELETE",
+		path:         "/log/levels",
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusOK,
+		expectedBody: `{"revert_at":null}`,
+	},
+}
+
+// TestLogLevelsREST tests the REST API of log levels with specified scenario.
+func TestLogLevelsREST(t *testing.T) {
+	prepareTest(t)
+
+	// Iterate through test single test cases
+	for _, test := range tests {
+		// Create a new http request with the route from the test case
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.bodyRequest))
+		req.Header.Add("Authorization", "Bearer "+test.token)
+		req.Header.Add("Content-Type", "application/json")
+
+		// Perform the request plain with the app, the second argument is a request latency (set to -1 for no latency)
+		res, err := app.Server().Test(req)
+
+		// Verify if the status code is as expected
+		utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+		utils.AssertEqual(t, test.expectedCode, res.StatusCode, test.description)
+
+		// Verify if the body response is as expected
+		body, err := io.ReadAll(res.Body)
+		utils.AssertEqual(t, nil, err, "io.ReadAll(res.Body)")
+		app.Test().AssertMatchJSONElement(t, []byte(test.expectedBody), body, test.description)
+		res.Body.Close()
+	}
+}
@@ -0,0 +1,83 @@
+package loglevel
+
+import (
+	"net/http"
+	"time"
+
+	"grest-belajar/app"
+)
+
+// UseCase returns a UseCaseHandler for expected use case functional.
+func UseCase(ctx app.Ctx) UseCaseHandler {
+	return UseCaseHandler{
+		Ctx: &ctx,
+	}
+}
+
+// UseCaseHandler provides a convenient interface for log levels use case, use UseCase to access UseCaseHandler.
+type UseCaseHandler struct {
+	// injectable dependencies
+	Ctx *app.Ctx `json:"-" db:"-" gorm:"-"`
+}
+
+// Get returns the current log levels of the instance.
+func (u UseCaseHandler) Get() (Levels, error) {
+	res := Levels{}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("log_levels.detail")
+	if err != nil {
+		return res, err
+	}
+
+	res.LogLevels = app.Logger().Levels()
+	return res, nil
+}
+
+// Update changes the log levels of all instances, they are reverted to the configured levels after the revert_after.
+func (u UseCaseHandler) Update(p *ParamUpdate) (Levels, error) {
+	res := Levels{}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("log_levels.edit")
+	if err != nil {
+		return res, err
+	}
+
+	// validate param
+	err = u.Ctx.ValidateParam(p)
+	if err != nil {
+		return res, err
+	}
+
+	levels, err := app.ParseLogLevels(p.Level.String, p.Modules.String)
+	if err != nil {
+		return res, app.Error().New(http.StatusBadRequest, u.Ctx.Trans("invalid_log_level"), err.Error())
+	}
+	revertAfter := app.LOG_LEVEL_REVERT_AFTER
+	if p.RevertAfter.String != "" {
+		revertAfter, err = time.ParseDuration(p.RevertAfter.String)
+		if err != nil || revertAfter <= 0 || revertAfter > MaxRevertAfter {
+			return res, app.Error().New(http.StatusBadRequest, u.Ctx.Trans("invalid_log_revert_after", map[string]string{"max": MaxRevertAfter.String()}))
+		}
+	}
+
+	app.Logger().SetLevels(levels, revertAfter)
+	res.LogLevels = app.Logger().Levels()
+	return res, nil
+}
+
+// Reset reverts the log levels of all instances to the configured levels.
+func (u UseCaseHandler) Reset() (Levels, error) {
+	res := Levels{}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("log_levels.delete")
+	if err != nil {
+		return res, err
+	}
+
+	app.Logger().Reset()
+	res.LogLevels = app.Logger().Levels()
+	return res, nil
+}
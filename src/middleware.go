@@ -22,5 +22,6 @@ type middlewareUtil struct {
 
 func (*middlewareUtil) Configure() {
 	app.Server().AddMiddleware(middleware.Ctx().New)
+	app.Server().AddMiddleware(middleware.Tenant().New)
 	app.Server().AddMiddleware(middleware.DB().New)
 }
@@ -3,7 +3,9 @@ package src
 import (
 	"grest-belajar/app"
 	"grest-belajar/src/category"
+	"grest-belajar/src/file"
 	"grest-belajar/src/product"
+	"grest-belajar/src/upload"
 	"grest-belajar/src/user"
 	// import : DONT REMOVE THIS COMMENT
 )
@@ -27,10 +29,21 @@ type migratorUtil struct {
 }
 
 func (*migratorUtil) Configure() {
+	app.DB().RegisterTable("main", app.OutboxEvent{})
+	app.DB().RegisterTable("main", app.ErrorGroup{})
 	app.DB().RegisterTable("main", user.User{})
 	app.DB().RegisterTable("main", category.Category{})
 	app.DB().RegisterTable("main", product.Product{})
+	app.DB().RegisterTable("main", product.StockMovement{})
+	app.DB().RegisterTable("main", product.ProductImage{})
+	app.DB().RegisterTable("main", file.File{})
+	app.DB().RegisterTable("main", upload.Upload{})
 	// RegisterTable : DONT REMOVE THIS COMMENT
+
+	// the cache of the models is invalidated when the end points they depend on are changed
+	app.Cache().RegisterDependency(&user.User{})
+	app.Cache().RegisterDependency(&category.Category{})
+	app.Cache().RegisterDependency(&product.Product{})
 }
 
 func (*migratorUtil) Run() {
@@ -3,17 +3,24 @@ package product
 import "grest-belajar/app"
 
 // Product is the main model of Product data. It provides a convenient interface for app.ModelInterface
+// The Stock is derived from the StockMovement ledger, it can only be changed by the stock adjustments,
+// the stock of the products created before the ledger is recorded as the opening movement by the OpeningStockSeeder.
+// The Images is the ordered gallery of the ProductImage, it is only loaded on the detail.
 type Product struct {
 	app.Model
-	ID           app.NullUUID      `json:"id"                   db:"m.id"              gorm:"column:id;primaryKey"`
-	Name         app.NullString    `json:"name"                 db:"m.name"            gorm:"column:name"`
-	Stock        app.NullInt64     `json:"stock"                db:"m.stock"           gorm:"column:stock"`
-	Price        app.NullFloat64   `json:"price"                db:"m.price"           gorm:"column:price"`
-	CategoryID   app.NullUUID      `json:"category.id"          db:"m.category_id"     gorm:"column:category_id"`
-	CategoryName app.NullString    `json:"category.name"        db:"c.name"            gorm:"-"`
-	CreatedAt    app.NullDateTime  `json:"created_at"           db:"m.created_at"      gorm:"column:created_at"`
-	UpdatedAt    app.NullDateTime  `json:"updated_at"           db:"m.updated_at"      gorm:"column:updated_at"`
-	DeletedAt    *app.NullDateTime `json:"deleted_at,omitempty" db:"m.deleted_at,hide" gorm:"column:deleted_at"`
+	ID                 app.NullUUID      `json:"id"                   db:"m.id"              gorm:"column:id;primaryKey"`
+	TenantID           *app.NullString   `json:"tenant_id,omitempty"  db:"m.tenant_id,hide"  gorm:"column:tenant_id;size:64;index"`
+	Name               app.NullString    `json:"name"                 db:"m.name"            gorm:"column:name"`
+	Stock              app.NullInt64     `json:"stock"                db:"m.stock"           gorm:"column:stock"`
+	Price              app.NullFloat64   `json:"price"                db:"m.price"           gorm:"column:price"`
+	CategoryID         app.NullUUID      `json:"category.id"          db:"m.category_id"     gorm:"column:category_id"`
+	CategoryName       app.NullString    `json:"category.name"        db:"c.name"            gorm:"-"`
+	CategoryPath       app.NullString    `json:"category.path"        db:"c.path"            gorm:"-"`
+	CategoryBreadcrumb app.NullText      `json:"category.breadcrumb"  db:"c.breadcrumb"      gorm:"-"`
+	Images             []ProductImage    `json:"images,omitempty"     db:"-"                 gorm:"-"` // the gallery, only on the detail
+	CreatedAt          app.NullDateTime  `json:"created_at"           db:"m.created_at"      gorm:"column:created_at"`
+	UpdatedAt          app.NullDateTime  `json:"updated_at"           db:"m.updated_at"      gorm:"column:updated_at"`
+	DeletedAt          *app.NullDateTime `json:"deleted_at,omitempty" db:"m.deleted_at,hide" gorm:"column:deleted_at"`
 }
 
 // EndPoint returns the Product end point, it used for cache key, etc.
@@ -24,7 +31,7 @@ func (Product) EndPoint() string {
 // TableVersion returns the versions of the Product table in the database.
 // Change this value with date format YY.MM.DDHHii when any table structure changes.
 func (Product) TableVersion() string {
-	return "24.03.261123"
+	return "26.10.191000"
 }
 
 // TableName returns the name of the Product table in the database.
@@ -95,7 +102,7 @@ func (p *ProductList) GetOpenAPISchema() map[string]any {
 type ParamCreate struct {
 	UseCaseHandler
 	Name       app.NullString  `json:"name"        gorm:"column:name"        validate:"required"`
-	Stock      app.NullInt64   `json:"stock"       gorm:"column:stock"       validate:"required"`
+	Stock      app.NullInt64   `json:"stock"       gorm:"column:stock"       validate:"required,min=0"`
 	Price      app.NullFloat64 `json:"price"       gorm:"column:price"       validate:"required"`
 	CategoryID app.NullUUID    `json:"category_id" gorm:"column:category_id" validate:"required"`
 }
@@ -103,12 +110,14 @@ type ParamCreate struct {
 // ParamUpdate is the expected parameters for update the Product data.
 type ParamUpdate struct {
 	UseCaseHandler
+	Stock  app.NullInt64  `json:"stock"  gorm:"-"` // read-only, it is rejected, use the stock adjustments to change it
 	Reason app.NullString `json:"reason" gorm:"-" validate:"required"`
 }
 
 // ParamPartiallyUpdate is the expected parameters for partially update the Product data.
 type ParamPartiallyUpdate struct {
 	UseCaseHandler
+	Stock  app.NullInt64  `json:"stock"  gorm:"-"` // read-only, it is rejected, use the stock adjustments to change it
 	Reason app.NullString `json:"reason" gorm:"-" validate:"required"`
 }
 
@@ -36,7 +36,8 @@ func (o *OpenAPIOperation) Get() *OpenAPIOperation {
 
 	o.Base()
 	o.Summary = "Get Product"
-	o.Description = "Use this method to get list of Product"
+	o.Description = "Use this method to get list of Product. " +
+		"Use `category.id.$subtree={id}` to filter the products by the category including all its sub categories"
 	o.QueryParams = []map[string]any{{"$ref": "#/components/parameters/queryParam.Any"}}
 	o.Responses = map[string]map[string]any{
 		"200": {
@@ -84,7 +85,7 @@ func (o *OpenAPIOperation) UpdateByID() *OpenAPIOperation {
 
 	o.Base()
 	o.Summary = "Update Product By ID"
-	o.Description = "Use this method to update Product by id"
+	o.Description = "Use this method to update Product by id, the stock is rejected since it is only changed by the stock adjustments"
 	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
 	o.Body = map[string]any{"application/json": &ParamUpdate{}}
 	return o
@@ -98,7 +99,7 @@ func (o *OpenAPIOperation) PartiallyUpdateByID() *OpenAPIOperation {
 
 	o.Base()
 	o.Summary = "Partially Update Product By ID"
-	o.Description = "Use this method to partially update Product by id"
+	o.Description = "Use this method to partially update Product by id, the stock is rejected since it is only changed by the stock adjustments"
 	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
 	o.Body = map[string]any{"application/json": &ParamPartiallyUpdate{}}
 	return o
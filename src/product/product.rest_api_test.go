@@ -1,7 +1,14 @@
 package product
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -11,15 +18,21 @@ import (
 	"gorm.io/gorm"
 
 	"grest-belajar/app"
+	"grest-belajar/src/category"
 )
 
 // prepareTest prepares the test.
 func prepareTest(tb testing.TB) {
 	app.Test()
 	tx := app.Test().Tx
+	app.DB().RegisterTable("main", category.Category{})
 	app.DB().RegisterTable("main", Product{})
+	app.DB().RegisterTable("main", StockMovement{})
+	app.DB().RegisterTable("main", ProductImage{})
+	app.DB().RegisterTable("main", app.OutboxEvent{})
 	app.DB().MigrateTable(tx, "main", app.Setting{})
 	tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Product{})
+	tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&category.Category{})
 
 	app.Server().AddMiddleware(app.Test().NewCtx([]string{
 		"products.detail",
@@ -34,6 +47,12 @@ func prepareTest(tb testing.TB) {
 	app.Server().AddRoute("/products/:id", "PUT", REST().UpdateByID, nil)
 	app.Server().AddRoute("/products/:id", "PATCH", REST().PartiallyUpdateByID, nil)
 	app.Server().AddRoute("/products/:id", "DELETE", REST().DeleteByID, nil)
+	app.Server().AddRoute("/products/:id/stock-adjustments", "POST", REST().AdjustStock, nil)
+	app.Server().AddRoute("/products/:id/stock-movements", "GET", REST().GetStockMovements, nil)
+	app.Server().AddRoute("/products/:id/images", "POST", REST().CreateImage, nil)
+	app.Server().AddRoute("/products/:id/images/order", "PUT", REST().ReorderImages, nil)
+	app.Server().AddRoute("/products/:id/images/:image_id/renditions", "POST", REST().RegenerateImageRenditions, nil)
+	app.Server().AddRoute("/products/:id/images/:image_id", "DELETE", REST().DeleteImage, nil)
 }
 
 // getTestProductID returns an available Product ID.
@@ -94,6 +113,53 @@ var tests = []struct {
 		expectedCode: http.StatusOK,
 		expectedBody: `{"name":"Kilo Gram"}`,
 	},
+	{
+		description:  "Stock is read-only on update",
+		method:       "PATCH",
+		path:         "/products/" + getTestProductID(),
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"reason":"Partially Update Product by ID","stock":1000}`,
+		expectedCode: http.StatusBadRequest,
+	},
+	{
+		description:  "Adjust Product stock in",
+		method:       "POST",
+		path:         "/products/" + getTestProductID() + "/stock-adjustments",
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"type":"in","quantity":10,"reference":"PO-001","reason":"Purchase"}`,
+		expectedCode: http.StatusCreated,
+		expectedBody: `{"type":"in","quantity":10}`,
+	},
+	{
+		description:  "Adjust Product stock out below zero",
+		method:       "POST",
+		path:         "/products/" + getTestProductID() + "/stock-adjustments",
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"type":"out","quantity":1000000,"reason":"Sales"}`,
+		expectedCode: http.StatusBadRequest,
+	},
+	{
+		description:  "Get Product stock movements",
+		method:       "GET",
+		path:         "/products/" + getTestProductID() + "/stock-movements",
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusOK,
+	},
+	{
+		description:  "Upload Product image without file",
+		method:       "POST",
+		path:         "/products/" + getTestProductID() + "/images",
+		token:        app.TestFullAccessToken,
+		expectedCode: http.StatusBadRequest,
+	},
+	{
+		description:  "Reorder Product images without image ids",
+		method:       "PUT",
+		path:         "/products/" + getTestProductID() + "/images/order",
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"image_ids":[]}`,
+		expectedCode: http.StatusBadRequest,
+	},
 	{
 		description:  "Delete Product by ID",
 		method:       "DELETE",
@@ -103,6 +169,14 @@ var tests = []struct {
 		expectedCode: http.StatusOK,
 		expectedBody: `{"code":200}`,
 	},
+	{
+		description:  "Adjust deleted Product stock",
+		method:       "POST",
+		path:         "/products/" + getTestProductID() + "/stock-adjustments",
+		token:        app.TestFullAccessToken,
+		bodyRequest:  `{"type":"in","quantity":10,"reason":"Purchase"}`,
+		expectedCode: http.StatusNotFound,
+	},
 }
 
 // TestProductREST tests the REST API of Product data with specified scenario.
@@ -132,6 +206,62 @@ func TestProductREST(t *testing.T) {
 	}
 }
 
+// TestOpeningStockSeeder tests the opening stock movement of the products created before the ledger is introduced.
+func TestOpeningStockSeeder(t *testing.T) {
+	prepareTest(t)
+	tx := app.Test().Tx
+	tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&StockMovement{})
+	legacy := Product{ID: app.NewNullUUID(), Name: app.NewNullString("Legacy"), Stock: app.NewNullInt64(5)}
+	reconciled := Product{ID: app.NewNullUUID(), Name: app.NewNullString("Reconciled"), Stock: app.NewNullInt64(3)}
+	tx.Create(&[]Product{legacy, reconciled})
+	tx.Create(&StockMovement{ID: app.NewNullUUID(), ProductID: reconciled.ID, Type: app.NewNullString(StockMovementIn), Quantity: app.NewNullInt64(3), Balance: app.NewNullInt64(3)})
+
+	utils.AssertEqual(t, nil, OpeningStockSeeder{}.Run(tx), "OpeningStockSeeder{}.Run(tx)")
+	movements := []StockMovement{}
+	tx.Where("product_id = ?", legacy.ID).Find(&movements)
+	utils.AssertEqual(t, 1, len(movements), "legacy movements")
+	utils.AssertEqual(t, StockMovementAdjustment, movements[0].Type.String, "legacy movement type")
+	utils.AssertEqual(t, int64(5), movements[0].Quantity.Int64, "legacy movement quantity")
+	utils.AssertEqual(t, int64(5), movements[0].Balance.Int64, "legacy movement balance")
+	count := int64(0)
+	tx.Model(&StockMovement{}).Where("product_id = ?", reconciled.ID).Count(&count)
+	utils.AssertEqual(t, int64(1), count, "reconciled movements")
+}
+
+// TestProductCategorySubtree tests the filter of the products by the category including all its sub categories.
+func TestProductCategorySubtree(t *testing.T) {
+	prepareTest(t)
+	tx := app.Test().Tx
+	electronics := category.Category{ID: app.NewNullUUID(), Name: app.NewNullString("Electronics")}
+	electronics.Path = app.NewNullString("/" + electronics.ID.String + "/")
+	phones := category.Category{ID: app.NewNullUUID(), Name: app.NewNullString("Phones"), ParentID: electronics.ID}
+	phones.Path = app.NewNullString(electronics.Path.String + phones.ID.String + "/")
+	books := category.Category{ID: app.NewNullUUID(), Name: app.NewNullString("Books")}
+	books.Path = app.NewNullString("/" + books.ID.String + "/")
+	tx.Create(&[]category.Category{electronics, phones, books})
+	tx.Create(&[]Product{
+		{ID: app.NewNullUUID(), Name: app.NewNullString("TV"), CategoryID: electronics.ID},
+		{ID: app.NewNullUUID(), Name: app.NewNullString("Phone"), CategoryID: phones.ID},
+		{ID: app.NewNullUUID(), Name: app.NewNullString("Novel"), CategoryID: books.ID},
+	})
+
+	for _, c := range []struct {
+		categoryID string
+		expected   string
+	}{
+		{electronics.ID.String, `{"count":2}`},
+		{phones.ID.String, `{"count":1,"results":[{"name":"Phone"}]}`},
+		{app.NewNullUUID().String, `{"count":0}`},
+	} {
+		res, err := app.Server().Test(newJSONRequest("GET", "/products?"+QueryCategorySubtree+"="+c.categoryID, ""))
+		utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+		utils.AssertEqual(t, http.StatusOK, res.StatusCode, "Get Product by the category subtree")
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		app.Test().AssertMatchJSONElement(t, []byte(c.expected), body, "Get Product by the category subtree")
+	}
+}
+
 // BenchmarkProductREST tests the REST API of Product data with specified scenario.
 func BenchmarkProductREST(b *testing.B) {
 	b.ReportAllocs()
@@ -145,3 +275,128 @@ func BenchmarkProductREST(b *testing.B) {
 		}
 	}
 }
+
+// newImageRequest returns the multipart request to upload the image of the Product gallery.
+func newImageRequest(productID, fileName string, content []byte) *http.Request {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	fw, _ := w.CreateFormFile("file", fileName)
+	fw.Write(content)
+	w.Close()
+	req := httptest.NewRequest("POST", "/products/"+productID+"/images", body)
+	req.Header.Add("Authorization", "Bearer "+app.TestFullAccessToken)
+	req.Header.Add("Content-Type", w.FormDataContentType())
+	return req
+}
+
+// newJSONRequest returns the json request with the full access token.
+func newJSONRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Add("Authorization", "Bearer "+app.TestFullAccessToken)
+	req.Header.Add("Content-Type", "application/json")
+	return req
+}
+
+// TestProductImageREST tests the upload, the renditions generation, the reorder and the delete of the Product gallery.
+func TestProductImageREST(t *testing.T) {
+	prepareTest(t)
+	app.DB().RegisterConn("main", app.Test().Tx) // the renditions are generated outside the request
+	app.Outbox().Subscribe("product-images.renditions", ImageRenditionsRequested, HandleImageRenditionsRequested)
+
+	c := category.Category{ID: app.NewNullUUID(), Name: app.NewNullString("Cameras")}
+	c.Path = app.NewNullString("/" + c.ID.String + "/")
+	app.Test().Tx.Create(&c)
+
+	res, err := app.Server().Test(newJSONRequest("POST", "/products", `{"name":"Gallery","stock":1,"price":100,"category_id":"`+c.ID.String+`"}`))
+	utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+	utils.AssertEqual(t, http.StatusCreated, res.StatusCode, "Create Product")
+	p := Product{}
+	json.NewDecoder(res.Body).Decode(&p)
+	res.Body.Close()
+
+	// 40x20 jpeg with the EXIF orientation 6 (rotate 90 cw) is stored upright as 20x40 without the EXIF
+	buf := &bytes.Buffer{}
+	jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil)
+	res, err = app.Server().Test(newImageRequest(p.ID.String, "photo.jpg", withExifOrientation(buf.Bytes(), 6)))
+	utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+	utils.AssertEqual(t, http.StatusCreated, res.StatusCode, "Upload Product image")
+	first := ProductImage{}
+	json.NewDecoder(res.Body).Decode(&first)
+	res.Body.Close()
+	utils.AssertEqual(t, int64(20), first.Width.Int64, "Upload Product image")
+	utils.AssertEqual(t, int64(40), first.Height.Int64, "Upload Product image")
+	utils.AssertEqual(t, ProductImageStatusPending, first.Status.String, "Upload Product image")
+	rc, err := app.FS().Download(first.Key.String)
+	utils.AssertEqual(t, nil, err, "Download Product image original")
+	original, _ := io.ReadAll(rc)
+	rc.Close()
+	utils.AssertEqual(t, false, bytes.Contains(original, []byte("Exif\x00\x00")), "Strip Product image EXIF")
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(original))
+	utils.AssertEqual(t, nil, err, "Decode Product image original")
+	utils.AssertEqual(t, [2]int{20, 40}, [2]int{cfg.Width, cfg.Height}, "Orient Product image original")
+
+	res, _ = app.Server().Test(newImageRequest(p.ID.String, "notes.jpg", []byte("not an image")))
+	utils.AssertEqual(t, http.StatusBadRequest, res.StatusCode, "Upload Product image with invalid content")
+
+	// the renditions are generated by the outbox relay after the upload is committed
+	app.Outbox().Relay()
+	im := ProductImage{}
+	err = app.Test().Tx.Where("id = ?", first.ID).First(&im).Error
+	utils.AssertEqual(t, nil, err, "Get Product image")
+	utils.AssertEqual(t, ProductImageStatusReady, im.Status.String, "Generate Product image renditions")
+	utils.AssertEqual(t, len(app.Image().Renditions), len(im.renditionNames()), "Generate Product image renditions")
+	for _, key := range im.keys() {
+		isExists, _ := app.FS().Exists(key)
+		utils.AssertEqual(t, true, isExists, "Store Product image rendition "+key)
+	}
+
+	buf.Reset()
+	png.Encode(buf, image.NewNRGBA(image.Rect(0, 0, 10, 10)))
+	res, _ = app.Server().Test(newImageRequest(p.ID.String, "logo.png", buf.Bytes()))
+	utils.AssertEqual(t, http.StatusCreated, res.StatusCode, "Upload second Product image")
+	second := ProductImage{}
+	json.NewDecoder(res.Body).Decode(&second)
+	res.Body.Close()
+
+	res, _ = app.Server().Test(newJSONRequest("PUT", "/products/"+p.ID.String+"/images/order", `{"image_ids":["`+first.ID.String+`"]}`))
+	utils.AssertEqual(t, http.StatusBadRequest, res.StatusCode, "Reorder Product images without all images")
+	res, _ = app.Server().Test(newJSONRequest("PUT", "/products/"+p.ID.String+"/images/order", `{"image_ids":["`+second.ID.String+`","`+first.ID.String+`"]}`))
+	utils.AssertEqual(t, http.StatusOK, res.StatusCode, "Reorder Product images")
+	detail := Product{}
+	json.NewDecoder(res.Body).Decode(&detail)
+	res.Body.Close()
+	utils.AssertEqual(t, 2, len(detail.Images), "Reorder Product images")
+	utils.AssertEqual(t, second.ID.String, detail.Images[0].ID.String, "Reorder Product images")
+	utils.AssertEqual(t, first.ID.String, detail.Images[1].ID.String, "Reorder Product images")
+
+	res, _ = app.Server().Test(newJSONRequest("DELETE", "/products/"+p.ID.String+"/images/"+first.ID.String, `{"reason":"Delete Product image"}`))
+	utils.AssertEqual(t, http.StatusOK, res.StatusCode, "Delete Product image")
+	for _, key := range im.keys() {
+		isExists, _ := app.FS().Exists(key)
+		utils.AssertEqual(t, false, isExists, "Delete Product image content "+key)
+	}
+	res, _ = app.Server().Test(newJSONRequest("GET", "/products/"+p.ID.String, ""))
+	detail = Product{}
+	json.NewDecoder(res.Body).Decode(&detail)
+	res.Body.Close()
+	utils.AssertEqual(t, 1, len(detail.Images), "Get Product after image is deleted")
+	res, _ = app.Server().Test(newJSONRequest("DELETE", "/products/"+p.ID.String+"/images/"+first.ID.String, `{"reason":"Delete Product image"}`))
+	utils.AssertEqual(t, http.StatusNotFound, res.StatusCode, "Delete deleted Product image")
+}
+
+// withExifOrientation returns the jpeg with the APP1 EXIF segment which only has the orientation tag.
+func withExifOrientation(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3) // SHORT
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	tiff = append(append(tiff, entry...), 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	res := append([]byte{}, data[:2]...)
+	res = append(append(res, app1...), segment...)
+	return append(res, data[2:]...)
+}
@@ -9,6 +9,10 @@ import (
 	"grest-belajar/src/category"
 )
 
+// QueryCategorySubtree is the query param to filter the products by the category including all its sub categories,
+// for example `GET /api/products?category.id.$subtree={electronics_id}`.
+const QueryCategorySubtree = "category.id.$subtree"
+
 // UseCase returns a UseCaseHandler for expected use case functional.
 func UseCase(ctx app.Ctx, query ...url.Values) UseCaseHandler {
 	u := UseCaseHandler{
@@ -46,33 +50,45 @@ func (u UseCaseHandler) GetByID(id string) (Product, error) {
 		return res, err
 	}
 
-	// get from cache and return if exists
-	cacheKey := u.EndPoint() + "." + id
-	app.Cache().Get(cacheKey, &res)
-	if res.ID.Valid {
-		return res, err
-	}
-
-	// prepare db for current ctx
-	tx, err := u.Ctx.DB()
+	// get from cache, or get from db and save to cache, the concurrent requests of the same id are coalesced
+	err = u.Ctx.Remember(u.EndPoint(), u.EndPoint()+"."+id, &res, func() (any, []string, error) {
+
+		// prepare db for current ctx
+		tx, err := u.Ctx.DB()
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+
+		// get from db
+		key := "id"
+		if !app.Validator().IsValid(id, "uuid") {
+			key = "code"
+		}
+		query := url.Values{}
+		for k, v := range u.Query {
+			query[k] = v
+		}
+		query.Set(key, id)
+		data := Product{}
+		err = app.Query().First(tx, &data, query)
+		if err != nil {
+			return nil, nil, u.Ctx.NotFoundError(err, u.EndPoint(), key, id)
+		}
+		data.Images, err = u.getImages(tx, data.ID.String)
+		if err != nil {
+			return nil, nil, err
+		}
+		return data, app.Cache().DetailTags(u.Ctx.CacheKey(u.EndPoint()), data.ID.String), nil
+	})
 	if err != nil {
-		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+		return res, err
 	}
 
-	// get from db
-	key := "id"
-	if !app.Validator().IsValid(id, "uuid") {
-		key = "code"
+	// the image urls are not cached since they expire
+	for i := range res.Images {
+		res.Images[i].setURLs(u.Ctx)
 	}
-	u.Query.Add(key, id)
-	err = app.Query().First(tx, &res, u.Query)
-	if err != nil {
-		return res, u.Ctx.NotFoundError(err, u.EndPoint(), key, id)
-	}
-
-	// save to cache and return if exists
-	app.Cache().Set(cacheKey, res)
-	return res, err
+	return res, nil
 }
 
 // Get returns the list of Product data.
@@ -84,42 +100,62 @@ func (u UseCaseHandler) Get() (app.ListModel, error) {
 	if err != nil {
 		return res, err
 	}
-	// get from cache and return if exists
-	cacheKey := u.EndPoint() + "?" + u.Query.Encode()
-	err = app.Cache().Get(cacheKey, &res)
-	if err == nil {
-		return res, err
-	}
-
-	// prepare db for current ctx
-	tx, err := u.Ctx.DB()
-	if err != nil {
-		return res, app.Error().New(http.StatusInternalServerError, err.Error())
-	}
-
-	// set pagination info
-	res.Count,
-		res.PageContext.Page,
-		res.PageContext.PerPage,
-		res.PageContext.PageCount,
-		err = app.Query().PaginationInfo(tx, &Product{}, u.Query)
-	if err != nil {
-		return res, app.Error().New(http.StatusInternalServerError, err.Error())
-	}
-	// return data count if $per_page set to 0
-	if res.PageContext.PerPage == 0 {
-		return res, err
-	}
-
-	// find data
-	data, err := app.Query().Find(tx, &Product{}, u.Query)
-	if err != nil {
-		return res, app.Error().New(http.StatusInternalServerError, err.Error())
-	}
-	res.SetData(data, u.Query)
-
-	// save to cache and return if exists
-	app.Cache().Set(cacheKey, res)
+	// filter by the category including all its sub categories
+	if categoryID := u.Query.Get(QueryCategorySubtree); categoryID != "" {
+		if !app.Validator().IsValid(categoryID, "uuid") {
+			return res, app.Error().New(http.StatusBadRequest, "invalid "+QueryCategorySubtree)
+		}
+		u.Query.Del(QueryCategorySubtree)
+
+		// the path of the sub categories starts with the path of the category, so the prefix filter uses the path index
+		tx, err := u.Ctx.DB()
+		if err != nil {
+			return res, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		path := ""
+		err = tx.Model(&category.Category{}).Select("path").Where("id = ? AND deleted_at IS NULL", categoryID).Scan(&path).Error
+		if err != nil {
+			return res, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		if path == "" {
+			u.Query.Set("category.id", categoryID) // the unavailable category has no sub categories
+		} else {
+			u.Query.Set("category.path.$like", path+"%")
+		}
+	}
+
+	// get from cache, or get from db and save to cache, the concurrent requests of the same query are coalesced
+	err = u.Ctx.Remember(u.EndPoint(), u.EndPoint()+"?"+u.Query.Encode(), &res, func() (any, []string, error) {
+		data := app.ListModel{}
+
+		// prepare db for current ctx
+		tx, err := u.Ctx.DB()
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+
+		// set pagination info
+		data.Count,
+			data.PageContext.Page,
+			data.PageContext.PerPage,
+			data.PageContext.PageCount,
+			err = app.Query().PaginationInfo(tx, &Product{}, u.Query)
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		// return data count if $per_page set to 0
+		if data.PageContext.PerPage == 0 {
+			return data, app.Cache().ListTags(u.Ctx.CacheKey(u.EndPoint()), nil), nil
+		}
+
+		// find data
+		rows, err := app.Query().Find(tx, &Product{}, u.Query)
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		data.SetData(rows, u.Query)
+		return data, app.Cache().ListTags(u.Ctx.CacheKey(u.EndPoint()), data.Data), nil
+	})
 	return res, err
 }
 
@@ -162,6 +198,23 @@ func (u UseCaseHandler) Create(p *ParamCreate) error {
 	if err != nil {
 		return app.Error().New(http.StatusInternalServerError, err.Error())
 	}
+
+	// save the initial stock to the ledger
+	if p.Stock.Int64 > 0 {
+		_, err = u.recordStockMovement(tx, p.ID, StockMovementIn, p.Stock.Int64, app.NullString{}, app.NewNullString("initial stock"))
+		if err != nil {
+			return err
+		}
+	}
+
+	// invalidate cache
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint())) })
+
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("POST", "create", p.ID.String, p)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
 	return nil
 }
 
@@ -180,6 +233,11 @@ func (u UseCaseHandler) UpdateByID(id string, p *ParamUpdate) error {
 		return err
 	}
 
+	// the stock is only changed by the stock adjustments, so the ledger is kept
+	if p.Stock.Valid {
+		return app.Error().New(http.StatusBadRequest, u.Ctx.Trans("stock_read_only", map[string]string{"id": id}))
+	}
+
 	// get previous data
 	old, err := u.GetByID(id)
 	if err != nil {
@@ -198,17 +256,20 @@ func (u UseCaseHandler) UpdateByID(id string, p *ParamUpdate) error {
 		return app.Error().New(http.StatusInternalServerError, err.Error())
 	}
 
-	// update data on the db
-	err = tx.Model(&p).Where("id = ?", old.ID).Updates(p).Error
+	// update data on the db, the stock is only changed by the stock adjustments
+	err = tx.Model(&p).Where("id = ?", old.ID).Omit("stock").Updates(p).Error
 	if err != nil {
 		return app.Error().New(http.StatusInternalServerError, err.Error())
 	}
 
 	// invalidate cache
-	app.Cache().Invalidate(u.EndPoint(), old.ID.String)
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String) })
 
-	// save history (user activity), send webhook, etc
-	go u.Ctx.Hook("PUT", p.Reason.String, old.ID.String, old)
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("PUT", p.Reason.String, old.ID.String, old)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
 	return nil
 }
 
@@ -227,6 +288,11 @@ func (u UseCaseHandler) PartiallyUpdateByID(id string, p *ParamPartiallyUpdate)
 		return err
 	}
 
+	// the stock is only changed by the stock adjustments, so the ledger is kept
+	if p.Stock.Valid {
+		return app.Error().New(http.StatusBadRequest, u.Ctx.Trans("stock_read_only", map[string]string{"id": id}))
+	}
+
 	// get previous data
 	old, err := u.GetByID(id)
 	if err != nil {
@@ -245,17 +311,20 @@ func (u UseCaseHandler) PartiallyUpdateByID(id string, p *ParamPartiallyUpdate)
 		return app.Error().New(http.StatusInternalServerError, err.Error())
 	}
 
-	// update data on the db
-	err = tx.Model(&p).Where("id = ?", old.ID).Updates(p).Error
+	// update data on the db, the stock is only changed by the stock adjustments
+	err = tx.Model(&p).Where("id = ?", old.ID).Omit("stock").Updates(p).Error
 	if err != nil {
 		return app.Error().New(http.StatusInternalServerError, err.Error())
 	}
 
 	// invalidate cache
-	app.Cache().Invalidate(u.EndPoint(), old.ID.String)
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String) })
 
-	// save history (user activity), send webhook, etc
-	go u.Ctx.Hook("PATCH", p.Reason.String, old.ID.String, old)
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("PATCH", p.Reason.String, old.ID.String, old)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
 	return nil
 }
 
@@ -293,10 +362,13 @@ func (u UseCaseHandler) DeleteByID(id string, p *ParamDelete) error {
 	}
 
 	// invalidate cache
-	app.Cache().Invalidate(u.EndPoint(), old.ID.String)
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String) })
 
-	// save history (user activity), send webhook, etc
-	go u.Ctx.Hook("DELETE", p.Reason.String, old.ID.String, old)
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("DELETE", p.Reason.String, old.ID.String, old)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
 	return nil
 }
 
@@ -0,0 +1,174 @@
+package product
+
+import (
+	"path"
+	"strings"
+
+	"grest-belajar/app"
+)
+
+// These are the status of the renditions of the product image.
+const (
+	ProductImageStatusPending = "pending"
+	ProductImageStatusReady   = "ready"
+	ProductImageStatusFailed  = "failed"
+)
+
+// ImageRenditionsRequested is the outbox event type which is recorded when the renditions of the ProductImage are requested,
+// it is handled asynchronously by HandleImageRenditionsRequested.
+const ImageRenditionsRequested = "product-images.renditions_requested"
+
+// ProductImage is the image of the Product gallery, ordered by the position.
+// The original is stored without the metadata (EXIF), the renditions of app.Image().Renditions are stored next to it.
+// The URLs are generated on every read since they expire.
+type ProductImage struct {
+	app.Model
+	ID         app.NullUUID      `json:"id"                   db:"m.id"               gorm:"column:id;primaryKey"`
+	TenantID   *app.NullString   `json:"tenant_id,omitempty"  db:"m.tenant_id,hide"   gorm:"column:tenant_id;size:64;index"`
+	ProductID  app.NullUUID      `json:"product.id"           db:"m.product_id"       gorm:"column:product_id;index"`
+	Position   app.NullInt64     `json:"position"             db:"m.position"         gorm:"column:position"`
+	Key        app.NullString    `json:"key"                  db:"m.key"              gorm:"column:key;size:760"`
+	Format     app.NullString    `json:"format"               db:"m.format"           gorm:"column:format;size:16"`
+	Width      app.NullInt64     `json:"width"                db:"m.width"            gorm:"column:width"`
+	Height     app.NullInt64     `json:"height"               db:"m.height"           gorm:"column:height"`
+	Status     app.NullString    `json:"status"               db:"m.status"           gorm:"column:status;size:16"`
+	Renditions app.NullText      `json:"-"                    db:"m.renditions,hide"  gorm:"column:renditions"` // comma separated file names of the generated renditions
+	Error      app.NullText      `json:"error"                db:"m.error"            gorm:"column:error"`
+	URLs       map[string]string `json:"urls"                 db:"-"                  gorm:"-"`
+	CreatedAt  app.NullDateTime  `json:"created_at"           db:"m.created_at"       gorm:"column:created_at"`
+	UpdatedAt  app.NullDateTime  `json:"updated_at"           db:"m.updated_at"       gorm:"column:updated_at"`
+	DeletedAt  *app.NullDateTime `json:"deleted_at,omitempty" db:"m.deleted_at,hide"  gorm:"column:deleted_at"`
+}
+
+// EndPoint returns the ProductImage end point, it used for cache key, etc.
+func (ProductImage) EndPoint() string {
+	return "product-images"
+}
+
+// TableVersion returns the versions of the ProductImage table in the database.
+// Change this value with date format YY.MM.DDHHii when any table structure changes.
+func (ProductImage) TableVersion() string {
+	return "26.10.191500"
+}
+
+// TableName returns the name of the ProductImage table in the database.
+func (ProductImage) TableName() string {
+	return "product_images"
+}
+
+// TableAliasName returns the table alias name of the ProductImage table, used for querying.
+func (ProductImage) TableAliasName() string {
+	return "m"
+}
+
+// GetRelations returns the relations of the ProductImage data in the database, used for querying.
+func (m *ProductImage) GetRelations() map[string]map[string]any {
+	return m.Relations
+}
+
+// GetFilters returns the filter of the ProductImage data in the database, used for querying.
+func (m *ProductImage) GetFilters() []map[string]any {
+	m.AddFilter(map[string]any{"column1": "m.deleted_at", "operator": "=", "value": nil})
+	return m.Filters
+}
+
+// GetSorts returns the default sort of the ProductImage data in the database, used for querying.
+func (m *ProductImage) GetSorts() []map[string]any {
+	m.AddSort(map[string]any{"column": "m.position", "direction": "asc"})
+	return m.Sorts
+}
+
+// GetFields returns list of the field of the ProductImage data in the database, used for querying.
+func (m *ProductImage) GetFields() map[string]map[string]any {
+	m.SetFields(m)
+	return m.Fields
+}
+
+// GetSchema returns the ProductImage schema, used for querying.
+func (m *ProductImage) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+// OpenAPISchemaName returns the name of the ProductImage schema in the open api documentation.
+func (ProductImage) OpenAPISchemaName() string {
+	return "ProductImage"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the ProductImage in the open api documentation.
+func (m *ProductImage) GetOpenAPISchema() map[string]any {
+	return m.SetOpenAPISchema(m)
+}
+
+// dir returns the directory of the original and the renditions of the image.
+func (m ProductImage) dir() string {
+	return "products/" + m.ProductID.String + "/images/" + m.ID.String + "/"
+}
+
+// keys returns the keys of the original and the generated renditions of the image.
+func (m ProductImage) keys() []string {
+	keys := []string{m.Key.String}
+	for _, name := range m.renditionNames() {
+		keys = append(keys, m.dir()+name)
+	}
+	return keys
+}
+
+// renditionNames returns the file names of the generated renditions, for example "thumbnail.jpg".
+func (m ProductImage) renditionNames() []string {
+	if m.Renditions.String == "" {
+		return nil
+	}
+	return strings.Split(m.Renditions.String, ",")
+}
+
+// setURLs sets the URLs of the original and the generated renditions by the rendition name, for example "thumbnail".
+func (m *ProductImage) setURLs(ctx *app.Ctx) {
+	m.URLs = map[string]string{"original": ctx.FS().GetFileUrl(m.Key.String)}
+	for _, name := range m.renditionNames() {
+		m.URLs[strings.TrimSuffix(name, path.Ext(name))] = ctx.FS().GetFileUrl(m.dir() + name)
+	}
+}
+
+// ParamReorderImages is the expected parameters for reorder the images of the Product gallery.
+// The image ids must be all images of the Product in the new order.
+type ParamReorderImages struct {
+	app.Model
+	ImageIDs []string `json:"image_ids" validate:"required,min=1,dive,uuid"`
+}
+
+// OpenAPISchemaName returns the name of the ParamReorderImages schema in the open api documentation.
+func (ParamReorderImages) OpenAPISchemaName() string {
+	return "ParamReorderImages"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the ParamReorderImages in the open api documentation.
+func (ParamReorderImages) GetOpenAPISchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"image_ids": map[string]any{"type": "array", "items": map[string]any{"type": "string", "format": "uuid"}},
+		},
+		"required": []string{"image_ids"},
+	}
+}
+
+// ParamCreateImage is the expected parameters for upload a new image of the Product gallery, sent as the `file` field of the multipart form.
+type ParamCreateImage struct {
+	app.Model
+}
+
+// OpenAPISchemaName returns the name of the ParamCreateImage schema in the open api documentation.
+func (ParamCreateImage) OpenAPISchemaName() string {
+	return "ParamCreateProductImage"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the ParamCreateImage in the open api documentation.
+func (ParamCreateImage) GetOpenAPISchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"file": map[string]any{"type": "string", "format": "binary"},
+		},
+		"required": []string{"file"},
+	}
+}
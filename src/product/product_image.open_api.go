@@ -0,0 +1,76 @@
+package product
+
+import "grest-belajar/app"
+
+// CreateImage is detail of `POST /api/v3/products/{id}/images` open api document component.
+func (o *OpenAPIOperation) CreateImage() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Upload Product Image"
+	o.Description = "Use this method to upload the image of Product by id as multipart form, it is added as the last image of the gallery. " +
+		"The content must be a valid jpeg, png, gif or webp image, its metadata (EXIF) is stripped. " +
+		"The renditions are generated asynchronously, the status is `pending` until they are `ready`"
+	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
+	o.Body = map[string]any{"multipart/form-data": &ParamCreateImage{}}
+	o.Responses["201"] = map[string]any{
+		"description": "Created",
+		"content":     map[string]any{"application/json": &ProductImage{}},
+	}
+	delete(o.Responses, "200")
+	return o
+}
+
+// ReorderImages is detail of `PUT /api/v3/products/{id}/images/order` open api document component.
+func (o *OpenAPIOperation) ReorderImages() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Reorder Product Images"
+	o.Description = "Use this method to change the order of the gallery of Product by id, the image ids must be all images of the Product in the new order"
+	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
+	o.Body = map[string]any{"application/json": &ParamReorderImages{}}
+	return o
+}
+
+// RegenerateImageRenditions is detail of `POST /api/v3/products/{id}/images/{image_id}/renditions` open api document component.
+func (o *OpenAPIOperation) RegenerateImageRenditions() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Regenerate Product Image Renditions"
+	o.Description = "Use this method to generate the renditions of the image of Product again, for example after it is failed"
+	o.PathParams = []map[string]any{
+		{"$ref": "#/components/parameters/pathParam.ID"},
+		{"in": "path", "name": "image_id", "required": true, "schema": map[string]any{"type": "string", "format": "uuid"}},
+	}
+	o.Responses["202"] = map[string]any{
+		"description": "Accepted",
+		"content":     map[string]any{"application/json": &ProductImage{}},
+	}
+	delete(o.Responses, "200")
+	return o
+}
+
+// DeleteImage is detail of `DELETE /api/v3/products/{id}/images/{image_id}` open api document component.
+func (o *OpenAPIOperation) DeleteImage() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Delete Product Image"
+	o.Description = "Use this method to delete the image of Product with its renditions"
+	o.PathParams = []map[string]any{
+		{"$ref": "#/components/parameters/pathParam.ID"},
+		{"in": "path", "name": "image_id", "required": true, "schema": map[string]any{"type": "string", "format": "uuid"}},
+	}
+	o.Body = map[string]any{"application/json": &ParamDelete{}}
+	return o
+}
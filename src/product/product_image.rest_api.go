@@ -0,0 +1,93 @@
+package product
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"grest.dev/grest"
+
+	"grest-belajar/app"
+)
+
+// CreateImage is the REST API handler for `POST /api/products/{id}/images`, the image is uploaded as the `file` field of the multipart form.
+func (r *RESTAPIHandler) CreateImage(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	fh, _ := c.FormFile("file") // the missing file is validated by the use case
+	res, err := r.UseCase.CreateImage(c.Params("id"), fh)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	if r.UseCase.IsFlat() {
+		return c.Status(http.StatusCreated).JSON(res)
+	}
+	return c.Status(http.StatusCreated).JSON(grest.NewJSON(res).ToStructured().Data)
+}
+
+// ReorderImages is the REST API handler for `PUT /api/products/{id}/images/order`.
+func (r *RESTAPIHandler) ReorderImages(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	p := ParamReorderImages{}
+	err = grest.NewJSON(c.Body()).ToFlat().Unmarshal(&p)
+	if err != nil {
+		return app.Error().Handler(c, app.Error().New(http.StatusBadRequest, err.Error()))
+	}
+	err = r.UseCase.ReorderImages(c.Params("id"), &p)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res, err := r.UseCase.GetByID(c.Params("id"))
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	if r.UseCase.IsFlat() {
+		return c.JSON(res)
+	}
+	return c.JSON(grest.NewJSON(res).ToStructured().Data)
+}
+
+// RegenerateImageRenditions is the REST API handler for `POST /api/products/{id}/images/{image_id}/renditions`.
+func (r *RESTAPIHandler) RegenerateImageRenditions(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res, err := r.UseCase.RegenerateImageRenditions(c.Params("id"), c.Params("image_id"))
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	if r.UseCase.IsFlat() {
+		return c.Status(http.StatusAccepted).JSON(res)
+	}
+	return c.Status(http.StatusAccepted).JSON(grest.NewJSON(res).ToStructured().Data)
+}
+
+// DeleteImage is the REST API handler for `DELETE /api/products/{id}/images/{image_id}`.
+func (r *RESTAPIHandler) DeleteImage(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	p := ParamDelete{}
+	err = grest.NewJSON(c.Body()).ToFlat().Unmarshal(&p)
+	if err != nil {
+		return app.Error().Handler(c, app.Error().New(http.StatusBadRequest, err.Error()))
+	}
+	err = r.UseCase.DeleteImage(c.Params("id"), c.Params("image_id"), &p)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res := map[string]any{
+		"code": http.StatusOK,
+		"message": r.UseCase.Ctx.Trans("deleted", map[string]string{
+			"products": ProductImage{}.EndPoint(),
+			"id":       c.Params("image_id"),
+		}),
+	}
+	return c.JSON(res)
+}
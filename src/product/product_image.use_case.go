@@ -0,0 +1,448 @@
+package product
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"grest-belajar/app"
+)
+
+// CreateImage validates the uploaded image and stores it as the last image of the Product gallery for the specified ID.
+// The image is decoded and re-encoded, so the content must be a real image and its metadata (EXIF) is stripped.
+// The renditions are generated asynchronously by the outbox relay, see HandleImageRenditionsRequested.
+func (u UseCaseHandler) CreateImage(id string, fh *multipart.FileHeader) (ProductImage, error) {
+	res := ProductImage{}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("products.edit")
+	if err != nil {
+		return res, err
+	}
+
+	// validate param
+	if fh == nil {
+		return res, app.Error().New(http.StatusBadRequest, u.Ctx.Trans("file_required"))
+	}
+	if fh.Size > int64(app.FILE_MAX_SIZE) {
+		return res, app.Error().New(http.StatusBadRequest, u.Ctx.Trans("file_too_large", map[string]string{"max": strconv.Itoa(app.FILE_MAX_SIZE)}))
+	}
+
+	// get previous data
+	old, err := u.GetByID(id)
+	if err != nil {
+		return res, err
+	}
+
+	// decode the image to validate it, then re-encode it without the metadata
+	src, err := fh.Open()
+	if err != nil {
+		return res, app.Error().New(http.StatusBadRequest, err.Error())
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, int64(app.FILE_MAX_SIZE)))
+	if err != nil {
+		return res, app.Error().New(http.StatusBadRequest, err.Error())
+	}
+	m, format, err := app.Image().Decode(data)
+	if errors.Is(err, app.ErrImageTooLarge) {
+		return res, app.Error().New(http.StatusBadRequest, u.Ctx.Trans("image_too_large", map[string]string{"max": strconv.Itoa(app.Image().MaxPixels)}))
+	}
+	if err != nil {
+		return res, app.Error().New(http.StatusBadRequest, u.Ctx.Trans("invalid_image"))
+	}
+	buf := &bytes.Buffer{}
+	err = app.Image().Encode(buf, m, format)
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// the new image is the last of the gallery, the product is locked so the concurrent uploads get the next positions
+	var lockedID string
+	err = tx.Model(&Product{}).Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", old.ID).Scan(&lockedID).Error
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	var position int64
+	err = tx.Model(&ProductImage{}).Select("COALESCE(MAX(position), 0)").Where("product_id = ? AND deleted_at IS NULL", old.ID).Scan(&position).Error
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	now := time.Now().UTC()
+	res = ProductImage{
+		ID:        app.NewNullUUID(),
+		ProductID: old.ID,
+		Position:  app.NewNullInt64(position + 1),
+		Format:    app.NewNullString(format),
+		Width:     app.NewNullInt64(int64(m.Bounds().Dx())),
+		Height:    app.NewNullInt64(int64(m.Bounds().Dy())),
+		Status:    app.NewNullString(ProductImageStatusPending),
+		CreatedAt: app.NewNullDateTime(now),
+		UpdatedAt: app.NewNullDateTime(now),
+	}
+	res.Key = app.NewNullString(res.dir() + "original" + app.ImageRendition{Format: format}.Ext())
+
+	// upload the original to the storage
+	opt := app.FileUploadOption{}
+	opt.ContentType = "image/" + format
+	_, err = u.Ctx.FS().Upload(res.Key.String, bytes.NewReader(buf.Bytes()), int64(buf.Len()), opt)
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// save data to db, the renditions are requested in the same transaction so they are generated only after it is committed
+	err = tx.Create(&res).Error
+	if err == nil {
+		err = app.Outbox().Record(tx, ImageRenditionsRequested, res.EndPoint(), res.ID.String, map[string]any{"product.id": old.ID.String})
+	}
+	if err != nil {
+		u.Ctx.FS().Delete(res.Key.String)
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// invalidate cache
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String) })
+
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("PATCH", "add image", old.ID.String, old)
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	res.setURLs(u.Ctx)
+	return res, nil
+}
+
+// RegenerateImageRenditions requests the renditions of the image of the Product for the specified ID to be generated again,
+// for example after it is failed or the IMAGE_RENDITIONS is changed.
+func (u UseCaseHandler) RegenerateImageRenditions(id, imageID string) (ProductImage, error) {
+	res := ProductImage{}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("products.edit")
+	if err != nil {
+		return res, err
+	}
+
+	// get previous data
+	old, err := u.GetByID(id)
+	if err != nil {
+		return res, err
+	}
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	res, err = u.getImage(tx, old.ID.String, imageID)
+	if err != nil {
+		return res, err
+	}
+
+	// update data on the db, the renditions are requested in the same transaction
+	res.Status = app.NewNullString(ProductImageStatusPending)
+	res.UpdatedAt = app.NewNullDateTime(time.Now().UTC())
+	err = tx.Model(&ProductImage{}).Where("id = ?", res.ID).Updates(map[string]any{"status": res.Status, "updated_at": res.UpdatedAt}).Error
+	if err == nil {
+		err = app.Outbox().Record(tx, ImageRenditionsRequested, res.EndPoint(), res.ID.String, map[string]any{"product.id": old.ID.String})
+	}
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// invalidate cache
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String) })
+	res.setURLs(u.Ctx)
+	return res, nil
+}
+
+// ReorderImages changes the order of the Product gallery for the specified ID, the image ids must be all images of the Product.
+func (u UseCaseHandler) ReorderImages(id string, p *ParamReorderImages) error {
+
+	// check permission
+	err := u.Ctx.ValidatePermission("products.edit")
+	if err != nil {
+		return err
+	}
+
+	// validate param
+	err = u.Ctx.ValidateParam(p)
+	if err != nil {
+		return err
+	}
+
+	// get previous data
+	old, err := u.GetByID(id)
+	if err != nil {
+		return err
+	}
+	isInGallery := map[string]bool{}
+	for _, im := range old.Images {
+		isInGallery[im.ID.String] = true
+	}
+	for _, imageID := range p.ImageIDs {
+		if !isInGallery[imageID] {
+			return app.Error().New(http.StatusBadRequest, u.Ctx.Trans("invalid_image_order"))
+		}
+		delete(isInGallery, imageID) // the duplicate id is invalid too
+	}
+	if len(isInGallery) > 0 {
+		return app.Error().New(http.StatusBadRequest, u.Ctx.Trans("invalid_image_order"))
+	}
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// update data on the db
+	now := time.Now().UTC()
+	for i, imageID := range p.ImageIDs {
+		err = tx.Model(&ProductImage{}).Where("id = ?", imageID).Updates(map[string]any{"position": i + 1, "updated_at": now}).Error
+		if err != nil {
+			return app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	// invalidate cache
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String) })
+
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("PATCH", "reorder images", old.ID.String, old)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	return nil
+}
+
+// DeleteImage deletes the image of the Product for the specified ID, with its original and renditions from the storage.
+func (u UseCaseHandler) DeleteImage(id, imageID string, p *ParamDelete) error {
+
+	// check permission
+	err := u.Ctx.ValidatePermission("products.edit")
+	if err != nil {
+		return err
+	}
+
+	// validate param
+	err = u.Ctx.ValidateParam(p)
+	if err != nil {
+		return err
+	}
+
+	// get previous data
+	old, err := u.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	im, err := u.getImage(tx, old.ID.String, imageID)
+	if err != nil {
+		return err
+	}
+
+	// update data on the db
+	err = tx.Model(&ProductImage{}).Where("id = ?", im.ID).Update("deleted_at", time.Now().UTC()).Error
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// invalidate cache and delete the content after commit, so the content of the rolled back deletion is kept,
+	// the content which fails to be deleted is orphan and deleted later by app.FileGC()
+	u.Ctx.AfterCommit(func() {
+		app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String)
+		for _, key := range im.keys() {
+			err := u.Ctx.FS().Delete(key)
+			if err != nil {
+				app.Logger().Module("fs").Error().Err(err).Str("key", key).Msg("Failed to delete the content of the deleted product image.")
+			}
+		}
+	})
+
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("PATCH", p.Reason.String, old.ID.String, old)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	return nil
+}
+
+// GenerateImageRenditions generates the renditions of app.Image().Renditions from the original of the image, next to it.
+// It is idempotent, the existing renditions are overwritten, so it can be retried safely.
+func (u UseCaseHandler) GenerateImageRenditions(imageID string) error {
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return err
+	}
+	im := ProductImage{}
+	err = tx.Where("id = ? AND deleted_at IS NULL", imageID).First(&im).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil // the image is deleted before the renditions are generated
+	}
+	if err != nil {
+		return err
+	}
+
+	// decode the original
+	rc, err := u.Ctx.FS().Download(im.Key.String)
+	if err != nil {
+		return u.saveImageError(tx, im, err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return u.saveImageError(tx, im, err)
+	}
+	m, _, err := app.Image().Decode(data)
+	if err != nil {
+		return u.saveImageError(tx, im, err)
+	}
+
+	// generate and upload the renditions
+	names := []string{}
+	for _, r := range app.Image().Renditions {
+		b, err := app.Image().Rendition(m, r)
+		if err != nil {
+			return u.saveImageError(tx, im, err)
+		}
+		name := r.Name + r.Ext()
+		opt := app.FileUploadOption{}
+		opt.ContentType = "image/" + r.Format
+		_, err = u.Ctx.FS().Upload(im.dir()+name, bytes.NewReader(b), int64(len(b)), opt)
+		if err != nil {
+			return u.saveImageError(tx, im, err)
+		}
+		names = append(names, name)
+	}
+
+	// update data on the db
+	err = tx.Model(&ProductImage{}).Where("id = ?", im.ID).Updates(map[string]any{
+		"status":     ProductImageStatusReady,
+		"renditions": strings.Join(names, ","),
+		"error":      nil,
+		"updated_at": time.Now().UTC(),
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	// invalidate cache
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), im.ProductID.String) })
+	return nil
+}
+
+// getImages returns the images of the Product gallery ordered by the position, without the URLs.
+func (u UseCaseHandler) getImages(tx *gorm.DB, productID string) ([]ProductImage, error) {
+	images := []ProductImage{}
+	err := tx.Where("product_id = ? AND deleted_at IS NULL", productID).Order("position").Find(&images).Error
+	if err != nil {
+		return images, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	return images, nil
+}
+
+// getImage returns the image of the Product gallery, or not found error if it is not the image of the Product.
+func (u UseCaseHandler) getImage(tx *gorm.DB, productID, imageID string) (ProductImage, error) {
+	im := ProductImage{}
+	err := tx.Where("id = ? AND product_id = ? AND deleted_at IS NULL", imageID, productID).First(&im).Error
+	if err != nil {
+		if nfErr := u.Ctx.NotFoundError(err, im.EndPoint(), "id", imageID); nfErr != nil {
+			return im, nfErr
+		}
+		return im, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	return im, nil
+}
+
+// saveImageError saves the error of the renditions generation on the image, then returns the error so it is retried.
+func (u UseCaseHandler) saveImageError(tx *gorm.DB, im ProductImage, err error) error {
+	tx.Model(&ProductImage{}).Where("id = ?", im.ID).Updates(map[string]any{"error": err.Error(), "updated_at": time.Now().UTC()})
+	return err
+}
+
+// ImageFileReferrer returns the referrer of the product images for the orphan files garbage collection, see app.FileGC().
+// The image of the hard purged product is orphan, the image of the soft deleted product is kept since it can be restored.
+func ImageFileReferrer() app.FileReferrer {
+	return app.FileReferrer{
+		Name:     ProductImage{}.TableName(),
+		Prefixes: []string{Product{}.EndPoint() + "/"},
+		References: func(tx *gorm.DB) ([]app.FileReference, error) {
+			images := []ProductImage{}
+			err := tx.Where("deleted_at IS NULL").Find(&images).Error
+			if err != nil {
+				return nil, err
+			}
+			products := []string{}
+			err = tx.Model(&Product{}).Where("id IN (?)", tx.Model(&ProductImage{}).Select("product_id").Where("deleted_at IS NULL")).Pluck("id", &products).Error
+			if err != nil {
+				return nil, err
+			}
+			isProductExists := map[string]bool{}
+			for _, id := range products {
+				isProductExists[id] = true
+			}
+			refs := []app.FileReference{}
+			for _, im := range images {
+				ref := app.FileReference{ID: im.ID.String, Keys: im.keys(), CreatedAt: im.CreatedAt.Time, IsOrphan: !isProductExists[im.ProductID.String]}
+				if im.TenantID != nil {
+					ref.TenantID = im.TenantID.String
+				}
+				refs = append(refs, ref)
+			}
+			return refs, nil
+		},
+		Delete: func(tx *gorm.DB, ref app.FileReference) error {
+			im := ProductImage{}
+			err := tx.Where("id = ?", ref.ID).First(&im).Error
+			if err == nil {
+				err = tx.Model(&ProductImage{}).Where("id = ?", ref.ID).Update("deleted_at", time.Now().UTC()).Error
+			}
+			if err != nil {
+				return err
+			}
+			app.Cache().Invalidate(app.Tenant().Prefix(ref.TenantID, Product{}.EndPoint()), im.ProductID.String)
+			return nil
+		},
+	}
+}
+
+// HandleImageRenditionsRequested is the outbox subscriber of ImageRenditionsRequested which generates the renditions of the image.
+// The failed generation is retried by the outbox relay with backoff, the image is marked as failed after the max attempts.
+func HandleImageRenditionsRequested(e app.OutboxEvent) error {
+	u := UseCase(app.Ctx{TenantID: e.TenantID, IsAsync: true})
+	err := u.GenerateImageRenditions(e.AggregateID)
+	if err != nil && e.Attempts+1 >= app.Outbox().MaxAttempts {
+		payload := struct {
+			ProductID string `json:"product.id"`
+		}{}
+		json.Unmarshal(e.Payload, &payload)
+		if tx, dbErr := u.Ctx.DB(); dbErr == nil {
+			tx.Model(&ProductImage{}).Where("id = ?", e.AggregateID).Update("status", ProductImageStatusFailed)
+			app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), payload.ProductID)
+		}
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package product
+
+import "grest-belajar/app"
+
+// These are the type of the stock movement.
+const (
+	StockMovementIn         = "in"
+	StockMovementOut        = "out"
+	StockMovementAdjustment = "adjustment"
+)
+
+// StockMovement is the ledger of the Product stock, the Product.Stock is the balance of its movements.
+type StockMovement struct {
+	app.Model
+	ID        app.NullUUID     `json:"id"                  db:"m.id"              gorm:"column:id;primaryKey"`
+	TenantID  *app.NullString  `json:"tenant_id,omitempty" db:"m.tenant_id,hide"  gorm:"column:tenant_id;size:64;index"`
+	ProductID app.NullUUID     `json:"product.id"          db:"m.product_id"      gorm:"column:product_id;index"`
+	Type      app.NullString   `json:"type"                db:"m.type"            gorm:"column:type;size:16"`
+	Quantity  app.NullInt64    `json:"quantity"            db:"m.quantity"        gorm:"column:quantity"`
+	Balance   app.NullInt64    `json:"balance"             db:"m.balance"         gorm:"column:balance"`
+	Reference app.NullString   `json:"reference"           db:"m.reference"       gorm:"column:reference"`
+	Reason    app.NullString   `json:"reason"              db:"m.reason"          gorm:"column:reason"`
+	CreatedAt app.NullDateTime `json:"created_at"          db:"m.created_at"      gorm:"column:created_at"`
+}
+
+// EndPoint returns the StockMovement end point, it used for cache key, etc.
+func (StockMovement) EndPoint() string {
+	return "stock-movements"
+}
+
+// TableVersion returns the versions of the StockMovement table in the database.
+// Change this value with date format YY.MM.DDHHii when any table structure changes.
+func (StockMovement) TableVersion() string {
+	return "26.10.191200"
+}
+
+// TableName returns the name of the StockMovement table in the database.
+func (StockMovement) TableName() string {
+	return "stock_movements"
+}
+
+// TableAliasName returns the table alias name of the StockMovement table, used for querying.
+func (StockMovement) TableAliasName() string {
+	return "m"
+}
+
+// GetRelations returns the relations of the StockMovement data in the database, used for querying.
+func (m *StockMovement) GetRelations() map[string]map[string]any {
+	return m.Relations
+}
+
+// GetFilters returns the filter of the StockMovement data in the database, used for querying.
+func (m *StockMovement) GetFilters() []map[string]any {
+	return m.Filters
+}
+
+// GetSorts returns the default sort of the StockMovement data in the database, used for querying.
+func (m *StockMovement) GetSorts() []map[string]any {
+	m.AddSort(map[string]any{"column": "m.created_at", "direction": "desc"})
+	return m.Sorts
+}
+
+// GetFields returns list of the field of the StockMovement data in the database, used for querying.
+func (m *StockMovement) GetFields() map[string]map[string]any {
+	m.SetFields(m)
+	return m.Fields
+}
+
+// GetSchema returns the StockMovement schema, used for querying.
+func (m *StockMovement) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+// OpenAPISchemaName returns the name of the StockMovement schema in the open api documentation.
+func (StockMovement) OpenAPISchemaName() string {
+	return "StockMovement"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the StockMovement in the open api documentation.
+func (m *StockMovement) GetOpenAPISchema() map[string]any {
+	return m.SetOpenAPISchema(m)
+}
+
+type StockMovementList struct {
+	app.ListModel
+}
+
+// OpenAPISchemaName returns the name of the StockMovementList schema in the open api documentation.
+func (StockMovementList) OpenAPISchemaName() string {
+	return "StockMovementList"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the StockMovementList in the open api documentation.
+func (p *StockMovementList) GetOpenAPISchema() map[string]any {
+	return p.SetOpenAPISchema(&StockMovement{})
+}
+
+// ParamStockAdjustment is the expected parameters for adjust the Product stock.
+// The quantity must be positive for "in" and "out", and it is the signed difference for "adjustment".
+type ParamStockAdjustment struct {
+	app.Model
+	Type      app.NullString `json:"type"      validate:"required,oneof=in out adjustment"`
+	Quantity  app.NullInt64  `json:"quantity"  validate:"required"`
+	Reference app.NullString `json:"reference"`
+	Reason    app.NullString `json:"reason"    validate:"required"`
+}
+
+// OpenAPISchemaName returns the name of the ParamStockAdjustment schema in the open api documentation.
+func (ParamStockAdjustment) OpenAPISchemaName() string {
+	return "ParamStockAdjustment"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the ParamStockAdjustment in the open api documentation.
+func (p *ParamStockAdjustment) GetOpenAPISchema() map[string]any {
+	return p.SetOpenAPISchema(p)
+}
+
+// delta returns the signed stock difference of the adjustment.
+func (p ParamStockAdjustment) delta() int64 {
+	if p.Type.String == StockMovementOut {
+		return -p.Quantity.Int64
+	}
+	return p.Quantity.Int64
+}
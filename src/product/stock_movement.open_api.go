@@ -0,0 +1,42 @@
+package product
+
+import "grest-belajar/app"
+
+// AdjustStock is detail of `POST /api/v3/products/{id}/stock-adjustments` open api document component.
+func (o *OpenAPIOperation) AdjustStock() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Adjust Product Stock"
+	o.Description = "Use this method to adjust the stock of Product by id. " +
+		"The quantity must be positive for `in` and `out` type, and it is the signed difference for `adjustment` type. " +
+		"The adjustment is refused if the stock goes below zero."
+	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
+	o.Body = map[string]any{"application/json": &ParamStockAdjustment{}}
+	o.Responses["201"] = map[string]any{
+		"description": "Created",
+		"content":     map[string]any{"application/json": &StockMovement{}},
+	}
+	delete(o.Responses, "200")
+	return o
+}
+
+// GetStockMovements is detail of `GET /api/v3/products/{id}/stock-movements` open api document component.
+func (o *OpenAPIOperation) GetStockMovements() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Get Product Stock Movements"
+	o.Description = "Use this method to get list of the stock movements of Product by id"
+	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
+	o.QueryParams = []map[string]any{{"$ref": "#/components/parameters/queryParam.Any"}}
+	o.Responses["200"] = map[string]any{
+		"description": "Success",
+		"content":     map[string]any{"application/json": &StockMovementList{}},
+	}
+	return o
+}
@@ -0,0 +1,48 @@
+package product
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"grest.dev/grest"
+
+	"grest-belajar/app"
+)
+
+// AdjustStock is the REST API handler for `POST /api/products/{id}/stock-adjustments`.
+func (r *RESTAPIHandler) AdjustStock(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	p := ParamStockAdjustment{}
+	err = grest.NewJSON(c.Body()).ToFlat().Unmarshal(&p)
+	if err != nil {
+		return app.Error().Handler(c, app.Error().New(http.StatusBadRequest, err.Error()))
+	}
+	res, err := r.UseCase.AdjustStock(c.Params("id"), &p)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	if r.UseCase.IsFlat() {
+		return c.Status(http.StatusCreated).JSON(res)
+	}
+	return c.Status(http.StatusCreated).JSON(grest.NewJSON(res).ToStructured().Data)
+}
+
+// GetStockMovements is the REST API handler for `GET /api/products/{id}/stock-movements`.
+func (r *RESTAPIHandler) GetStockMovements(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res, err := r.UseCase.GetStockMovements(c.Params("id"))
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res.SetLink(c)
+	if r.UseCase.IsFlat() {
+		return c.JSON(res)
+	}
+	return c.JSON(grest.NewJSON(res).ToStructured().Data)
+}
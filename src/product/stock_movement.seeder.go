@@ -0,0 +1,56 @@
+package product
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"grest-belajar/app"
+)
+
+// OpeningStockSeeder records the opening stock movement of the products which are created before the ledger is introduced,
+// it is registered on the seeder so it is executed once per database.
+type OpeningStockSeeder struct{}
+
+// Run records an adjustment movement of the difference between the stock and the balance of the ledger of every product,
+// so the ledger of the existing products is reconciled with their stock.
+func (OpeningStockSeeder) Run(tx *gorm.DB) error {
+	rows := []struct {
+		ID       string
+		TenantID *string
+		Stock    int64
+		Ledger   int64
+	}{}
+	err := tx.Table(Product{}.TableName() + " AS p").
+		Select("p.id, p.tenant_id, COALESCE(p.stock, 0) AS stock, " +
+			"(SELECT COALESCE(SUM(sm.quantity), 0) FROM " + StockMovement{}.TableName() + " sm WHERE sm.product_id = p.id) AS ledger").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, r := range rows {
+		if r.Stock == r.Ledger {
+			continue
+		}
+		m := StockMovement{
+			ID:        app.NewNullUUID(),
+			ProductID: app.NewNullUUID(r.ID),
+			Type:      app.NewNullString(StockMovementAdjustment),
+			Quantity:  app.NewNullInt64(r.Stock - r.Ledger),
+			Balance:   app.NewNullInt64(r.Stock),
+			Reason:    app.NewNullString("opening stock"),
+			CreatedAt: app.NewNullDateTime(now),
+		}
+		if r.TenantID != nil {
+			tenantID := app.NewNullString(*r.TenantID)
+			m.TenantID = &tenantID
+		}
+		err = tx.Create(&m).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package product
+
+import (
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+
+	"grest-belajar/app"
+)
+
+// AdjustStock records the stock movement of the Product for the specified ID and updates its stock atomically.
+// The adjustment is refused if the stock goes below zero, or not found if the product is deleted.
+func (u UseCaseHandler) AdjustStock(id string, p *ParamStockAdjustment) (StockMovement, error) {
+	res := StockMovement{}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("products.edit")
+	if err != nil {
+		return res, err
+	}
+
+	// validate param
+	err = u.Ctx.ValidateParam(p)
+	if err != nil {
+		return res, err
+	}
+	if p.Quantity.Int64 == 0 || (p.Type.String != StockMovementAdjustment && p.Quantity.Int64 < 0) {
+		return res, app.Error().New(http.StatusBadRequest, u.Ctx.Trans("invalid_stock_quantity"))
+	}
+
+	// get previous data
+	old, err := u.GetByID(id)
+	if err != nil {
+		return res, err
+	}
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// update the stock with conditional update, so the concurrent adjustments can't make it negative
+	delta := p.delta()
+	q := tx.Model(&Product{}).
+		Where("id = ? AND deleted_at IS NULL AND COALESCE(stock, 0) + ? >= 0", old.ID, delta).
+		Updates(map[string]any{"stock": gorm.Expr("COALESCE(stock, 0) + ?", delta), "updated_at": time.Now().UTC()})
+	if q.Error != nil {
+		return res, app.Error().New(http.StatusInternalServerError, q.Error.Error())
+	}
+	if q.RowsAffected == 0 {
+		// the cached product may be deleted in the meantime, it is not an insufficient stock
+		var count int64
+		err = tx.Model(&Product{}).Where("id = ? AND deleted_at IS NULL", old.ID).Count(&count).Error
+		if err != nil {
+			return res, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		if count == 0 {
+			return res, u.Ctx.NotFoundError(gorm.ErrRecordNotFound, u.EndPoint(), "id", old.ID.String)
+		}
+		return res, app.Error().New(http.StatusBadRequest, u.Ctx.Trans("insufficient_stock"))
+	}
+
+	// save the movement to the ledger
+	res, err = u.recordStockMovement(tx, old.ID, p.Type.String, delta, p.Reference, p.Reason)
+	if err != nil {
+		return res, err
+	}
+
+	// invalidate cache
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String) })
+
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("PATCH", p.Reason.String, old.ID.String, old)
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	return res, nil
+}
+
+// GetStockMovements returns the list of the stock movements of the Product for the specified ID.
+func (u UseCaseHandler) GetStockMovements(id string) (app.ListModel, error) {
+	res := app.ListModel{}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("products.detail")
+	if err != nil {
+		return res, err
+	}
+
+	// make sure the product is exists
+	old, err := u.GetByID(id)
+	if err != nil {
+		return res, err
+	}
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// the ledger is not cached, it is read directly from the db
+	u.Query.Set("product.id", old.ID.String)
+
+	// set pagination info
+	res.Count,
+		res.PageContext.Page,
+		res.PageContext.PerPage,
+		res.PageContext.PageCount,
+		err = app.Query().PaginationInfo(tx, &StockMovement{}, u.Query)
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	// return data count if $per_page set to 0
+	if res.PageContext.PerPage == 0 {
+		return res, err
+	}
+
+	// find data
+	data, err := app.Query().Find(tx, &StockMovement{}, u.Query)
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	res.SetData(data, u.Query)
+	return res, err
+}
+
+// recordStockMovement saves the stock movement with the product stock balance after the movement.
+// It must be called in the same transaction right after the product stock is updated.
+func (u UseCaseHandler) recordStockMovement(tx *gorm.DB, productID app.NullUUID, movementType string, delta int64, reference, reason app.NullString) (StockMovement, error) {
+	m := StockMovement{
+		ID:        app.NewNullUUID(),
+		ProductID: productID,
+		Type:      app.NewNullString(movementType),
+		Quantity:  app.NewNullInt64(delta),
+		Reference: reference,
+		Reason:    reason,
+		CreatedAt: app.NewNullDateTime(time.Now().UTC()),
+	}
+	var balance int64
+	err := tx.Model(&Product{}).Select("COALESCE(stock, 0)").Where("id = ?", productID).Scan(&balance).Error
+	if err != nil {
+		return m, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	m.Balance = app.NewNullInt64(balance)
+	err = tx.Create(&m).Error
+	if err != nil {
+		return m, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	return m, nil
+}
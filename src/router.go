@@ -2,8 +2,13 @@ package src
 
 import (
 	"grest-belajar/app"
+	"grest-belajar/src/cache"
 	"grest-belajar/src/category"
+	"grest-belajar/src/errorgroup"
+	"grest-belajar/src/file"
+	"grest-belajar/src/loglevel"
 	"grest-belajar/src/product"
+	"grest-belajar/src/upload"
 	"grest-belajar/src/user"
 	// import : DONT REMOVE THIS COMMENT
 )
@@ -26,6 +31,29 @@ type routerUtil struct {
 func (r *routerUtil) Configure() {
 	app.Server().AddRoute("/api/version", "GET", app.VersionHandler, nil)
 
+	// the prometheus metrics, it must be excluded from the tenant, see TENANT_EXCLUDED_PATHS
+	if app.METRICS_ENABLED {
+		app.Server().AddRoute(app.METRICS_PATH, "GET", app.Metrics().Handler, nil)
+	}
+
+	// the signed urls of the drivers which can't presign, the signature is the authorization so the path must be excluded from the tenant, see TENANT_EXCLUDED_PATHS
+	app.Server().AddRoute(app.FS_SIGNED_URL_PATH+"/*", "GET", app.FS().DownloadHandler, nil)
+	app.Server().AddRoute(app.FS_SIGNED_URL_PATH+"/*", "PUT", app.FS().UploadHandler, nil)
+
+	app.Server().AddRoute("/api/cache/stats", "GET", cache.REST().GetStats, cache.OpenAPI().GetStats())
+	app.Server().AddRoute("/api/cache/keys", "GET", cache.REST().GetKeys, cache.OpenAPI().GetKeys())
+	app.Server().AddRoute("/api/cache/keys/{key}", "GET", cache.REST().GetByKey, cache.OpenAPI().GetByKey())
+	app.Server().AddRoute("/api/cache/flush", "POST", cache.REST().Flush, cache.OpenAPI().Flush())
+
+	app.Server().AddRoute("/api/log/levels", "GET", loglevel.REST().Get, loglevel.OpenAPI().Get())
+	app.Server().AddRoute("/api/log/levels", "PUT", loglevel.REST().Update, loglevel.OpenAPI().Update())
+	app.Server().AddRoute("/api/log/levels", "DELETE", loglevel.REST().Reset, loglevel.OpenAPI().Reset())
+
+	app.Server().AddRoute("/api/error-groups", "GET", errorgroup.REST().Get, errorgroup.OpenAPI().Get())
+	app.Server().AddRoute("/api/error-groups/{id}", "GET", errorgroup.REST().GetByID, errorgroup.OpenAPI().GetByID())
+	app.Server().AddRoute("/api/error-groups/{id}/resolve", "POST", errorgroup.REST().ResolveByID, errorgroup.OpenAPI().ResolveByID())
+	app.Server().AddRoute("/api/error-groups/{id}/mute", "POST", errorgroup.REST().MuteByID, errorgroup.OpenAPI().MuteByID())
+
 	app.Server().AddRoute("/api/users", "POST", user.REST().Create, user.OpenAPI().Create())
 	app.Server().AddRoute("/api/users", "GET", user.REST().Get, user.OpenAPI().Get())
 	app.Server().AddRoute("/api/users/{id}", "GET", user.REST().GetByID, user.OpenAPI().GetByID())
@@ -35,6 +63,7 @@ func (r *routerUtil) Configure() {
 
 	app.Server().AddRoute("/api/categories", "POST", category.REST().Create, category.OpenAPI().Create())
 	app.Server().AddRoute("/api/categories", "GET", category.REST().Get, category.OpenAPI().Get())
+	app.Server().AddRoute("/api/categories/tree", "GET", category.REST().GetTree, category.OpenAPI().GetTree())
 	app.Server().AddRoute("/api/categories/{id}", "GET", category.REST().GetByID, category.OpenAPI().GetByID())
 	app.Server().AddRoute("/api/categories/{id}", "PUT", category.REST().UpdateByID, category.OpenAPI().UpdateByID())
 	app.Server().AddRoute("/api/categories/{id}", "PATCH", category.REST().PartiallyUpdateByID, category.OpenAPI().PartiallyUpdateByID())
@@ -46,6 +75,32 @@ func (r *routerUtil) Configure() {
 	app.Server().AddRoute("/api/products/{id}", "PUT", product.REST().UpdateByID, product.OpenAPI().UpdateByID())
 	app.Server().AddRoute("/api/products/{id}", "PATCH", product.REST().PartiallyUpdateByID, product.OpenAPI().PartiallyUpdateByID())
 	app.Server().AddRoute("/api/products/{id}", "DELETE", product.REST().DeleteByID, product.OpenAPI().DeleteByID())
+	app.Server().AddRoute("/api/products/{id}/stock-adjustments", "POST", product.REST().AdjustStock, product.OpenAPI().AdjustStock())
+	app.Server().AddRoute("/api/products/{id}/stock-movements", "GET", product.REST().GetStockMovements, product.OpenAPI().GetStockMovements())
+	app.Server().AddRoute("/api/products/{id}/images", "POST", product.REST().CreateImage, product.OpenAPI().CreateImage())
+	app.Server().AddRoute("/api/products/{id}/images/order", "PUT", product.REST().ReorderImages, product.OpenAPI().ReorderImages())
+	app.Server().AddRoute("/api/products/{id}/images/{image_id}/renditions", "POST", product.REST().RegenerateImageRenditions, product.OpenAPI().RegenerateImageRenditions())
+	app.Server().AddRoute("/api/products/{id}/images/{image_id}", "DELETE", product.REST().DeleteImage, product.OpenAPI().DeleteImage())
+
+	// the entities the files can be attached to, the owner must be accessible by the uploader
+	file.RegisterOwner(category.Category{}.EndPoint(), func(ctx app.Ctx, id string) error {
+		_, err := category.UseCase(ctx).GetByID(id)
+		return err
+	})
+	file.RegisterOwner(product.Product{}.EndPoint(), func(ctx app.Ctx, id string) error {
+		_, err := product.UseCase(ctx).GetByID(id)
+		return err
+	})
+	app.Server().AddRoute("/api/files", "POST", file.REST().Create, file.OpenAPI().Create())
+	app.Server().AddRoute("/api/files", "GET", file.REST().Get, file.OpenAPI().Get())
+	app.Server().AddRoute("/api/files/{id}", "GET", file.REST().GetByID, file.OpenAPI().GetByID())
+	app.Server().AddRoute("/api/files/{id}", "DELETE", file.REST().DeleteByID, file.OpenAPI().DeleteByID())
+
+	app.Server().AddRoute("/api/uploads", "OPTIONS", upload.REST().Options, upload.OpenAPI().Options())
+	app.Server().AddRoute("/api/uploads", "POST", upload.REST().Create, upload.OpenAPI().Create())
+	app.Server().AddRoute("/api/uploads/{id}", "HEAD", upload.REST().GetByID, upload.OpenAPI().GetByID())
+	app.Server().AddStreamRoute("/api/uploads/{id}", "PATCH", upload.REST().WriteChunk, upload.OpenAPI().WriteChunk())
+	app.Server().AddRoute("/api/uploads/{id}", "DELETE", upload.REST().DeleteByID, upload.OpenAPI().DeleteByID())
 
 	// AddRoute : DONT REMOVE THIS COMMENT
 }
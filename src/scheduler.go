@@ -1,9 +1,14 @@
 package src
 
 import (
+	"context"
+
 	"github.com/robfig/cron/v3"
 
 	"grest-belajar/app"
+	"grest-belajar/src/file"
+	"grest-belajar/src/product"
+	"grest-belajar/src/upload"
 )
 
 func Scheduler() *schedulerUtil {
@@ -21,13 +26,48 @@ var scheduler *schedulerUtil
 
 type schedulerUtil struct {
 	isConfigured bool
+	cron         *cron.Cron
 }
 
 func (s *schedulerUtil) Configure() {
 	c := cron.New()
+	s.cron = c
+
+	// add scheduler func here, wrap it with app.Metrics().CronJob to record its runs, for example :
+	// c.AddFunc("CRON_TZ=Asia/Jakarta 5 0 * * *", app.Metrics().CronJob("remove_expired_token", app.Auth().RemoveExpiredToken))
+
+	// generate the renditions of the uploaded product images asynchronously, retried by the outbox relay
+	app.Outbox().Subscribe("product-images.renditions", product.ImageRenditionsRequested, product.HandleImageRenditionsRequested)
+
+	// assemble the received resumable uploads into the files asynchronously, retried by the outbox relay
+	app.Outbox().Subscribe("uploads.assembly", upload.UploadReceived, upload.HandleUploadReceived)
+
+	// relay the domain events recorded on the outbox to the subscribers
+	c.AddFunc("@every "+app.OUTBOX_RELAY_INTERVAL.String(), app.Metrics().CronJob("outbox_relay", app.Outbox().Relay))
+	c.AddFunc("CRON_TZ=Asia/Jakarta 10 0 * * *", app.Metrics().CronJob("outbox_cleanup", app.Outbox().Cleanup))
 
-	// add scheduler func here, for example :
-	// c.AddFunc("CRON_TZ=Asia/Jakarta 5 0 * * *", app.Auth().RemoveExpiredToken)
+	// collect the stored files which are not referenced anymore, and the rows which have no file behind them, including the expired uploads
+	if app.FS_GC_SCHEDULE != "" {
+		app.FileGC().Register(file.FileReferrer())
+		app.FileGC().Register(product.ImageFileReferrer())
+		app.FileGC().Register(upload.FileReferrer())
+		c.AddFunc(app.FS_GC_SCHEDULE, app.Metrics().CronJob("file_gc", app.FileGC().Collect))
+	}
 
 	c.Start()
 }
+
+// Stop stops the scheduling of the jobs and waits until the running ones are done or the ctx is done,
+// then the events recorded by the drained requests are relayed once more until the ctx is done, so they are not delayed until the next start.
+func (s *schedulerUtil) Stop(ctx context.Context) error {
+	if s.cron == nil {
+		return nil
+	}
+	select {
+	case <-s.cron.Stop().Done():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	app.Outbox().RelayContext(ctx)
+	return nil
+}
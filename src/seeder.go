@@ -1,6 +1,10 @@
 package src
 
-import "grest-belajar/app"
+import (
+	"grest-belajar/app"
+	"grest-belajar/src/category"
+	"grest-belajar/src/product"
+)
 
 func Seeder() *seederUtil {
 	if seeder == nil {
@@ -21,9 +25,18 @@ type seederUtil struct {
 }
 
 func (s *seederUtil) Configure() {
-
+	app.DB().RegisterSeeder("main", "26.10.191900_category_path", category.PathSeeder{})
+	app.DB().RegisterSeeder("main", "26.10.192100_product_opening_stock", product.OpeningStockSeeder{})
 }
 
 func (s *seederUtil) Run() {
-
+	tx, err := app.DB().Conn("main")
+	if err != nil {
+		app.Logger().Fatal().Err(err).Send()
+	} else {
+		err = app.DB().RunSeeder(tx, "main", app.Setting{})
+	}
+	if err != nil {
+		app.Logger().Fatal().Err(err).Send()
+	}
 }
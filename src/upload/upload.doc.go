@@ -0,0 +1,3 @@
+// upload is a package related to upload data, the resumable uploads of the tus protocol (https://tus.io/protocols/resumable-upload).
+// The completed upload is handed off to the file package, so it ends up as the regular File data.
+package upload
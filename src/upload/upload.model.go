@@ -0,0 +1,170 @@
+package upload
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"grest-belajar/app"
+)
+
+// TusVersion is the supported version of the tus protocol, it must be sent as the Tus-Resumable header of every request except OPTIONS.
+const TusVersion = "1.0.0"
+
+// TusExtensions is the supported extensions of the tus protocol.
+const TusExtensions = "creation,termination,expiration"
+
+// UploadReceived is the outbox event type which is recorded when all the content of the Upload is received,
+// it is handled asynchronously by HandleUploadReceived which assembles the chunks into the File data.
+const UploadReceived = "uploads.received"
+
+// Upload is the main model of Upload data, the state of the resumable upload.
+// Every chunk is stored through the tenant scoped app.Ctx.FS() under the upload directory,
+// once the offset reaches the length the chunks are assembled into the File data asynchronously and they are deleted.
+type Upload struct {
+	app.Model
+	ID               app.NullUUID      `json:"id"                   db:"m.id"                  gorm:"column:id;primaryKey"`
+	TenantID         *app.NullString   `json:"tenant_id,omitempty"  db:"m.tenant_id,hide"      gorm:"column:tenant_id;size:64;index"`
+	Length           app.NullInt64     `json:"length"               db:"m.upload_length"       gorm:"column:upload_length"`
+	Offset           app.NullInt64     `json:"offset"               db:"m.upload_offset"       gorm:"column:upload_offset"`
+	FileName         app.NullString    `json:"file_name"            db:"m.file_name"           gorm:"column:file_name"`
+	OwnerEndPoint    app.NullString    `json:"owner.end_point"      db:"m.owner_end_point"     gorm:"column:owner_end_point;size:64"`
+	OwnerID          app.NullString    `json:"owner.id"             db:"m.owner_id"            gorm:"column:owner_id;size:64"`
+	Chunks           app.NullText      `json:"-"                    db:"m.chunks,hide"         gorm:"column:chunks"` // comma separated file names of the stored chunks, ordered by the offset
+	FileID           app.NullString    `json:"file.id"              db:"m.file_id"             gorm:"column:file_id;size:64"`
+	UploadedByUserID app.NullString    `json:"uploaded_by.id"       db:"m.uploaded_by_user_id" gorm:"column:uploaded_by_user_id;size:64"`
+	ExpiresAt        app.NullDateTime  `json:"expires_at"           db:"m.expires_at"          gorm:"column:expires_at;index"`
+	CreatedAt        app.NullDateTime  `json:"created_at"           db:"m.created_at"          gorm:"column:created_at"`
+	UpdatedAt        app.NullDateTime  `json:"updated_at"           db:"m.updated_at"          gorm:"column:updated_at"`
+	DeletedAt        *app.NullDateTime `json:"deleted_at,omitempty" db:"m.deleted_at,hide"     gorm:"column:deleted_at"`
+}
+
+// EndPoint returns the Upload end point, it used for cache key, etc.
+func (Upload) EndPoint() string {
+	return "uploads"
+}
+
+// TableVersion returns the versions of the Upload table in the database.
+// Change this value with date format YY.MM.DDHHii when any table structure changes.
+func (Upload) TableVersion() string {
+	return "26.10.191600"
+}
+
+// TableName returns the name of the Upload table in the database.
+func (Upload) TableName() string {
+	return "uploads"
+}
+
+// TableAliasName returns the table alias name of the Upload table, used for querying.
+func (Upload) TableAliasName() string {
+	return "m"
+}
+
+// GetRelations returns the relations of the Upload data in the database, used for querying.
+func (m *Upload) GetRelations() map[string]map[string]any {
+	return m.Relations
+}
+
+// GetFilters returns the filter of the Upload data in the database, used for querying.
+func (m *Upload) GetFilters() []map[string]any {
+	m.AddFilter(map[string]any{"column1": "m.deleted_at", "operator": "=", "value": nil})
+	return m.Filters
+}
+
+// GetSorts returns the default sort of the Upload data in the database, used for querying.
+func (m *Upload) GetSorts() []map[string]any {
+	m.AddSort(map[string]any{"column": "m.created_at", "direction": "desc"})
+	return m.Sorts
+}
+
+// GetFields returns list of the field of the Upload data in the database, used for querying.
+func (m *Upload) GetFields() map[string]map[string]any {
+	m.SetFields(m)
+	return m.Fields
+}
+
+// GetSchema returns the Upload schema, used for querying.
+func (m *Upload) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+// OpenAPISchemaName returns the name of the Upload schema in the open api documentation.
+func (Upload) OpenAPISchemaName() string {
+	return "Upload"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the Upload in the open api documentation.
+func (m *Upload) GetOpenAPISchema() map[string]any {
+	return m.SetOpenAPISchema(m)
+}
+
+// dir returns the directory of the chunks of the upload.
+func (m Upload) dir() string {
+	return m.EndPoint() + "/" + m.ID.String + "/"
+}
+
+// chunkNames returns the file names of the stored chunks, ordered by the offset.
+func (m Upload) chunkNames() []string {
+	if m.Chunks.String == "" {
+		return nil
+	}
+	return strings.Split(m.Chunks.String, ",")
+}
+
+// chunkKeys returns the keys of the stored chunks, ordered by the offset.
+func (m Upload) chunkKeys() []string {
+	keys := []string{}
+	for _, name := range m.chunkNames() {
+		keys = append(keys, m.dir()+name)
+	}
+	return keys
+}
+
+// IsReceived returns true if all the content is received, it is assembled into the File data by HandleUploadReceived.
+func (m Upload) IsReceived() bool {
+	return m.Offset.Int64 == m.Length.Int64
+}
+
+// IsCompleted returns true if all the content is received and it is handed off to the File data.
+func (m Upload) IsCompleted() bool {
+	return m.FileID.String != ""
+}
+
+// ParamCreate is the expected parameters for create a new Upload, sent as the Upload-Length and Upload-Metadata headers.
+// The metadata is the comma separated key and base64 encoded value pairs, the known keys are
+// "filename", "owner.end_point" and "owner.id", the other keys are ignored.
+type ParamCreate struct {
+	UseCaseHandler
+}
+
+// ParamWriteChunk is the expected parameters for write the chunk of the Upload, sent as the body at the Upload-Offset header.
+type ParamWriteChunk struct {
+	app.Model
+}
+
+// OpenAPISchemaName returns the name of the ParamWriteChunk schema in the open api documentation.
+func (ParamWriteChunk) OpenAPISchemaName() string {
+	return "UploadParamWriteChunk"
+}
+
+// GetOpenAPISchema returns the Open API Schema of the ParamWriteChunk in the open api documentation.
+func (ParamWriteChunk) GetOpenAPISchema() map[string]any {
+	return map[string]any{"type": "string", "format": "binary"}
+}
+
+// ParseMetadata parses the Upload-Metadata header into the key and the decoded value, the value of the key without value is empty.
+func ParseMetadata(header string) (map[string]string, error) {
+	res := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		val, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, err
+		}
+		res[key] = string(val)
+	}
+	return res, nil
+}
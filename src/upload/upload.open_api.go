@@ -0,0 +1,116 @@
+package upload
+
+import "grest-belajar/app"
+
+// OpenAPI is constructor for *openAPI, to autogenerate open api document.
+func OpenAPI() *OpenAPIOperation {
+	return &OpenAPIOperation{}
+}
+
+// OpenAPIOperation embed from app.OpenAPIOperation for simplicity, used for autogenerate open api document.
+type OpenAPIOperation struct {
+	app.OpenAPIOperation
+}
+
+// Base is common detail of uploads open api document component.
+func (o *OpenAPIOperation) Base() {
+	o.Tags = []string{"Upload"}
+	o.HeaderParams = []map[string]any{
+		{"$ref": "#/components/parameters/headerParam.Accept-Language"},
+		{"in": "header", "name": "Tus-Resumable", "required": true, "schema": map[string]any{"type": "string", "example": TusVersion}},
+	}
+	o.Responses = map[string]map[string]any{
+		"400": app.OpenAPIError().BadRequest(),
+		"401": app.OpenAPIError().Unauthorized(),
+		"403": app.OpenAPIError().Forbidden(),
+		"412": {"description": "The Tus-Resumable header is not supported, the supported version is sent as the Tus-Version header"},
+	}
+	o.Securities = []map[string][]string{}
+}
+
+// Options is detail of `OPTIONS /api/uploads` open api document component.
+func (o *OpenAPIOperation) Options() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Get Upload Capabilities"
+	o.Description = "Use this method to get the supported version (Tus-Version), extensions (Tus-Extension) and max size (Tus-Max-Size) of the resumable upload"
+	o.HeaderParams = []map[string]any{{"$ref": "#/components/parameters/headerParam.Accept-Language"}}
+	o.Responses = map[string]map[string]any{"204": {"description": "No Content"}}
+	return o
+}
+
+// Create is detail of `POST /api/uploads` open api document component.
+func (o *OpenAPIOperation) Create() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Create Upload"
+	o.Description = "Use this method to start the resumable upload (tus protocol) with the size as the Upload-Length header. " +
+		"The Upload-Metadata header is the comma separated key and base64 encoded value pairs, for example `filename Y2F0YWxvZy5wZGY=,owner.end_point cHJvZHVjdHM=`. " +
+		"The url of the upload is sent as the Location header, it expires at the Upload-Expires header"
+	o.HeaderParams = append(o.HeaderParams,
+		map[string]any{"in": "header", "name": "Upload-Length", "required": true, "schema": map[string]any{"type": "integer"}},
+		map[string]any{"in": "header", "name": "Upload-Metadata", "schema": map[string]any{"type": "string"}},
+	)
+	o.Responses["201"] = map[string]any{"description": "Created"}
+	o.Responses["413"] = map[string]any{"description": "The Upload-Length exceeds the Tus-Max-Size"}
+	return o
+}
+
+// GetByID is detail of `HEAD /api/uploads/{id}` open api document component.
+func (o *OpenAPIOperation) GetByID() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Get Upload Offset"
+	o.Description = "Use this method to get the current offset of the upload as the Upload-Offset header, to resume the upload from it. " +
+		"The id of the File is sent as the Upload-File-Id header once the upload is completed"
+	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
+	o.Responses["200"] = map[string]any{"description": "Success"}
+	o.Responses["410"] = map[string]any{"description": "The upload is expired"}
+	return o
+}
+
+// WriteChunk is detail of `PATCH /api/uploads/{id}` open api document component.
+func (o *OpenAPIOperation) WriteChunk() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Write Upload Chunk"
+	o.Description = "Use this method to send the chunk of the upload at the current offset as the Upload-Offset header, the new offset is sent as the Upload-Offset header. " +
+		"The interrupted chunk keeps the offset reached, get the Upload-Offset with HEAD to resume it. " +
+		"The chunk which reaches the Upload-Length completes the upload, the content is saved as the File asynchronously and its id is sent as the Upload-File-Id header of HEAD once it is saved"
+	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
+	o.HeaderParams = append(o.HeaderParams,
+		map[string]any{"in": "header", "name": "Upload-Offset", "required": true, "schema": map[string]any{"type": "integer"}},
+	)
+	o.Body = map[string]any{"application/offset+octet-stream": &ParamWriteChunk{}}
+	o.Responses["204"] = map[string]any{"description": "No Content"}
+	o.Responses["409"] = map[string]any{"description": "The Upload-Offset does not match the current offset of the upload"}
+	o.Responses["410"] = map[string]any{"description": "The upload is expired"}
+	o.Responses["415"] = map[string]any{"description": "The Content-Type is not application/offset+octet-stream"}
+	return o
+}
+
+// DeleteByID is detail of `DELETE /api/uploads/{id}` open api document component.
+func (o *OpenAPIOperation) DeleteByID() *OpenAPIOperation {
+	if !app.IS_GENERATE_OPEN_API_DOC {
+		return o // skip for efficiency
+	}
+
+	o.Base()
+	o.Summary = "Terminate Upload"
+	o.Description = "Use this method to terminate the upload, the received chunks are deleted"
+	o.PathParams = []map[string]any{{"$ref": "#/components/parameters/pathParam.ID"}}
+	o.Responses["204"] = map[string]any{"description": "No Content"}
+	return o
+}
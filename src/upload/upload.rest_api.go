@@ -0,0 +1,139 @@
+package upload
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"grest-belajar/app"
+)
+
+// REST returns a *RESTAPIHandler.
+func REST() *RESTAPIHandler {
+	return &RESTAPIHandler{}
+}
+
+// RESTAPIHandler provides a convenient interface for Upload REST API handler.
+// The responses follow the tus protocol, the state of the upload is sent on the headers instead of the body.
+type RESTAPIHandler struct {
+	UseCase UseCaseHandler
+}
+
+// injectDeps inject the dependencies of the Upload REST API handler, and validates the version of the tus protocol.
+func (r *RESTAPIHandler) injectDeps(c *fiber.Ctx) error {
+	c.Set("Tus-Resumable", TusVersion)
+	ctx, ok := c.Locals(app.CtxKey).(*app.Ctx)
+	if !ok {
+		return app.Error().New(http.StatusInternalServerError, "ctx is not found")
+	}
+	r.UseCase = UseCase(*ctx, app.Query().Parse(c.OriginalURL()))
+	if c.Get("Tus-Resumable") != TusVersion {
+		c.Set("Tus-Version", TusVersion)
+		return app.Error().New(http.StatusPreconditionFailed, r.UseCase.Ctx.Trans("unsupported_tus_version", map[string]string{"version": TusVersion}))
+	}
+	return nil
+}
+
+// setHeaders sets the state of the upload on the response headers.
+func (r *RESTAPIHandler) setHeaders(c *fiber.Ctx, up Upload) {
+	c.Set("Upload-Offset", strconv.FormatInt(up.Offset.Int64, 10))
+	c.Set("Upload-Length", strconv.FormatInt(up.Length.Int64, 10))
+	if up.IsCompleted() {
+		c.Set("Upload-File-Id", up.FileID.String)
+	} else {
+		c.Set("Upload-Expires", up.ExpiresAt.Time.UTC().Format(http.TimeFormat))
+	}
+	c.Set("Cache-Control", "no-store")
+}
+
+// Options is the REST API handler for `OPTIONS /api/uploads`, it describes the supported version and extensions of the tus protocol.
+func (r *RESTAPIHandler) Options(c *fiber.Ctx) error {
+	c.Set("Tus-Resumable", TusVersion)
+	c.Set("Tus-Version", TusVersion)
+	c.Set("Tus-Extension", TusExtensions)
+	c.Set("Tus-Max-Size", strconv.Itoa(app.TUS_MAX_SIZE))
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// Create is the REST API handler for `POST /api/uploads`, the location of the new upload is sent as the Location header.
+func (r *RESTAPIHandler) Create(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	length, err := strconv.ParseInt(c.Get("Upload-Length"), 10, 64)
+	if err != nil {
+		return app.Error().Handler(c, app.Error().New(http.StatusBadRequest, r.UseCase.Ctx.Trans("invalid_upload_length")))
+	}
+	metadata, err := ParseMetadata(c.Get("Upload-Metadata"))
+	if err != nil {
+		return app.Error().Handler(c, app.Error().New(http.StatusBadRequest, err.Error()))
+	}
+	p := ParamCreate{}
+	err = r.UseCase.Create(&p, length, metadata)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res, err := r.UseCase.GetByID(p.ID.String)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	r.setHeaders(c, res)
+	c.Set("Location", c.BaseURL()+c.Path()+"/"+res.ID.String)
+	return c.SendStatus(http.StatusCreated)
+}
+
+// GetByID is the REST API handler for `HEAD /api/uploads/{id}`, the current offset is sent as the Upload-Offset header to resume the upload.
+func (r *RESTAPIHandler) GetByID(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	res, err := r.UseCase.GetByID(c.Params("id"))
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	r.setHeaders(c, res)
+	return c.SendStatus(http.StatusOK)
+}
+
+// WriteChunk is the REST API handler for `PATCH /api/uploads/{id}`, the chunk is sent as the body at the Upload-Offset header.
+// The id of the File data is sent as the Upload-File-Id header once the received upload is assembled, see HandleUploadReceived.
+func (r *RESTAPIHandler) WriteChunk(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	if c.Get("Content-Type") != "application/offset+octet-stream" {
+		return app.Error().Handler(c, app.Error().New(http.StatusUnsupportedMediaType, r.UseCase.Ctx.Trans("invalid_upload_content_type")))
+	}
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return app.Error().Handler(c, app.Error().New(http.StatusBadRequest, r.UseCase.Ctx.Trans("invalid_upload_offset", map[string]string{"offset": c.Get("Upload-Offset")})))
+	}
+	body := c.Context().RequestBodyStream() // the route is added by app.Server().AddStreamRoute, so the chunk is not buffered
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+	res, err := r.UseCase.WriteChunk(c.Params("id"), offset, body, int64(c.Request().Header.ContentLength()))
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	r.setHeaders(c, res)
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// DeleteByID is the REST API handler for `DELETE /api/uploads/{id}`, it terminates the upload.
+func (r *RESTAPIHandler) DeleteByID(c *fiber.Ctx) error {
+	err := r.injectDeps(c)
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	err = r.UseCase.DeleteByID(c.Params("id"))
+	if err != nil {
+		return app.Error().Handler(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
@@ -0,0 +1,220 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/gorm"
+
+	"grest-belajar/app"
+	"grest-belajar/src/file"
+)
+
+// prepareTest prepares the test, the chunks and the files are stored on the memory driver.
+func prepareTest(tb testing.TB) {
+	app.Test()
+	tx := app.Test().Tx
+	app.DB().RegisterTable("main", Upload{})
+	app.DB().RegisterTable("main", file.File{})
+	app.DB().RegisterTable("main", app.OutboxEvent{})
+	app.DB().MigrateTable(tx, "main", app.Setting{})
+	tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Upload{})
+	tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&file.File{})
+
+	file.RegisterOwner("products", func(ctx app.Ctx, id string) error { return nil })
+	app.DB().RegisterConn("main", app.Test().Tx) // the received uploads are assembled outside the request
+	app.Outbox().Subscribe("uploads.assembly", UploadReceived, HandleUploadReceived)
+
+	app.Server().AddMiddleware(app.Test().NewCtx([]string{
+		"files.create",
+	}))
+	app.Server().AddRoute("/uploads", "OPTIONS", REST().Options, nil)
+	app.Server().AddRoute("/uploads", "POST", REST().Create, nil)
+	app.Server().AddRoute("/uploads/:id", "HEAD", REST().GetByID, nil)
+	app.Server().AddStreamRoute("/uploads/:id", "PATCH", REST().WriteChunk, nil)
+	app.Server().AddRoute("/uploads/:id", "DELETE", REST().DeleteByID, nil)
+}
+
+// newTusRequest returns the tus request with the headers.
+func newTusRequest(method, path string, body []byte, headers map[string]string) *http.Request {
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Add("Authorization", "Bearer "+app.TestFullAccessToken)
+	req.Header.Add("Tus-Resumable", TusVersion)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+// TestUploadREST tests the resumable upload from the creation to the completion with specified scenario.
+func TestUploadREST(t *testing.T) {
+	prepareTest(t)
+
+	content := []byte("name,stock\nApple,10\nBanana,20\n")
+	metadata := "filename " + base64.StdEncoding.EncodeToString([]byte("catalog.csv")) +
+		",owner.end_point " + base64.StdEncoding.EncodeToString([]byte("products")) +
+		",owner.id " + base64.StdEncoding.EncodeToString([]byte("a"))
+	res, err := app.Server().Test(newTusRequest("POST", "/uploads", nil, map[string]string{
+		"Upload-Length":   strconv.Itoa(len(content)),
+		"Upload-Metadata": metadata,
+	}))
+	utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+	utils.AssertEqual(t, http.StatusCreated, res.StatusCode, "Create Upload")
+	utils.AssertEqual(t, TusVersion, res.Header.Get("Tus-Resumable"), "Create Upload")
+	path := strings.TrimPrefix(res.Header.Get("Location"), "http://example.com")
+	if !strings.HasPrefix(path, "/uploads/") {
+		t.Fatalf("Expected the location of the upload, got [%v]", res.Header.Get("Location"))
+	}
+
+	chunk := map[string]string{"Content-Type": "application/offset+octet-stream"}
+	tests := []struct {
+		description    string            // description of the test case
+		method         string            // method of the request
+		offset         string            // Upload-Offset header of the request
+		body           []byte            // chunk of the request
+		headers        map[string]string // other headers of the request
+		expectedCode   int               // expected HTTP status code
+		expectedOffset string            // expected Upload-Offset header of the response
+	}{
+		{"Get Upload Offset", "HEAD", "", nil, nil, http.StatusOK, "0"},
+		{"Write Upload Chunk without content type", "PATCH", "0", content[:10], nil, http.StatusUnsupportedMediaType, ""},
+		{"Write Upload Chunk", "PATCH", "0", content[:10], chunk, http.StatusNoContent, "10"},
+		{"Write Upload Chunk with stale offset", "PATCH", "0", content[:10], chunk, http.StatusConflict, ""},
+		{"Write Upload Chunk exceeding length", "PATCH", "10", append(content[10:], 'x'), chunk, http.StatusBadRequest, ""},
+		{"Get Upload Offset after chunk", "HEAD", "", nil, nil, http.StatusOK, "10"},
+		{"Write last Upload Chunk", "PATCH", "10", content[10:], chunk, http.StatusNoContent, strconv.Itoa(len(content))},
+		{"Write Upload Chunk after completed", "PATCH", strconv.Itoa(len(content)), content[:1], chunk, http.StatusConflict, ""},
+	}
+	for _, test := range tests {
+		headers := map[string]string{"Upload-Offset": test.offset}
+		for k, v := range test.headers {
+			headers[k] = v
+		}
+		res, err := app.Server().Test(newTusRequest(test.method, path, test.body, headers))
+		utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+		utils.AssertEqual(t, test.expectedCode, res.StatusCode, test.description)
+		if test.expectedOffset != "" {
+			utils.AssertEqual(t, test.expectedOffset, res.Header.Get("Upload-Offset"), test.description)
+		}
+		res.Body.Close()
+	}
+
+	// the received upload is handed off to the file data by the outbox relay, and its chunks are deleted
+	res, _ = app.Server().Test(newTusRequest("HEAD", path, nil, nil))
+	utils.AssertEqual(t, "", res.Header.Get("Upload-File-Id"), "Get received Upload before it is assembled")
+	app.Outbox().Relay()
+	res, _ = app.Server().Test(newTusRequest("HEAD", path, nil, nil))
+	fileID := res.Header.Get("Upload-File-Id")
+	f := file.File{}
+	err = app.Test().Tx.Where("id = ?", fileID).First(&f).Error
+	utils.AssertEqual(t, nil, err, "Get completed File")
+	utils.AssertEqual(t, "catalog.csv", f.Name.String, "Get completed File")
+	utils.AssertEqual(t, "text/csv", f.MimeType.String, "Get completed File")
+	utils.AssertEqual(t, int64(len(content)), f.Size.Int64, "Get completed File")
+	stored, _ := app.FS().List(Upload{}.EndPoint() + "/")
+	utils.AssertEqual(t, 0, len(stored), "Delete completed Upload chunks")
+}
+
+// TestUploadRESTTermination tests the protocol validation and the termination of the resumable upload.
+func TestUploadRESTTermination(t *testing.T) {
+	prepareTest(t)
+
+	res, err := app.Server().Test(newTusRequest("OPTIONS", "/uploads", nil, map[string]string{"Tus-Resumable": ""}))
+	utils.AssertEqual(t, nil, err, "app.Server().Test(req)")
+	utils.AssertEqual(t, http.StatusNoContent, res.StatusCode, "Get Upload Capabilities")
+	utils.AssertEqual(t, TusExtensions, res.Header.Get("Tus-Extension"), "Get Upload Capabilities")
+
+	res, _ = app.Server().Test(newTusRequest("POST", "/uploads", nil, map[string]string{"Tus-Resumable": "0.2.2", "Upload-Length": "1"}))
+	utils.AssertEqual(t, http.StatusPreconditionFailed, res.StatusCode, "Create Upload with unsupported version")
+	utils.AssertEqual(t, TusVersion, res.Header.Get("Tus-Version"), "Create Upload with unsupported version")
+
+	res, _ = app.Server().Test(newTusRequest("POST", "/uploads", nil, map[string]string{"Upload-Length": strconv.Itoa(app.TUS_MAX_SIZE + 1)}))
+	utils.AssertEqual(t, http.StatusRequestEntityTooLarge, res.StatusCode, "Create Upload exceeding max size")
+
+	res, _ = app.Server().Test(newTusRequest("POST", "/uploads", nil, map[string]string{
+		"Upload-Length":   "10",
+		"Upload-Metadata": "owner.id " + base64.StdEncoding.EncodeToString([]byte("a")),
+	}))
+	utils.AssertEqual(t, http.StatusBadRequest, res.StatusCode, "Create Upload with owner id only")
+
+	res, _ = app.Server().Test(newTusRequest("POST", "/uploads", nil, map[string]string{"Upload-Length": "10"}))
+	utils.AssertEqual(t, http.StatusCreated, res.StatusCode, "Create Upload")
+	path := strings.TrimPrefix(res.Header.Get("Location"), "http://example.com")
+	res, _ = app.Server().Test(newTusRequest("PATCH", path, []byte("hello"), map[string]string{"Upload-Offset": "0", "Content-Type": "application/offset+octet-stream"}))
+	utils.AssertEqual(t, http.StatusNoContent, res.StatusCode, "Write Upload Chunk")
+
+	res, _ = app.Server().Test(newTusRequest("DELETE", path, nil, nil))
+	utils.AssertEqual(t, http.StatusNoContent, res.StatusCode, "Terminate Upload")
+	res, _ = app.Server().Test(newTusRequest("HEAD", path, nil, nil))
+	utils.AssertEqual(t, http.StatusNotFound, res.StatusCode, "Get terminated Upload Offset")
+	stored, _ := app.FS().List(Upload{}.EndPoint() + "/")
+	utils.AssertEqual(t, 0, len(stored), "Delete terminated Upload chunks")
+}
+
+// interruptedReader returns the content, then the error as if the client is disconnected.
+type interruptedReader struct {
+	content []byte
+}
+
+// Read reads the content, then returns io.ErrUnexpectedEOF.
+func (r *interruptedReader) Read(b []byte) (int, error) {
+	if len(r.content) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(b, r.content)
+	r.content = r.content[n:]
+	return n, nil
+}
+
+// TestUploadWriteChunkInterrupted tests the interrupted chunk keeps the offset reached, so it is resumed from there.
+func TestUploadWriteChunkInterrupted(t *testing.T) {
+	prepareTest(t)
+	partSize := app.TUS_PART_SIZE
+	app.TUS_PART_SIZE = 4
+	defer func() { app.TUS_PART_SIZE = partSize }()
+
+	u := UseCase(app.Ctx{IsAsync: true})
+	p := ParamCreate{}
+	err := u.Create(&p, 20, map[string]string{"filename": "notes.txt"})
+	utils.AssertEqual(t, nil, err, "Create Upload")
+
+	up, err := u.WriteChunk(p.ID.String, 0, &interruptedReader{content: []byte("hello wor")}, 20)
+	utils.AssertEqual(t, nil, err, "Write interrupted Upload Chunk")
+	utils.AssertEqual(t, int64(9), up.Offset.Int64, "Write interrupted Upload Chunk")
+	up, err = u.GetByID(p.ID.String)
+	utils.AssertEqual(t, nil, err, "Get interrupted Upload")
+	utils.AssertEqual(t, int64(9), up.Offset.Int64, "Get interrupted Upload")
+	utils.AssertEqual(t, 3, len(up.chunkNames()), "Get interrupted Upload")
+
+	_, err = u.WriteChunk(p.ID.String, 0, strings.NewReader("hello"), 5)
+	utils.AssertEqual(t, http.StatusConflict, app.Error().StatusCode(err), "Write Upload Chunk with stale offset")
+	up, err = u.WriteChunk(p.ID.String, 9, strings.NewReader("ld, again!!"), -1)
+	utils.AssertEqual(t, nil, err, "Resume interrupted Upload")
+	utils.AssertEqual(t, true, up.IsReceived(), "Resume interrupted Upload")
+
+	err = u.Assemble(p.ID.String)
+	utils.AssertEqual(t, nil, err, "Assemble Upload")
+	up, _ = u.GetByID(p.ID.String)
+	f := file.File{}
+	err = app.Test().Tx.Where("id = ?", up.FileID.String).First(&f).Error
+	utils.AssertEqual(t, nil, err, "Get assembled File")
+	utils.AssertEqual(t, int64(20), f.Size.Int64, "Get assembled File")
+}
+
+func TestParseMetadata(t *testing.T) {
+	res, err := ParseMetadata("filename Y2F0YWxvZy5jc3Y=, is_confidential,owner.id YQ==")
+	utils.AssertEqual(t, nil, err, "ParseMetadata")
+	utils.AssertEqual(t, map[string]string{"filename": "catalog.csv", "is_confidential": "", "owner.id": "a"}, res, "ParseMetadata")
+
+	_, err = ParseMetadata("filename not-base64!")
+	if err == nil {
+		t.Errorf("Expected the invalid base64 value to be rejected")
+	}
+}
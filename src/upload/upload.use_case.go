@@ -0,0 +1,484 @@
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"grest-belajar/app"
+	"grest-belajar/src/file"
+)
+
+// UseCase returns a UseCaseHandler for expected use case functional.
+func UseCase(ctx app.Ctx, query ...url.Values) UseCaseHandler {
+	u := UseCaseHandler{
+		Ctx:   &ctx,
+		Query: url.Values{},
+	}
+	if len(query) > 0 {
+		u.Query = query[0]
+	}
+	return u
+}
+
+// UseCaseHandler provides a convenient interface for Upload use case, use UseCase to access UseCaseHandler.
+type UseCaseHandler struct {
+	Upload
+
+	// injectable dependencies
+	Ctx   *app.Ctx   `json:"-" db:"-" gorm:"-"`
+	Query url.Values `json:"-" db:"-" gorm:"-"`
+}
+
+// Async return UseCaseHandler with async process.
+func (u UseCaseHandler) Async(ctx app.Ctx, query ...url.Values) UseCaseHandler {
+	ctx.IsAsync = true
+	return UseCase(ctx, query...)
+}
+
+// GetByID returns the Upload data for the specified ID, it is only visible to the user who creates it.
+// The upload is not cached and it is read from the primary db since the offset changes on every chunk.
+func (u UseCaseHandler) GetByID(id string) (Upload, error) {
+	res := Upload{}
+
+	// check permission
+	err := u.Ctx.ValidatePermission("files.create")
+	if err != nil {
+		return res, err
+	}
+
+	// prepare db for current ctx
+	u.Ctx.IsStrongConsistency = true
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return res, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	return u.get(tx, id)
+}
+
+// Create creates a new Upload with the length and the metadata, the content is sent later by WriteChunk.
+// The empty upload is received immediately, so it is assembled into the File data by HandleUploadReceived.
+func (u UseCaseHandler) Create(p *ParamCreate, length int64, metadata map[string]string) error {
+
+	// check permission
+	err := u.Ctx.ValidatePermission("files.create")
+	if err != nil {
+		return err
+	}
+
+	// validate param
+	if length < 0 {
+		return app.Error().New(http.StatusBadRequest, u.Ctx.Trans("invalid_upload_length"))
+	}
+	if length > int64(app.TUS_MAX_SIZE) {
+		return app.Error().New(http.StatusRequestEntityTooLarge, u.Ctx.Trans("file_too_large", map[string]string{"max": strconv.Itoa(app.TUS_MAX_SIZE)}))
+	}
+	pf := file.ParamCreate{}
+	if v := metadata["owner.end_point"]; v != "" {
+		pf.OwnerEndPoint = app.NewNullString(v)
+	}
+	if v := metadata["owner.id"]; v != "" {
+		pf.OwnerID = app.NewNullString(v)
+	}
+	err = u.Ctx.ValidateParam(&pf)
+	if err != nil {
+		return err
+	}
+	err = file.UseCase(*u.Ctx).ValidateOwner(pf.OwnerEndPoint.String, pf.OwnerID.String)
+	if err != nil {
+		return err
+	}
+
+	// set default value for undefined field
+	err = p.setDefaultValue(Upload{})
+	if err != nil {
+		return err
+	}
+	p.Length = app.NewNullInt64(length)
+	p.Offset = app.NewNullInt64(0)
+	p.FileName = app.NewNullString(metadata["filename"])
+	p.OwnerEndPoint = pf.OwnerEndPoint
+	p.OwnerID = pf.OwnerID
+	if u.Ctx.UserID != "" {
+		p.UploadedByUserID = app.NewNullString(u.Ctx.UserID)
+	}
+	p.ExpiresAt = app.NewNullDateTime(time.Now().Add(app.TUS_EXPIRY).UTC())
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// save data to db
+	err = tx.Model(&p).Create(&p).Error
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	if length == 0 {
+		err = app.Outbox().Record(tx, UploadReceived, p.EndPoint(), p.ID.String, nil)
+		if err != nil {
+			return app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+	}
+	return nil
+}
+
+// WriteChunk stores the chunk of the content from the body at the offset, the offset must be the current offset of the upload.
+// The size is the Content-Length of the body, or -1 if it is unknown (chunked transfer encoding).
+// The body is streamed to the storage in parts of app.TUS_PART_SIZE and the offset is moved after every part,
+// so the interrupted chunk (for example the client is disconnected) keeps the offset reached and it is resumed from there.
+// The chunk which reaches the length records UploadReceived, then the chunks are assembled into the File data by HandleUploadReceived.
+// The concurrent chunks of the same offset are stored on their own keys, only the first one is accepted, the others are conflict.
+func (u UseCaseHandler) WriteChunk(id string, offset int64, body io.Reader, size int64) (Upload, error) {
+
+	// check permission
+	err := u.Ctx.ValidatePermission("files.create")
+	if err != nil {
+		return Upload{}, err
+	}
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return Upload{}, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// get previous data
+	up, err := u.get(tx, id)
+	if err != nil {
+		return up, err
+	}
+	if up.IsReceived() || offset != up.Offset.Int64 {
+		return up, app.Error().New(http.StatusConflict, u.Ctx.Trans("invalid_upload_offset", map[string]string{"offset": strconv.FormatInt(up.Offset.Int64, 10)}))
+	}
+	if up.Offset.Int64+size > up.Length.Int64 {
+		return up, app.Error().New(http.StatusBadRequest, u.Ctx.Trans("invalid_upload_length"))
+	}
+
+	// the offset is moved on the autocommit connection per part, not on the transaction of the request which lasts for the whole chunk,
+	// so the offset reached is durable and the concurrent chunk of the same upload is conflicted immediately instead of waiting for the row lock
+	asyncCtx := *u.Ctx
+	asyncCtx.IsAsync = true
+	asyncCtx.IsStrongConsistency = true
+	offsetTx, err := asyncCtx.DB()
+	if err != nil {
+		return up, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// store the chunk part by part, the unaccepted part is collected by the orphan files garbage collection
+	part := make([]byte, min(int64(app.TUS_PART_SIZE), up.Length.Int64-up.Offset.Int64))
+	for up.Offset.Int64 < up.Length.Int64 {
+		n, readErr := readPart(body, part[:min(int64(len(part)), up.Length.Int64-up.Offset.Int64)])
+		if n > 0 && up.Offset.Int64 == 0 {
+			mimeType := file.DetectMimeType(part[:min(n, 512)], up.FileName.String)
+			if !file.IsAllowedMimeType(mimeType) {
+				return up, app.Error().New(http.StatusBadRequest, u.Ctx.Trans("file_type_not_allowed", map[string]string{"type": mimeType}))
+			}
+		}
+		if n > 0 && up.Offset.Int64+int64(n) == up.Length.Int64 && readErr == nil {
+			if extra, _ := body.Read(make([]byte, 1)); extra > 0 {
+				return up, app.Error().New(http.StatusBadRequest, u.Ctx.Trans("invalid_upload_length"))
+			}
+		}
+		if n > 0 {
+			err = u.storePart(offsetTx, &up, part[:n])
+			if err != nil {
+				return up, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			app.Logger().Warn().Err(readErr).Str("id", up.ID.String).Int64("offset", up.Offset.Int64).Msg("The upload chunk is interrupted, the offset reached is saved.")
+			return up, nil
+		}
+	}
+	return up, nil
+}
+
+// DeleteByID terminates the Upload for the specified ID, the stored chunks are deleted.
+func (u UseCaseHandler) DeleteByID(id string) error {
+
+	// check permission
+	err := u.Ctx.ValidatePermission("files.create")
+	if err != nil {
+		return err
+	}
+
+	// prepare db for current ctx
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+
+	// get previous data
+	up, err := u.get(tx, id)
+	if err != nil {
+		return err
+	}
+
+	// update data on the db
+	err = u.terminate(tx, up)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	return nil
+}
+
+// get returns the live Upload data of the current user for the specified ID, the expired incomplete upload is gone.
+func (u UseCaseHandler) get(tx *gorm.DB, id string) (Upload, error) {
+	up := Upload{}
+	err := tx.Where("id = ? AND deleted_at IS NULL", id).First(&up).Error
+	if err == nil && up.UploadedByUserID.String != "" && up.UploadedByUserID.String != u.Ctx.UserID {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if nfErr := u.Ctx.NotFoundError(err, up.EndPoint(), "id", id); nfErr != nil {
+			return up, nfErr
+		}
+		return up, app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	if !up.IsReceived() && up.ExpiresAt.Time.Before(time.Now()) {
+		return up, app.Error().New(http.StatusGone, u.Ctx.Trans("upload_expired"))
+	}
+	return up, nil
+}
+
+// moveOffset saves the offset and the chunks of the upload if its offset is not moved by the other request yet.
+func (u UseCaseHandler) moveOffset(tx *gorm.DB, up Upload, prevOffset int64) error {
+	res := tx.Model(&Upload{}).Where("id = ? AND upload_offset = ?", up.ID, prevOffset).Updates(map[string]any{
+		"upload_offset": up.Offset.Int64,
+		"chunks":        up.Chunks.String,
+		"file_id":       up.FileID.String,
+		"updated_at":    time.Now().UTC(),
+	})
+	if res.Error != nil {
+		return app.Error().New(http.StatusInternalServerError, res.Error.Error())
+	}
+	if res.RowsAffected == 0 {
+		return app.Error().New(http.StatusConflict, u.Ctx.Trans("invalid_upload_offset", map[string]string{"offset": strconv.FormatInt(prevOffset, 10)}))
+	}
+	return nil
+}
+
+// storePart stores the part of the chunk at the current offset of the upload, then moves the offset in its own transaction of the db.
+// The received upload is assembled after the last offset is committed, so the request never waits for it.
+func (u UseCaseHandler) storePart(db *gorm.DB, up *Upload, part []byte) error {
+	name := fmt.Sprintf("%020d-%s", up.Offset.Int64, app.NewNullUUID().String)
+	_, err := u.Ctx.FS().Upload(up.dir()+name, bytes.NewReader(part), int64(len(part)))
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
+	prevOffset := up.Offset.Int64
+	next := *up
+	next.Chunks = app.NewNullText(strings.Join(append(up.chunkNames(), name), ","))
+	next.Offset = app.NewNullInt64(prevOffset + int64(len(part)))
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := u.moveOffset(tx, next, prevOffset)
+		if err != nil || !next.IsReceived() {
+			return err
+		}
+		err = app.Outbox().Record(tx, UploadReceived, next.EndPoint(), next.ID.String, nil)
+		if err != nil {
+			return app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*up = next
+	return nil
+}
+
+// Assemble assembles the chunks of the received Upload for the specified ID into the File data, then deletes the chunks.
+// The content which is rejected by the File data (for example the not allowed mime type) terminates the upload.
+// It is idempotent, the terminated or the already assembled upload is skipped, so it can be retried safely.
+func (u UseCaseHandler) Assemble(id string) error {
+
+	// prepare db for current ctx
+	u.Ctx.IsStrongConsistency = true
+	tx, err := u.Ctx.DB()
+	if err != nil {
+		return err
+	}
+	up := Upload{}
+	err = tx.Where("id = ? AND deleted_at IS NULL", id).First(&up).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil // the upload is terminated before it is assembled
+	}
+	if err != nil {
+		return err
+	}
+	if up.IsCompleted() || !up.IsReceived() {
+		return nil
+	}
+
+	// the File data is created on behalf of the user who uploads it
+	p := file.ParamCreate{}
+	p.OwnerEndPoint = up.OwnerEndPoint
+	p.OwnerID = up.OwnerID
+	ctx := *u.Ctx
+	ctx.UserID = up.UploadedByUserID.String
+	src := &chunkReader{download: u.Ctx.FS().Download, keys: up.chunkKeys()}
+	defer src.Close()
+	err = file.UseCase(ctx).CreateFromReader(&p, up.FileName.String, src, up.Length.Int64)
+	if err != nil {
+		if app.Error().StatusCode(err) < http.StatusInternalServerError {
+			app.Logger().Warn().Err(err).Str("id", up.ID.String).Msg("The upload is rejected by the file, it is terminated.")
+			return u.terminate(tx, up)
+		}
+		return err
+	}
+
+	// update data on the db
+	err = tx.Model(&Upload{}).Where("id = ?", up.ID).Updates(map[string]any{
+		"file_id":    p.ID.String,
+		"chunks":     "",
+		"updated_at": time.Now().UTC(),
+	}).Error
+	if err != nil {
+		return err
+	}
+	u.deleteChunks(up)
+	return nil
+}
+
+// terminate deletes the Upload data and its chunks.
+func (u UseCaseHandler) terminate(tx *gorm.DB, up Upload) error {
+	err := tx.Model(&Upload{}).Where("id = ?", up.ID).Update("deleted_at", time.Now().UTC()).Error
+	if err != nil {
+		return err
+	}
+	u.deleteChunks(up)
+	return nil
+}
+
+// deleteChunks deletes the stored chunks of the upload, the failed ones are collected by the orphan files garbage collection.
+func (u UseCaseHandler) deleteChunks(up Upload) {
+	for _, key := range up.chunkKeys() {
+		err := u.Ctx.FS().Delete(key)
+		if err != nil {
+			app.Logger().Error().Err(err).Str("key", key).Msg("Failed to delete the upload chunk.")
+		}
+	}
+}
+
+// setDefaultValue set default value of undefined field when create Upload data.
+func (u *UseCaseHandler) setDefaultValue(old Upload) error {
+	if !old.ID.Valid {
+		u.ID = app.NewNullUUID()
+	} else {
+		u.ID = old.ID
+	}
+
+	return nil
+}
+
+// readPart reads the reader until the part is full, the error of the reader is returned as is, for example io.EOF at the end of the body.
+func readPart(r io.Reader, part []byte) (int, error) {
+	n := 0
+	for n < len(part) {
+		m, err := r.Read(part[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// chunkReader reads the stored chunks sequentially, each chunk is downloaded only when the previous one is read.
+type chunkReader struct {
+	download func(key string) (io.ReadCloser, error)
+	keys     []string
+	cur      io.ReadCloser
+}
+
+// Read reads the current chunk, then continues to the next chunk at the end of the current one.
+func (r *chunkReader) Read(b []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			cur, err := r.download(r.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			r.cur, r.keys = cur, r.keys[1:]
+		}
+		n, err := r.cur.Read(b)
+		if err == io.EOF {
+			r.cur.Close()
+			r.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Close closes the current chunk.
+func (r *chunkReader) Close() error {
+	if r.cur != nil {
+		return r.cur.Close()
+	}
+	return nil
+}
+
+// FileReferrer returns the referrer of the upload chunks for the orphan files garbage collection, see app.FileGC().
+// The expired upload is orphan unless it is received, so it is deleted along with its chunks.
+func FileReferrer() app.FileReferrer {
+	return app.FileReferrer{
+		Name:     Upload{}.TableName(),
+		Prefixes: []string{Upload{}.EndPoint() + "/"},
+		References: func(tx *gorm.DB) ([]app.FileReference, error) {
+			uploads := []Upload{}
+			err := tx.Select("id", "tenant_id", "upload_length", "upload_offset", "chunks", "expires_at", "created_at").Where("deleted_at IS NULL").Find(&uploads).Error
+			if err != nil {
+				return nil, err
+			}
+			refs := []app.FileReference{}
+			for _, up := range uploads {
+				ref := app.FileReference{ID: up.ID.String, Keys: up.chunkKeys(), CreatedAt: up.CreatedAt.Time, IsOrphan: !up.IsReceived() && up.ExpiresAt.Time.Before(time.Now())}
+				if up.TenantID != nil {
+					ref.TenantID = up.TenantID.String
+				}
+				refs = append(refs, ref)
+			}
+			return refs, nil
+		},
+		Delete: func(tx *gorm.DB, ref app.FileReference) error {
+			return tx.Model(&Upload{}).Where("id = ?", ref.ID).Update("deleted_at", time.Now().UTC()).Error
+		},
+	}
+}
+
+// HandleUploadReceived is the outbox subscriber of UploadReceived which assembles the chunks of the upload into the File data.
+// The failed assembly is retried by the outbox relay with backoff, the upload is terminated after the max attempts.
+func HandleUploadReceived(e app.OutboxEvent) error {
+	u := UseCase(app.Ctx{TenantID: e.TenantID, IsAsync: true})
+	err := u.Assemble(e.AggregateID)
+	if err != nil && e.Attempts+1 >= app.Outbox().MaxAttempts {
+		if tx, dbErr := u.Ctx.DB(); dbErr == nil {
+			up := Upload{}
+			if tx.Where("id = ?", e.AggregateID).First(&up).Error == nil {
+				u.terminate(tx, up)
+			}
+		}
+	}
+	return err
+}
@@ -6,6 +6,7 @@ import "grest-belajar/app"
 type User struct {
 	app.Model
 	ID        app.NullUUID      `json:"id"                   db:"m.id"              gorm:"column:id;primaryKey"`
+	TenantID  *app.NullString   `json:"tenant_id,omitempty"  db:"m.tenant_id,hide"  gorm:"column:tenant_id;size:64;index"`
 	Name      app.NullString    `json:"name"                 db:"m.name"            gorm:"column:name"`
 	Email     app.NullString    `json:"email"                db:"m.email"           gorm:"column:email"`
 	Password  *app.NullString   `json:"password,omitempty"   db:"m.password,hide"   gorm:"column:password;min:6"`
@@ -23,7 +24,7 @@ func (User) EndPoint() string {
 // TableVersion returns the versions of the User table in the database.
 // Change this value with date format YY.MM.DDHHii when any table structure changes.
 func (User) TableVersion() string {
-	return "24.03.261019"
+	return "26.10.191000"
 }
 
 // TableName returns the name of the User table in the database.
@@ -18,6 +18,7 @@ func prepareTest(tb testing.TB) {
 	app.Test()
 	tx := app.Test().Tx
 	app.DB().RegisterTable("main", User{})
+	app.DB().RegisterTable("main", app.OutboxEvent{})
 	app.DB().MigrateTable(tx, "main", app.Setting{})
 	tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&User{})
 
@@ -45,32 +45,32 @@ func (u UseCaseHandler) GetByID(id string) (User, error) {
 		return res, err
 	}
 
-	// get from cache and return if exists
-	cacheKey := u.EndPoint() + "." + id
-	app.Cache().Get(cacheKey, &res)
-	if res.ID.Valid {
-		return res, err
-	}
-
-	// prepare db for current ctx
-	tx, err := u.Ctx.DB()
-	if err != nil {
-		return res, app.Error().New(http.StatusInternalServerError, err.Error())
-	}
-
-	// get from db
-	key := "id"
-	if !app.Validator().IsValid(id, "uuid") {
-		key = "code"
-	}
-	u.Query.Add(key, id)
-	err = app.Query().First(tx, &res, u.Query)
-	if err != nil {
-		return res, u.Ctx.NotFoundError(err, u.EndPoint(), key, id)
-	}
-
-	// save to cache and return if exists
-	app.Cache().Set(cacheKey, res)
+	// get from cache, or get from db and save to cache, the concurrent requests of the same id are coalesced
+	err = u.Ctx.Remember(u.EndPoint(), u.EndPoint()+"."+id, &res, func() (any, []string, error) {
+
+		// prepare db for current ctx
+		tx, err := u.Ctx.DB()
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+
+		// get from db
+		key := "id"
+		if !app.Validator().IsValid(id, "uuid") {
+			key = "code"
+		}
+		query := url.Values{}
+		for k, v := range u.Query {
+			query[k] = v
+		}
+		query.Set(key, id)
+		data := User{}
+		err = app.Query().First(tx, &data, query)
+		if err != nil {
+			return nil, nil, u.Ctx.NotFoundError(err, u.EndPoint(), key, id)
+		}
+		return data, app.Cache().DetailTags(u.Ctx.CacheKey(u.EndPoint()), data.ID.String), nil
+	})
 	return res, err
 }
 
@@ -83,42 +83,38 @@ func (u UseCaseHandler) Get() (app.ListModel, error) {
 	if err != nil {
 		return res, err
 	}
-	// get from cache and return if exists
-	cacheKey := u.EndPoint() + "?" + u.Query.Encode()
-	err = app.Cache().Get(cacheKey, &res)
-	if err == nil {
-		return res, err
-	}
-
-	// prepare db for current ctx
-	tx, err := u.Ctx.DB()
-	if err != nil {
-		return res, app.Error().New(http.StatusInternalServerError, err.Error())
-	}
-
-	// set pagination info
-	res.Count,
-		res.PageContext.Page,
-		res.PageContext.PerPage,
-		res.PageContext.PageCount,
-		err = app.Query().PaginationInfo(tx, &User{}, u.Query)
-	if err != nil {
-		return res, app.Error().New(http.StatusInternalServerError, err.Error())
-	}
-	// return data count if $per_page set to 0
-	if res.PageContext.PerPage == 0 {
-		return res, err
-	}
-
-	// find data
-	data, err := app.Query().Find(tx, &User{}, u.Query)
-	if err != nil {
-		return res, app.Error().New(http.StatusInternalServerError, err.Error())
-	}
-	res.SetData(data, u.Query)
-
-	// save to cache and return if exists
-	app.Cache().Set(cacheKey, res)
+	// get from cache, or get from db and save to cache, the concurrent requests of the same query are coalesced
+	err = u.Ctx.Remember(u.EndPoint(), u.EndPoint()+"?"+u.Query.Encode(), &res, func() (any, []string, error) {
+		data := app.ListModel{}
+
+		// prepare db for current ctx
+		tx, err := u.Ctx.DB()
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+
+		// set pagination info
+		data.Count,
+			data.PageContext.Page,
+			data.PageContext.PerPage,
+			data.PageContext.PageCount,
+			err = app.Query().PaginationInfo(tx, &User{}, u.Query)
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		// return data count if $per_page set to 0
+		if data.PageContext.PerPage == 0 {
+			return data, app.Cache().ListTags(u.Ctx.CacheKey(u.EndPoint()), nil), nil
+		}
+
+		// find data
+		rows, err := app.Query().Find(tx, &User{}, u.Query)
+		if err != nil {
+			return nil, nil, app.Error().New(http.StatusInternalServerError, err.Error())
+		}
+		data.SetData(rows, u.Query)
+		return data, app.Cache().ListTags(u.Ctx.CacheKey(u.EndPoint()), data.Data), nil
+	})
 	return res, err
 }
 
@@ -156,10 +152,13 @@ func (u UseCaseHandler) Create(p *ParamCreate) error {
 	}
 
 	// invalidate cache
-	app.Cache().Invalidate(u.EndPoint())
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint())) })
 
-	// save history (user activity), send webhook, etc
-	go u.Ctx.Hook("POST", "create", p.ID.String, p)
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("POST", "create", p.ID.String, p)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
 	return nil
 }
 
@@ -203,10 +202,13 @@ func (u UseCaseHandler) UpdateByID(id string, p *ParamUpdate) error {
 	}
 
 	// invalidate cache
-	app.Cache().Invalidate(u.EndPoint(), old.ID.String)
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String) })
 
-	// save history (user activity), send webhook, etc
-	go u.Ctx.Hook("PUT", p.Reason.String, old.ID.String, old)
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("PUT", p.Reason.String, old.ID.String, old)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
 	return nil
 }
 
@@ -250,10 +252,13 @@ func (u UseCaseHandler) PartiallyUpdateByID(id string, p *ParamPartiallyUpdate)
 	}
 
 	// invalidate cache
-	app.Cache().Invalidate(u.EndPoint(), old.ID.String)
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String) })
 
-	// save history (user activity), send webhook, etc
-	go u.Ctx.Hook("PATCH", p.Reason.String, old.ID.String, old)
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("PATCH", p.Reason.String, old.ID.String, old)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
 	return nil
 }
 
@@ -291,10 +296,13 @@ func (u UseCaseHandler) DeleteByID(id string, p *ParamDelete) error {
 	}
 
 	// invalidate cache
-	app.Cache().Invalidate(u.EndPoint(), old.ID.String)
+	u.Ctx.AfterCommit(func() { app.Cache().Invalidate(u.Ctx.CacheKey(u.EndPoint()), old.ID.String) })
 
-	// save history (user activity), send webhook, etc
-	go u.Ctx.Hook("DELETE", p.Reason.String, old.ID.String, old)
+	// record the event to save history (user activity), send webhook, etc
+	err = u.Ctx.Hook("DELETE", p.Reason.String, old.ID.String, old)
+	if err != nil {
+		return app.Error().New(http.StatusInternalServerError, err.Error())
+	}
 	return nil
 }
 